@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE notification_attempts ADD COLUMN event_id INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE notification_attempts ADD COLUMN provider_message_id VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX notifications_account_id_person_id ON notifications (account_id, person_id);
+CREATE INDEX notifications_account_id_door_id ON notifications (account_id, door_id);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX notifications_account_id_door_id;
+DROP INDEX notifications_account_id_person_id;
+
+ALTER TABLE notification_attempts DROP COLUMN provider_message_id;
+ALTER TABLE notification_attempts DROP COLUMN event_id;
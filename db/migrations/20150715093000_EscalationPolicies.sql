@@ -0,0 +1,28 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE people ADD COLUMN notifications_mode VARCHAR(16) NOT NULL DEFAULT 'first';
+
+CREATE TABLE escalation_steps (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    delay INTEGER NOT NULL DEFAULT 0,
+    recipient VARCHAR(32) NOT NULL DEFAULT 'person'
+);
+
+CREATE UNIQUE INDEX escalation_steps_person_id_position ON escalation_steps (person_id, position);
+
+ALTER TABLE notifications ADD COLUMN step INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE notifications ADD COLUMN acknowledged_at TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE notifications DROP COLUMN acknowledged_at;
+ALTER TABLE notifications DROP COLUMN step;
+
+DROP INDEX escalation_steps_person_id_position;
+DROP TABLE escalation_steps;
+
+ALTER TABLE people DROP COLUMN notifications_mode;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE notifications ADD COLUMN acknowledged_via VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN acknowledgement_reply VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN acknowledgement_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN acknowledgement_eta INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE notifications DROP COLUMN acknowledgement_eta;
+ALTER TABLE notifications DROP COLUMN acknowledgement_message;
+ALTER TABLE notifications DROP COLUMN acknowledgement_reply;
+ALTER TABLE notifications DROP COLUMN acknowledged_via;
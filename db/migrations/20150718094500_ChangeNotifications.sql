@@ -0,0 +1,38 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Publishes row changes on the doorbot_changes channel. Listeners load the row themselves, keeping the payload small.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION doorbot_notify_change() RETURNS trigger AS $$
+DECLARE
+    rec RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+    ELSE
+        rec := NEW;
+    END IF;
+
+    PERFORM pg_notify('doorbot_changes', '{"table":"' || TG_TABLE_NAME || '","action":"' || TG_OP || '","account_id":' || rec.account_id || ',"id":' || rec.id || '}');
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER people_notify_change AFTER INSERT OR UPDATE OR DELETE ON people FOR EACH ROW EXECUTE PROCEDURE doorbot_notify_change();
+CREATE TRIGGER doors_notify_change AFTER INSERT OR UPDATE OR DELETE ON doors FOR EACH ROW EXECUTE PROCEDURE doorbot_notify_change();
+CREATE TRIGGER devices_notify_change AFTER INSERT OR UPDATE OR DELETE ON devices FOR EACH ROW EXECUTE PROCEDURE doorbot_notify_change();
+
+CREATE TRIGGER notifications_notify_acknowledgement AFTER UPDATE ON notifications FOR EACH ROW
+    WHEN (OLD.acknowledged_at IS NULL AND NEW.acknowledged_at IS NOT NULL)
+    EXECUTE PROCEDURE doorbot_notify_change();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER notifications_notify_acknowledgement ON notifications;
+DROP TRIGGER devices_notify_change ON devices;
+DROP TRIGGER doors_notify_change ON doors;
+DROP TRIGGER people_notify_change ON people;
+
+DROP FUNCTION doorbot_notify_change();
@@ -0,0 +1,45 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE visitors (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    company VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    phone_number VARCHAR(32) NOT NULL DEFAULT '',
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX visitors_account_id_email ON visitors (account_id, lower(email));
+
+CREATE TABLE visits (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    visitor_id INTEGER NOT NULL REFERENCES visitors(id) ON DELETE CASCADE,
+    person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    door_id INTEGER NOT NULL REFERENCES doors(id) ON DELETE CASCADE,
+    device_id INTEGER REFERENCES devices(id) ON DELETE SET NULL,
+    checked_in_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    checked_out_at TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX visits_account_id_checked_in_at ON visits (account_id, checked_in_at);
+CREATE INDEX visits_person_id ON visits (person_id);
+
+ALTER TABLE notifications ADD COLUMN visit_id INTEGER REFERENCES visits(id) ON DELETE SET NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE notifications DROP COLUMN visit_id;
+
+DROP INDEX visits_person_id;
+DROP INDEX visits_account_id_checked_in_at;
+DROP TABLE visits;
+
+DROP INDEX visitors_account_id_email;
+DROP TABLE visitors;
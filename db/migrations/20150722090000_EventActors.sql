@@ -0,0 +1,32 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE events ADD COLUMN actor_type VARCHAR(16) NOT NULL DEFAULT 'system';
+ALTER TABLE events ADD COLUMN actor_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN metadata JSON NOT NULL DEFAULT '{}';
+
+-- Events are an audit log: an account holds many of them and they outlive the doors, devices and people they mention.
+DROP INDEX events_account_id;
+CREATE INDEX events_account_id_created_at ON events (account_id, created_at);
+
+ALTER TABLE events DROP CONSTRAINT events_door_id_fkey;
+ALTER TABLE events DROP CONSTRAINT events_device_id_fkey;
+ALTER TABLE events DROP CONSTRAINT events_person_id_fkey;
+
+CREATE INDEX events_account_id_id ON events (account_id, id);
+CREATE INDEX events_account_id_event_id ON events (account_id, event_id);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX events_account_id_event_id;
+DROP INDEX events_account_id_id;
+
+ALTER TABLE events ADD CONSTRAINT events_person_id_fkey FOREIGN KEY (person_id) REFERENCES people(id);
+ALTER TABLE events ADD CONSTRAINT events_device_id_fkey FOREIGN KEY (device_id) REFERENCES devices(id);
+ALTER TABLE events ADD CONSTRAINT events_door_id_fkey FOREIGN KEY (door_id) REFERENCES doors(id);
+
+DROP INDEX events_account_id_created_at;
+CREATE UNIQUE INDEX events_account_id ON events (account_id);
+
+ALTER TABLE events DROP COLUMN metadata;
+ALTER TABLE events DROP COLUMN actor_id;
+ALTER TABLE events DROP COLUMN actor_type;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE people ADD COLUMN digest_frequency VARCHAR(16) NOT NULL DEFAULT 'never';
+ALTER TABLE people ADD COLUMN digest_sent_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+
+ALTER TABLE devices ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+
+-- Bookkeeping updates are not pushed to the devices.
+DROP TRIGGER people_notify_change ON people;
+DROP TRIGGER devices_notify_change ON devices;
+
+CREATE TRIGGER people_notify_change AFTER INSERT OR DELETE ON people FOR EACH ROW EXECUTE PROCEDURE doorbot_notify_change();
+CREATE TRIGGER people_notify_update AFTER UPDATE ON people FOR EACH ROW
+    WHEN (OLD.digest_sent_at IS NOT DISTINCT FROM NEW.digest_sent_at)
+    EXECUTE PROCEDURE doorbot_notify_change();
+
+CREATE TRIGGER devices_notify_change AFTER INSERT OR DELETE ON devices FOR EACH ROW EXECUTE PROCEDURE doorbot_notify_change();
+-- Devices write last_seen_at every DeviceSeenInterval, only the updates changing another column are pushed.
+CREATE TRIGGER devices_notify_update AFTER UPDATE ON devices FOR EACH ROW
+    WHEN ((OLD.name, OLD.device_id, OLD.door_id, OLD.make, OLD.description, OLD.is_enabled, OLD.token)
+        IS DISTINCT FROM (NEW.name, NEW.device_id, NEW.door_id, NEW.make, NEW.description, NEW.is_enabled, NEW.token))
+    EXECUTE PROCEDURE doorbot_notify_change();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER devices_notify_update ON devices;
+DROP TRIGGER devices_notify_change ON devices;
+DROP TRIGGER people_notify_update ON people;
+DROP TRIGGER people_notify_change ON people;
+
+CREATE TRIGGER people_notify_change AFTER INSERT OR UPDATE OR DELETE ON people FOR EACH ROW EXECUTE PROCEDURE doorbot_notify_change();
+CREATE TRIGGER devices_notify_change AFTER INSERT OR UPDATE OR DELETE ON devices FOR EACH ROW EXECUTE PROCEDURE doorbot_notify_change();
+
+ALTER TABLE devices DROP COLUMN last_seen_at;
+
+ALTER TABLE people DROP COLUMN digest_sent_at;
+ALTER TABLE people DROP COLUMN digest_frequency;
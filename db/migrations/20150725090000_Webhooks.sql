@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE accounts ADD COLUMN notifications_webhook_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE accounts ADD COLUMN notifications_webhook_url VARCHAR(2048) NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN notifications_webhook_secret VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE people ADD COLUMN notifications_webhook_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE people ADD COLUMN notifications_webhook_url VARCHAR(2048) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE people DROP COLUMN notifications_webhook_url;
+ALTER TABLE people DROP COLUMN notifications_webhook_enabled;
+
+ALTER TABLE accounts DROP COLUMN notifications_webhook_secret;
+ALTER TABLE accounts DROP COLUMN notifications_webhook_url;
+ALTER TABLE accounts DROP COLUMN notifications_webhook_enabled;
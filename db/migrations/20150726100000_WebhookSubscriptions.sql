@@ -0,0 +1,62 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    event_ids INTEGER[] NOT NULL DEFAULT '{}',
+    is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_subscriptions_account_id ON webhook_subscriptions (account_id);
+
+CREATE TABLE webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    locked_at TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    delivered_at TIMESTAMP WITH TIME ZONE DEFAULT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id, id);
+CREATE INDEX webhook_deliveries_status_next_attempt_at ON webhook_deliveries (status, next_attempt_at);
+
+-- Queues a delivery for every enabled subscription of the account listening to the event code.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION doorbot_queue_webhook_deliveries() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO webhook_deliveries (account_id, subscription_id, event_id)
+        SELECT NEW.account_id, s.id, NEW.id FROM webhook_subscriptions s
+        WHERE s.account_id = NEW.account_id AND s.is_enabled AND NEW.event_id = ANY (s.event_ids);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER events_queue_webhook_deliveries AFTER INSERT ON events FOR EACH ROW EXECUTE PROCEDURE doorbot_queue_webhook_deliveries();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER events_queue_webhook_deliveries ON events;
+DROP FUNCTION doorbot_queue_webhook_deliveries();
+
+DROP INDEX webhook_deliveries_status_next_attempt_at;
+DROP INDEX webhook_deliveries_subscription_id;
+DROP TABLE webhook_deliveries;
+
+DROP INDEX webhook_subscriptions_account_id;
+DROP TABLE webhook_subscriptions;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE accounts ADD COLUMN notifications_email_from VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN notifications_smtp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE accounts DROP COLUMN notifications_smtp_enabled;
+ALTER TABLE accounts DROP COLUMN notifications_email_from;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE accounts ADD COLUMN notifications_mattermost_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE accounts ADD COLUMN notifications_mattermost_url VARCHAR(2048) NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN notifications_rocketchat_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE accounts ADD COLUMN notifications_rocketchat_url VARCHAR(2048) NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN notifications_teams_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE accounts ADD COLUMN notifications_teams_url VARCHAR(2048) NOT NULL DEFAULT '';
+
+ALTER TABLE doors ADD COLUMN notifications_chat_channel VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE doors ADD COLUMN notifications_teams_url VARCHAR(2048) NOT NULL DEFAULT '';
+
+ALTER TABLE people ADD COLUMN notifications_chat_handle VARCHAR(255) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE people DROP COLUMN notifications_chat_handle;
+
+ALTER TABLE doors DROP COLUMN notifications_teams_url;
+ALTER TABLE doors DROP COLUMN notifications_chat_channel;
+
+ALTER TABLE accounts DROP COLUMN notifications_teams_url;
+ALTER TABLE accounts DROP COLUMN notifications_teams_enabled;
+ALTER TABLE accounts DROP COLUMN notifications_rocketchat_url;
+ALTER TABLE accounts DROP COLUMN notifications_rocketchat_enabled;
+ALTER TABLE accounts DROP COLUMN notifications_mattermost_url;
+ALTER TABLE accounts DROP COLUMN notifications_mattermost_enabled;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE people ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE TABLE working_hours (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    day INTEGER NOT NULL,
+    starts_at VARCHAR(5) NOT NULL,
+    ends_at VARCHAR(5) NOT NULL
+);
+
+CREATE INDEX working_hours_person_id ON working_hours (person_id);
+
+CREATE TABLE out_of_office_periods (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX out_of_office_periods_account_id_ends_at ON out_of_office_periods (account_id, ends_at);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE out_of_office_periods;
+DROP TABLE working_hours;
+
+ALTER TABLE people DROP COLUMN timezone;
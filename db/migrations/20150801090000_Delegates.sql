@@ -0,0 +1,31 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE delegates (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    person_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    delegate_id INTEGER NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX delegates_person_id ON delegates (person_id);
+
+ALTER TABLE accounts ADD COLUMN reception_person_id INTEGER REFERENCES people(id) ON DELETE SET NULL;
+ALTER TABLE accounts ADD COLUMN notifications_fallback_delay INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE notifications ADD COLUMN rerouted_from_person_id INTEGER REFERENCES people(id) ON DELETE SET NULL;
+ALTER TABLE notifications ADD COLUMN reroute_at TIMESTAMP WITH TIME ZONE;
+
+-- Delivered knock-knocks waiting to be rerouted are claimed by the queue at their reroute time.
+CREATE INDEX notifications_reroute_at ON notifications (reroute_at) WHERE acknowledged_at IS NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX notifications_reroute_at;
+ALTER TABLE notifications DROP COLUMN reroute_at;
+ALTER TABLE notifications DROP COLUMN rerouted_from_person_id;
+
+ALTER TABLE accounts DROP COLUMN notifications_fallback_delay;
+ALTER TABLE accounts DROP COLUMN reception_person_id;
+
+DROP TABLE delegates;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE accounts ADD COLUMN notifications_dedup_window INTEGER NOT NULL DEFAULT 60;
+ALTER TABLE accounts ADD COLUMN notifications_device_rate_limit INTEGER NOT NULL DEFAULT 10;
+
+CREATE INDEX notifications_door_id_person_id_created_at ON notifications (door_id, person_id, created_at);
+CREATE INDEX events_device_id_event_id_created_at ON events (device_id, event_id, created_at);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX events_device_id_event_id_created_at;
+DROP INDEX notifications_door_id_person_id_created_at;
+
+ALTER TABLE accounts DROP COLUMN notifications_device_rate_limit;
+ALTER TABLE accounts DROP COLUMN notifications_dedup_window;
@@ -6,6 +6,8 @@ import (
 	"github.com/masom/doorbot/doorbot/auth"
 	"github.com/masom/doorbot/doorbot/security"
 	"github.com/masom/doorbot/doorbot/services/notifications"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	"github.com/masom/doorbot/doorbot/services/notifications/webhook"
 	log "github.com/Sirupsen/logrus"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/binding"
@@ -207,6 +209,10 @@ func Register(render render.Render, config *doorbot.DoorbotConfig, r doorbot.Rep
 		Host: host,
 
 		IsEnabled: true,
+
+		// Matches the database defaults, gorp inserts every column.
+		NotificationsDedupWindow:     60,
+		NotificationsDeviceRateLimit: 10,
 	}
 
 	err = repo.Create(tx, account)
@@ -410,8 +416,81 @@ func Put(render render.Render, a *doorbot.Account, r doorbot.Repositories, vm Ac
 		}
 	}
 
+	if len(vm.Account.NotificationsEmailFrom) > 0 {
+		if _, err := mail.ParseAddress(vm.Account.NotificationsEmailFrom); err != nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The sender email address is invalid."}))
+			return
+		}
+	}
+
+	if len(vm.Account.NotificationsWebhookURL) > 0 && !webhook.ValidURL(vm.Account.NotificationsWebhookURL) {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The webhook URL must be an absolute http or https URL."}))
+		return
+	}
+
+	// Chat incoming webhook URLs
+	incoming := []struct {
+		name string
+		url  string
+	}{
+		{"Mattermost", vm.Account.NotificationsMattermostURL},
+		{"Rocket.Chat", vm.Account.NotificationsRocketChatURL},
+		{"Microsoft Teams", vm.Account.NotificationsTeamsURL},
+	}
+
+	for _, i := range incoming {
+		if len(i.url) > 0 && !webhook.ValidURL(i.url) {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The " + i.name + " URL must be an absolute http or https URL."}))
+			return
+		}
+	}
+
+	if errors := templates.ValidateLegacy(vm.Account); len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	if vm.Account.NotificationsFallbackDelay > 3600 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The fallback delay cannot be longer than an hour."}))
+		return
+	}
+
+	if vm.Account.NotificationsDedupWindow > 3600 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The duplicate knock-knock window cannot be longer than an hour."}))
+		return
+	}
+
+	// Knock-knocks are counted with the event listing, limited to 100 results.
+	if vm.Account.NotificationsDeviceRateLimit > 100 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The device rate limit cannot be more than 100 knock-knocks per minute."}))
+		return
+	}
+
+	// The reception takes the knock-knocks of unavailable people without an available delegate.
+	if vm.Account.ReceptionPersonID != nil {
+		reception, err := r.PersonRepository().Find(r.DB(), *vm.Account.ReceptionPersonID)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": a.ID,
+				"person_id":  *vm.Account.ReceptionPersonID,
+				"step":       "reception-find",
+			}).Error("Api::Accounts->Put database error")
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+
+		if reception == nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The reception person does not exists."}))
+			return
+		}
+	}
+
 	a.Name = vm.Account.Name
 
+	a.ReceptionPersonID = vm.Account.ReceptionPersonID
+
 	a.BridgeHubEnabled = vm.Account.BridgeHubEnabled
 	a.BridgeHubURL = vm.Account.BridgeHubURL
 	a.BridgeHubToken = vm.Account.BridgeHubToken
@@ -424,13 +503,25 @@ func Put(render render.Render, a *doorbot.Account, r doorbot.Repositories, vm Ac
 
 	a.NotificationsEmailMessageTemplate = vm.Account.NotificationsEmailMessageTemplate
 	a.NotificationsSMSMessageTemplate = vm.Account.NotificationsSMSMessageTemplate
+	a.NotificationsEmailFrom = vm.Account.NotificationsEmailFrom
 
 	a.NotificationsEnabled = vm.Account.NotificationsEnabled
+	a.NotificationsFallbackDelay = vm.Account.NotificationsFallbackDelay
+	a.NotificationsDedupWindow = vm.Account.NotificationsDedupWindow
+	a.NotificationsDeviceRateLimit = vm.Account.NotificationsDeviceRateLimit
+
+	a.NotificationsHipChatToken = vm.Account.NotificationsHipChatToken
 
 	a.NotificationsMailgunEnabled = vm.Account.NotificationsMailgunEnabled
 
+	a.NotificationsMattermostEnabled = vm.Account.NotificationsMattermostEnabled
+	a.NotificationsMattermostURL = vm.Account.NotificationsMattermostURL
+
 	a.NotificationsPostmarkEnabled = vm.Account.NotificationsPostmarkEnabled
 
+	a.NotificationsRocketChatEnabled = vm.Account.NotificationsRocketChatEnabled
+	a.NotificationsRocketChatURL = vm.Account.NotificationsRocketChatURL
+
 
 	a.NotificationsMailgunEnabled = vm.Account.NotificationsMailgunEnabled
 
@@ -439,9 +530,25 @@ func Put(render render.Render, a *doorbot.Account, r doorbot.Repositories, vm Ac
 
 	a.NotificationsSlackEnabled = vm.Account.NotificationsSlackEnabled
 	a.NotificationsSlackToken = vm.Account.NotificationsSlackToken
+
+	a.NotificationsSMTPEnabled = vm.Account.NotificationsSMTPEnabled
 	
+	a.NotificationsTeamsEnabled = vm.Account.NotificationsTeamsEnabled
+	a.NotificationsTeamsURL = vm.Account.NotificationsTeamsURL
+
 	a.NotificationsTwilioEnabled = vm.Account.NotificationsTwilioEnabled
+	a.NotificationsTwilioSourcePhoneNumber = vm.Account.NotificationsTwilioSourcePhoneNumber
+	a.NotificationsTwilioToken = vm.Account.NotificationsTwilioToken
 
+	a.NotificationsWebhookEnabled = vm.Account.NotificationsWebhookEnabled
+	a.NotificationsWebhookURL = vm.Account.NotificationsWebhookURL
+
+	// The secret can be rotated by sending a new one. A secret is generated the first time the webhook is enabled.
+	if len(vm.Account.NotificationsWebhookSecret) > 0 {
+		a.NotificationsWebhookSecret = vm.Account.NotificationsWebhookSecret
+	} else if a.NotificationsWebhookEnabled && len(a.NotificationsWebhookSecret) == 0 {
+		a.NotificationsWebhookSecret = security.GenerateAPIToken()
+	}
 
 	_, err := repo.Update(r.DB(), a)
 	if err != nil {
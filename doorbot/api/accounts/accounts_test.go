@@ -486,3 +486,105 @@ func TestRegister(t *testing.T) {
 	personRepo.Mock.AssertExpectations(t)
 	authRepo.Mock.AssertExpectations(t)
 }
+
+func TestPutWebhookSecretGenerated(t *testing.T) {
+	render := new(tests.MockRender)
+	repo := new(tests.MockAccountRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountRepository").Return(repo)
+	repositories.On("DB").Return(db)
+
+	postAccount := &doorbot.Account{
+		Name:                        "ACME",
+		NotificationsWebhookEnabled: true,
+		NotificationsWebhookURL:     "https://hooks.example.com/doorbot",
+	}
+
+	repoAccount := &doorbot.Account{
+		ID:   5555,
+		Name: "ACME",
+	}
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationAdministrator,
+	}
+
+	repo.On("Update", db, repoAccount).Return(true, nil)
+
+	render.On("JSON", http.StatusOK, AccountViewModel{Account: repoAccount}).Return()
+
+	Put(render, repoAccount, repositories, AccountViewModel{Account: postAccount}, session)
+
+	assert.Equal(t, "https://hooks.example.com/doorbot", repoAccount.NotificationsWebhookURL)
+	assert.Len(t, repoAccount.NotificationsWebhookSecret, 36)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+}
+
+func TestPutInvalidWebhookURL(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountRepository").Return(new(tests.MockAccountRepository))
+
+	postAccount := &doorbot.Account{
+		Name:                    "ACME",
+		NotificationsWebhookURL: "hooks.example.com",
+	}
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationAdministrator,
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The webhook URL must be an absolute http or https URL."})).Return()
+
+	Put(render, &doorbot.Account{ID: 5555}, repositories, AccountViewModel{Account: postAccount}, session)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestPutInvalidEmailFrom(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountRepository").Return(new(tests.MockAccountRepository))
+
+	postAccount := &doorbot.Account{
+		Name:                   "ACME",
+		NotificationsEmailFrom: "lobby at example.com",
+	}
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationAdministrator,
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The sender email address is invalid."})).Return()
+
+	Put(render, &doorbot.Account{ID: 5555}, repositories, AccountViewModel{Account: postAccount}, session)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestPutInvalidMessageTemplate(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountRepository").Return(new(tests.MockAccountRepository))
+
+	template := "Hi {{name}}, {{#visitor}}{{visitor}} is at the {{door}}."
+
+	postAccount := &doorbot.Account{
+		Name:                            "ACME",
+		NotificationsSMSMessageTemplate: &template,
+	}
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationAdministrator,
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The template body is invalid: {{#visitor}} is never closed."})).Return()
+
+	Put(render, &doorbot.Account{ID: 5555}, repositories, AccountViewModel{Account: postAccount}, session)
+
+	render.Mock.AssertExpectations(t)
+}
@@ -0,0 +1,210 @@
+// Package acknowledgements handles the answers sent back by people through the links and chat messages of a knock-knock.
+package acknowledgements
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// ResultViewModel is returned to the person following an acknowledgement link
+type ResultViewModel struct {
+	Message string `json:"message"`
+}
+
+// confirmation is the page asking the person to confirm the reply of an acknowledgement link.
+var confirmation = template.Must(template.New("confirmation").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Doorbot</title>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>Tell the visitor: {{.Reply}}</p>
+<button type="submit">Send</button>
+</form>
+</body>
+</html>
+`))
+
+// SlackPayload holds the fields of a Slack interactive message callback used by doorbot
+type SlackPayload struct {
+	CallbackID string `json:"callback_id"`
+	Actions    []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"actions"`
+}
+
+// SlackResponse replaces the original Slack message once the person answered
+type SlackResponse struct {
+	Text            string `json:"text"`
+	ReplaceOriginal bool   `json:"replace_original"`
+}
+
+// Confirm renders the page of a signed one-click link sent by email, SMS or chat.
+// Nothing is changed: link previews and prefetchers follow the links, the page posts the reply back to Link.
+func Confirm(render render.Render, params martini.Params, req *http.Request, a *doorbot.Account, config *doorbot.DoorbotConfig) {
+	_, _, reply, _, ok := link(render, params, req, a, config)
+	if !ok {
+		return
+	}
+
+	var page bytes.Buffer
+
+	err := confirmation.Execute(&page, map[string]string{
+		"Action": req.URL.RequestURI(),
+		"Reply":  notifications.Replies[reply],
+	})
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": a.ID,
+			"step":       "confirmation-render",
+		}).Error("Api::Acknowledgements->Confirm template error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	render.Data(http.StatusOK, page.Bytes())
+}
+
+// Link acknowledges a notification using a signed one-click link sent by email, SMS or chat, once confirmed.
+func Link(render render.Render, r doorbot.Repositories, params martini.Params, req *http.Request, a *doorbot.Account, config *doorbot.DoorbotConfig) {
+	id, channel, reply, eta, ok := link(render, params, req, a, config)
+	if !ok {
+		return
+	}
+
+	status, message := acknowledge(r, id, channel, reply, eta)
+
+	render.JSON(status, ResultViewModel{Message: message})
+}
+
+// link verifies a signed one-click link and returns the notification, channel, reply and eta it holds.
+// Errors are rendered and false returned when the link cannot be used.
+func link(render render.Render, params martini.Params, req *http.Request, a *doorbot.Account, config *doorbot.DoorbotConfig) (uint, string, string, uint, bool) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return 0, "", "", 0, false
+	}
+
+	expires, err := strconv.ParseInt(req.FormValue("expires"), 10, 64)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The link is invalid."}))
+		return 0, "", "", 0, false
+	}
+
+	channel := req.FormValue("channel")
+	links := notifications.NewLinks(config)
+
+	if !links.Verify(a, uint(id), channel, expires, req.FormValue("signature")) {
+		log.WithFields(log.Fields{
+			"account_id":      a.ID,
+			"notification_id": id,
+			"channel":         channel,
+		}).Warn("Api::Acknowledgements->Link invalid or expired signature")
+
+		render.JSON(http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{"The link is invalid or has expired."}))
+		return 0, "", "", 0, false
+	}
+
+	reply := req.FormValue("reply")
+	if _, ok := notifications.Replies[reply]; !ok {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The reply must be one of on_my_way, please_wait or unavailable."}))
+		return 0, "", "", 0, false
+	}
+
+	var eta uint64
+	if len(req.FormValue("eta")) > 0 {
+		eta, err = strconv.ParseUint(req.FormValue("eta"), 10, 32)
+		if err != nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The eta must be an unsigned integer"}))
+			return 0, "", "", 0, false
+		}
+	}
+
+	return uint(id), channel, reply, uint(eta), true
+}
+
+// Slack acknowledges a notification using the buttons of a Slack interactive message.
+// The message callback id holds a token signed by notifications.Links.
+func Slack(render render.Render, r doorbot.Repositories, req *http.Request, a *doorbot.Account, config *doorbot.DoorbotConfig) {
+	var payload SlackPayload
+
+	err := json.Unmarshal([]byte(req.FormValue("payload")), &payload)
+	if err != nil || len(payload.Actions) == 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"Invalid Slack payload."}))
+		return
+	}
+
+	id, channel, ok := notifications.NewLinks(config).ParseToken(a, payload.CallbackID)
+	if !ok {
+		log.WithFields(log.Fields{
+			"account_id": a.ID,
+		}).Warn("Api::Acknowledgements->Slack invalid or expired token")
+
+		render.JSON(http.StatusOK, SlackResponse{Text: "This knock-knock has expired."})
+		return
+	}
+
+	reply := payload.Actions[0].Value
+	if _, ok := notifications.Replies[reply]; !ok {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The reply must be one of on_my_way, please_wait or unavailable."}))
+		return
+	}
+
+	_, message := acknowledge(r, id, channel, reply, 0)
+
+	render.JSON(http.StatusOK, SlackResponse{Text: message, ReplaceOriginal: true})
+}
+
+// acknowledge records the reply and returns the status code and message describing the outcome.
+func acknowledge(r doorbot.Repositories, id uint, channel string, reply string, eta uint) (int, string) {
+	notification, err := r.NotificationRepository().Find(r.DB(), id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"notification_id": id,
+			"step":            "notification-find",
+		}).Error("Api::Acknowledgements->acknowledge database error")
+
+		return http.StatusInternalServerError, "Something went wrong, please try again."
+	}
+
+	if notification == nil {
+		return http.StatusNotFound, "The specified notification does not exists."
+	}
+
+	acknowledged, err := notifications.Acknowledge(r, notification, channel, reply, "", eta)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"notification_id": id,
+			"step":            "notification-acknowledge",
+		}).Error("Api::Acknowledgements->acknowledge database error")
+
+		return http.StatusInternalServerError, "Something went wrong, please try again."
+	}
+
+	if !acknowledged {
+		return http.StatusConflict, "This knock-knock was already answered."
+	}
+
+	return http.StatusOK, "Thanks! The visitor was told: " + notification.AcknowledgementMessage
+}
@@ -0,0 +1,150 @@
+// +build tests
+
+package acknowledgements
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func newRequest(method string, link string) *http.Request {
+	u, _ := url.Parse(link)
+	req, _ := http.NewRequest(method, u.String(), nil)
+	return req
+}
+
+func TestLink(t *testing.T) {
+	config := &doorbot.DoorbotConfig{Secret: "secret", UserAccountsDomain: "doorbot.com"}
+	account := &doorbot.Account{ID: 1, Host: "acme"}
+
+	notification := &doorbot.Notification{
+		ID:        12,
+		AccountID: 1,
+		DoorID:    3,
+		PersonID:  45,
+	}
+
+	links := notifications.NewLinks(config).Acknowledgements(account, notification, doorbot.NotificationChannelSMS)
+
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	notificationRepo := new(tests.MockNotificationRepository)
+	eventRepo := new(tests.MockEventRepository)
+
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+
+	notificationRepo.On("Find", db, uint(12)).Return(notification, nil)
+	notificationRepo.On("Acknowledge", db, notification).Return(true, nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	render.On("JSON", http.StatusOK, ResultViewModel{Message: "Thanks! The visitor was told: I'm on my way."}).Return()
+
+	Link(render, repositories, martini.Params{"id": "12"}, newRequest("POST", links[doorbot.AcknowledgementOnMyWay]), account, config)
+
+	assert.Equal(t, doorbot.NotificationChannelSMS, notification.AcknowledgedVia)
+
+	event := eventRepo.Mock.Calls[0].Arguments.Get(1).(*doorbot.Event)
+	assert.Equal(t, uint(doorbot.EventNotificationSMSAcknowledged), event.EventID)
+
+	render.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+}
+
+func TestLinkInvalidSignature(t *testing.T) {
+	config := &doorbot.DoorbotConfig{Secret: "secret", UserAccountsDomain: "doorbot.com"}
+	account := &doorbot.Account{ID: 1, Host: "acme"}
+
+	links := notifications.Links{Secret: "other", Domain: "doorbot.com"}.Acknowledgements(account, &doorbot.Notification{ID: 12}, doorbot.NotificationChannelSMS)
+
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	render.On("JSON", http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{"The link is invalid or has expired."})).Return()
+
+	Link(render, repositories, martini.Params{"id": "12"}, newRequest("POST", links[doorbot.AcknowledgementOnMyWay]), account, config)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestConfirm(t *testing.T) {
+	config := &doorbot.DoorbotConfig{Secret: "secret", UserAccountsDomain: "doorbot.com"}
+	account := &doorbot.Account{ID: 1, Host: "acme"}
+
+	links := notifications.NewLinks(config).Acknowledgements(account, &doorbot.Notification{ID: 12}, doorbot.NotificationChannelEmail)
+
+	render := new(tests.MockRender)
+
+	render.On("Header").Return()
+	render.On("Data", http.StatusOK, mock.AnythingOfType("[]uint8")).Return()
+
+	// Following the link only renders the confirmation, the notification is left as is.
+	Confirm(render, martini.Params{"id": "12"}, newRequest("GET", links[doorbot.AcknowledgementOnMyWay]), account, config)
+
+	render.Mock.AssertExpectations(t)
+
+	page := string(render.Mock.Calls[1].Arguments.Get(1).([]byte))
+	assert.Contains(t, page, `<form method="post" action="/api/acknowledgements/12?`)
+	assert.Contains(t, page, "I&#39;m on my way.")
+}
+
+func TestConfirmInvalidSignature(t *testing.T) {
+	config := &doorbot.DoorbotConfig{Secret: "secret", UserAccountsDomain: "doorbot.com"}
+	account := &doorbot.Account{ID: 1, Host: "acme"}
+
+	links := notifications.Links{Secret: "other", Domain: "doorbot.com"}.Acknowledgements(account, &doorbot.Notification{ID: 12}, doorbot.NotificationChannelEmail)
+
+	render := new(tests.MockRender)
+
+	render.On("JSON", http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{"The link is invalid or has expired."})).Return()
+
+	Confirm(render, martini.Params{"id": "12"}, newRequest("GET", links[doorbot.AcknowledgementOnMyWay]), account, config)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestSlack(t *testing.T) {
+	config := &doorbot.DoorbotConfig{Secret: "secret"}
+	account := &doorbot.Account{ID: 1, Host: "acme"}
+
+	notification := &doorbot.Notification{
+		ID:        12,
+		AccountID: 1,
+	}
+
+	token := notifications.NewLinks(config).Token(account, notification, doorbot.NotificationChannelChat)
+
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	notificationRepo := new(tests.MockNotificationRepository)
+	eventRepo := new(tests.MockEventRepository)
+
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+
+	notificationRepo.On("Find", db, uint(12)).Return(notification, nil)
+	notificationRepo.On("Acknowledge", db, notification).Return(false, nil)
+
+	render.On("JSON", http.StatusOK, SlackResponse{Text: "This knock-knock was already answered.", ReplaceOriginal: true}).Return()
+
+	form := url.Values{}
+	form.Set("payload", `{"callback_id":"`+token+`","actions":[{"name":"reply","value":"please_wait"}]}`)
+
+	Slack(render, repositories, newRequest("POST", "https://acme.doorbot.com/api/acknowledgements/slack?"+form.Encode()), account, config)
+
+	render.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+}
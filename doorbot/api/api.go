@@ -2,6 +2,11 @@ package api
 
 import (
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/digests"
+	"github.com/masom/doorbot/doorbot/services/notifications"
+	"github.com/masom/doorbot/doorbot/services/realtime"
+	"github.com/masom/doorbot/doorbot/services/webhooks"
+	log "github.com/Sirupsen/logrus"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/render"
 	"net/http"
@@ -16,9 +21,54 @@ func NewServer(c *doorbot.DoorbotConfig) *martini.Martini {
 
 	m.Use(CORSHandler())
 
-	doorbot.MapDatabase(m.Martini, c)
+	db := doorbot.MapDatabase(m.Martini, c)
 	doorbot.UseRepositories(m.Martini)
 
+	// Skip failing notification providers in favor of the next one of the same kind.
+	notifications.Breakers.Configure(c.Notificator.BreakerThreshold, c.Notificator.BreakerCooldown)
+
+	// Deliver queued notifications in the background.
+	if c.Notificator.Workers > 0 {
+		queue := notifications.NewQueue(c.Notificator, func() doorbot.Repositories {
+			return doorbot.NewRepositories(db)
+		})
+
+		queue.Server = c
+		queue.Start()
+	}
+
+	// Email the lobby activity digests to the account managers.
+	if c.Digest.Interval > 0 {
+		scheduler := digests.NewScheduler(c.Digest, func() doorbot.Repositories {
+			return doorbot.NewRepositories(db)
+		})
+
+		scheduler.Notificator = func(a *doorbot.Account, r doorbot.Repositories) notifications.Notificator {
+			return notifications.New(notifications.NewConfig(a, r, c))
+		}
+
+		scheduler.Start()
+	}
+
+	// Deliver the account events to the webhook subscriptions.
+	if c.Webhooks.Workers > 0 {
+		dispatcher := webhooks.NewDispatcher(c.Webhooks, func() doorbot.Repositories {
+			return doorbot.NewRepositories(db)
+		})
+
+		dispatcher.Start()
+	}
+
+	// Push database changes to the connected devices.
+	hub := realtime.NewHub(c.Database.URL)
+	if err := hub.Start(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("Api::NewServer unable to listen for database changes")
+	}
+
+	m.Map(hub)
+
 	m.Use(func(req *http.Request, render render.Render) {
 		if req.Method == "OPTIONS" {
 			render.Status(http.StatusOK)
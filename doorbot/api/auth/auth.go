@@ -4,6 +4,7 @@ import (
 	"github.com/masom/doorbot/doorbot"
 	"github.com/masom/doorbot/doorbot/auth"
 	"github.com/masom/doorbot/doorbot/security"
+	"github.com/masom/doorbot/doorbot/services/audit"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/martini-contrib/render"
@@ -154,6 +155,8 @@ func Password(render render.Render, account *doorbot.Account, r doorbot.Reposito
 		"person_id":  person.ID,
 	}).Info("Api::Auth->Password user logged in")
 
+	audit.Person(r, &auth.Authorization{Type: auth.AuthorizationPerson, Person: person}, doorbot.EventSignIn, person)
+
 	resp := APITokenResponse{}
 	resp.Authentication.Token = fmt.Sprintf("%d.%s", person.ID, token.Token)
 	resp.Person = person
@@ -225,10 +228,13 @@ func Token(render render.Render, account *doorbot.Account, r doorbot.Repositorie
 
 		policy = getPolicyForPerson(person)
 
+		audit.Person(r, &auth.Authorization{Type: auth.AuthorizationPerson, Person: person}, doorbot.EventSignIn, person)
+
 		break
 	case auth.AuthorizationDevice:
 		deviceRepo := r.DeviceRepository()
-		device, err := deviceRepo.FindByToken(r.DB(), vm.Authentication.Token)
+		var err error
+		device, err = deviceRepo.FindByToken(r.DB(), vm.Authentication.Token)
 
 		if err != nil {
 			log.WithFields(log.Fields{
@@ -252,6 +258,8 @@ func Token(render render.Render, account *doorbot.Account, r doorbot.Repositorie
 			render.JSON(http.StatusUnauthorized, doorbot.NewUnauthorizedErrorResponse([]string{"Invalid token"}))
 			return
 		}
+
+		audit.Device(r, &auth.Authorization{Type: auth.AuthorizationDevice, Device: device}, doorbot.EventDeviceSignIn, device)
 	}
 
 	resp := APITokenResponse{}
@@ -40,14 +40,19 @@ func TestPassword(t *testing.T) {
 	render := new(tests.MockRender)
 	personRepo := new(tests.MockPersonRepository)
 	authRepo := new(tests.MockAuthenticationRepository)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("PersonRepository").Return(personRepo)
 	repositories.On("AuthenticationRepository").Return(authRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("AccountScope").Return(uint(444))
 
 	db := new(tests.MockExecutor)
 	repositories.On("DB").Return(db)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	personRepo.On("FindByEmail", db, "cookiemonster@aol.com").Return(person, nil)
 
 	authRepo.On("FindByPersonIDAndProviderID", db, person.ID, auth.ProviderPassword).Return(passwordAuthentication, nil).Once()
@@ -61,6 +66,7 @@ func TestPassword(t *testing.T) {
 	personRepo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 	authRepo.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 }
 
 func TestPasswordReuseToken(t *testing.T) {
@@ -104,14 +110,19 @@ func TestPasswordReuseToken(t *testing.T) {
 	render := new(tests.MockRender)
 	personRepo := new(tests.MockPersonRepository)
 	authRepo := new(tests.MockAuthenticationRepository)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("PersonRepository").Return(personRepo)
 	repositories.On("AuthenticationRepository").Return(authRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("AccountScope").Return(uint(1))
 
 	db := new(tests.MockExecutor)
 	repositories.On("DB").Return(db)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	personRepo.On("FindByEmail", db, "cookiemonster@aol.com").Return(person, nil)
 	authRepo.On("FindByPersonIDAndProviderID", db, person.ID, auth.ProviderPassword).Return(passwordAuthentication, nil)
 	authRepo.On("FindByPersonIDAndProviderID", db, person.ID, auth.ProviderAPIToken).Return(apiTokenAuthentication, nil)
@@ -124,6 +135,7 @@ func TestPasswordReuseToken(t *testing.T) {
 	personRepo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 	authRepo.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 }
 
 func TestPasswordPersonNotFound(t *testing.T) {
@@ -2,7 +2,9 @@ package devices
 
 import (
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
 	"github.com/masom/doorbot/doorbot/security"
+	"github.com/masom/doorbot/doorbot/services/audit"
 	log "github.com/Sirupsen/logrus"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/render"
@@ -66,7 +68,7 @@ func Get(render render.Render, r doorbot.Repositories, params martini.Params) {
 }
 
 // Register creates a new device
-func Register(render render.Render, r doorbot.Repositories, vm DeviceViewModel) {
+func Register(render render.Render, r doorbot.Repositories, vm DeviceViewModel, session *auth.Authorization) {
 
 	repo := r.DeviceRepository()
 
@@ -112,11 +114,13 @@ func Register(render render.Render, r doorbot.Repositories, vm DeviceViewModel)
 		"device_id":  device.ID,
 	}).Info("Api::Devices->Put device registered")
 
+	audit.Device(r, session, doorbot.EventDeviceUpdated, device)
+
 	render.JSON(http.StatusOK, device)
 }
 
 // Post creates a new device
-func Post(render render.Render, r doorbot.Repositories, vm DeviceViewModel) {
+func Post(render render.Render, r doorbot.Repositories, vm DeviceViewModel, session *auth.Authorization) {
 	repo := r.DeviceRepository()
 
 	vm.Device.Token = security.GenerateAPIToken()
@@ -138,11 +142,13 @@ func Post(render render.Render, r doorbot.Repositories, vm DeviceViewModel) {
 		"device_id":  vm.Device.ID,
 	}).Info("Api::Devices->Put device created")
 
+	audit.Device(r, session, doorbot.EventDeviceAdded, vm.Device)
+
 	render.JSON(http.StatusCreated, vm)
 }
 
 // Put updates a device
-func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm DeviceViewModel) {
+func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm DeviceViewModel, session *auth.Authorization) {
 	id, err := strconv.ParseUint(params["id"], 10, 32)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
@@ -193,13 +199,15 @@ func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm
 		"device_id":  id,
 	}).Info("Api::Devices->Put device updated")
 
+	audit.Device(r, session, doorbot.EventDeviceUpdated, device)
+
 	vm.Device = device
 
 	render.JSON(http.StatusOK, vm)
 }
 
 // Enable a device
-func Enable(render render.Render, r doorbot.Repositories, params martini.Params) {
+func Enable(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
 	id, err := strconv.ParseUint(params["id"], 10, 32)
 
 	if err != nil {
@@ -245,11 +253,14 @@ func Enable(render render.Render, r doorbot.Repositories, params martini.Params)
 		"device_id":  id,
 	}).Info("Api::Devices->Enable device enabled")
 
+	device.IsEnabled = true
+	audit.Device(r, session, doorbot.EventDeviceUpdated, device)
+
 	render.Status(http.StatusNoContent)
 }
 
 // Disable a device
-func Disable(render render.Render, r doorbot.Repositories, params martini.Params) {
+func Disable(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
 	id, err := strconv.ParseUint(params["id"], 10, 32)
 
 	if err != nil {
@@ -295,11 +306,14 @@ func Disable(render render.Render, r doorbot.Repositories, params martini.Params
 		"device_id":  id,
 	}).Info("Api::Devices->Disabled device disabled")
 
+	device.IsEnabled = false
+	audit.Device(r, session, doorbot.EventDeviceUpdated, device)
+
 	render.Status(http.StatusNoContent)
 }
 
 // Delete a device
-func Delete(render render.Render, r doorbot.Repositories, params martini.Params) {
+func Delete(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
 	id, err := strconv.ParseUint(params["id"], 10, 32)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
@@ -344,5 +358,7 @@ func Delete(render render.Render, r doorbot.Repositories, params martini.Params)
 		"device_id":  id,
 	}).Info("Api::Devices->Disabled device deleted")
 
+	audit.Device(r, session, doorbot.EventDeviceRemoved, device)
+
 	render.Status(http.StatusNoContent)
 }
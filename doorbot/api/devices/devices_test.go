@@ -2,10 +2,12 @@ package devices
 
 import (
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
 	"bitbucket.org/msamson/doorbot-api/tests"
 	"errors"
 	"github.com/go-martini/martini"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"testing"
 )
@@ -141,10 +143,12 @@ func TestPost(t *testing.T) {
 	repo := new(tests.MockDeviceRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("DeviceRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	device := &doorbot.Device{
@@ -153,11 +157,16 @@ func TestPost(t *testing.T) {
 
 	repo.On("Create", db, device).Return(nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("JSON", http.StatusCreated, DeviceViewModel{Device: device}).Return()
 
-	Post(render, repositories, DeviceViewModel{Device: device})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Post(render, repositories, DeviceViewModel{Device: device}, session)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -181,7 +190,9 @@ func TestPostCreateError(t *testing.T) {
 
 	render.On("JSON", http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{})).Return()
 
-	Post(render, repositories, DeviceViewModel{Device: device})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Post(render, repositories, DeviceViewModel{Device: device}, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -193,10 +204,12 @@ func TestPut(t *testing.T) {
 	repo := new(tests.MockDeviceRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("DeviceRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	params := martini.Params{
@@ -215,13 +228,18 @@ func TestPut(t *testing.T) {
 	repo.On("Find", db, uint64(5555)).Return(repoDevice, nil)
 	repo.On("Update", db, repoDevice).Return(true, nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("JSON", http.StatusOK, DeviceViewModel{Device: repoDevice}).Return()
 
-	Put(render, repositories, params, DeviceViewModel{Device: postDevice})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Put(render, repositories, params, DeviceViewModel{Device: postDevice}, session)
 
 	assert.Equal(t, "Romanian Landlords", repoDevice.Name)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -241,7 +259,9 @@ func TestPutInvalidID(t *testing.T) {
 
 	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"})).Return()
 
-	Put(render, repositories, params, DeviceViewModel{Device: postDevice})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Put(render, repositories, params, DeviceViewModel{Device: postDevice}, session)
 
 	render.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
@@ -270,7 +290,9 @@ func TestPutNotFound(t *testing.T) {
 	repo.On("Find", db, uint64(44)).Return(device, nil)
 	render.On("JSON", http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified device does not exists"})).Return()
 
-	Put(render, repositories, params, DeviceViewModel{Device: postDevice})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Put(render, repositories, params, DeviceViewModel{Device: postDevice}, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -306,7 +328,9 @@ func TestPutFailed(t *testing.T) {
 
 	render.On("JSON", http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{})).Return()
 
-	Put(render, repositories, params, DeviceViewModel{Device: postDevice})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Put(render, repositories, params, DeviceViewModel{Device: postDevice}, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -318,10 +342,12 @@ func TestEnable(t *testing.T) {
 	repo := new(tests.MockDeviceRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("DeviceRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	params := martini.Params{
@@ -336,11 +362,16 @@ func TestEnable(t *testing.T) {
 	repo.On("Find", db, uint64(4443)).Return(device, nil)
 	repo.On("Enable", db, device, true).Return(true, nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("Status", http.StatusNoContent).Return()
 
-	Enable(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Enable(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 }
@@ -350,10 +381,12 @@ func TestDisable(t *testing.T) {
 	repo := new(tests.MockDeviceRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("DeviceRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	params := martini.Params{
@@ -368,11 +401,16 @@ func TestDisable(t *testing.T) {
 	repo.On("Find", db, uint64(4443)).Return(device, nil)
 	repo.On("Enable", db, device, false).Return(true, nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("Status", http.StatusNoContent).Return()
 
-	Disable(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Disable(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -382,10 +420,12 @@ func TestDelete(t *testing.T) {
 	repo := new(tests.MockDeviceRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("DeviceRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	params := martini.Params{
@@ -400,11 +440,16 @@ func TestDelete(t *testing.T) {
 	repo.On("Find", db, uint64(33)).Return(device, nil)
 	repo.On("Delete", db, device).Return(true, nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("Status", http.StatusNoContent).Return()
 
-	Delete(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Delete(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -420,7 +465,9 @@ func TestDeleteInvalidID(t *testing.T) {
 
 	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"})).Return()
 
-	Delete(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Delete(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
@@ -446,7 +493,9 @@ func TestDeleteNotFound(t *testing.T) {
 
 	render.On("JSON", http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified device does not exists"})).Return()
 
-	Delete(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Delete(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -478,7 +527,9 @@ func TestDeleteFailed(t *testing.T) {
 
 	render.On("Status", http.StatusInternalServerError).Return()
 
-	Delete(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Delete(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
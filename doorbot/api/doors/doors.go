@@ -3,6 +3,9 @@ package doors
 
 import (
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/audit"
+	"github.com/masom/doorbot/doorbot/services/notifications/webhook"
 	log "github.com/Sirupsen/logrus"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/render"
@@ -66,7 +69,12 @@ func Get(render render.Render, r doorbot.Repositories, params martini.Params) {
 }
 
 // Post creates a door
-func Post(render render.Render, r doorbot.Repositories, vm DoorViewModel) {
+func Post(render render.Render, r doorbot.Repositories, vm DoorViewModel, session *auth.Authorization) {
+	if len(vm.Door.NotificationsTeamsURL) > 0 && !webhook.ValidURL(vm.Door.NotificationsTeamsURL) {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The Microsoft Teams URL must be an absolute http or https URL."}))
+		return
+	}
+
 	repo := r.DoorRepository()
 
 	err := repo.Create(r.DB(), vm.Door)
@@ -86,17 +94,24 @@ func Post(render render.Render, r doorbot.Repositories, vm DoorViewModel) {
 		"door_id":    vm.Door.ID,
 	}).Error("Api::Doors->Post door created")
 
+	audit.Door(r, session, doorbot.EventDoorAdded, vm.Door)
+
 	render.JSON(http.StatusCreated, vm)
 }
 
 // Put updates a door
-func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm DoorViewModel) {
+func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm DoorViewModel, session *auth.Authorization) {
 	id, err := strconv.ParseUint(params["id"], 10, 32)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
 		return
 	}
 
+	if len(vm.Door.NotificationsTeamsURL) > 0 && !webhook.ValidURL(vm.Door.NotificationsTeamsURL) {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The Microsoft Teams URL must be an absolute http or https URL."}))
+		return
+	}
+
 	repo := r.DoorRepository()
 	door, err := repo.Find(r.DB(), uint(id))
 	if err != nil {
@@ -117,6 +132,8 @@ func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm
 	}
 
 	door.Name = vm.Door.Name
+	door.NotificationsChatChannel = vm.Door.NotificationsChatChannel
+	door.NotificationsTeamsURL = vm.Door.NotificationsTeamsURL
 
 	_, err = repo.Update(r.DB(), door)
 
@@ -137,11 +154,13 @@ func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm
 		"door_id":    vm.Door.ID,
 	}).Error("Api::Doors->Post door updated")
 
+	audit.Door(r, session, doorbot.EventDoorUpdated, door)
+
 	render.JSON(http.StatusOK, DoorViewModel{Door: door})
 }
 
 // Delete a door
-func Delete(render render.Render, r doorbot.Repositories, params martini.Params) {
+func Delete(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
 	id, err := strconv.ParseUint(params["id"], 10, 32)
 	if err != nil {
 		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
@@ -186,5 +205,7 @@ func Delete(render render.Render, r doorbot.Repositories, params martini.Params)
 		"door_id":    door.ID,
 	}).Error("Api::Doors->Post door deleted")
 
+	audit.Door(r, session, doorbot.EventDoorRemoved, door)
+
 	render.Status(http.StatusNoContent)
 }
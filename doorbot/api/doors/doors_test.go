@@ -2,10 +2,12 @@ package doors
 
 import (
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
 	"bitbucket.org/msamson/doorbot-api/tests"
 	"errors"
 	"github.com/go-martini/martini"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"testing"
 )
@@ -145,7 +147,9 @@ func TestPost(t *testing.T) {
 	repositories.On("AccountScope").Return(uint(1))
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 
 	door := &doorbot.Door{
 		Name: "ACME",
@@ -153,11 +157,16 @@ func TestPost(t *testing.T) {
 
 	repo.On("Create", db, door).Return(nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("JSON", http.StatusCreated, DoorViewModel{Door: door}).Return()
 
-	Post(render, repositories, DoorViewModel{Door: door})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Post(render, repositories, DoorViewModel{Door: door}, session)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -181,7 +190,9 @@ func TestPostCreateError(t *testing.T) {
 
 	render.On("JSON", http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{})).Return()
 
-	Post(render, repositories, DoorViewModel{Door: door})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Post(render, repositories, DoorViewModel{Door: door}, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -193,10 +204,12 @@ func TestPut(t *testing.T) {
 	repo := new(tests.MockDoorRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("DoorRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	params := martini.Params{
@@ -215,13 +228,18 @@ func TestPut(t *testing.T) {
 	repo.On("Find", db, uint(5555)).Return(repoDoor, nil)
 	repo.On("Update", db, repoDoor).Return(true, nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("JSON", http.StatusOK, DoorViewModel{Door: repoDoor}).Return()
 
-	Put(render, repositories, params, DoorViewModel{Door: postDoor})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Put(render, repositories, params, DoorViewModel{Door: postDoor}, session)
 
 	assert.Equal(t, "Romanian Landlords", repoDoor.Name)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -241,7 +259,9 @@ func TestPutInvalidID(t *testing.T) {
 
 	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"})).Return()
 
-	Put(render, repositories, params, DoorViewModel{Door: postDoor})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Put(render, repositories, params, DoorViewModel{Door: postDoor}, session)
 
 	render.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
@@ -270,7 +290,9 @@ func TestPutNotFound(t *testing.T) {
 	repo.On("Find", db, uint(44)).Return(door, nil)
 	render.On("JSON", http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified door does not exists"})).Return()
 
-	Put(render, repositories, params, DoorViewModel{Door: postDoor})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Put(render, repositories, params, DoorViewModel{Door: postDoor}, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -306,7 +328,9 @@ func TestPutFailed(t *testing.T) {
 
 	render.On("JSON", http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{})).Return()
 
-	Put(render, repositories, params, DoorViewModel{Door: postDoor})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Put(render, repositories, params, DoorViewModel{Door: postDoor}, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -318,10 +342,12 @@ func TestDelete(t *testing.T) {
 	repo := new(tests.MockDoorRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("DoorRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	params := martini.Params{
@@ -336,11 +362,16 @@ func TestDelete(t *testing.T) {
 	repo.On("Find", db, uint(33)).Return(door, nil)
 	repo.On("Delete", db, door).Return(true, nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("Status", http.StatusNoContent).Return()
 
-	Delete(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Delete(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -356,7 +387,9 @@ func TestDeleteInvalidID(t *testing.T) {
 
 	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"})).Return()
 
-	Delete(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Delete(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
@@ -382,7 +415,9 @@ func TestDeleteNotFound(t *testing.T) {
 
 	render.On("JSON", http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified door does not exists"})).Return()
 
-	Delete(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Delete(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -414,7 +449,9 @@ func TestDeleteFailed(t *testing.T) {
 
 	render.On("Status", http.StatusInternalServerError).Return()
 
-	Delete(render, repositories, params)
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Delete(render, repositories, params, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
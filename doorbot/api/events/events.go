@@ -0,0 +1,135 @@
+// Package events exposes the account audit log.
+package events
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	log "github.com/Sirupsen/logrus"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLimit is the number of events returned when no limit is given
+	DefaultLimit = 50
+	// MaxLimit is the maximum number of events returned at once
+	MaxLimit = 100
+)
+
+// EventsViewModel wraps a page of events.
+// NextCursor is the `before` value to use to fetch the next page, it is omitted on the last page.
+type EventsViewModel struct {
+	Events     []*doorbot.Event `json:"events"`
+	NextCursor uint             `json:"next_cursor,omitempty"`
+}
+
+// Index returns the most recent events.
+// The list can be filtered by event_id (comma separated), door_id, device_id, person_id, since and until (RFC 3339).
+// Pages are walked using the before cursor and limit.
+func Index(render render.Render, r doorbot.Repositories, req *http.Request) {
+	filter, errors := parseFilter(req)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	events, err := r.EventRepository().All(r.DB(), filter)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "event-all",
+		}).Error("Api::Events->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if events == nil {
+		events = []*doorbot.Event{}
+	}
+
+	vm := EventsViewModel{Events: events}
+
+	if uint(len(events)) == filter.Limit {
+		vm.NextCursor = events[len(events)-1].ID
+	}
+
+	render.JSON(http.StatusOK, vm)
+}
+
+// parseFilter builds the event filter out of the query string. Every invalid parameter is reported.
+func parseFilter(req *http.Request) (*doorbot.EventFilter, []string) {
+	query := req.URL.Query()
+	filter := &doorbot.EventFilter{Limit: DefaultLimit}
+	errors := []string{}
+
+	if param := query.Get("event_id"); len(param) > 0 {
+		for _, value := range strings.Split(param, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+			if err != nil {
+				errors = append(errors, "The event_id must be a comma separated list of unsigned integers")
+				break
+			}
+
+			filter.EventIDs = append(filter.EventIDs, uint(id))
+		}
+	}
+
+	ids := []struct {
+		name  string
+		value *uint
+	}{
+		{"door_id", &filter.DoorID},
+		{"device_id", &filter.DeviceID},
+		{"person_id", &filter.PersonID},
+		{"before", &filter.Before},
+		{"limit", &filter.Limit},
+	}
+
+	for _, p := range ids {
+		param := query.Get(p.name)
+		if len(param) == 0 {
+			continue
+		}
+
+		id, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			errors = append(errors, "The "+p.name+" must be an unsigned integer")
+			continue
+		}
+
+		*p.value = uint(id)
+	}
+
+	if filter.Limit == 0 || filter.Limit > MaxLimit {
+		filter.Limit = MaxLimit
+	}
+
+	times := []struct {
+		name  string
+		value **time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	}
+
+	for _, p := range times {
+		param := query.Get(p.name)
+		if len(param) == 0 {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			errors = append(errors, "The "+p.name+" parameter must be a RFC 3339 date")
+			continue
+		}
+
+		*p.value = &t
+	}
+
+	return filter, errors
+}
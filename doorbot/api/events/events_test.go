@@ -0,0 +1,95 @@
+// +build tests
+
+package events
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIndex(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+
+	req := &http.Request{URL: &url.URL{RawQuery: "event_id=10,12&door_id=3&since=2015-07-01T00:00:00Z&before=90&limit=2"}}
+
+	since := time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	events := []*doorbot.Event{
+		&doorbot.Event{ID: 80, EventID: doorbot.EventDoorAdded, DoorID: 3},
+		&doorbot.Event{ID: 75, EventID: doorbot.EventDoorUpdated, DoorID: 3},
+	}
+
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+
+	eventRepo.On("All", db, &doorbot.EventFilter{
+		EventIDs: []uint{10, 12},
+		DoorID:   3,
+		Since:    &since,
+		Before:   90,
+		Limit:    2,
+	}).Return(events, nil)
+
+	render.On("JSON", http.StatusOK, EventsViewModel{Events: events, NextCursor: 75}).Return()
+
+	Index(render, repositories, req)
+
+	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+}
+
+func TestIndexLastPage(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+
+	req := &http.Request{URL: &url.URL{RawQuery: "person_id=5"}}
+
+	events := []*doorbot.Event{
+		&doorbot.Event{ID: 4, EventID: doorbot.EventSignIn, PersonID: 5},
+	}
+
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+
+	eventRepo.On("All", db, &doorbot.EventFilter{PersonID: 5, Limit: DefaultLimit}).Return(events, nil)
+
+	render.On("JSON", http.StatusOK, EventsViewModel{Events: events}).Return()
+
+	Index(render, repositories, req)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestIndexInvalidParameters(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	req := &http.Request{URL: &url.URL{RawQuery: "event_id=1,a&device_id=-1&until=yesterday"}}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{
+		"The event_id must be a comma separated list of unsigned integers",
+		"The device_id must be an unsigned integer",
+		"The until parameter must be a RFC 3339 date",
+	})).Return()
+
+	Index(render, repositories, req)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestParseFilterLimit(t *testing.T) {
+	filter, errors := parseFilter(&http.Request{URL: &url.URL{RawQuery: "limit=5000"}})
+
+	assert.Empty(t, errors)
+	assert.Equal(t, uint(MaxLimit), filter.Limit)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/martini-contrib/render"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // RepositoryScopeHandler set the AccountId on the various repositories.
@@ -31,12 +32,8 @@ func RepositoryScopeHandler() martini.Handler {
 
 // NotificatorHandler returns a martini.Handler that map a Notification instance
 func NotificatorHandler() martini.Handler {
-	return func(c martini.Context, a *doorbot.Account, config *doorbot.DoorbotConfig) {
-		notificatorConfig := notifications.Config{
-			Account: a,
-		}
-
-		c.MapTo(notifications.New(notificatorConfig), (*notifications.Notificator)(nil))
+	return func(c martini.Context, a *doorbot.Account, r doorbot.Repositories, config *doorbot.DoorbotConfig) {
+		c.MapTo(notifications.New(notifications.NewConfig(a, r, config)), (*notifications.Notificator)(nil))
 	}
 }
 
@@ -269,6 +266,18 @@ func SecuredRouteHandler() martini.Handler {
 				"account_id": a.ID,
 			}).Info("Api::Handlers->SecuredRouteHandler device request")
 
+			if device.LastSeenAt == nil || time.Since(*device.LastSeenAt) > doorbot.DeviceSeenInterval {
+				_, err = r.DeviceRepository().Seen(r.DB(), device)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"error":      err,
+						"account_id": a.ID,
+						"device_id":  device.ID,
+						"step":       "device-seen",
+					}).Error("Api::Handlers->SecuredRouteHandler database error")
+				}
+			}
+
 			session.Type = auth.AuthorizationDevice
 			session.Device = device
 			session.Policy = security.NewDevicePolicy()
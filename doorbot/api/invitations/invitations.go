@@ -0,0 +1,670 @@
+// Package invitations lets hosts register their guests ahead of time.
+// Guests check in on the lobby device using the code they received by email.
+package invitations
+
+import (
+	"errors"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/api/people"
+	"github.com/masom/doorbot/doorbot/api/visits"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/audit"
+	"github.com/masom/doorbot/doorbot/security"
+	"github.com/masom/doorbot/doorbot/services/notifications"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CodeLength is the length of the generated invitation codes
+const CodeLength = 8
+
+// QRPrefix prefixes the invitation code in the QR payload scanned by the lobby device
+const QRPrefix = "doorbot:invitation:"
+
+// ErrCodeCollision is returned when every generated code was already used by another invitation of the account.
+var ErrCodeCollision = errors.New("unable to generate an unused invitation code")
+
+// InvitationViewModel wraps an invitation.
+// EmailError explains why the invitation could not be emailed to the guest.
+type InvitationViewModel struct {
+	Invitation *Invitation `json:"invitation"`
+	EmailError string      `json:"email_error,omitempty"`
+}
+
+// InvitationsViewModel wraps a list of invitations
+type InvitationsViewModel struct {
+	Invitations []*Invitation `json:"invitations"`
+}
+
+// RequestViewModel wraps an invitation request
+type RequestViewModel struct {
+	Invitation *Request `json:"invitation" binding:"required"`
+}
+
+// Request represents a host inviting a guest. The person defaults to the current person.
+type Request struct {
+	PersonID uint             `json:"person_id"`
+	DoorID   *uint            `json:"door_id"`
+	StartsAt time.Time        `json:"starts_at" binding:"required"`
+	EndsAt   time.Time        `json:"ends_at" binding:"required"`
+	Visitor  *doorbot.Visitor `json:"visitor" binding:"required"`
+}
+
+// Invitation represents an invitation along with its guest
+type Invitation struct {
+	*doorbot.Invitation
+	Visitor   *doorbot.Visitor     `json:"visitor"`
+	Host      *people.PublicPerson `json:"host,omitempty"`
+	QRPayload string               `json:"qr_payload"`
+}
+
+// newInvitation wraps an invitation and its guest
+func newInvitation(i *doorbot.Invitation, v *doorbot.Visitor) *Invitation {
+	return &Invitation{Invitation: i, Visitor: v, QRPayload: QRPrefix + i.Code}
+}
+
+// canManage determines if the session can see and manage the invitations of the given host.
+// Members can only manage their own invitations.
+func canManage(session *auth.Authorization, personID uint) bool {
+	switch session.Type {
+	case auth.AuthorizationAdministrator:
+		return true
+	case auth.AuthorizationPerson:
+		return session.Person.IsAccountManager() || session.Person.ID == personID
+	}
+
+	return false
+}
+
+// Index returns the invitations, optionally filtered by person_id and upcoming.
+func Index(render render.Render, r doorbot.Repositories, req *http.Request, session *auth.Authorization) {
+	filter := &doorbot.InvitationFilter{}
+
+	if param := req.URL.Query().Get("person_id"); len(param) > 0 {
+		id, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The person_id must be an unsigned integer"}))
+			return
+		}
+
+		filter.PersonID = uint(id)
+	}
+
+	if param := req.URL.Query().Get("upcoming"); len(param) > 0 {
+		upcoming, err := strconv.ParseBool(param)
+		if err != nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The upcoming parameter must be a boolean"}))
+			return
+		}
+
+		filter.Upcoming = upcoming
+	}
+
+	if session.Type == auth.AuthorizationPerson && !session.Person.IsAccountManager() {
+		if filter.PersonID != 0 && filter.PersonID != session.Person.ID {
+			render.Status(http.StatusForbidden)
+			return
+		}
+
+		filter.PersonID = session.Person.ID
+	}
+
+	if !canManage(session, filter.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	invitations, err := r.InvitationRepository().All(r.DB(), filter)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  filter.PersonID,
+			"step":       "invitation-all",
+		}).Error("Api::Invitations->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	seen := map[uint]bool{}
+	ids := []uint{}
+
+	for _, i := range invitations {
+		if !seen[i.VisitorID] {
+			seen[i.VisitorID] = true
+			ids = append(ids, i.VisitorID)
+		}
+	}
+
+	visitors, err := r.VisitorRepository().FindByIDs(r.DB(), ids)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "visitors-find",
+		}).Error("Api::Invitations->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	index := map[uint]*doorbot.Visitor{}
+	for _, v := range visitors {
+		index[v.ID] = v
+	}
+
+	result := make([]*Invitation, len(invitations))
+	for n, i := range invitations {
+		result[n] = newInvitation(i, index[i.VisitorID])
+	}
+
+	render.JSON(http.StatusOK, InvitationsViewModel{Invitations: result})
+}
+
+// Get returns an invitation
+func Get(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	invitation, ok := find(render, r, params, "Get")
+	if !ok {
+		return
+	}
+
+	if !canManage(session, invitation.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	visitor, err := r.VisitorRepository().Find(r.DB(), invitation.VisitorID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": invitation.ID,
+			"step":          "visitor-find",
+		}).Error("Api::Invitations->Get database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.JSON(http.StatusOK, InvitationViewModel{Invitation: newInvitation(invitation, visitor)})
+}
+
+// Post registers a guest and emails them the invitation code
+func Post(render render.Render, r doorbot.Repositories, notificator notifications.Notificator, vm RequestViewModel, session *auth.Authorization) {
+	request := vm.Invitation
+
+	if session.Type != auth.AuthorizationPerson && session.Type != auth.AuthorizationAdministrator {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	if request.PersonID == 0 && session.Type == auth.AuthorizationPerson {
+		request.PersonID = session.Person.ID
+	}
+
+	if !canManage(session, request.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	errs := validate(request, time.Now())
+	if len(errs) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errs))
+		return
+	}
+
+	host, err := r.PersonRepository().Find(r.DB(), request.PersonID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  request.PersonID,
+			"step":       "person-find",
+		}).Error("Api::Invitations->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if host == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified person does not exists."}))
+		return
+	}
+
+	if request.DoorID != nil {
+		door, err := r.DoorRepository().Find(r.DB(), *request.DoorID)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": r.AccountScope(),
+				"door_id":    *request.DoorID,
+				"step":       "door-find",
+			}).Error("Api::Invitations->Post database error")
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+
+		if door == nil {
+			render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified door does not exists."}))
+			return
+		}
+	}
+
+	code, err := generateCode(r)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "invitation-code",
+		}).Error("Api::Invitations->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "transaction-create",
+		}).Error("Api::Invitations->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	visitor, err := visits.SaveVisitor(r, tx, request.Visitor)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "visitor-save",
+		}).Error("Api::Invitations->Post database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	invitation := &doorbot.Invitation{
+		PersonID:  host.ID,
+		VisitorID: visitor.ID,
+		DoorID:    request.DoorID,
+		Code:      code,
+		StartsAt:  request.StartsAt,
+		EndsAt:    request.EndsAt,
+	}
+
+	err = r.InvitationRepository().Create(tx, invitation)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"visitor_id": visitor.ID,
+			"step":       "invitation-create",
+		}).Error("Api::Invitations->Post database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "transaction-commit",
+		}).Error("Api::Invitations->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	result := InvitationViewModel{Invitation: newInvitation(invitation, visitor)}
+
+	err = notificator.Invite(invitation, visitor, host)
+
+	switch {
+	case err == notifications.ErrNoChannels:
+		result.EmailError = "The invitation cannot be emailed, the guest has no email or no email notifier is enabled."
+	case err != nil:
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": invitation.ID,
+			"step":          "invitation-send",
+		}).Error("Api::Invitations->Post notificator error")
+
+		result.EmailError = "The invitation could not be emailed."
+	default:
+		now := time.Now()
+		invitation.SentAt = &now
+
+		_, err = r.InvitationRepository().Update(r.DB(), invitation)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":         err,
+				"account_id":    r.AccountScope(),
+				"invitation_id": invitation.ID,
+				"step":          "invitation-update",
+			}).Error("Api::Invitations->Post database error")
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":    r.AccountScope(),
+		"invitation_id": invitation.ID,
+		"person_id":     host.ID,
+		"visitor_id":    visitor.ID,
+	}).Info("Api::Invitations->Post invitation created")
+
+	render.JSON(http.StatusCreated, result)
+}
+
+// Delete cancels an invitation
+func Delete(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	invitation, ok := find(render, r, params, "Delete")
+	if !ok {
+		return
+	}
+
+	if !canManage(session, invitation.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	_, err := r.InvitationRepository().Delete(r.DB(), invitation)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": invitation.ID,
+			"step":          "invitation-delete",
+		}).Error("Api::Invitations->Delete database error")
+
+		render.Status(http.StatusInternalServerError)
+		return
+	}
+
+	render.Status(http.StatusNoContent)
+}
+
+// Lookup returns the open invitation matching the code so the lobby device can greet the guest.
+func Lookup(render render.Render, r doorbot.Repositories, params martini.Params) {
+	invitation, visitor, host, ok := open(render, r, params["code"], "Lookup")
+	if !ok {
+		return
+	}
+
+	vm := newInvitation(invitation, visitor)
+	vm.Host = people.NewPublicPerson(host)
+
+	render.JSON(http.StatusOK, InvitationViewModel{Invitation: vm})
+}
+
+// CheckIn checks the guest of an open invitation in and knocks the host, or their delegate when they are away.
+// The door is the one the device is assigned to, falling back to the invitation door.
+func CheckIn(render render.Render, account *doorbot.Account, r doorbot.Repositories, notificator notifications.Notificator, params martini.Params, session *auth.Authorization) {
+	if session.Type != auth.AuthorizationDevice {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	invitation, visitor, host, ok := open(render, r, params["code"], "CheckIn")
+	if !ok {
+		return
+	}
+
+	doorID := session.Device.DoorID
+	if doorID == nil {
+		doorID = invitation.DoorID
+	}
+
+	if doorID == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The device is not assigned to a door."}))
+		return
+	}
+
+	door, err := r.DoorRepository().Find(r.DB(), *doorID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": invitation.ID,
+			"door_id":       *doorID,
+			"step":          "door-find",
+		}).Error("Api::Invitations->CheckIn database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if door == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified door does not exists."}))
+		return
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "transaction-create",
+		}).Error("Api::Invitations->CheckIn database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	visit := doorbot.NewVisit(r.AccountScope(), visitor.ID, host.ID, door.ID)
+	visit.DeviceID = &session.Device.ID
+
+	err = r.VisitRepository().Create(tx, visit)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": invitation.ID,
+			"step":          "visit-create",
+		}).Error("Api::Invitations->CheckIn database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	// Another device may have checked the guest in since the invitation was loaded.
+	used, err := r.InvitationRepository().Use(tx, invitation, visit.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": invitation.ID,
+			"step":          "invitation-use",
+		}).Error("Api::Invitations->CheckIn database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if !used {
+		tx.Rollback()
+
+		render.JSON(http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"The invitation was already used."}))
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "transaction-commit",
+		}).Error("Api::Invitations->CheckIn database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":    r.AccountScope(),
+		"invitation_id": invitation.ID,
+		"visit_id":      visit.ID,
+		"person_id":     host.ID,
+		"door_id":       door.ID,
+	}).Info("Api::Invitations->CheckIn guest checked in")
+
+	audit.Visit(r, session, doorbot.EventVisitCheckedIn, visit)
+
+	result := visits.CheckInResultViewModel{Visit: &visits.Visit{Visit: visit, Visitor: visitor}}
+
+	visits.Notify(&result, account, r, notificator, door, host, session)
+
+	render.JSON(http.StatusCreated, result)
+}
+
+// open loads the invitation using the code along with its guest and host.
+// Errors are rendered and false returned when the invitation cannot be used now.
+func open(render render.Render, r doorbot.Repositories, code string, action string) (*doorbot.Invitation, *doorbot.Visitor, *doorbot.Person, bool) {
+	code = strings.TrimPrefix(strings.TrimSpace(code), QRPrefix)
+
+	invitation, err := r.InvitationRepository().FindByCode(r.DB(), code)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "invitation-find",
+		}).Error("Api::Invitations->" + action + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, nil, nil, false
+	}
+
+	if invitation == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The invitation code is invalid."}))
+		return nil, nil, nil, false
+	}
+
+	if invitation.VisitID != nil {
+		render.JSON(http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"The invitation was already used."}))
+		return nil, nil, nil, false
+	}
+
+	if !invitation.IsOpen(time.Now()) {
+		render.JSON(http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{"The invitation is not valid at this time."}))
+		return nil, nil, nil, false
+	}
+
+	visitor, err := r.VisitorRepository().Find(r.DB(), invitation.VisitorID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": invitation.ID,
+			"step":          "visitor-find",
+		}).Error("Api::Invitations->" + action + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, nil, nil, false
+	}
+
+	host, err := r.PersonRepository().Find(r.DB(), invitation.PersonID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": invitation.ID,
+			"step":          "person-find",
+		}).Error("Api::Invitations->" + action + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, nil, nil, false
+	}
+
+	if visitor == nil || host == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The invitation code is invalid."}))
+		return nil, nil, nil, false
+	}
+
+	return invitation, visitor, host, true
+}
+
+// find loads the invitation identified by the id parameter. Errors are rendered and false returned when not found.
+func find(render render.Render, r doorbot.Repositories, params martini.Params, action string) (*doorbot.Invitation, bool) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return nil, false
+	}
+
+	invitation, err := r.InvitationRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":         err,
+			"account_id":    r.AccountScope(),
+			"invitation_id": id,
+			"step":          "invitation-find",
+		}).Error("Api::Invitations->" + action + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	if invitation == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified invitation does not exists."}))
+		return nil, false
+	}
+
+	return invitation, true
+}
+
+// generateCode returns a code not used by another invitation of the account.
+func generateCode(r doorbot.Repositories) (string, error) {
+	for i := 0; i < 5; i++ {
+		code := security.GenerateCode(CodeLength)
+
+		existing, err := r.InvitationRepository().FindByCode(r.DB(), code)
+		if err != nil {
+			return "", err
+		}
+
+		if existing == nil {
+			return code, nil
+		}
+	}
+
+	return "", ErrCodeCollision
+}
+
+// validate an invitation request
+func validate(request *Request, now time.Time) []string {
+	var errs []string
+
+	if request.Visitor == nil || len(strings.TrimSpace(request.Visitor.Name)) == 0 {
+		errs = append(errs, "The visitor name is required.")
+	}
+
+	if !request.EndsAt.After(request.StartsAt) {
+		errs = append(errs, "The invitation must end after it starts.")
+	}
+
+	if request.EndsAt.Before(now) {
+		errs = append(errs, "The invitation cannot end in the past.")
+	}
+
+	return errs
+}
@@ -0,0 +1,372 @@
+// +build tests
+
+package invitations
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/api/visits"
+	"github.com/masom/doorbot/doorbot/auth"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+
+	request := &Request{
+		StartsAt: now.Add(time.Hour),
+		EndsAt:   now.Add(2 * time.Hour),
+		Visitor:  &doorbot.Visitor{Name: "Jane Doe"},
+	}
+
+	assert.Empty(t, validate(request, now))
+
+	request.EndsAt = request.StartsAt
+	assert.Equal(t, []string{"The invitation must end after it starts."}, validate(request, now))
+
+	request = &Request{
+		StartsAt: now.Add(-2 * time.Hour),
+		EndsAt:   now.Add(-time.Hour),
+		Visitor:  &doorbot.Visitor{},
+	}
+
+	assert.Equal(t, []string{"The visitor name is required.", "The invitation cannot end in the past."}, validate(request, now))
+}
+
+func TestPostMemberForbidden(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	notificator := new(tests.MockNotificator)
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 5, AccountType: doorbot.AccountMember},
+	}
+
+	vm := RequestViewModel{Invitation: &Request{PersonID: 6}}
+
+	render.On("Status", http.StatusForbidden).Return()
+
+	Post(render, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestPost(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	invitationRepo := new(tests.MockInvitationRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+
+	host := &doorbot.Person{ID: 5, AccountType: doorbot.AccountMember}
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: host}
+
+	visitor := &doorbot.Visitor{Name: "Jane Doe"}
+
+	vm := RequestViewModel{Invitation: &Request{
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(2 * time.Hour),
+		Visitor:  visitor,
+	}}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("InvitationRepository").Return(invitationRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	personRepo.On("Find", db, uint(5)).Return(host, nil)
+	invitationRepo.On("FindByCode", db, mock.AnythingOfType("string")).Return((*doorbot.Invitation)(nil), nil)
+	visitorRepo.On("Create", tx, visitor).Return(nil)
+	invitationRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Invitation")).Return(nil)
+	tx.On("Commit").Return(nil)
+	notificator.On("Invite", mock.AnythingOfType("*doorbot.Invitation"), visitor, host).Return(nil)
+	invitationRepo.On("Update", db, mock.AnythingOfType("*doorbot.Invitation")).Return(true, nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("invitations.InvitationViewModel")).Return()
+
+	Post(render, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	invitationRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+
+	result := render.Calls[0].Arguments.Get(1).(InvitationViewModel)
+
+	assert.Len(t, result.Invitation.Code, CodeLength)
+	assert.Equal(t, QRPrefix+result.Invitation.Code, result.Invitation.QRPayload)
+	assert.Equal(t, uint(5), result.Invitation.PersonID)
+	assert.NotNil(t, result.Invitation.SentAt)
+	assert.Empty(t, result.EmailError)
+}
+
+func TestLookupUsed(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	invitationRepo := new(tests.MockInvitationRepository)
+
+	visitID := uint(3)
+	invitation := &doorbot.Invitation{ID: 1, VisitID: &visitID}
+
+	repositories.On("InvitationRepository").Return(invitationRepo)
+	repositories.On("DB").Return(db)
+
+	invitationRepo.On("FindByCode", db, "ABCD2345").Return(invitation, nil)
+	render.On("JSON", http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"The invitation was already used."})).Return()
+
+	Lookup(render, repositories, martini.Params{"code": QRPrefix + "ABCD2345"})
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestLookupExpired(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	invitationRepo := new(tests.MockInvitationRepository)
+
+	invitation := &doorbot.Invitation{
+		ID:       1,
+		StartsAt: time.Now().Add(-2 * time.Hour),
+		EndsAt:   time.Now().Add(-time.Hour),
+	}
+
+	repositories.On("InvitationRepository").Return(invitationRepo)
+	repositories.On("DB").Return(db)
+
+	invitationRepo.On("FindByCode", db, "ABCD2345").Return(invitation, nil)
+	render.On("JSON", http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{"The invitation is not valid at this time."})).Return()
+
+	Lookup(render, repositories, martini.Params{"code": "ABCD2345"})
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestCheckIn(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	invitationRepo := new(tests.MockInvitationRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+
+	doorID := uint(2)
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9, DoorID: &doorID}}
+
+	invitation := &doorbot.Invitation{
+		ID:        1,
+		PersonID:  5,
+		VisitorID: 3,
+		StartsAt:  time.Now().Add(-time.Hour),
+		EndsAt:    time.Now().Add(time.Hour),
+	}
+
+	host := &doorbot.Person{ID: 5, IsVisible: true, IsAvailable: true}
+	visitor := &doorbot.Visitor{ID: 3, Name: "Jane Doe"}
+	door := &doorbot.Door{ID: 2}
+	queued := &doorbot.Notification{ID: 8}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("InvitationRepository").Return(invitationRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	invitationRepo.On("FindByCode", db, "ABCD2345").Return(invitation, nil)
+	visitorRepo.On("Find", db, uint(3)).Return(visitor, nil)
+	personRepo.On("Find", db, uint(5)).Return(host, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	invitationRepo.On("Use", tx, invitation, uint(0)).Return(true, nil)
+	tx.On("Commit").Return(nil)
+	notificator.On("KnockKnock", door, host, mock.AnythingOfType("*doorbot.Visit")).Return(queued, nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	CheckIn(render, &doorbot.Account{ID: 1}, repositories, notificator, martini.Params{"code": "ABCD2345"}, session)
+
+	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	invitationRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+
+	result := render.Calls[0].Arguments.Get(1).(visits.CheckInResultViewModel)
+
+	assert.Equal(t, uint(5), result.Visit.PersonID)
+	assert.Equal(t, uint(3), result.Visit.VisitorID)
+	assert.Equal(t, uint(9), *result.Visit.DeviceID)
+	assert.Equal(t, queued, result.Notification)
+}
+
+func TestCheckInRateLimited(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	invitationRepo := new(tests.MockInvitationRepository)
+	notificationRepo := new(tests.MockNotificationRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+
+	doorID := uint(2)
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9, DoorID: &doorID}}
+
+	invitation := &doorbot.Invitation{
+		ID:        1,
+		PersonID:  5,
+		VisitorID: 3,
+		StartsAt:  time.Now().Add(-time.Hour),
+		EndsAt:    time.Now().Add(time.Hour),
+	}
+
+	host := &doorbot.Person{ID: 5, IsVisible: true, IsAvailable: true}
+	visitor := &doorbot.Visitor{ID: 3, Name: "Jane Doe"}
+	door := &doorbot.Door{ID: 2}
+	account := &doorbot.Account{ID: 1, NotificationsDeviceRateLimit: 1}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("InvitationRepository").Return(invitationRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	invitationRepo.On("FindByCode", db, "ABCD2345").Return(invitation, nil)
+	visitorRepo.On("Find", db, uint(3)).Return(visitor, nil)
+	personRepo.On("Find", db, uint(5)).Return(host, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	invitationRepo.On("Use", tx, invitation, uint(0)).Return(true, nil)
+	tx.On("Commit").Return(nil)
+	notificationRepo.On("Lock", tx).Return(nil)
+	eventRepo.On("All", tx, mock.AnythingOfType("*doorbot.EventFilter")).Return([]*doorbot.Event{
+		&doorbot.Event{DeviceID: 9, EventID: doorbot.EventKnockKnock, CreatedAt: time.Now().Add(-30 * time.Second)},
+	}, nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	CheckIn(render, account, repositories, notificator, martini.Params{"code": "ABCD2345"}, session)
+
+	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	invitationRepo.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+	notificator.Mock.AssertNotCalled(t, "KnockKnock", mock.Anything, mock.Anything, mock.Anything)
+
+	result := render.Calls[0].Arguments.Get(1).(visits.CheckInResultViewModel)
+
+	assert.Nil(t, result.Notification)
+	assert.Contains(t, result.NotificationError, "Too many knock-knocks from this device")
+}
+
+func TestCheckInAlreadyUsed(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	invitationRepo := new(tests.MockInvitationRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+
+	doorID := uint(2)
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9, DoorID: &doorID}}
+
+	invitation := &doorbot.Invitation{
+		ID:        1,
+		PersonID:  5,
+		VisitorID: 3,
+		StartsAt:  time.Now().Add(-time.Hour),
+		EndsAt:    time.Now().Add(time.Hour),
+	}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("InvitationRepository").Return(invitationRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	// Another device checked the guest in after the invitation was loaded.
+	invitationRepo.On("FindByCode", db, "ABCD2345").Return(invitation, nil)
+	visitorRepo.On("Find", db, uint(3)).Return(&doorbot.Visitor{ID: 3, Name: "Jane Doe"}, nil)
+	personRepo.On("Find", db, uint(5)).Return(&doorbot.Person{ID: 5, IsVisible: true, IsAvailable: true}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(&doorbot.Door{ID: 2}, nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	invitationRepo.On("Use", tx, invitation, uint(0)).Return(false, nil)
+	tx.On("Rollback").Return(nil)
+	render.On("JSON", http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"The invitation was already used."})).Return()
+
+	CheckIn(render, &doorbot.Account{ID: 1}, repositories, notificator, martini.Params{"code": "ABCD2345"}, session)
+
+	render.Mock.AssertExpectations(t)
+	invitationRepo.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+	tx.Mock.AssertNotCalled(t, "Commit")
+	notificator.Mock.AssertNotCalled(t, "KnockKnock", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGenerateCodeCollision(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	invitationRepo := new(tests.MockInvitationRepository)
+
+	repositories.On("InvitationRepository").Return(invitationRepo)
+	repositories.On("DB").Return(db)
+
+	invitationRepo.On("FindByCode", db, mock.AnythingOfType("string")).Return(&doorbot.Invitation{ID: 1}, nil)
+
+	code, err := generateCode(repositories)
+
+	assert.Equal(t, ErrCodeCollision, err)
+	assert.Empty(t, code)
+	invitationRepo.Mock.AssertNumberOfCalls(t, "FindByCode", 5)
+}
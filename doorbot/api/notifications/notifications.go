@@ -1,11 +1,18 @@
 package notifications
 
 import (
+	"fmt"
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
 	"github.com/masom/doorbot/doorbot/services/notifications"
+	"github.com/masom/doorbot/doorbot/services/teams"
+	"github.com/masom/doorbot/doorbot/services/throttle"
 	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
 	"github.com/martini-contrib/render"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // ViewModel wraps requests and responses.
@@ -13,17 +20,239 @@ type ViewModel struct {
 	Notification *Notification `json:"notification" binding:"required"`
 }
 
-// Notification represents a notification request.
+// NotificationViewModel wraps a queued notification.
+// Message tells the visitor who is coming instead when the knock-knock was rerouted, or when the person was already notified.
+// Duplicate is set when the notification was sent earlier and nothing new was queued.
+type NotificationViewModel struct {
+	Notification *doorbot.Notification `json:"notification"`
+	Message      string                `json:"message,omitempty"`
+	Duplicate    bool                  `json:"duplicate,omitempty"`
+}
+
+// NotificationStatusViewModel wraps a notification delivery status
+type NotificationStatusViewModel struct {
+	Notification *NotificationStatus `json:"notification"`
+}
+
+// NotificationStatusesViewModel wraps a list of notification delivery statuses
+type NotificationStatusesViewModel struct {
+	Notifications []*NotificationStatus `json:"notifications"`
+}
+
+// AcknowledgementViewModel wraps an acknowledgement sent from the dashboard or the companion apps
+type AcknowledgementViewModel struct {
+	Acknowledgement *Acknowledgement `json:"acknowledgement" binding:"required"`
+}
+
+// Acknowledgement holds the answer of a person to a knock-knock.
+// Reply is one of the canned replies, Message is a free form message and ETA is in minutes.
+type Acknowledgement struct {
+	Reply   string `json:"reply"`
+	Message string `json:"message"`
+	ETA     uint   `json:"eta"`
+}
+
+// TestViewModel wraps a test message request
+type TestViewModel struct {
+	Test *TestMessage `json:"test" binding:"required"`
+}
+
+// TestMessage selects the channel kind a test message is sent through. The provider ( ex: Twilio ) is optional.
+type TestMessage struct {
+	Channel  string `json:"channel"`
+	Provider string `json:"provider"`
+}
+
+// TestResultsViewModel wraps the response of every provider a test message was sent through
+type TestResultsViewModel struct {
+	Results []*doorbot.NotificationAttempt `json:"results"`
+}
+
+// NotificationStatus represents a notification along with every channel tried to deliver it
+type NotificationStatus struct {
+	*doorbot.Notification
+	Channels []*doorbot.NotificationAttempt `json:"channels"`
+}
+
+// newNotificationStatuses pairs notifications with their delivery attempts
+func newNotificationStatuses(notifications []*doorbot.Notification, attempts []*doorbot.NotificationAttempt) []*NotificationStatus {
+	statuses := make([]*NotificationStatus, len(notifications))
+	index := map[uint]*NotificationStatus{}
+
+	for i, n := range notifications {
+		statuses[i] = &NotificationStatus{Notification: n, Channels: []*doorbot.NotificationAttempt{}}
+		index[n.ID] = statuses[i]
+	}
+
+	for _, a := range attempts {
+		if status, ok := index[a.NotificationID]; ok {
+			status.Channels = append(status.Channels, a)
+		}
+	}
+
+	return statuses
+}
+
+// canView determines if the session can see notifications sent to the given person.
+// Members can only see their own notifications.
+func canView(session *auth.Authorization, personID uint) bool {
+	switch session.Type {
+	case auth.AuthorizationAdministrator, auth.AuthorizationDevice:
+		return true
+	case auth.AuthorizationPerson:
+		return session.Person.IsAccountManager() || session.Person.ID == personID
+	}
+
+	return false
+}
+
+// Index returns the most recent notifications, optionally filtered by person_id and door_id
+func Index(render render.Render, r doorbot.Repositories, req *http.Request, session *auth.Authorization) {
+	filter := &doorbot.NotificationFilter{}
+
+	for name, value := range map[string]*uint{"person_id": &filter.PersonID, "door_id": &filter.DoorID} {
+		param := req.URL.Query().Get(name)
+		if len(param) == 0 {
+			continue
+		}
+
+		id, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The " + name + " must be an unsigned integer"}))
+			return
+		}
+
+		*value = uint(id)
+	}
+
+	if session.Type == auth.AuthorizationPerson && !session.Person.IsAccountManager() {
+		if filter.PersonID != 0 && filter.PersonID != session.Person.ID {
+			render.Status(http.StatusForbidden)
+			return
+		}
+
+		filter.PersonID = session.Person.ID
+	}
+
+	if !canView(session, filter.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	notifications, err := r.NotificationRepository().All(r.DB(), filter)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  filter.PersonID,
+			"door_id":    filter.DoorID,
+			"step":       "notification-all",
+		}).Error("Api::Notifications->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	ids := make([]uint, len(notifications))
+	for i, n := range notifications {
+		ids[i] = n.ID
+	}
+
+	attempts, err := r.NotificationAttemptRepository().FindByNotificationIDs(r.DB(), ids)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "notification-attempts-find",
+		}).Error("Api::Notifications->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.JSON(http.StatusOK, NotificationStatusesViewModel{Notifications: newNotificationStatuses(notifications, attempts)})
+}
+
+// Get returns a notification delivery status and every channel tried
+func Get(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return
+	}
+
+	notification, err := r.NotificationRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"notification_id": id,
+			"step":            "notification-find",
+		}).Error("Api::Notifications->Get database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if notification == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified notification does not exists"}))
+		return
+	}
+
+	if !canView(session, notification.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	attempts, err := r.NotificationAttemptRepository().FindByNotificationIDs(r.DB(), []uint{notification.ID})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"notification_id": id,
+			"step":            "notification-attempts-find",
+		}).Error("Api::Notifications->Get database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	statuses := newNotificationStatuses([]*doorbot.Notification{notification}, attempts)
+
+	render.JSON(http.StatusOK, NotificationStatusViewModel{Notification: statuses[0]})
+}
+
+// TeamNotificationViewModel wraps the notifications queued for the members of a team
+type TeamNotificationViewModel struct {
+	Notifications []*doorbot.Notification `json:"notifications"`
+	Message       string                  `json:"message"`
+	Duplicate     bool                    `json:"duplicate,omitempty"`
+}
+
+// Notification represents a notification request, for either a person or a team.
 type Notification struct {
 	DoorID   uint `json:"door_id" binding:"required"`
-	PersonID uint `json:"person_id" binding:"required"`
+	PersonID uint `json:"person_id"`
+	TeamID   uint `json:"team_id"`
 }
 
 // Notify someone their presence is needed at a given door.
-func Notify(render render.Render, account *doorbot.Account, r doorbot.Repositories, notificator notifications.Notificator, vm ViewModel) {
+// A knock-knock repeated within the account dedup window is not sent again, devices are limited to a number of knock-knocks per minute.
+func Notify(render render.Render, account *doorbot.Account, r doorbot.Repositories, notificator notifications.Notificator, vm ViewModel, session *auth.Authorization) {
 
 	notification := vm.Notification
 
+	if (notification.PersonID == 0) == (notification.TeamID == 0) {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"Either a person_id or a team_id is required."}))
+		return
+	}
+
+	if notification.TeamID > 0 {
+		notifyTeam(render, account, r, notificator, notification, session)
+		return
+	}
+
 	log.WithFields(log.Fields{
 		"account_id": account.ID,
 		"person_id":  notification.PersonID,
@@ -58,20 +287,6 @@ func Notify(render render.Render, account *doorbot.Account, r doorbot.Repositori
 		return
 	}
 
-	if !person.IsVisible || !person.IsAvailable {
-		log.WithFields(log.Fields{
-			"account_id":          account.ID,
-			"person_id":           notification.PersonID,
-			"door_id":             notification.DoorID,
-			"person_is_visible":   person.IsVisible,
-			"person_is_available": person.IsAvailable,
-		}).Info("Api::Notifications->Notify person is not available/visible")
-
-		//TODO Would there be a better status code?
-		render.JSON(http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{"The specified user is currently not available."}))
-		return
-	}
-
 	//TODO Infer from the  device token?
 	door, err := doorRepo.Find(r.DB(), notification.DoorID)
 
@@ -97,7 +312,453 @@ func Notify(render render.Render, account *doorbot.Account, r doorbot.Repositori
 		return
 	}
 
-	notificator.KnockKnock(door, person)
+	knocked, err := notifications.Knock(r, account, notificator, door, person, nil, session)
+
+	switch err {
+	case nil:
+	case notifications.ErrUnavailable:
+		//TODO Would there be a better status code?
+		render.JSON(http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{knocked.Status.Message}))
+		return
+	case notifications.ErrRateLimited:
+		retry(render, knocked.RetryAfter)
+		return
+	case notifications.ErrNoChannels:
+		render.JSON(http.StatusServiceUnavailable, doorbot.NewServiceUnavailableErrorResponse([]string{"The specified person cannot be reached on any notification channel."}))
+		return
+	default:
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"person_id":  person.ID,
+			"door_id":    door.ID,
+			"step":       "notification-queue",
+		}).Error("Api::Notifications->Notify notificator error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if knocked.Duplicate {
+		render.JSON(http.StatusOK, NotificationViewModel{
+			Notification: knocked.Notification,
+			Message:      fmt.Sprintf("%s was already notified %s ago.", person.Name, knocked.Ago),
+			Duplicate:    true,
+		})
+		return
+	}
+
+	var message string
+	if knocked.Delegate != nil {
+		message = fmt.Sprintf("%s %s is coming instead.", knocked.Status.Message, knocked.Delegate.Name)
+	}
+
+	render.JSON(http.StatusAccepted, NotificationViewModel{Notification: knocked.Notification, Message: message})
+}
+
+// notifyTeam knocks the members of a team picked by the team strategy.
+func notifyTeam(render render.Render, account *doorbot.Account, r doorbot.Repositories, notificator notifications.Notificator, notification *Notification, session *auth.Authorization) {
+	log.WithFields(log.Fields{
+		"account_id": account.ID,
+		"team_id":    notification.TeamID,
+		"door_id":    notification.DoorID,
+	}).Info("Api::Notifications->Notify team request")
+
+	team, err := r.TeamRepository().Find(r.DB(), notification.TeamID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"team_id":    notification.TeamID,
+			"door_id":    notification.DoorID,
+			"step":       "team-find",
+		}).Error("Api::Notifications->Notify database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if team == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified team does not exists."}))
+		return
+	}
+
+	door, err := r.DoorRepository().Find(r.DB(), notification.DoorID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"team_id":    team.ID,
+			"door_id":    notification.DoorID,
+			"step":       "door-find",
+		}).Error("Api::Notifications->Notify database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if door == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified door does not exists."}))
+		return
+	}
+
+	// A knock-knock already sent to any member of the team from this door is a duplicate.
+	var members []uint
+
+	if account.NotificationsDedupWindow > 0 {
+		found, err := teams.Members(r, team)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": account.ID,
+				"team_id":    team.ID,
+				"door_id":    door.ID,
+				"step":       "members-find",
+			}).Error("Api::Notifications->Notify database error")
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+
+		for _, member := range found {
+			members = append(members, member.ID)
+		}
+	}
+
+	recipients, err := teams.Recipients(r, team)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"team_id":    team.ID,
+			"door_id":    door.ID,
+			"step":       "recipients-find",
+		}).Error("Api::Notifications->Notify database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if len(recipients) == 0 {
+		log.WithFields(log.Fields{
+			"account_id": account.ID,
+			"team_id":    team.ID,
+			"door_id":    door.ID,
+		}).Info("Api::Notifications->Notify nobody from the team is available")
+
+		render.JSON(http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{fmt.Sprintf("Nobody from %s is currently available.", team.Name)}))
+		return
+	}
+
+	limiter, ok := limit(render, account, r, session)
+	if !ok {
+		return
+	}
+
+	defer release(account, limiter)
+
+	duplicate, err := limiter.Duplicate(door.ID, members...)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"team_id":    team.ID,
+			"door_id":    door.ID,
+			"step":       "duplicate-find",
+		}).Error("Api::Notifications->Notify database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if duplicate != nil {
+		log.WithFields(log.Fields{
+			"account_id":      account.ID,
+			"team_id":         team.ID,
+			"door_id":         door.ID,
+			"notification_id": duplicate.ID,
+		}).Info("Api::Notifications->Notify duplicate team knock-knock")
+
+		render.JSON(http.StatusOK, TeamNotificationViewModel{
+			Notifications: []*doorbot.Notification{duplicate},
+			Message:       fmt.Sprintf("%s was already notified %s ago.", team.Name, limiter.Ago(duplicate)),
+			Duplicate:     true,
+		})
+		return
+	}
+
+	queued := []*doorbot.Notification{}
+	var names []string
+
+	// Members without notification channels are skipped as long as someone else is reached.
+	// Teams that do not broadcast stop at the first member reached.
+	for _, person := range recipients {
+		n, err := notificator.KnockKnock(door, person, nil)
+
+		if err == notifications.ErrNoChannels {
+			continue
+		}
+
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": account.ID,
+				"team_id":    team.ID,
+				"person_id":  person.ID,
+				"door_id":    door.ID,
+				"step":       "notification-queue",
+			}).Error("Api::Notifications->Notify notificator error")
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+
+		queued = append(queued, n)
+		names = append(names, person.Name)
+
+		if team.Strategy == doorbot.TeamStrategyBroadcast {
+			continue
+		}
+
+		err = teams.Knocked(r, team, person)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": account.ID,
+				"team_id":    team.ID,
+				"person_id":  person.ID,
+				"door_id":    door.ID,
+				"step":       "team-update",
+			}).Error("Api::Notifications->Notify database error")
+		}
+
+		break
+	}
+
+	if len(queued) == 0 {
+		render.JSON(http.StatusServiceUnavailable, doorbot.NewServiceUnavailableErrorResponse([]string{"Nobody from the specified team can be reached on any notification channel."}))
+		return
+	}
+
+	ids := make([]uint, len(queued))
+	for i, n := range queued {
+		ids[i] = n.ID
+	}
+
+	record(account, limiter, door.ID, 0, doorbot.Metadata{"team_id": team.ID, "notification_ids": ids})
+
+	message := fmt.Sprintf("%s from %s has been notified.", names[0], team.Name)
+	if len(names) > 1 {
+		message = fmt.Sprintf("%d people from %s have been notified.", len(names), team.Name)
+	}
+
+	render.JSON(http.StatusAccepted, TeamNotificationViewModel{Notifications: queued, Message: message})
+}
+
+// limit acquires the knock-knock limiter of the account and rejects devices over their rate limit.
+// It is called once the recipient and the door are loaded, so the account lock only covers the checks, the queueing and the record.
+// Errors are rendered and false returned when the knock-knock cannot go on.
+func limit(render render.Render, account *doorbot.Account, r doorbot.Repositories, session *auth.Authorization) (*throttle.Limiter, bool) {
+	var deviceID uint
+	if session != nil && session.Type == auth.AuthorizationDevice && session.Device != nil {
+		deviceID = session.Device.ID
+	}
+
+	limiter, err := throttle.Acquire(r, account, deviceID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"device_id":  deviceID,
+			"step":       "limiter-acquire",
+		}).Error("Api::Notifications->Notify database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	wait, err := limiter.RetryAfter()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"device_id":  deviceID,
+			"step":       "rate-limit",
+		}).Error("Api::Notifications->Notify database error")
+
+		release(account, limiter)
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	if wait > 0 {
+		seconds := int(wait.Seconds())
+
+		log.WithFields(log.Fields{
+			"account_id":  account.ID,
+			"device_id":   deviceID,
+			"retry_after": seconds,
+		}).Warn("Api::Notifications->Notify device rate limited")
+
+		release(account, limiter)
+
+		retry(render, wait)
+		return nil, false
+	}
+
+	return limiter, true
+}
+
+// retry renders the rate limit denial of a device and when it can knock again.
+func retry(render render.Render, wait time.Duration) {
+	seconds := int(wait.Seconds())
+
+	render.Header().Set("Retry-After", strconv.Itoa(seconds))
+	render.JSON(429, doorbot.NewTooManyRequestsErrorResponse([]string{
+		fmt.Sprintf("Too many knock-knocks from this device, please try again in %ds.", seconds),
+	}))
+}
+
+// record counts a knock-knock against the device rate limit. Errors are logged, the knock-knock is already queued.
+func record(account *doorbot.Account, limiter *throttle.Limiter, doorID uint, personID uint, metadata doorbot.Metadata) {
+	err := limiter.Record(doorID, personID, metadata)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"door_id":    doorID,
+			"step":       "knock-knock-record",
+		}).Error("Api::Notifications->Notify database error")
+	}
+}
+
+// release ends the limiter transaction, letting the next knock-knock of the account through.
+func release(account *doorbot.Account, limiter *throttle.Limiter) {
+	err := limiter.Release()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"step":       "limiter-release",
+		}).Error("Api::Notifications->Notify database error")
+	}
+}
+
+// Acknowledge lets the notified person answer the visitor from the dashboard or the companion apps.
+func Acknowledge(render render.Render, r doorbot.Repositories, params martini.Params, vm AcknowledgementViewModel, session *auth.Authorization) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return
+	}
+
+	if session.Type != auth.AuthorizationPerson {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	ack := vm.Acknowledgement
+
+	if len(ack.Reply) == 0 && len(ack.Message) == 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"A reply or a message is required."}))
+		return
+	}
+
+	if _, ok := notifications.Replies[ack.Reply]; len(ack.Reply) > 0 && !ok {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The reply must be one of on_my_way, please_wait or unavailable."}))
+		return
+	}
+
+	notification, err := r.NotificationRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"notification_id": id,
+			"step":            "notification-find",
+		}).Error("Api::Notifications->Acknowledge database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if notification == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified notification does not exists."}))
+		return
+	}
+
+	if notification.PersonID != session.Person.ID {
+		log.WithFields(log.Fields{
+			"account_id":        r.AccountScope(),
+			"notification_id":   id,
+			"request_person_id": session.Person.ID,
+		}).Warn("Api::Notifications->Acknowledge forbidden")
+
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	acknowledged, err := notifications.Acknowledge(r, notification, doorbot.NotificationChannelApp, ack.Reply, ack.Message, ack.ETA)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"notification_id": id,
+			"step":            "notification-acknowledge",
+		}).Error("Api::Notifications->Acknowledge database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if !acknowledged {
+		render.JSON(http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"The notification was already acknowledged."}))
+		return
+	}
+
+	render.JSON(http.StatusOK, NotificationViewModel{Notification: notification})
+}
+
+// Test sends a test message to the calling person so managers can check the provider settings of a channel.
+// The response of each provider is returned, whether it accepted the message or not.
+func Test(render render.Render, account *doorbot.Account, notificator notifications.Notificator, vm TestViewModel, session *auth.Authorization) {
+	if session.Person == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"Test messages are sent to the calling person, an administrator cannot receive them."}))
+		return
+	}
+
+	switch vm.Test.Channel {
+	case doorbot.NotificationChannelApp, doorbot.NotificationChannelChat, doorbot.NotificationChannelSMS, doorbot.NotificationChannelEmail, doorbot.NotificationChannelWebhook:
+	default:
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The channel must be one of app, chat, sms, email or webhook."}))
+		return
+	}
+
+	attempts, err := notificator.Test(session.Person, vm.Test.Channel, vm.Test.Provider)
+	if err == notifications.ErrNoChannels {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"No provider of this channel is enabled on the account or able to reach you."}))
+		return
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": account.ID,
+			"person_id":  session.Person.ID,
+			"channel":    vm.Test.Channel,
+		}).Error("Api::Notifications->Test error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": account.ID,
+		"person_id":  session.Person.ID,
+		"channel":    vm.Test.Channel,
+		"provider":   vm.Test.Provider,
+	}).Info("Api::Notifications->Test sent")
 
-	render.JSON(http.StatusAccepted, ViewModel{Notification: notification})
+	render.JSON(http.StatusOK, TestResultsViewModel{Results: attempts})
 }
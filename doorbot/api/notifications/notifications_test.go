@@ -4,14 +4,23 @@ package notifications
 
 import (
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/notifications"
 	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestNotify(t *testing.T) {
 	render := new(tests.MockRender)
 	db := new(tests.MockExecutor)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
 	repositories := new(tests.MockRepositories)
 
 	notificator := new(tests.MockNotificator)
@@ -41,17 +50,28 @@ func TestNotify(t *testing.T) {
 	repositories.On("PersonRepository").Return(peopleRepo)
 	repositories.On("DoorRepository").Return(doorRepo)
 	repositories.On("DB").Return(db)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
 
 	peopleRepo.On("Find", db, uint(45)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.OutOfOffice{}, nil)
 	doorRepo.On("Find", db, uint(33)).Return(door, nil)
 
-	notificator.On("Notify", account, door, person).Return(nil)
+	queued := &doorbot.Notification{
+		ID:       55,
+		DoorID:   33,
+		PersonID: 45,
+		Status:   doorbot.NotificationPending,
+	}
+
+	notificator.On("KnockKnock", door, person, (*doorbot.Visit)(nil)).Return(queued, nil)
 
 	vm := ViewModel{Notification: &notification}
 
-	render.On("JSON", http.StatusAccepted, ViewModel{Notification: &notification}).Return()
+	render.On("JSON", http.StatusAccepted, NotificationViewModel{Notification: queued}).Return()
 
-	Notify(render, account, repositories, notificator, vm)
+	Notify(render, account, repositories, notificator, vm, &auth.Authorization{Type: auth.AuthorizationDevice})
 
 	render.Mock.AssertExpectations(t)
 	peopleRepo.Mock.AssertExpectations(t)
@@ -59,3 +79,721 @@ func TestNotify(t *testing.T) {
 	repositories.Mock.AssertExpectations(t)
 	notificator.Mock.AssertExpectations(t)
 }
+
+func TestNotifyNoChannels(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	repositories := new(tests.MockRepositories)
+
+	notificator := new(tests.MockNotificator)
+	peopleRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+
+	account := &doorbot.Account{
+		ID: 44,
+	}
+
+	person := &doorbot.Person{
+		AccountID:   44,
+		ID:          45,
+		Name:        "John Rambo",
+		Email:       "jrambo@example.com",
+		IsVisible:   true,
+		IsAvailable: true,
+	}
+
+	door := &doorbot.Door{}
+
+	notification := Notification{
+		DoorID:   33,
+		PersonID: 45,
+	}
+
+	repositories.On("PersonRepository").Return(peopleRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+
+	peopleRepo.On("Find", db, uint(45)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(33)).Return(door, nil)
+
+	var empty *doorbot.Notification
+	notificator.On("KnockKnock", door, person, (*doorbot.Visit)(nil)).Return(empty, notifications.ErrNoChannels)
+
+	vm := ViewModel{Notification: &notification}
+
+	render.On("JSON", http.StatusServiceUnavailable, doorbot.NewServiceUnavailableErrorResponse([]string{"The specified person cannot be reached on any notification channel."})).Return()
+
+	Notify(render, account, repositories, notificator, vm, &auth.Authorization{Type: auth.AuthorizationDevice})
+
+	render.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+}
+
+func TestNotifyAway(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+
+	notificator := new(tests.MockNotificator)
+	peopleRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	account := &doorbot.Account{
+		ID: 44,
+	}
+
+	person := &doorbot.Person{
+		AccountID:   44,
+		ID:          45,
+		Name:        "John Rambo",
+		IsVisible:   true,
+		IsAvailable: true,
+	}
+
+	periods := []*doorbot.OutOfOffice{
+		&doorbot.OutOfOffice{
+			PersonID: 45,
+			StartsAt: time.Now().Add(-time.Hour),
+			EndsAt:   time.Now().Add(24 * time.Hour),
+			Message:  "Please ask for Trautman.",
+		},
+	}
+
+	repositories.On("PersonRepository").Return(peopleRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+
+	peopleRepo.On("Find", db, uint(45)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(45)).Return(periods, nil)
+	delegateRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.Delegate{}, nil)
+	doorRepo.On("Find", db, uint(33)).Return(&doorbot.Door{ID: 33}, nil)
+
+	vm := ViewModel{Notification: &Notification{DoorID: 33, PersonID: 45}}
+
+	render.On("JSON", http.StatusForbidden, doorbot.NewForbiddenErrorResponse([]string{"John Rambo is out of the office, back tomorrow. Please ask for Trautman."})).Return()
+
+	Notify(render, account, repositories, notificator, vm, &auth.Authorization{Type: auth.AuthorizationDevice})
+
+	render.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+}
+
+func TestNotifyDelegate(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+
+	notificator := new(tests.MockNotificator)
+	peopleRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	receptionID := uint(47)
+
+	account := &doorbot.Account{
+		ID:                44,
+		ReceptionPersonID: &receptionID,
+	}
+
+	person := &doorbot.Person{ID: 45, Name: "John Rambo", IsVisible: true, IsAvailable: false}
+
+	// The first delegate is unavailable, the second one takes the knock-knock before the reception.
+	unavailable := &doorbot.Person{ID: 46, Name: "Sam Trautman", IsAvailable: false}
+	delegate := &doorbot.Person{ID: 48, Name: "Co Bao", IsVisible: true, IsAvailable: true}
+
+	door := &doorbot.Door{ID: 33}
+
+	repositories.On("PersonRepository").Return(peopleRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+
+	peopleRepo.On("Find", db, uint(45)).Return(person, nil)
+	peopleRepo.On("Find", db, uint(46)).Return(unavailable, nil)
+	peopleRepo.On("Find", db, uint(48)).Return(delegate, nil)
+	delegateRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.Delegate{
+		&doorbot.Delegate{PersonID: 45, DelegateID: 46},
+		&doorbot.Delegate{PersonID: 45, DelegateID: 48, Position: 1},
+	}, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(48)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(48)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(33)).Return(door, nil)
+
+	var visit *doorbot.Visit
+	queued := &doorbot.Notification{ID: 3, PersonID: 48, ReroutedFromPersonID: &person.ID}
+	notificator.On("Reroute", door, person, delegate, visit).Return(queued, nil)
+
+	render.On("JSON", http.StatusAccepted, NotificationViewModel{
+		Notification: queued,
+		Message:      "John Rambo is currently not available. Co Bao is coming instead.",
+	}).Return()
+
+	vm := ViewModel{Notification: &Notification{DoorID: 33, PersonID: 45}}
+
+	Notify(render, account, repositories, notificator, vm, &auth.Authorization{Type: auth.AuthorizationDevice})
+
+	render.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+	peopleRepo.Mock.AssertExpectations(t)
+}
+
+func TestNotifyTeam(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+
+	notificator := new(tests.MockNotificator)
+	peopleRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	teamRepo := new(tests.MockTeamRepository)
+	memberRepo := new(tests.MockTeamMemberRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+
+	account := &doorbot.Account{ID: 44}
+	team := &doorbot.Team{ID: 12, Name: "IT", Strategy: doorbot.TeamStrategyBroadcast}
+	door := &doorbot.Door{ID: 33}
+
+	// The second member is unavailable and the third one has no notification channels.
+	first := &doorbot.Person{ID: 45, Name: "John Rambo", IsVisible: true, IsAvailable: true}
+	second := &doorbot.Person{ID: 46, Name: "Sam Trautman", IsVisible: true, IsAvailable: false}
+	third := &doorbot.Person{ID: 47, Name: "Co Bao", IsVisible: true, IsAvailable: true}
+
+	repositories.On("TeamRepository").Return(teamRepo)
+	repositories.On("TeamMemberRepository").Return(memberRepo)
+	repositories.On("PersonRepository").Return(peopleRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DB").Return(db)
+
+	teamRepo.On("Find", db, uint(12)).Return(team, nil)
+	doorRepo.On("Find", db, uint(33)).Return(door, nil)
+	memberRepo.On("FindByTeamID", db, uint(12)).Return([]*doorbot.TeamMember{
+		&doorbot.TeamMember{TeamID: 12, PersonID: 45},
+		&doorbot.TeamMember{TeamID: 12, PersonID: 46, Position: 1},
+		&doorbot.TeamMember{TeamID: 12, PersonID: 47, Position: 2},
+	}, nil)
+	peopleRepo.On("Find", db, uint(45)).Return(first, nil)
+	peopleRepo.On("Find", db, uint(46)).Return(second, nil)
+	peopleRepo.On("Find", db, uint(47)).Return(third, nil)
+	workingHoursRepo.On("All", db).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindCurrent", db, mock.AnythingOfType("time.Time")).Return([]*doorbot.OutOfOffice{}, nil)
+
+	queued := &doorbot.Notification{ID: 3, PersonID: 45}
+	notificator.On("KnockKnock", door, first, (*doorbot.Visit)(nil)).Return(queued, nil)
+	notificator.On("KnockKnock", door, third, (*doorbot.Visit)(nil)).Return((*doorbot.Notification)(nil), notifications.ErrNoChannels)
+
+	render.On("JSON", http.StatusAccepted, TeamNotificationViewModel{
+		Notifications: []*doorbot.Notification{queued},
+		Message:       "John Rambo from IT has been notified.",
+	}).Return()
+
+	vm := ViewModel{Notification: &Notification{DoorID: 33, TeamID: 12}}
+
+	Notify(render, account, repositories, notificator, vm, &auth.Authorization{Type: auth.AuthorizationDevice})
+
+	render.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+	teamRepo.Mock.AssertExpectations(t)
+	memberRepo.Mock.AssertExpectations(t)
+}
+
+func TestNotifyTeamRoundRobin(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+
+	notificator := new(tests.MockNotificator)
+	peopleRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	teamRepo := new(tests.MockTeamRepository)
+	memberRepo := new(tests.MockTeamMemberRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+
+	account := &doorbot.Account{ID: 44}
+	team := &doorbot.Team{ID: 12, Name: "IT", Strategy: doorbot.TeamStrategyRoundRobin}
+	door := &doorbot.Door{ID: 33}
+
+	// The first member is hidden and the second one has no notification channels, the third one is knocked.
+	first := &doorbot.Person{ID: 45, Name: "John Rambo", IsVisible: false, IsAvailable: true}
+	second := &doorbot.Person{ID: 46, Name: "Sam Trautman", IsVisible: true, IsAvailable: true}
+	third := &doorbot.Person{ID: 47, Name: "Co Bao", IsVisible: true, IsAvailable: true}
+
+	repositories.On("TeamRepository").Return(teamRepo)
+	repositories.On("TeamMemberRepository").Return(memberRepo)
+	repositories.On("PersonRepository").Return(peopleRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DB").Return(db)
+
+	teamRepo.On("Find", db, uint(12)).Return(team, nil)
+	doorRepo.On("Find", db, uint(33)).Return(door, nil)
+	memberRepo.On("FindByTeamID", db, uint(12)).Return([]*doorbot.TeamMember{
+		&doorbot.TeamMember{TeamID: 12, PersonID: 45},
+		&doorbot.TeamMember{TeamID: 12, PersonID: 46, Position: 1},
+		&doorbot.TeamMember{TeamID: 12, PersonID: 47, Position: 2},
+	}, nil)
+	peopleRepo.On("Find", db, uint(45)).Return(first, nil)
+	peopleRepo.On("Find", db, uint(46)).Return(second, nil)
+	peopleRepo.On("Find", db, uint(47)).Return(third, nil)
+	workingHoursRepo.On("All", db).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindCurrent", db, mock.AnythingOfType("time.Time")).Return([]*doorbot.OutOfOffice{}, nil)
+
+	queued := &doorbot.Notification{ID: 3, PersonID: 47}
+	notificator.On("KnockKnock", door, second, (*doorbot.Visit)(nil)).Return((*doorbot.Notification)(nil), notifications.ErrNoChannels)
+	notificator.On("KnockKnock", door, third, (*doorbot.Visit)(nil)).Return(queued, nil)
+	teamRepo.On("UpdateLastKnocked", db, team).Return(true, nil)
+
+	render.On("JSON", http.StatusAccepted, TeamNotificationViewModel{
+		Notifications: []*doorbot.Notification{queued},
+		Message:       "Co Bao from IT has been notified.",
+	}).Return()
+
+	vm := ViewModel{Notification: &Notification{DoorID: 33, TeamID: 12}}
+
+	Notify(render, account, repositories, notificator, vm, &auth.Authorization{Type: auth.AuthorizationDevice})
+
+	render.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+	teamRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertNotCalled(t, "KnockKnock", door, first, (*doorbot.Visit)(nil))
+
+	assert.Equal(t, uint(47), team.LastKnockedID)
+}
+
+func TestNotifyPersonOrTeam(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	notificator := new(tests.MockNotificator)
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"Either a person_id or a team_id is required."})).Return()
+
+	vm := ViewModel{Notification: &Notification{DoorID: 33, PersonID: 45, TeamID: 12}}
+
+	Notify(render, &doorbot.Account{ID: 44}, repositories, notificator, vm, &auth.Authorization{Type: auth.AuthorizationDevice})
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestNotifyDuplicate(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+
+	notificator := new(tests.MockNotificator)
+	peopleRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	notificationRepo := new(tests.MockNotificationRepository)
+	eventRepo := new(tests.MockEventRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+
+	account := &doorbot.Account{ID: 44, NotificationsDedupWindow: 60, NotificationsDeviceRateLimit: 5}
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	person := &doorbot.Person{ID: 45, Name: "John Rambo", IsVisible: true, IsAvailable: true}
+	door := &doorbot.Door{ID: 33}
+	sent := &doorbot.Notification{ID: 3, DoorID: 33, PersonID: 45, CreatedAt: time.Now().Add(-20 * time.Second)}
+
+	repositories.On("Transaction").Return(tx, nil)
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("PersonRepository").Return(peopleRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DB").Return(db)
+
+	notificationRepo.On("Lock", tx).Return(nil)
+	eventRepo.On("All", tx, mock.AnythingOfType("*doorbot.EventFilter")).Return([]*doorbot.Event{}, nil)
+	peopleRepo.On("Find", db, uint(45)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(33)).Return(door, nil)
+	notificationRepo.On("All", tx, mock.AnythingOfType("*doorbot.NotificationFilter")).Return([]*doorbot.Notification{sent}, nil)
+	tx.On("Commit").Return(nil)
+
+	render.On("JSON", http.StatusOK, NotificationViewModel{
+		Notification: sent,
+		Message:      "John Rambo was already notified 20s ago.",
+		Duplicate:    true,
+	}).Return()
+
+	vm := ViewModel{Notification: &Notification{DoorID: 33, PersonID: 45}}
+
+	Notify(render, account, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+	notificator.Mock.AssertNotCalled(t, "KnockKnock", mock.Anything, mock.Anything, mock.Anything)
+
+	filter := notificationRepo.Mock.Calls[1].Arguments.Get(1).(*doorbot.NotificationFilter)
+	assert.Equal(t, uint(33), filter.DoorID)
+	assert.Equal(t, uint(45), filter.PersonID)
+	assert.True(t, filter.IncludeRerouted)
+}
+
+func TestNotifyRateLimited(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+
+	notificator := new(tests.MockNotificator)
+	peopleRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	notificationRepo := new(tests.MockNotificationRepository)
+	eventRepo := new(tests.MockEventRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+
+	account := &doorbot.Account{ID: 44, NotificationsDeviceRateLimit: 2}
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	person := &doorbot.Person{ID: 45, Name: "John Rambo", IsVisible: true, IsAvailable: true}
+
+	repositories.On("Transaction").Return(tx, nil)
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("PersonRepository").Return(peopleRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DB").Return(db)
+
+	peopleRepo.On("Find", db, uint(45)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(45)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(33)).Return(&doorbot.Door{ID: 33}, nil)
+
+	// The oldest knock-knock counted leaves the window in 30 seconds.
+	now := time.Now()
+	eventRepo.On("All", tx, mock.AnythingOfType("*doorbot.EventFilter")).Return([]*doorbot.Event{
+		&doorbot.Event{DeviceID: 9, EventID: doorbot.EventKnockKnock, CreatedAt: now.Add(-10 * time.Second)},
+		&doorbot.Event{DeviceID: 9, EventID: doorbot.EventKnockKnock, CreatedAt: now.Add(-30 * time.Second)},
+	}, nil)
+
+	notificationRepo.On("Lock", tx).Return(nil)
+	tx.On("Commit").Return(nil)
+
+	render.On("Header").Return()
+	render.On("JSON", 429, mock.AnythingOfType("*doorbot.TooManyRequestsErrorResponse")).Return()
+
+	vm := ViewModel{Notification: &Notification{DoorID: 33, PersonID: 45}}
+
+	Notify(render, account, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+	notificator.Mock.AssertNotCalled(t, "KnockKnock", mock.Anything, mock.Anything, mock.Anything)
+
+	filter := eventRepo.Mock.Calls[0].Arguments.Get(1).(*doorbot.EventFilter)
+	assert.Equal(t, uint(9), filter.DeviceID)
+	assert.Equal(t, uint(2), filter.Limit)
+
+	response := render.Mock.Calls[1].Arguments.Get(1).(*doorbot.TooManyRequestsErrorResponse)
+	assert.Contains(t, response.Errors[0], "Too many knock-knocks from this device")
+}
+
+func TestGet(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+
+	notificationRepo := new(tests.MockNotificationRepository)
+	attemptRepo := new(tests.MockNotificationAttemptRepository)
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationDevice,
+	}
+
+	notification := &doorbot.Notification{
+		ID:       55,
+		DoorID:   33,
+		PersonID: 45,
+		Status:   doorbot.NotificationDelivered,
+	}
+
+	attempts := []*doorbot.NotificationAttempt{
+		&doorbot.NotificationAttempt{
+			NotificationID: 55,
+			Channel:        "Nexmo",
+			EventID:        doorbot.EventNotificationSMSSent,
+			Status:         doorbot.NotificationAttemptFailed,
+			Error:          "nexmo: throttled",
+		},
+		&doorbot.NotificationAttempt{
+			NotificationID:    55,
+			Channel:           "Twilio",
+			EventID:           doorbot.EventNotificationSMSSent,
+			Status:            doorbot.NotificationAttemptDelivered,
+			ProviderMessageID: "SM123",
+		},
+	}
+
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("NotificationAttemptRepository").Return(attemptRepo)
+	repositories.On("DB").Return(db)
+
+	notificationRepo.On("Find", db, uint(55)).Return(notification, nil)
+	attemptRepo.On("FindByNotificationIDs", db, []uint{55}).Return(attempts, nil)
+
+	render.On("JSON", http.StatusOK, NotificationStatusViewModel{
+		Notification: &NotificationStatus{Notification: notification, Channels: attempts},
+	}).Return()
+
+	Get(render, repositories, martini.Params{"id": "55"}, session)
+
+	render.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+	attemptRepo.Mock.AssertExpectations(t)
+}
+
+func TestGetForbidden(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+
+	notificationRepo := new(tests.MockNotificationRepository)
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationPerson,
+		Person: &doorbot.Person{
+			ID:          12,
+			AccountType: doorbot.AccountMember,
+		},
+	}
+
+	notification := &doorbot.Notification{
+		ID:       55,
+		PersonID: 45,
+	}
+
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("DB").Return(db)
+
+	notificationRepo.On("Find", db, uint(55)).Return(notification, nil)
+
+	render.On("Status", http.StatusForbidden).Return()
+
+	Get(render, repositories, martini.Params{"id": "55"}, session)
+
+	render.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+}
+
+func TestIndexMemberScope(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+
+	notificationRepo := new(tests.MockNotificationRepository)
+	attemptRepo := new(tests.MockNotificationAttemptRepository)
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationPerson,
+		Person: &doorbot.Person{
+			ID:          45,
+			AccountType: doorbot.AccountMember,
+		},
+	}
+
+	notifications := []*doorbot.Notification{
+		&doorbot.Notification{ID: 55, DoorID: 33, PersonID: 45},
+	}
+
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("NotificationAttemptRepository").Return(attemptRepo)
+	repositories.On("DB").Return(db)
+
+	notificationRepo.On("All", db, &doorbot.NotificationFilter{DoorID: 33, PersonID: 45}).Return(notifications, nil)
+	attemptRepo.On("FindByNotificationIDs", db, []uint{55}).Return([]*doorbot.NotificationAttempt{}, nil)
+
+	render.On("JSON", http.StatusOK, NotificationStatusesViewModel{
+		Notifications: []*NotificationStatus{
+			&NotificationStatus{Notification: notifications[0], Channels: []*doorbot.NotificationAttempt{}},
+		},
+	}).Return()
+
+	req := &http.Request{URL: &url.URL{RawQuery: "door_id=33"}}
+
+	Index(render, repositories, req, session)
+
+	render.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+	attemptRepo.Mock.AssertExpectations(t)
+}
+
+func TestAcknowledge(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	notificationRepo := new(tests.MockNotificationRepository)
+	eventRepo := new(tests.MockEventRepository)
+
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+
+	notification := &doorbot.Notification{
+		ID:        12,
+		AccountID: 1,
+		DoorID:    3,
+		PersonID:  45,
+	}
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 45, AccountType: doorbot.AccountMember},
+	}
+
+	vm := AcknowledgementViewModel{
+		Acknowledgement: &Acknowledgement{Reply: doorbot.AcknowledgementOnMyWay, ETA: 5},
+	}
+
+	notificationRepo.On("Find", db, uint(12)).Return(notification, nil)
+	notificationRepo.On("Acknowledge", db, notification).Return(true, nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	render.On("JSON", http.StatusOK, NotificationViewModel{Notification: notification}).Return()
+
+	Acknowledge(render, repositories, martini.Params{"id": "12"}, vm, session)
+
+	assert.Equal(t, doorbot.NotificationChannelApp, notification.AcknowledgedVia)
+	assert.Equal(t, notifications.Replies[doorbot.AcknowledgementOnMyWay], notification.AcknowledgementMessage)
+	assert.Equal(t, uint(5), notification.AcknowledgementETA)
+
+	event := eventRepo.Mock.Calls[0].Arguments.Get(1).(*doorbot.Event)
+	assert.Equal(t, uint(doorbot.EventNotificationAppAcknowledged), event.EventID)
+
+	render.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+}
+
+func TestAcknowledgeAlreadyAcknowledged(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	notificationRepo := new(tests.MockNotificationRepository)
+
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("DB").Return(db)
+
+	notification := &doorbot.Notification{
+		ID:       12,
+		PersonID: 45,
+	}
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 45, AccountType: doorbot.AccountMember},
+	}
+
+	vm := AcknowledgementViewModel{
+		Acknowledgement: &Acknowledgement{Message: "Coming!"},
+	}
+
+	notificationRepo.On("Find", db, uint(12)).Return(notification, nil)
+	notificationRepo.On("Acknowledge", db, notification).Return(false, nil)
+
+	render.On("JSON", http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"The notification was already acknowledged."})).Return()
+
+	Acknowledge(render, repositories, martini.Params{"id": "12"}, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+}
+
+func TestAcknowledgeInvalidReply(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 45},
+	}
+
+	vm := AcknowledgementViewModel{
+		Acknowledgement: &Acknowledgement{Reply: "maybe"},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The reply must be one of on_my_way, please_wait or unavailable."})).Return()
+
+	Acknowledge(render, repositories, martini.Params{"id": "12"}, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestTest(t *testing.T) {
+	render := new(tests.MockRender)
+	notificator := new(tests.MockNotificator)
+
+	person := &doorbot.Person{ID: 45, Name: "John Rambo"}
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: person}
+
+	attempts := []*doorbot.NotificationAttempt{
+		&doorbot.NotificationAttempt{Channel: "Twilio", Status: doorbot.NotificationAttemptFailed, Error: "Authenticate"},
+	}
+
+	notificator.On("Test", person, doorbot.NotificationChannelSMS, "twilio").Return(attempts, nil)
+	render.On("JSON", http.StatusOK, TestResultsViewModel{Results: attempts}).Return()
+
+	Test(render, &doorbot.Account{ID: 44}, notificator, TestViewModel{Test: &TestMessage{Channel: doorbot.NotificationChannelSMS, Provider: "twilio"}}, session)
+
+	render.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+}
+
+func TestTestNoChannels(t *testing.T) {
+	render := new(tests.MockRender)
+	notificator := new(tests.MockNotificator)
+
+	person := &doorbot.Person{ID: 45}
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: person}
+
+	notificator.On("Test", person, doorbot.NotificationChannelChat, "").Return([]*doorbot.NotificationAttempt(nil), notifications.ErrNoChannels)
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"No provider of this channel is enabled on the account or able to reach you."})).Return()
+
+	Test(render, &doorbot.Account{ID: 44}, notificator, TestViewModel{Test: &TestMessage{Channel: doorbot.NotificationChannelChat}}, session)
+
+	render.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+}
+
+func TestTestAdministrator(t *testing.T) {
+	render := new(tests.MockRender)
+	notificator := new(tests.MockNotificator)
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"Test messages are sent to the calling person, an administrator cannot receive them."})).Return()
+
+	Test(render, &doorbot.Account{ID: 44}, notificator, TestViewModel{Test: &TestMessage{Channel: doorbot.NotificationChannelChat}}, session)
+
+	render.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+}
@@ -0,0 +1,280 @@
+package notifications
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+)
+
+// TemplatesViewModel represents the account notification templates along with the variables they can use
+type TemplatesViewModel struct {
+	Templates []*doorbot.NotificationTemplate `json:"templates"`
+	Variables []templates.Variable            `json:"variables"`
+}
+
+// TemplateViewModel represents a notification template
+type TemplateViewModel struct {
+	Template *doorbot.NotificationTemplate `json:"template"`
+}
+
+// PreviewViewModel represents a template rendered with sample values
+type PreviewViewModel struct {
+	Preview *Preview `json:"preview"`
+}
+
+// Preview holds a rendered template. Characters is the length of the text, which matters for SMS.
+type Preview struct {
+	Subject    string `json:"subject"`
+	Text       string `json:"text"`
+	HTML       string `json:"html"`
+	Characters int    `json:"characters"`
+}
+
+// GetTemplates returns the account and door notification templates
+func GetTemplates(render render.Render, r doorbot.Repositories) {
+	list, err := r.NotificationTemplateRepository().All(r.DB())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+		}).Error("Api::Notifications->GetTemplates database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if list == nil {
+		list = []*doorbot.NotificationTemplate{}
+	}
+
+	render.JSON(http.StatusOK, TemplatesViewModel{Templates: list, Variables: templates.Variables})
+}
+
+// GetTemplate returns a specific notification template
+func GetTemplate(render render.Render, r doorbot.Repositories, params martini.Params) {
+	template, ok := findTemplate(render, r, params["id"], "GetTemplate")
+	if !ok {
+		return
+	}
+
+	render.JSON(http.StatusOK, TemplateViewModel{Template: template})
+}
+
+// PostTemplate creates the template of a channel, for the whole account or a single door.
+func PostTemplate(render render.Render, r doorbot.Repositories, vm TemplateViewModel) {
+	if vm.Template == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The template is required."}))
+		return
+	}
+
+	errors := templates.Validate(vm.Template)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	repo := r.NotificationTemplateRepository()
+
+	var doorID uint
+
+	if vm.Template.DoorID != nil {
+		doorID = *vm.Template.DoorID
+
+		door, err := r.DoorRepository().Find(r.DB(), doorID)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": r.AccountScope(),
+				"door_id":    doorID,
+				"step":       "door-find",
+			}).Error("Api::Notifications->PostTemplate database error")
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+
+		if door == nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The specified door does not exists."}))
+			return
+		}
+	}
+
+	existing, err := repo.FindByChannel(r.DB(), vm.Template.Channel, doorID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"door_id":    doorID,
+			"step":       "template-find",
+		}).Error("Api::Notifications->PostTemplate database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	// FindByChannel falls back to the account template, only an exact match is a conflict.
+	if existing != nil && (existing.DoorID == nil) == (vm.Template.DoorID == nil) {
+		render.JSON(http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"A template already exists for this channel."}))
+		return
+	}
+
+	template := &doorbot.NotificationTemplate{
+		DoorID:   vm.Template.DoorID,
+		Channel:  vm.Template.Channel,
+		Subject:  vm.Template.Subject,
+		Body:     vm.Template.Body,
+		HTMLBody: vm.Template.HTMLBody,
+	}
+
+	err = repo.Create(r.DB(), template)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"door_id":    doorID,
+			"step":       "template-create",
+		}).Error("Api::Notifications->PostTemplate database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":  r.AccountScope(),
+		"template_id": template.ID,
+		"channel":     template.Channel,
+		"door_id":     doorID,
+	}).Info("Api::Notifications->PostTemplate template created")
+
+	render.JSON(http.StatusCreated, TemplateViewModel{Template: template})
+}
+
+// PutTemplate updates the content of a template. The channel and the door cannot be changed.
+func PutTemplate(render render.Render, r doorbot.Repositories, params martini.Params, vm TemplateViewModel) {
+	if vm.Template == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The template is required."}))
+		return
+	}
+
+	template, ok := findTemplate(render, r, params["id"], "PutTemplate")
+	if !ok {
+		return
+	}
+
+	updated := *template
+	updated.Subject = vm.Template.Subject
+	updated.Body = vm.Template.Body
+	updated.HTMLBody = vm.Template.HTMLBody
+
+	errors := templates.Validate(&updated)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	_, err := r.NotificationTemplateRepository().Update(r.DB(), &updated)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err,
+			"account_id":  r.AccountScope(),
+			"template_id": template.ID,
+			"step":        "template-update",
+		}).Error("Api::Notifications->PutTemplate database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":  r.AccountScope(),
+		"template_id": template.ID,
+	}).Info("Api::Notifications->PutTemplate template updated")
+
+	render.JSON(http.StatusOK, TemplateViewModel{Template: &updated})
+}
+
+// DeleteTemplate removes a template, the channel falls back to the account template or the default one.
+func DeleteTemplate(render render.Render, r doorbot.Repositories, params martini.Params) {
+	template, ok := findTemplate(render, r, params["id"], "DeleteTemplate")
+	if !ok {
+		return
+	}
+
+	_, err := r.NotificationTemplateRepository().Delete(r.DB(), template)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err,
+			"account_id":  r.AccountScope(),
+			"template_id": template.ID,
+			"step":        "template-delete",
+		}).Error("Api::Notifications->DeleteTemplate database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":  r.AccountScope(),
+		"template_id": template.ID,
+	}).Info("Api::Notifications->DeleteTemplate template deleted")
+
+	render.Status(http.StatusNoContent)
+}
+
+// PreviewTemplate renders a template with sample values, without saving or sending it.
+func PreviewTemplate(render render.Render, account *doorbot.Account, vm TemplateViewModel) {
+	if vm.Template == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The template is required."}))
+		return
+	}
+
+	errors := templates.Validate(vm.Template)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	message := templates.Render(vm.Template, templates.Sample(account))
+
+	render.JSON(http.StatusOK, PreviewViewModel{
+		Preview: &Preview{
+			Subject:    message.Subject,
+			Text:       message.Text,
+			HTML:       message.HTML,
+			Characters: len([]rune(message.Text)),
+		},
+	})
+}
+
+// findTemplate loads the template identified by `value`, rendering the error response when it cannot.
+func findTemplate(render render.Render, r doorbot.Repositories, value string, method string) (*doorbot.NotificationTemplate, bool) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return nil, false
+	}
+
+	template, err := r.NotificationTemplateRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err,
+			"account_id":  r.AccountScope(),
+			"template_id": id,
+			"step":        "template-find",
+		}).Error("Api::Notifications->" + method + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	if template == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified template does not exists"}))
+		return nil, false
+	}
+
+	return template, true
+}
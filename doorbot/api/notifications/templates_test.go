@@ -0,0 +1,160 @@
+// +build tests
+
+package notifications
+
+import (
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"testing"
+)
+
+func TestPostTemplate(t *testing.T) {
+	render := new(tests.MockRender)
+	repo := new(tests.MockNotificationTemplateRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("NotificationTemplateRepository").Return(repo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(3))
+
+	doorID := uint(7)
+
+	vm := TemplateViewModel{
+		Template: &doorbot.NotificationTemplate{
+			DoorID:  &doorID,
+			Channel: doorbot.NotificationChannelSMS,
+			Body:    "{{visitor}} is at the {{door}}.",
+		},
+	}
+
+	// The account template is returned when the door has none.
+	account := &doorbot.NotificationTemplate{ID: 1, Channel: doorbot.NotificationChannelSMS, Body: "{{visitor}}"}
+
+	doorRepo.On("Find", db, doorID).Return(&doorbot.Door{ID: doorID}, nil)
+	repo.On("FindByChannel", db, doorbot.NotificationChannelSMS, doorID).Return(account, nil)
+	repo.On("Create", db, mock.AnythingOfType("*doorbot.NotificationTemplate")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("TemplateViewModel")).Return()
+
+	PostTemplate(render, repositories, vm)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+	doorRepo.Mock.AssertExpectations(t)
+
+	created := render.Mock.Calls[0].Arguments.Get(1).(TemplateViewModel).Template
+	assert.Equal(t, doorID, *created.DoorID)
+	assert.Equal(t, "{{visitor}} is at the {{door}}.", created.Body)
+}
+
+func TestPostTemplateConflict(t *testing.T) {
+	render := new(tests.MockRender)
+	repo := new(tests.MockNotificationTemplateRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("NotificationTemplateRepository").Return(repo)
+	repositories.On("DB").Return(db)
+
+	vm := TemplateViewModel{
+		Template: &doorbot.NotificationTemplate{
+			Channel: doorbot.NotificationChannelChat,
+			Body:    "{{visitor}} is at the {{door}}.",
+		},
+	}
+
+	repo.On("FindByChannel", db, doorbot.NotificationChannelChat, uint(0)).Return(&doorbot.NotificationTemplate{ID: 1}, nil)
+	render.On("JSON", http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"A template already exists for this channel."})).Return()
+
+	PostTemplate(render, repositories, vm)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+}
+
+func TestPostTemplateInvalid(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	vm := TemplateViewModel{
+		Template: &doorbot.NotificationTemplate{
+			Channel: doorbot.NotificationChannelChat,
+			Body:    "{{guest}} is at the {{door}}.",
+		},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The template body uses an unknown variable: guest."})).Return()
+
+	PostTemplate(render, repositories, vm)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestPutTemplate(t *testing.T) {
+	render := new(tests.MockRender)
+	repo := new(tests.MockNotificationTemplateRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("NotificationTemplateRepository").Return(repo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(3))
+
+	existing := &doorbot.NotificationTemplate{ID: 4, Channel: doorbot.NotificationChannelEmail, Body: "{{visitor}}"}
+
+	vm := TemplateViewModel{
+		Template: &doorbot.NotificationTemplate{
+			Channel:  doorbot.NotificationChannelSMS,
+			Subject:  "{{visitor}} is here",
+			Body:     "Hi {{name}}",
+			HTMLBody: "<p>Hi {{name}}</p>",
+		},
+	}
+
+	params := martini.Params{"id": "4"}
+
+	repo.On("Find", db, uint(4)).Return(existing, nil)
+	repo.On("Update", db, mock.AnythingOfType("*doorbot.NotificationTemplate")).Return(true, nil)
+	render.On("JSON", http.StatusOK, mock.AnythingOfType("TemplateViewModel")).Return()
+
+	PutTemplate(render, repositories, params, vm)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+
+	updated := render.Mock.Calls[0].Arguments.Get(1).(TemplateViewModel).Template
+	assert.Equal(t, doorbot.NotificationChannelEmail, updated.Channel)
+	assert.Equal(t, "<p>Hi {{name}}</p>", updated.HTMLBody)
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	render := new(tests.MockRender)
+
+	vm := TemplateViewModel{
+		Template: &doorbot.NotificationTemplate{
+			Channel:  doorbot.NotificationChannelEmail,
+			Body:     "Hi {{name}}, {{visitor}}{{#visitor_company}} from {{visitor_company}}{{/visitor_company}} is here.",
+			HTMLBody: "<p>{{visitor}} is at {{account}}</p>",
+		},
+	}
+
+	preview := &Preview{
+		Subject:    "Doorbot - John Doe is waiting at the Main Entrance.",
+		Text:       "Hi Jane Appleseed, John Doe from Acme Corporation is here.",
+		HTML:       "<p>John Doe is at ACME &amp; Co</p>",
+		Characters: 58,
+	}
+
+	render.On("JSON", http.StatusOK, PreviewViewModel{Preview: preview}).Return()
+
+	PreviewTemplate(render, &doorbot.Account{Name: "ACME & Co"}, vm)
+
+	render.Mock.AssertExpectations(t)
+}
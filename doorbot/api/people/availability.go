@@ -0,0 +1,428 @@
+package people
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/audit"
+	"github.com/masom/doorbot/doorbot/services/availability"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WorkingHoursViewModel represents the weekly working hours of a person and the timezone they are expressed in
+type WorkingHoursViewModel struct {
+	Timezone     string                  `json:"timezone"`
+	WorkingHours []*doorbot.WorkingHours `json:"working_hours"`
+}
+
+// OutOfOfficeViewModel represents an out-of-office period
+type OutOfOfficeViewModel struct {
+	OutOfOffice *doorbot.OutOfOffice `json:"out_of_office"`
+}
+
+// OutOfOfficePeriodsViewModel represents the current and upcoming out-of-office periods of a person
+type OutOfOfficePeriodsViewModel struct {
+	OutOfOffice []*doorbot.OutOfOffice `json:"out_of_office"`
+}
+
+// GetWorkingHours returns the working hours of a person
+func GetWorkingHours(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	person, ok := findPerson(render, r, params["id"], session, "GetWorkingHours")
+	if !ok {
+		return
+	}
+
+	hours, err := r.WorkingHoursRepository().FindByPersonID(r.DB(), person.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "working-hours-find",
+		}).Error("Api::People->GetWorkingHours database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if hours == nil {
+		hours = []*doorbot.WorkingHours{}
+	}
+
+	render.JSON(http.StatusOK, WorkingHoursViewModel{Timezone: person.Timezone, WorkingHours: hours})
+}
+
+// PutWorkingHours replaces the working hours of a person.
+// A person without working hours can be knocked at any time.
+func PutWorkingHours(render render.Render, r doorbot.Repositories, params martini.Params, vm WorkingHoursViewModel, session *auth.Authorization) {
+	errors := availability.Validate(vm.Timezone, vm.WorkingHours)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	person, ok := findPerson(render, r, params["id"], session, "PutWorkingHours")
+	if !ok {
+		return
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "transaction-create",
+		}).Error("Api::People->PutWorkingHours database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	person.Timezone = vm.Timezone
+
+	_, err = r.PersonRepository().Update(tx, person)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "person-update",
+		}).Error("Api::People->PutWorkingHours database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	repo := r.WorkingHoursRepository()
+
+	err = repo.DeleteByPersonID(tx, person.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "working-hours-delete",
+		}).Error("Api::People->PutWorkingHours database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	for _, hours := range vm.WorkingHours {
+		hours.PersonID = person.ID
+
+		err = repo.Create(tx, hours)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": r.AccountScope(),
+				"person_id":  person.ID,
+				"step":       "working-hours-create",
+			}).Error("Api::People->PutWorkingHours database error")
+
+			tx.Rollback()
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "transaction-commit",
+		}).Error("Api::People->PutWorkingHours database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":    r.AccountScope(),
+		"person_id":     person.ID,
+		"working_hours": len(vm.WorkingHours),
+	}).Info("Api::People->PutWorkingHours working hours updated")
+
+	audit.Person(r, session, doorbot.EventPersonUpdated, person)
+
+	if vm.WorkingHours == nil {
+		vm.WorkingHours = []*doorbot.WorkingHours{}
+	}
+
+	render.JSON(http.StatusOK, vm)
+}
+
+// GetOutOfOffice returns the current and upcoming out-of-office periods of a person
+func GetOutOfOffice(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	person, ok := findPerson(render, r, params["id"], session, "GetOutOfOffice")
+	if !ok {
+		return
+	}
+
+	periods, err := r.OutOfOfficeRepository().FindByPersonID(r.DB(), person.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "out-of-office-find",
+		}).Error("Api::People->GetOutOfOffice database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if periods == nil {
+		periods = []*doorbot.OutOfOffice{}
+	}
+
+	render.JSON(http.StatusOK, OutOfOfficePeriodsViewModel{OutOfOffice: periods})
+}
+
+// PostOutOfOffice adds an out-of-office period to a person. The period starts now when no start is given.
+func PostOutOfOffice(render render.Render, r doorbot.Repositories, params martini.Params, vm OutOfOfficeViewModel, session *auth.Authorization) {
+	if vm.OutOfOffice == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The out-of-office period is required."}))
+		return
+	}
+
+	period := &doorbot.OutOfOffice{
+		StartsAt: vm.OutOfOffice.StartsAt,
+		EndsAt:   vm.OutOfOffice.EndsAt,
+		Message:  vm.OutOfOffice.Message,
+	}
+
+	if period.StartsAt.IsZero() {
+		period.StartsAt = time.Now()
+	}
+
+	errors := validateOutOfOffice(period)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	person, ok := findPerson(render, r, params["id"], session, "PostOutOfOffice")
+	if !ok {
+		return
+	}
+
+	period.PersonID = person.ID
+
+	err := r.OutOfOfficeRepository().Create(r.DB(), period)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "out-of-office-create",
+		}).Error("Api::People->PostOutOfOffice database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":       r.AccountScope(),
+		"person_id":        person.ID,
+		"out_of_office_id": period.ID,
+	}).Info("Api::People->PostOutOfOffice period added")
+
+	render.JSON(http.StatusCreated, OutOfOfficeViewModel{OutOfOffice: period})
+}
+
+// PutOutOfOffice updates an out-of-office period, ex: to come back earlier.
+func PutOutOfOffice(render render.Render, r doorbot.Repositories, params martini.Params, vm OutOfOfficeViewModel, session *auth.Authorization) {
+	if vm.OutOfOffice == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The out-of-office period is required."}))
+		return
+	}
+
+	period, ok := findOutOfOffice(render, r, params, session, "PutOutOfOffice")
+	if !ok {
+		return
+	}
+
+	updated := *period
+	updated.EndsAt = vm.OutOfOffice.EndsAt
+	updated.Message = vm.OutOfOffice.Message
+
+	if !vm.OutOfOffice.StartsAt.IsZero() {
+		updated.StartsAt = vm.OutOfOffice.StartsAt
+	}
+
+	errors := validateOutOfOffice(&updated)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	_, err := r.OutOfOfficeRepository().Update(r.DB(), &updated)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":            err,
+			"account_id":       r.AccountScope(),
+			"person_id":        period.PersonID,
+			"out_of_office_id": period.ID,
+			"step":             "out-of-office-update",
+		}).Error("Api::People->PutOutOfOffice database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":       r.AccountScope(),
+		"person_id":        period.PersonID,
+		"out_of_office_id": period.ID,
+	}).Info("Api::People->PutOutOfOffice period updated")
+
+	render.JSON(http.StatusOK, OutOfOfficeViewModel{OutOfOffice: &updated})
+}
+
+// DeleteOutOfOffice removes an out-of-office period
+func DeleteOutOfOffice(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	period, ok := findOutOfOffice(render, r, params, session, "DeleteOutOfOffice")
+	if !ok {
+		return
+	}
+
+	_, err := r.OutOfOfficeRepository().Delete(r.DB(), period)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":            err,
+			"account_id":       r.AccountScope(),
+			"person_id":        period.PersonID,
+			"out_of_office_id": period.ID,
+			"step":             "out-of-office-delete",
+		}).Error("Api::People->DeleteOutOfOffice database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":       r.AccountScope(),
+		"person_id":        period.PersonID,
+		"out_of_office_id": period.ID,
+	}).Info("Api::People->DeleteOutOfOffice period removed")
+
+	render.Status(http.StatusNoContent)
+}
+
+// findPerson loads the person identified by `value` when the session can manage their availability.
+// Errors are rendered and false returned otherwise.
+func findPerson(render render.Render, r doorbot.Repositories, value string, session *auth.Authorization, method string) (*doorbot.Person, bool) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return nil, false
+	}
+
+	if !canManageNotifications(session, uint(id)) {
+		log.WithFields(log.Fields{
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+		}).Warn("Api::People->" + method + " forbidden")
+
+		render.Status(http.StatusForbidden)
+		return nil, false
+	}
+
+	person, err := r.PersonRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+			"step":       "person-find",
+		}).Error("Api::People->" + method + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	if person == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified person does not exists"}))
+		return nil, false
+	}
+
+	return person, true
+}
+
+// findOutOfOffice loads the out-of-office period identified by the `period_id` parameter for the person `id`.
+// Errors are rendered and false returned when it cannot.
+func findOutOfOffice(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization, method string) (*doorbot.OutOfOffice, bool) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return nil, false
+	}
+
+	periodID, err := strconv.ParseUint(params["period_id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The period id must be an unsigned integer"}))
+		return nil, false
+	}
+
+	if !canManageNotifications(session, uint(id)) {
+		render.Status(http.StatusForbidden)
+		return nil, false
+	}
+
+	period, err := r.OutOfOfficeRepository().Find(r.DB(), uint(periodID))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":            err,
+			"account_id":       r.AccountScope(),
+			"person_id":        id,
+			"out_of_office_id": periodID,
+			"step":             "out-of-office-find",
+		}).Error("Api::People->" + method + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	if period == nil || period.PersonID != uint(id) {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified out-of-office period does not exists"}))
+		return nil, false
+	}
+
+	return period, true
+}
+
+// validateOutOfOffice returns the list of problems found in an out-of-office period.
+func validateOutOfOffice(period *doorbot.OutOfOffice) []string {
+	var errors []string
+
+	if period.EndsAt.IsZero() {
+		errors = append(errors, "The return date is required.")
+	} else {
+		if !period.EndsAt.After(period.StartsAt) {
+			errors = append(errors, "The return date must be after the start of the period.")
+		}
+
+		if !period.EndsAt.After(time.Now()) {
+			errors = append(errors, "The return date must be in the future.")
+		}
+	}
+
+	if len([]rune(period.Message)) > 500 {
+		errors = append(errors, "The message cannot exceed 500 characters.")
+	}
+
+	return errors
+}
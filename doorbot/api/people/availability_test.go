@@ -0,0 +1,231 @@
+package people
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPutWorkingHours(t *testing.T) {
+	render := new(tests.MockRender)
+	personRepo := new(tests.MockPersonRepository)
+	hoursRepo := new(tests.MockWorkingHoursRepository)
+
+	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
+	tx := new(tests.MockTransaction)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("WorkingHoursRepository").Return(hoursRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	params := martini.Params{
+		"id": "5",
+	}
+
+	person := &doorbot.Person{ID: 5}
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: person,
+	}
+
+	hours := []*doorbot.WorkingHours{
+		&doorbot.WorkingHours{Day: 1, StartsAt: "09:00", EndsAt: "17:00"},
+		&doorbot.WorkingHours{Day: 2, StartsAt: "09:00", EndsAt: "12:00"},
+	}
+
+	vm := WorkingHoursViewModel{Timezone: "America/Montreal", WorkingHours: hours}
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	personRepo.On("Update", tx, person).Return(true, nil)
+	hoursRepo.On("DeleteByPersonID", tx, uint(5)).Return(nil)
+	hoursRepo.On("Create", tx, hours[0]).Return(nil)
+	hoursRepo.On("Create", tx, hours[1]).Return(nil)
+	tx.On("Commit").Return(nil)
+
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	render.On("JSON", http.StatusOK, vm).Return()
+
+	PutWorkingHours(render, repositories, params, vm, session)
+
+	assert.Equal(t, "America/Montreal", person.Timezone)
+	assert.Equal(t, uint(5), hours[1].PersonID)
+
+	render.Mock.AssertExpectations(t)
+	personRepo.Mock.AssertExpectations(t)
+	hoursRepo.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+}
+
+func TestPutWorkingHoursInvalid(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	vm := WorkingHoursViewModel{
+		Timezone:     "Mars/Olympus",
+		WorkingHours: []*doorbot.WorkingHours{&doorbot.WorkingHours{Day: 1, StartsAt: "17:00", EndsAt: "09:00"}},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{
+		"The timezone is unknown: Mars/Olympus.",
+		"Working hours 1: the end must be after the start.",
+	})).Return()
+
+	PutWorkingHours(render, repositories, martini.Params{"id": "5"}, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestPostOutOfOffice(t *testing.T) {
+	render := new(tests.MockRender)
+	personRepo := new(tests.MockPersonRepository)
+	periodRepo := new(tests.MockOutOfOfficeRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("OutOfOfficeRepository").Return(periodRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+
+	person := &doorbot.Person{ID: 5}
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: person}
+
+	back := time.Now().Add(72 * time.Hour)
+	vm := OutOfOfficeViewModel{OutOfOffice: &doorbot.OutOfOffice{EndsAt: back, Message: "Please ask for John."}}
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	periodRepo.On("Create", db, mock.AnythingOfType("*doorbot.OutOfOffice")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("people.OutOfOfficeViewModel")).Return()
+
+	PostOutOfOffice(render, repositories, martini.Params{"id": "5"}, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	periodRepo.Mock.AssertExpectations(t)
+
+	created := render.Mock.Calls[0].Arguments.Get(1).(OutOfOfficeViewModel).OutOfOffice
+	assert.Equal(t, uint(5), created.PersonID)
+	assert.Equal(t, back, created.EndsAt)
+	assert.False(t, created.StartsAt.IsZero())
+}
+
+func TestPostOutOfOfficeInvalid(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	vm := OutOfOfficeViewModel{OutOfOffice: &doorbot.OutOfOffice{EndsAt: time.Now().Add(-time.Hour)}}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{
+		"The return date must be after the start of the period.",
+		"The return date must be in the future.",
+	})).Return()
+
+	PostOutOfOffice(render, repositories, martini.Params{"id": "5"}, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestDeleteOutOfOfficeOtherPerson(t *testing.T) {
+	render := new(tests.MockRender)
+	periodRepo := new(tests.MockOutOfOfficeRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("OutOfOfficeRepository").Return(periodRepo)
+	repositories.On("DB").Return(db)
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	periodRepo.On("Find", db, uint(3)).Return(&doorbot.OutOfOffice{ID: 3, PersonID: 6}, nil)
+	render.On("JSON", http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified out-of-office period does not exists"})).Return()
+
+	DeleteOutOfOffice(render, repositories, martini.Params{"id": "5", "period_id": "3"}, session)
+
+	render.Mock.AssertExpectations(t)
+	periodRepo.Mock.AssertExpectations(t)
+}
+
+func TestIndexDevice(t *testing.T) {
+	render := new(tests.MockRender)
+	personRepo := new(tests.MockPersonRepository)
+	hoursRepo := new(tests.MockWorkingHoursRepository)
+	periodRepo := new(tests.MockOutOfOfficeRepository)
+	teamRepo := new(tests.MockTeamRepository)
+	memberRepo := new(tests.MockTeamMemberRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("WorkingHoursRepository").Return(hoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(periodRepo)
+	repositories.On("TeamRepository").Return(teamRepo)
+	repositories.On("TeamMemberRepository").Return(memberRepo)
+	repositories.On("DB").Return(db)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice}
+
+	people := []*doorbot.Person{
+		&doorbot.Person{ID: 1, Name: "Jane", IsAvailable: true},
+		&doorbot.Person{ID: 2, Name: "John", IsAvailable: true},
+	}
+
+	back := time.Now().Add(24 * time.Hour)
+	periods := []*doorbot.OutOfOffice{
+		&doorbot.OutOfOffice{PersonID: 2, StartsAt: time.Now().Add(-time.Hour), EndsAt: back},
+	}
+
+	personRepo.On("All", db).Return(people, nil)
+	hoursRepo.On("All", db).Return([]*doorbot.WorkingHours{}, nil)
+	periodRepo.On("FindCurrent", db, mock.AnythingOfType("time.Time")).Return(periods, nil)
+	teamRepo.On("All", db).Return([]*doorbot.Team{
+		&doorbot.Team{ID: 3, Name: "IT", IsVisible: true},
+		&doorbot.Team{ID: 4, Name: "Sales", IsVisible: true},
+		&doorbot.Team{ID: 5, Name: "Executives"},
+	}, nil)
+	memberRepo.On("All", db).Return([]*doorbot.TeamMember{
+		&doorbot.TeamMember{TeamID: 3, PersonID: 1},
+		&doorbot.TeamMember{TeamID: 3, PersonID: 2},
+		&doorbot.TeamMember{TeamID: 4, PersonID: 2},
+		&doorbot.TeamMember{TeamID: 5, PersonID: 1},
+	}, nil)
+	render.On("JSON", http.StatusOK, mock.AnythingOfType("people.PublicPeopleViewModel")).Return()
+
+	Index(render, repositories, session)
+
+	render.Mock.AssertExpectations(t)
+
+	public := render.Mock.Calls[0].Arguments.Get(1).(PublicPeopleViewModel).People
+
+	assert.True(t, public[0].IsAvailable)
+	assert.Nil(t, public[0].BackAt)
+
+	assert.False(t, public[1].IsAvailable)
+	assert.Equal(t, back.UTC(), *public[1].BackAt)
+	assert.Equal(t, "John is out of the office, back tomorrow.", public[1].AwayMessage)
+
+	// Hidden teams are left out, a team is available when one of its members is.
+	publicTeams := render.Mock.Calls[0].Arguments.Get(1).(PublicPeopleViewModel).Teams
+
+	assert.Equal(t, 2, len(publicTeams))
+	assert.True(t, publicTeams[0].IsAvailable)
+	assert.Equal(t, "Sales", publicTeams[1].Name)
+	assert.False(t, publicTeams[1].IsAvailable)
+}
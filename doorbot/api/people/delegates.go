@@ -0,0 +1,183 @@
+package people
+
+import (
+	"fmt"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/audit"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+)
+
+// DelegatesViewModel represents the people taking the knock-knocks of a person while they are unavailable, in order
+type DelegatesViewModel struct {
+	Delegates []*doorbot.Delegate `json:"delegates"`
+}
+
+// GetDelegates returns the delegates of a person
+func GetDelegates(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	person, ok := findPerson(render, r, params["id"], session, "GetDelegates")
+	if !ok {
+		return
+	}
+
+	delegates, err := r.DelegateRepository().FindByPersonID(r.DB(), person.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "delegates-find",
+		}).Error("Api::People->GetDelegates database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if delegates == nil {
+		delegates = []*doorbot.Delegate{}
+	}
+
+	render.JSON(http.StatusOK, DelegatesViewModel{Delegates: delegates})
+}
+
+// PutDelegates replaces the delegates of a person.
+// The first available delegate is knocked when the person is away or does not acknowledge a knock-knock in time.
+func PutDelegates(render render.Render, r doorbot.Repositories, params martini.Params, vm DelegatesViewModel, session *auth.Authorization) {
+	person, ok := findPerson(render, r, params["id"], session, "PutDelegates")
+	if !ok {
+		return
+	}
+
+	errors := validateDelegates(person, vm.Delegates)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	for i, delegate := range vm.Delegates {
+		found, err := r.PersonRepository().Find(r.DB(), delegate.DelegateID)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":       err,
+				"account_id":  r.AccountScope(),
+				"person_id":   person.ID,
+				"delegate_id": delegate.DelegateID,
+				"step":        "delegate-find",
+			}).Error("Api::People->PutDelegates database error")
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+
+		if found == nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{fmt.Sprintf("Delegate %d does not exists.", i+1)}))
+			return
+		}
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "transaction-create",
+		}).Error("Api::People->PutDelegates database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	repo := r.DelegateRepository()
+
+	err = repo.DeleteByPersonID(tx, person.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "delegates-delete",
+		}).Error("Api::People->PutDelegates database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	for i, delegate := range vm.Delegates {
+		delegate.PersonID = person.ID
+		delegate.Position = uint(i)
+
+		err = repo.Create(tx, delegate)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": r.AccountScope(),
+				"person_id":  person.ID,
+				"step":       "delegate-create",
+			}).Error("Api::People->PutDelegates database error")
+
+			tx.Rollback()
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  person.ID,
+			"step":       "transaction-commit",
+		}).Error("Api::People->PutDelegates database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": r.AccountScope(),
+		"person_id":  person.ID,
+		"delegates":  len(vm.Delegates),
+	}).Info("Api::People->PutDelegates delegates updated")
+
+	audit.Person(r, session, doorbot.EventPersonUpdated, person)
+
+	if vm.Delegates == nil {
+		vm.Delegates = []*doorbot.Delegate{}
+	}
+
+	render.JSON(http.StatusOK, vm)
+}
+
+// validateDelegates returns the list of problems found in the delegates of a person.
+func validateDelegates(person *doorbot.Person, delegates []*doorbot.Delegate) []string {
+	var errors []string
+
+	if len(delegates) > 5 {
+		errors = append(errors, "A person cannot have more than 5 delegates.")
+	}
+
+	seen := map[uint]bool{}
+
+	for i, delegate := range delegates {
+		switch {
+		case delegate == nil || delegate.DelegateID == 0:
+			errors = append(errors, fmt.Sprintf("Delegate %d: the delegate_id is required.", i+1))
+		case delegate.DelegateID == person.ID:
+			errors = append(errors, fmt.Sprintf("Delegate %d: a person cannot be their own delegate.", i+1))
+		case seen[delegate.DelegateID]:
+			errors = append(errors, fmt.Sprintf("Delegate %d: the person is already a delegate.", i+1))
+		default:
+			seen[delegate.DelegateID] = true
+		}
+	}
+
+	return errors
+}
@@ -0,0 +1,104 @@
+package people
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"testing"
+)
+
+func TestPutDelegates(t *testing.T) {
+	render := new(tests.MockRender)
+	personRepo := new(tests.MockPersonRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
+	tx := new(tests.MockTransaction)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	params := martini.Params{
+		"id": "5",
+	}
+
+	person := &doorbot.Person{ID: 5}
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: person,
+	}
+
+	delegates := []*doorbot.Delegate{
+		&doorbot.Delegate{DelegateID: 7},
+		&doorbot.Delegate{DelegateID: 6},
+	}
+
+	vm := DelegatesViewModel{Delegates: delegates}
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	personRepo.On("Find", db, uint(6)).Return(&doorbot.Person{ID: 6}, nil)
+	personRepo.On("Find", db, uint(7)).Return(&doorbot.Person{ID: 7}, nil)
+	delegateRepo.On("DeleteByPersonID", tx, uint(5)).Return(nil)
+	delegateRepo.On("Create", tx, delegates[0]).Return(nil)
+	delegateRepo.On("Create", tx, delegates[1]).Return(nil)
+	tx.On("Commit").Return(nil)
+
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	render.On("JSON", http.StatusOK, vm).Return()
+
+	PutDelegates(render, repositories, params, vm, session)
+
+	assert.Equal(t, uint(5), delegates[1].PersonID)
+	assert.Equal(t, uint(1), delegates[1].Position)
+
+	render.Mock.AssertExpectations(t)
+	personRepo.Mock.AssertExpectations(t)
+	delegateRepo.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+}
+
+func TestPutDelegatesInvalid(t *testing.T) {
+	render := new(tests.MockRender)
+	personRepo := new(tests.MockPersonRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DB").Return(db)
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	vm := DelegatesViewModel{
+		Delegates: []*doorbot.Delegate{
+			&doorbot.Delegate{DelegateID: 5},
+			&doorbot.Delegate{DelegateID: 6},
+			&doorbot.Delegate{DelegateID: 6},
+			&doorbot.Delegate{},
+		},
+	}
+
+	personRepo.On("Find", db, uint(5)).Return(&doorbot.Person{ID: 5}, nil)
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{
+		"Delegate 1: a person cannot be their own delegate.",
+		"Delegate 3: the person is already a delegate.",
+		"Delegate 4: the delegate_id is required.",
+	})).Return()
+
+	PutDelegates(render, repositories, martini.Params{"id": "5"}, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	personRepo.Mock.AssertExpectations(t)
+}
@@ -3,13 +3,20 @@ package people
 
 import (
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/api/teams"
 	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/audit"
+	"github.com/masom/doorbot/doorbot/services/availability"
 	"github.com/masom/doorbot/doorbot/services/bridges"
+	"github.com/masom/doorbot/doorbot/services/notifications/webhook"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/render"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 )
 
 // PublicPerson represents publicly visible person data
@@ -18,6 +25,10 @@ type PublicPerson struct {
 	Name        string `json:"name"`
 	Email       string `json:"email"`
 	IsAvailable bool   `json:"is_available"`
+
+	// Set while the person is away, see SetAvailability
+	BackAt      *time.Time `json:"back_at,omitempty"`
+	AwayMessage string     `json:"away_message,omitempty"`
 }
 
 // PeopleViewModel represents a list of people
@@ -30,29 +41,39 @@ type PersonViewModel struct {
 	Person *doorbot.Person `json:"person"`
 }
 
+// EscalationPolicyViewModel represents the escalation policy of a person
+type EscalationPolicyViewModel struct {
+	EscalationPolicy []*doorbot.EscalationStep `json:"escalation_policy"`
+}
+
 // PublicPersonViewModel represents a publicly visibile person data
 type PublicPersonViewModel struct {
 	Person *PublicPerson `json:"person"`
 }
 
-// PublicPeopleViewModel represents a list of publicly visible people
+// PublicPeopleViewModel represents a list of publicly visible people, along with the teams visitors can ask for
 type PublicPeopleViewModel struct {
-	People []*PublicPerson `json:"people"`
+	People []*PublicPerson     `json:"people"`
+	Teams  []*teams.PublicTeam `json:"teams"`
 }
 
-// Transform a list of poeople into a public version of the data
-func newPublicPeople(people []*doorbot.Person) []*PublicPerson {
+// Transform a list of poeople into a public version of the data, along with their current availability
+func newPublicPeople(people []*doorbot.Person, statuses map[uint]*availability.Status) []*PublicPerson {
 	publicPeople := make([]*PublicPerson, len(people))
 
 	for i, p := range people {
-		publicPeople[i] = newPublicPerson(p)
+		publicPeople[i] = NewPublicPerson(p)
+
+		if status, ok := statuses[p.ID]; ok {
+			publicPeople[i].SetAvailability(status)
+		}
 	}
 
 	return publicPeople
 }
 
-// Transform a person into a public version of the data
-func newPublicPerson(p *doorbot.Person) *PublicPerson {
+// NewPublicPerson transforms a person into a public version of the data
+func NewPublicPerson(p *doorbot.Person) *PublicPerson {
 	return &PublicPerson{
 		ID:          p.ID,
 		Name:        p.Name,
@@ -61,6 +82,13 @@ func newPublicPerson(p *doorbot.Person) *PublicPerson {
 	}
 }
 
+// SetAvailability replaces the availability flag with the availability computed from the person schedule
+func (p *PublicPerson) SetAvailability(s *availability.Status) {
+	p.IsAvailable = s.IsAvailable
+	p.BackAt = s.BackAt
+	p.AwayMessage = s.Message
+}
+
 // Index returns people
 func Index(render render.Render, r doorbot.Repositories, session *auth.Authorization) {
 	repo := r.PersonRepository()
@@ -77,19 +105,51 @@ func Index(render render.Render, r doorbot.Repositories, session *auth.Authoriza
 		return
 	}
 
-	switch session.Type {
-	case auth.AuthorizationAdministrator:
+	if session.Type == auth.AuthorizationAdministrator || (session.Type == auth.AuthorizationPerson && session.Person.IsAccountManager()) {
 		render.JSON(http.StatusOK, PeopleViewModel{People: people})
-	case auth.AuthorizationDevice:
-		render.JSON(http.StatusOK, PublicPeopleViewModel{People: newPublicPeople(people)})
-	case auth.AuthorizationPerson:
-		if session.Person.IsAccountManager() {
-			render.JSON(http.StatusOK, PeopleViewModel{People: people})
-			return
-		}
+		return
+	}
+
+	statuses, err := availability.People(r, people)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "availability-find",
+		}).Error("Api::People->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	allTeams, err := r.TeamRepository().All(r.DB())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "teams-find",
+		}).Error("Api::People->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
 
-		render.JSON(http.StatusOK, PublicPeopleViewModel{People: newPublicPeople(people)})
+	members, err := r.TeamMemberRepository().All(r.DB())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "team-members-find",
+		}).Error("Api::People->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
 	}
+
+	render.JSON(http.StatusOK, PublicPeopleViewModel{
+		People: newPublicPeople(people, statuses),
+		Teams:  teams.NewPublicTeams(allTeams, members, statuses),
+	})
 }
 
 // Get a specific person
@@ -124,9 +184,9 @@ func Get(render render.Render, r doorbot.Repositories, params martini.Params, se
 	switch session.Type {
 	case auth.AuthorizationAdministrator:
 		render.JSON(http.StatusOK, PersonViewModel{Person: person})
+		return
 
 	case auth.AuthorizationDevice:
-		render.JSON(http.StatusOK, PublicPersonViewModel{Person: newPublicPerson(person)})
 
 	case auth.AuthorizationPerson:
 		// Display detailed info if the requesting user is an account manager or it is the same person
@@ -135,14 +195,32 @@ func Get(render render.Render, r doorbot.Repositories, params martini.Params, se
 			return
 		}
 
-		render.JSON(http.StatusOK, PublicPersonViewModel{Person: newPublicPerson(person)})
 	default:
 		render.Status(http.StatusForbidden)
+		return
 	}
+
+	status, err := availability.Person(r, person)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+			"step":       "availability-find",
+		}).Error("Api::People->Get database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	public := NewPublicPerson(person)
+	public.SetAvailability(status)
+
+	render.JSON(http.StatusOK, PublicPersonViewModel{Person: public})
 }
 
 // Post creates a new person
-func Post(render render.Render, r doorbot.Repositories, vm PersonViewModel) {
+func Post(render render.Render, r doorbot.Repositories, vm PersonViewModel, session *auth.Authorization) {
 
 	repo := r.PersonRepository()
 
@@ -162,6 +240,8 @@ func Post(render render.Render, r doorbot.Repositories, vm PersonViewModel) {
 		"person_id":  vm.Person.ID,
 	}).Info("Api::People->Post person added.")
 
+	audit.Person(r, session, doorbot.EventPersonAdded, vm.Person)
+
 	render.JSON(http.StatusCreated, vm)
 }
 
@@ -223,6 +303,25 @@ func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm
 		return
 	}
 
+	switch vm.Person.NotificationsMode {
+	case "", doorbot.NotificationsModeFirst, doorbot.NotificationsModeAll, doorbot.NotificationsModeEscalate:
+	default:
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The notifications mode must be one of first, all or escalate."}))
+		return
+	}
+
+	switch vm.Person.DigestFrequency {
+	case "", doorbot.DigestNever, doorbot.DigestDaily, doorbot.DigestWeekly:
+	default:
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The digest frequency must be one of never, daily or weekly."}))
+		return
+	}
+
+	if len(vm.Person.NotificationsWebhookURL) > 0 && !webhook.PublicURL(vm.Person.NotificationsWebhookURL) {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The webhook URL must be an absolute http or https URL outside of the private network."}))
+		return
+	}
+
 	repo := r.PersonRepository()
 	person, err := repo.Find(r.DB(), uint(id))
 
@@ -252,8 +351,19 @@ func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm
 	person.NotificationsEnabled = vm.Person.NotificationsEnabled
 	person.NotificationsAppEnabled = vm.Person.NotificationsAppEnabled
 	person.NotificationsChatEnabled = vm.Person.NotificationsChatEnabled
+	person.NotificationsChatHandle = vm.Person.NotificationsChatHandle
 	person.NotificationsEmailEnabled = vm.Person.NotificationsEmailEnabled
 	person.NotificationsSMSEnabled = vm.Person.NotificationsSMSEnabled
+	person.NotificationsWebhookEnabled = vm.Person.NotificationsWebhookEnabled
+	person.NotificationsWebhookURL = vm.Person.NotificationsWebhookURL
+
+	if len(vm.Person.NotificationsMode) > 0 {
+		person.NotificationsMode = vm.Person.NotificationsMode
+	}
+
+	if len(vm.Person.DigestFrequency) > 0 {
+		person.DigestFrequency = vm.Person.DigestFrequency
+	}
 
 	if canUpdateAccountType {
 		person.AccountType = vm.Person.AccountType
@@ -278,9 +388,209 @@ func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm
 
 	log.WithFields(logFields).Info(logMessage)
 
+	audit.Person(r, session, doorbot.EventPersonUpdated, person)
+
+	render.JSON(http.StatusOK, vm)
+}
+
+// GetEscalationPolicy returns the escalation policy of a person
+func GetEscalationPolicy(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return
+	}
+
+	if !canManageNotifications(session, uint(id)) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	steps, err := r.EscalationStepRepository().FindByPersonID(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+		}).Error("Api::People->GetEscalationPolicy database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if steps == nil {
+		steps = []*doorbot.EscalationStep{}
+	}
+
+	render.JSON(http.StatusOK, EscalationPolicyViewModel{EscalationPolicy: steps})
+}
+
+// PutEscalationPolicy replaces the escalation policy of a person.
+// The policy is used when the person notifications mode is `escalate`.
+func PutEscalationPolicy(render render.Render, r doorbot.Repositories, params martini.Params, vm EscalationPolicyViewModel, session *auth.Authorization) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return
+	}
+
+	if !canManageNotifications(session, uint(id)) {
+		log.WithFields(log.Fields{
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+		}).Warn("Api::People->PutEscalationPolicy forbidden")
+
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	errors := validateEscalationPolicy(vm.EscalationPolicy)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	person, err := r.PersonRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+			"step":       "person-find",
+		}).Error("Api::People->PutEscalationPolicy database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if person == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified person does not exists"}))
+		return
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+			"step":       "transaction-create",
+		}).Error("Api::People->PutEscalationPolicy database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	repo := r.EscalationStepRepository()
+
+	err = repo.DeleteByPersonID(tx, person.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+			"step":       "escalation-steps-delete",
+		}).Error("Api::People->PutEscalationPolicy database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	for i, step := range vm.EscalationPolicy {
+		step.PersonID = person.ID
+		step.Position = uint(i)
+
+		if len(step.Recipient) == 0 {
+			step.Recipient = doorbot.EscalationRecipientPerson
+		}
+
+		err = repo.Create(tx, step)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": r.AccountScope(),
+				"person_id":  id,
+				"step":       "escalation-step-create",
+			}).Error("Api::People->PutEscalationPolicy database error")
+
+			tx.Rollback()
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+			"step":       "transaction-commit",
+		}).Error("Api::People->PutEscalationPolicy database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": r.AccountScope(),
+		"person_id":  id,
+		"steps":      len(vm.EscalationPolicy),
+	}).Info("Api::People->PutEscalationPolicy policy updated")
+
+	audit.Person(r, session, doorbot.EventPersonUpdated, person)
+
 	render.JSON(http.StatusOK, vm)
 }
 
+// canManageNotifications tells if the session can manage the notification settings of a person.
+func canManageNotifications(session *auth.Authorization, id uint) bool {
+	switch session.Type {
+	case auth.AuthorizationAdministrator:
+		return true
+	case auth.AuthorizationPerson:
+		return session.Person.ID == id || session.Person.IsAccountManager()
+	}
+
+	return false
+}
+
+// validateEscalationPolicy returns the list of problems found in an escalation policy.
+func validateEscalationPolicy(steps []*doorbot.EscalationStep) []string {
+	var errors []string
+
+	if len(steps) > 10 {
+		errors = append(errors, "An escalation policy cannot have more than 10 steps.")
+	}
+
+	var delay uint
+
+	for i, step := range steps {
+		switch step.Channel {
+		case doorbot.NotificationChannelApp, doorbot.NotificationChannelChat, doorbot.NotificationChannelSMS, doorbot.NotificationChannelEmail, doorbot.NotificationChannelWebhook:
+		default:
+			errors = append(errors, fmt.Sprintf("Step %d: the channel must be one of app, chat, sms, email or webhook.", i+1))
+		}
+
+		switch step.Recipient {
+		case "", doorbot.EscalationRecipientPerson, doorbot.EscalationRecipientAccountContact:
+		default:
+			errors = append(errors, fmt.Sprintf("Step %d: the recipient must be either person or account_contact.", i+1))
+		}
+
+		if step.Delay < delay {
+			errors = append(errors, fmt.Sprintf("Step %d: the delay cannot be shorter than the previous step delay.", i+1))
+		}
+
+		delay = step.Delay
+	}
+
+	return errors
+}
+
 // Delete a person
 func Delete(render render.Render, r doorbot.Repositories, params martini.Params, a *doorbot.Account, session *auth.Authorization) {
 	id, err := strconv.ParseUint(params["id"], 10, 32)
@@ -368,6 +678,8 @@ func Delete(render render.Render, r doorbot.Repositories, params martini.Params,
 
 	log.WithFields(logFields).Info(logMessage)
 
+	audit.Person(r, session, doorbot.EventPersonRemoved, person)
+
 	render.Status(http.StatusNoContent)
 }
 
@@ -378,6 +690,9 @@ func Sync(render render.Render, r doorbot.Repositories, b bridges.Bridges, a *do
 	var registered []*doorbot.BridgeUser
 	var err error
 
+	// Bridges that answered, their teams are reconciled even when they returned no users.
+	var fetched []uint
+
 	personRepo := r.PersonRepository()
 	bUserRepo := r.BridgeUserRepository()
 
@@ -415,6 +730,7 @@ func Sync(render render.Render, r doorbot.Repositories, b bridges.Bridges, a *do
 
 			registered = append(registered, existing...)
 			bUsers = append(bUsers, users...)
+			fetched = append(fetched, bridgeId)
 
 			return true;
 		}
@@ -436,6 +752,7 @@ func Sync(render render.Render, r doorbot.Repositories, b bridges.Bridges, a *do
 	}
 
 	var buser *doorbot.BridgeUser
+	var added, updated []*doorbot.Person
 
 	for _, u := range bUsers {
 		log.WithFields(log.Fields{
@@ -492,6 +809,8 @@ func Sync(render render.Render, r doorbot.Repositories, b bridges.Bridges, a *do
 				"person_id":      buser.PersonID,
 			}).Info("Api::People->Sync person updated from bridge data")
 
+			u.PersonID = buser.PersonID
+			updated = append(updated, person)
 			continue
 		}
 
@@ -534,9 +853,17 @@ func Sync(render render.Render, r doorbot.Repositories, b bridges.Bridges, a *do
 
 			break
 		}
+
+		added = append(added, person)
 		continue
 	}
 
+	var synced []*doorbot.Team
+
+	if err == nil {
+		synced, err = syncTeams(r, tx, fetched, bUsers)
+	}
+
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error":      err,
@@ -565,6 +892,18 @@ func Sync(render render.Render, r doorbot.Repositories, b bridges.Bridges, a *do
 		"account_id": r.AccountScope(),
 	}).Info("Api::People->Sync bridge sync completed.")
 
+	for _, p := range added {
+		audit.Person(r, session, doorbot.EventPersonAdded, p)
+	}
+
+	for _, p := range updated {
+		audit.Person(r, session, doorbot.EventPersonUpdated, p)
+	}
+
+	for _, t := range synced {
+		audit.Team(r, session, doorbot.EventTeamAdded, t)
+	}
+
 	render.Status(http.StatusNoContent)
 }
 
@@ -578,3 +917,89 @@ func findRegistered(users []*doorbot.BridgeUser, id string) *doorbot.BridgeUser
 
 	return nil
 }
+
+// syncTeams mirrors the bridge groups as teams, replacing their members with the synced people.
+// Teams whose group no longer has any member are emptied. The teams created are returned.
+func syncTeams(r doorbot.Repositories, tx doorbot.Transaction, bridgeIDs []uint, users []*doorbot.BridgeUser) ([]*doorbot.Team, error) {
+	var created []*doorbot.Team
+
+	groups := map[uint]map[string][]uint{}
+
+	for _, bridgeID := range bridgeIDs {
+		groups[bridgeID] = map[string][]uint{}
+	}
+
+	for _, u := range users {
+		if groups[u.BridgeID] == nil {
+			continue
+		}
+
+		for _, name := range u.Groups {
+			if len(name) > 0 && u.PersonID > 0 {
+				groups[u.BridgeID][name] = append(groups[u.BridgeID][name], u.PersonID)
+			}
+		}
+	}
+
+	teamRepo := r.TeamRepository()
+	memberRepo := r.TeamMemberRepository()
+
+	for bridgeID, members := range groups {
+		existing, err := teamRepo.FindByBridgeID(tx, bridgeID)
+		if err != nil {
+			return nil, err
+		}
+
+		byName := map[string]*doorbot.Team{}
+		for _, t := range existing {
+			byName[t.Name] = t
+		}
+
+		// Sorted to create the teams in a stable order
+		names := make([]string, 0, len(members))
+		for name := range members {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			if byName[name] != nil {
+				continue
+			}
+
+			team := doorbot.NewTeam(r.AccountScope(), name)
+			team.BridgeID = bridgeID
+
+			err = teamRepo.Create(tx, team)
+			if err != nil {
+				return nil, err
+			}
+
+			byName[name] = team
+			created = append(created, team)
+		}
+
+		for name, team := range byName {
+			err = memberRepo.DeleteByTeamID(tx, team.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, personID := range members[name] {
+				err = memberRepo.Create(tx, &doorbot.TeamMember{TeamID: team.ID, PersonID: personID, Position: uint(i)})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		log.WithFields(log.Fields{
+			"account_id": r.AccountScope(),
+			"bridge_id":  bridgeID,
+			"teams":      len(byName),
+		}).Info("Api::People->Sync teams synced from bridge groups")
+	}
+
+	return created, nil
+}
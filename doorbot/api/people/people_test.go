@@ -8,6 +8,7 @@ import (
 	"errors"
 	"github.com/go-martini/martini"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"testing"
 )
@@ -176,10 +177,12 @@ func TestPost(t *testing.T) {
 	repo := new(tests.MockPersonRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("PersonRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(0))
 
 	person := &doorbot.Person{
@@ -188,11 +191,16 @@ func TestPost(t *testing.T) {
 
 	repo.On("Create", db, person).Return(nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("JSON", http.StatusCreated, PersonViewModel{Person: person}).Return()
 
-	Post(render, repositories, PersonViewModel{Person: person})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Post(render, repositories, PersonViewModel{Person: person}, session)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -216,7 +224,9 @@ func TestPostCreateError(t *testing.T) {
 
 	render.On("JSON", http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{})).Return()
 
-	Post(render, repositories, PersonViewModel{Person: person})
+	session := &auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 7, AccountType: doorbot.AccountOwner}}
+
+	Post(render, repositories, PersonViewModel{Person: person}, session)
 
 	render.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
@@ -228,10 +238,12 @@ func TestPut(t *testing.T) {
 	repo := new(tests.MockPersonRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("PersonRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	params := martini.Params{
@@ -256,6 +268,8 @@ func TestPut(t *testing.T) {
 	repo.On("Find", db, uint(5555)).Return(repoPerson, nil)
 	repo.On("Update", db, repoPerson).Return(true, nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("JSON", http.StatusOK, PersonViewModel{Person: repoPerson}).Return()
 
 	Put(render, repositories, params, PersonViewModel{Person: postPerson}, session)
@@ -263,6 +277,7 @@ func TestPut(t *testing.T) {
 	assert.Equal(t, "Romanian Landlords", repoPerson.Name)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -371,15 +386,220 @@ func TestPutFailed(t *testing.T) {
 	repositories.Mock.AssertExpectations(t)
 }
 
+func TestPutInvalidNotificationsMode(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountScope").Return(uint(1))
+
+	params := martini.Params{
+		"id": "5",
+	}
+
+	postPerson := &doorbot.Person{
+		Name:              "Chicken Nick",
+		NotificationsMode: "carrier-pigeon",
+	}
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationPerson,
+		Person: &doorbot.Person{
+			ID: 5,
+		},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The notifications mode must be one of first, all or escalate."})).Return()
+
+	Put(render, repositories, params, PersonViewModel{postPerson}, session)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestPutInvalidDigestFrequency(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountScope").Return(uint(1))
+
+	params := martini.Params{
+		"id": "5",
+	}
+
+	postPerson := &doorbot.Person{
+		Name:            "Chicken Nick",
+		DigestFrequency: "hourly",
+	}
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationPerson,
+		Person: &doorbot.Person{
+			ID: 5,
+		},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The digest frequency must be one of never, daily or weekly."})).Return()
+
+	Put(render, repositories, params, PersonViewModel{postPerson}, session)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestPutInvalidWebhookURL(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountScope").Return(uint(1))
+
+	params := martini.Params{
+		"id": "5",
+	}
+
+	postPerson := &doorbot.Person{
+		Name:                    "Chicken Nick",
+		NotificationsWebhookURL: "ftp://example.com/knock",
+	}
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationPerson,
+		Person: &doorbot.Person{
+			ID: 5,
+		},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The webhook URL must be an absolute http or https URL outside of the private network."})).Return()
+
+	Put(render, repositories, params, PersonViewModel{postPerson}, session)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestGetEscalationPolicyForbidden(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	params := martini.Params{
+		"id": "33",
+	}
+
+	session := &auth.Authorization{
+		Type: auth.AuthorizationPerson,
+		Person: &doorbot.Person{
+			ID:          5,
+			AccountType: doorbot.AccountMember,
+		},
+	}
+
+	render.On("Status", http.StatusForbidden).Return()
+
+	GetEscalationPolicy(render, repositories, params, session)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestPutEscalationPolicy(t *testing.T) {
+	render := new(tests.MockRender)
+	personRepo := new(tests.MockPersonRepository)
+	stepRepo := new(tests.MockEscalationStepRepository)
+
+	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
+	tx := new(tests.MockTransaction)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("EscalationStepRepository").Return(stepRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	params := martini.Params{
+		"id": "5",
+	}
+
+	person := &doorbot.Person{
+		ID: 5,
+	}
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: person,
+	}
+
+	steps := []*doorbot.EscalationStep{
+		&doorbot.EscalationStep{Channel: doorbot.NotificationChannelChat},
+		&doorbot.EscalationStep{Channel: doorbot.NotificationChannelSMS, Delay: 60},
+		&doorbot.EscalationStep{Channel: doorbot.NotificationChannelEmail, Delay: 120, Recipient: doorbot.EscalationRecipientAccountContact},
+	}
+
+	vm := EscalationPolicyViewModel{EscalationPolicy: steps}
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	stepRepo.On("DeleteByPersonID", tx, uint(5)).Return(nil)
+	stepRepo.On("Create", tx, steps[0]).Return(nil)
+	stepRepo.On("Create", tx, steps[1]).Return(nil)
+	stepRepo.On("Create", tx, steps[2]).Return(nil)
+	tx.On("Commit").Return(nil)
+
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	render.On("JSON", http.StatusOK, vm).Return()
+
+	PutEscalationPolicy(render, repositories, params, vm, session)
+
+	assert.Equal(t, uint(2), steps[2].Position)
+	assert.Equal(t, uint(5), steps[2].PersonID)
+	assert.Equal(t, doorbot.EscalationRecipientPerson, steps[0].Recipient)
+
+	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+	personRepo.Mock.AssertExpectations(t)
+	stepRepo.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestPutEscalationPolicyInvalid(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	params := martini.Params{
+		"id": "5",
+	}
+
+	session := &auth.Authorization{
+		Type:          auth.AuthorizationAdministrator,
+		Administrator: &doorbot.Administrator{},
+	}
+
+	steps := []*doorbot.EscalationStep{
+		&doorbot.EscalationStep{Channel: doorbot.NotificationChannelSMS, Delay: 60},
+		&doorbot.EscalationStep{Channel: "fax", Delay: 30},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{
+		"Step 2: the channel must be one of app, chat, sms, email or webhook.",
+		"Step 2: the delay cannot be shorter than the previous step delay.",
+	})).Return()
+
+	PutEscalationPolicy(render, repositories, params, EscalationPolicyViewModel{EscalationPolicy: steps}, session)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
 func TestDelete(t *testing.T) {
 	render := new(tests.MockRender)
 	repo := new(tests.MockPersonRepository)
 
 	db := new(tests.MockExecutor)
+	eventRepo := new(tests.MockEventRepository)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("PersonRepository").Return(repo)
 	repositories.On("DB").Return(db)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("AccountScope").Return(uint(1))
 
 	params := martini.Params{
@@ -402,11 +622,14 @@ func TestDelete(t *testing.T) {
 	repo.On("Find", db, uint(33)).Return(person, nil)
 	repo.On("Delete", db, person).Return(true, nil)
 
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
 	render.On("Status", http.StatusNoContent).Return()
 
 	Delete(render, repositories, params, account, session)
 
 	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
 	repo.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 }
@@ -515,12 +738,18 @@ func TestDeleteFailed(t *testing.T) {
 func TestSync(t *testing.T) {
 	personRepo := new(tests.MockPersonRepository)
 	bridgeUserRepo := new(tests.MockBridgeUserRepository)
+	teamRepo := new(tests.MockTeamRepository)
+	memberRepo := new(tests.MockTeamMemberRepository)
+	eventRepo := new(tests.MockEventRepository)
 
 	db := new(tests.MockExecutor)
 
 	repositories := new(tests.MockRepositories)
 	repositories.On("PersonRepository").Return(personRepo)
 	repositories.On("BridgeUserRepository").Return(bridgeUserRepo)
+	repositories.On("TeamRepository").Return(teamRepo)
+	repositories.On("TeamMemberRepository").Return(memberRepo)
+	repositories.On("EventRepository").Return(eventRepo)
 	repositories.On("DB").Return(db)
 	repositories.On("AccountScope").Return(uint(1))
 
@@ -538,12 +767,14 @@ func TestSync(t *testing.T) {
 
 	bridgeUsers := make([]*doorbot.BridgeUser, 2)
 	bridgeUsers[0] = &doorbot.BridgeUser{
+		BridgeID: bridges.BridgeHub,
 		UserID: "34",
 		Name:   "Bob",
 		Email:  "joe+bob@example.com",
 	}
 
 	bridgeUsers[1] = &doorbot.BridgeUser{
+		BridgeID: bridges.BridgeHub,
 		UserID: "35",
 		Name:   "Rambo",
 		Email:  "rambo@example.com",
@@ -569,20 +800,75 @@ func TestSync(t *testing.T) {
 
 	bs := new(tests.MockBridges)
 	bs.On("GetUsers", bridges.BridgeHub).Return(bridgeUsers, nil)
+	bs.On("GetUsers", bridges.BridgeHipChat).Return([]*doorbot.BridgeUser{}, nil)
 
 	personRepo.On("Find", transaction, uint(34)).Return(person, nil)
-	personRepo.On("Create", transaction, rambo).Return(nil)
+	personRepo.On("Create", transaction, mock.AnythingOfType("*doorbot.Person")).Return(nil)
 	personRepo.On("Update", transaction, person).Return(true, nil)
 
-	bridgeUserRepo.On("FindByBridgeID", db, uint(1)).Return(registeredUsers, nil)
+	bridgeUserRepo.On("FindByBridgeID", db, bridges.BridgeHub).Return(registeredUsers, nil)
+	bridgeUserRepo.On("FindByBridgeID", db, bridges.BridgeHipChat).Return([]*doorbot.BridgeUser{}, nil)
+	teamRepo.On("FindByBridgeID", transaction, bridges.BridgeHub).Return([]*doorbot.Team{}, nil)
+	// HipChat returned no users, the members of its team are removed.
+	teamRepo.On("FindByBridgeID", transaction, bridges.BridgeHipChat).Return([]*doorbot.Team{&doorbot.Team{ID: 7, Name: "Ops", BridgeID: bridges.BridgeHipChat}}, nil)
+	memberRepo.On("DeleteByTeamID", transaction, uint(7)).Return(nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
 	bridgeUserRepo.On("Create", transaction, bridgeUsers[1]).Return(nil)
 	render.On("Status", http.StatusNoContent).Return()
 
-	Sync(render, repositories, bs, session)
+	Sync(render, repositories, bs, &doorbot.Account{ID: 1}, session)
 
 	render.Mock.AssertExpectations(t)
 	repositories.Mock.AssertExpectations(t)
 	personRepo.Mock.AssertExpectations(t)
 	bridgeUserRepo.Mock.AssertExpectations(t)
+	teamRepo.Mock.AssertExpectations(t)
+	memberRepo.Mock.AssertExpectations(t)
 	bs.Mock.AssertExpectations(t)
+
+	// Joe is updated from the bridge data and Rambo is created.
+	assert.Equal(t, "Bob", person.Name)
+
+	created := personRepo.Calls[2].Arguments.Get(1).(*doorbot.Person)
+	assert.Equal(t, rambo.Name, created.Name)
+	assert.Equal(t, rambo.Email, created.Email)
+}
+
+func TestSyncTeams(t *testing.T) {
+	teamRepo := new(tests.MockTeamRepository)
+	memberRepo := new(tests.MockTeamMemberRepository)
+	transaction := new(tests.MockTransaction)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("TeamRepository").Return(teamRepo)
+	repositories.On("TeamMemberRepository").Return(memberRepo)
+	repositories.On("AccountScope").Return(uint(1))
+
+	users := []*doorbot.BridgeUser{
+		&doorbot.BridgeUser{BridgeID: bridges.BridgeHub, PersonID: 34, Groups: []string{"Sales", "IT"}},
+		&doorbot.BridgeUser{BridgeID: bridges.BridgeHub, PersonID: 35, Groups: []string{"IT"}},
+	}
+
+	// IT already exists, Sales is created and Legal no longer has members.
+	it := &doorbot.Team{ID: 3, Name: "IT", BridgeID: bridges.BridgeHub}
+	legal := &doorbot.Team{ID: 4, Name: "Legal", BridgeID: bridges.BridgeHub}
+
+	teamRepo.On("FindByBridgeID", transaction, bridges.BridgeHub).Return([]*doorbot.Team{it, legal}, nil)
+	teamRepo.On("Create", transaction, mock.AnythingOfType("*doorbot.Team")).Return(nil)
+	memberRepo.On("DeleteByTeamID", transaction, uint(0)).Return(nil)
+	memberRepo.On("DeleteByTeamID", transaction, uint(3)).Return(nil)
+	memberRepo.On("DeleteByTeamID", transaction, uint(4)).Return(nil)
+	memberRepo.On("Create", transaction, &doorbot.TeamMember{TeamID: 3, PersonID: 34}).Return(nil)
+	memberRepo.On("Create", transaction, &doorbot.TeamMember{TeamID: 3, PersonID: 35, Position: 1}).Return(nil)
+	memberRepo.On("Create", transaction, &doorbot.TeamMember{TeamID: 0, PersonID: 34}).Return(nil)
+
+	created, err := syncTeams(repositories, transaction, []uint{bridges.BridgeHub}, users)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(created))
+	assert.Equal(t, "Sales", created[0].Name)
+	assert.Equal(t, bridges.BridgeHub, created[0].BridgeID)
+
+	teamRepo.Mock.AssertExpectations(t)
+	memberRepo.Mock.AssertExpectations(t)
 }
@@ -0,0 +1,212 @@
+package people
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+)
+
+// PushDevicesViewModel represents the push devices of a person
+type PushDevicesViewModel struct {
+	PushDevices []*doorbot.PushDevice `json:"push_devices"`
+}
+
+// PushDeviceViewModel represents a push device
+type PushDeviceViewModel struct {
+	PushDevice *doorbot.PushDevice `json:"push_device"`
+}
+
+// GetPushDevices returns the push devices registered by a person
+func GetPushDevices(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return
+	}
+
+	if !canManageNotifications(session, uint(id)) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	devices, err := r.PushDeviceRepository().FindByPersonID(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+		}).Error("Api::People->GetPushDevices database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if devices == nil {
+		devices = []*doorbot.PushDevice{}
+	}
+
+	render.JSON(http.StatusOK, PushDevicesViewModel{PushDevices: devices})
+}
+
+// PostPushDevice registers a companion app installation of a person.
+// Registering a known push token moves the device to the person and enables it again.
+func PostPushDevice(render render.Render, r doorbot.Repositories, params martini.Params, vm PushDeviceViewModel, session *auth.Authorization) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return
+	}
+
+	if !canManageNotifications(session, uint(id)) {
+		log.WithFields(log.Fields{
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+		}).Warn("Api::People->PostPushDevice forbidden")
+
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	if vm.PushDevice == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The push device is required."}))
+		return
+	}
+
+	var errors []string
+
+	switch vm.PushDevice.Platform {
+	case doorbot.PushPlatformAPNs, doorbot.PushPlatformFCM:
+	default:
+		errors = append(errors, "The platform must be either apns or fcm.")
+	}
+
+	if len(vm.PushDevice.Token) == 0 {
+		errors = append(errors, "The push token is required.")
+	}
+
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	repo := r.PushDeviceRepository()
+
+	device, err := repo.FindByToken(r.DB(), vm.PushDevice.Token)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+			"step":       "push-device-find",
+		}).Error("Api::People->PostPushDevice database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	status := http.StatusOK
+
+	if device == nil {
+		status = http.StatusCreated
+		device = &doorbot.PushDevice{Token: vm.PushDevice.Token}
+	}
+
+	device.PersonID = uint(id)
+	device.Platform = vm.PushDevice.Platform
+	device.AppVersion = vm.PushDevice.AppVersion
+	device.IsEnabled = true
+
+	if status == http.StatusCreated {
+		err = repo.Create(r.DB(), device)
+	} else {
+		_, err = repo.Update(r.DB(), device)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  id,
+			"step":       "push-device-save",
+		}).Error("Api::People->PostPushDevice database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":     r.AccountScope(),
+		"person_id":      id,
+		"push_device_id": device.ID,
+		"platform":       device.Platform,
+	}).Info("Api::People->PostPushDevice device registered")
+
+	render.JSON(status, PushDeviceViewModel{PushDevice: device})
+}
+
+// DeletePushDevice unregisters a push device of a person
+func DeletePushDevice(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return
+	}
+
+	deviceID, err := strconv.ParseUint(params["device_id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The device id must be an unsigned integer"}))
+		return
+	}
+
+	if !canManageNotifications(session, uint(id)) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	repo := r.PushDeviceRepository()
+
+	device, err := repo.Find(r.DB(), uint(deviceID))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err,
+			"account_id":     r.AccountScope(),
+			"person_id":      id,
+			"push_device_id": deviceID,
+			"step":           "push-device-find",
+		}).Error("Api::People->DeletePushDevice database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if device == nil || device.PersonID != uint(id) {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified push device does not exists"}))
+		return
+	}
+
+	_, err = repo.Delete(r.DB(), device)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err,
+			"account_id":     r.AccountScope(),
+			"person_id":      id,
+			"push_device_id": deviceID,
+			"step":           "push-device-delete",
+		}).Error("Api::People->DeletePushDevice database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":     r.AccountScope(),
+		"person_id":      id,
+		"push_device_id": deviceID,
+	}).Info("Api::People->DeletePushDevice device removed")
+
+	render.Status(http.StatusNoContent)
+}
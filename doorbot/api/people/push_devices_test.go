@@ -0,0 +1,86 @@
+package people
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"testing"
+)
+
+func TestPostPushDevice(t *testing.T) {
+	render := new(tests.MockRender)
+	repo := new(tests.MockPushDeviceRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PushDeviceRepository").Return(repo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 5, AccountType: doorbot.AccountMember},
+	}
+
+	vm := PushDeviceViewModel{
+		PushDevice: &doorbot.PushDevice{Platform: doorbot.PushPlatformFCM, Token: "android-token", AppVersion: "1.2.0"},
+	}
+
+	repo.On("FindByToken", db, "android-token").Return((*doorbot.PushDevice)(nil), nil)
+	repo.On("Create", db, mock.AnythingOfType("*doorbot.PushDevice")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("PushDeviceViewModel")).Return()
+
+	PostPushDevice(render, repositories, martini.Params{"id": "5"}, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+
+	device := render.Mock.Calls[0].Arguments.Get(1).(PushDeviceViewModel).PushDevice
+	assert.Equal(t, uint(5), device.PersonID)
+	assert.Equal(t, "1.2.0", device.AppVersion)
+	assert.True(t, device.IsEnabled)
+}
+
+func TestPostPushDeviceForbidden(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountScope").Return(uint(1))
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 5, AccountType: doorbot.AccountMember},
+	}
+
+	render.On("Status", http.StatusForbidden).Return()
+
+	PostPushDevice(render, repositories, martini.Params{"id": "6"}, PushDeviceViewModel{}, session)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestDeletePushDeviceOtherPerson(t *testing.T) {
+	render := new(tests.MockRender)
+	repo := new(tests.MockPushDeviceRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PushDeviceRepository").Return(repo)
+	repositories.On("DB").Return(db)
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 5, AccountType: doorbot.AccountMember},
+	}
+
+	repo.On("Find", db, uint(9)).Return(&doorbot.PushDevice{ID: 9, PersonID: 6}, nil)
+	render.On("JSON", http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified push device does not exists"})).Return()
+
+	DeletePushDevice(render, repositories, martini.Params{"id": "5", "device_id": "9"}, session)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+}
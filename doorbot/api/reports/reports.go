@@ -0,0 +1,281 @@
+// Package reports exposes the visits and response times analytics of an account.
+package reports
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatCSV is the format parameter value requesting a CSV report
+const FormatCSV = "csv"
+
+// ReportViewModel describes the report parameters
+type ReportViewModel struct {
+	Interval string    `json:"interval"`
+	GroupBy  string    `json:"group_by"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+}
+
+// VisitsViewModel wraps a visits report
+type VisitsViewModel struct {
+	ReportViewModel
+	Visits []*doorbot.VisitReport `json:"visits"`
+}
+
+// ResponseTimesViewModel wraps a response times report
+type ResponseTimesViewModel struct {
+	ReportViewModel
+	ResponseTimes []*doorbot.ResponseTimeReport `json:"response_times"`
+}
+
+// Visits reports the visits per door, device or person.
+func Visits(render render.Render, r doorbot.Repositories, req *http.Request) {
+	filter, errors := parseFilter(req, time.Now())
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	reports, err := r.ReportRepository().Visits(r.DB(), filter)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"interval":   filter.Interval,
+			"group_by":   filter.GroupBy,
+			"step":       "report-visits",
+		}).Error("Api::Reports->Visits database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if reports == nil {
+		reports = []*doorbot.VisitReport{}
+	}
+
+	if wantsCSV(req) {
+		rows := [][]string{{"period", filter.GroupBy + "_id", "visits", "visitors", "peak_hour", "average_duration"}}
+
+		for _, v := range reports {
+			rows = append(rows, []string{
+				v.Period.Format(time.RFC3339),
+				strconv.FormatUint(uint64(v.GroupID), 10),
+				strconv.FormatUint(uint64(v.Visits), 10),
+				strconv.FormatUint(uint64(v.Visitors), 10),
+				strconv.FormatUint(uint64(v.PeakHour), 10),
+				formatSeconds(v.AverageDuration),
+			})
+		}
+
+		renderCSV(render, r, "visits", rows)
+		return
+	}
+
+	render.JSON(http.StatusOK, VisitsViewModel{ReportViewModel: newReportViewModel(filter), Visits: reports})
+}
+
+// ResponseTimes reports how fast the knock-knocks are acknowledged per door, device or person.
+func ResponseTimes(render render.Render, r doorbot.Repositories, req *http.Request) {
+	filter, errors := parseFilter(req, time.Now())
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	reports, err := r.ReportRepository().ResponseTimes(r.DB(), filter)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"interval":   filter.Interval,
+			"group_by":   filter.GroupBy,
+			"step":       "report-response-times",
+		}).Error("Api::Reports->ResponseTimes database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if reports == nil {
+		reports = []*doorbot.ResponseTimeReport{}
+	}
+
+	if wantsCSV(req) {
+		rows := [][]string{{"period", filter.GroupBy + "_id", "notifications", "acknowledged", "unanswered", "median_response_time", "average_response_time"}}
+
+		for _, rt := range reports {
+			rows = append(rows, []string{
+				rt.Period.Format(time.RFC3339),
+				strconv.FormatUint(uint64(rt.GroupID), 10),
+				strconv.FormatUint(uint64(rt.Notifications), 10),
+				strconv.FormatUint(uint64(rt.Acknowledged), 10),
+				strconv.FormatUint(uint64(rt.Unanswered), 10),
+				formatSeconds(rt.MedianResponseTime),
+				formatSeconds(rt.AverageResponseTime),
+			})
+		}
+
+		renderCSV(render, r, "response-times", rows)
+		return
+	}
+
+	render.JSON(http.StatusOK, ResponseTimesViewModel{ReportViewModel: newReportViewModel(filter), ResponseTimes: reports})
+}
+
+func newReportViewModel(f *doorbot.ReportFilter) ReportViewModel {
+	return ReportViewModel{
+		Interval: f.Interval,
+		GroupBy:  f.GroupBy,
+		Since:    f.Since,
+		Until:    f.Until,
+	}
+}
+
+// parseFilter builds the report filter out of the query string. Every invalid parameter is reported.
+// The report defaults to daily buckets per door over the last 30 days.
+func parseFilter(req *http.Request, now time.Time) (*doorbot.ReportFilter, []string) {
+	query := req.URL.Query()
+	errors := []string{}
+
+	filter := &doorbot.ReportFilter{
+		Interval: doorbot.ReportIntervalDay,
+		GroupBy:  doorbot.ReportGroupDoor,
+		Until:    now,
+	}
+
+	if interval := query.Get("interval"); len(interval) > 0 {
+		switch interval {
+		case doorbot.ReportIntervalDay, doorbot.ReportIntervalWeek, doorbot.ReportIntervalMonth:
+			filter.Interval = interval
+		default:
+			errors = append(errors, "The interval must be one of day, week or month")
+		}
+	}
+
+	if group := query.Get("group_by"); len(group) > 0 {
+		switch group {
+		case doorbot.ReportGroupDoor, doorbot.ReportGroupDevice, doorbot.ReportGroupPerson:
+			filter.GroupBy = group
+		default:
+			errors = append(errors, "The group_by must be one of door, device or person")
+		}
+	}
+
+	ids := []struct {
+		name  string
+		value *uint
+	}{
+		{"door_id", &filter.DoorID},
+		{"device_id", &filter.DeviceID},
+		{"person_id", &filter.PersonID},
+	}
+
+	for _, p := range ids {
+		param := query.Get(p.name)
+		if len(param) == 0 {
+			continue
+		}
+
+		id, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			errors = append(errors, "The "+p.name+" must be an unsigned integer")
+			continue
+		}
+
+		*p.value = uint(id)
+	}
+
+	since, ok := parseTime(query.Get("since"))
+	if !ok {
+		errors = append(errors, "The since parameter must be a RFC 3339 date")
+	}
+
+	until, ok := parseTime(query.Get("until"))
+	if !ok {
+		errors = append(errors, "The until parameter must be a RFC 3339 date")
+	}
+
+	if until != nil {
+		filter.Until = *until
+	}
+
+	if since != nil {
+		filter.Since = *since
+	} else {
+		switch filter.Interval {
+		case doorbot.ReportIntervalWeek:
+			filter.Since = filter.Until.AddDate(0, 0, -7*12)
+		case doorbot.ReportIntervalMonth:
+			filter.Since = filter.Until.AddDate(-1, 0, 0)
+		default:
+			filter.Since = filter.Until.AddDate(0, 0, -30)
+		}
+	}
+
+	if len(errors) == 0 && !filter.Since.Before(filter.Until) {
+		errors = append(errors, "The since parameter must be before until")
+	}
+
+	return filter, errors
+}
+
+// parseTime parses an optional RFC 3339 date. A nil time is returned when the value is empty.
+func parseTime(value string) (*time.Time, bool) {
+	if len(value) == 0 {
+		return nil, true
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, false
+	}
+
+	return &t, true
+}
+
+// wantsCSV tells if the report was requested as CSV, either with `format=csv` or the Accept header.
+func wantsCSV(req *http.Request) bool {
+	if format := req.URL.Query().Get("format"); len(format) > 0 {
+		return format == FormatCSV
+	}
+
+	return strings.Contains(req.Header.Get("Accept"), "text/csv")
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 1, 64)
+}
+
+// renderCSV sends the rows as a CSV attachment.
+func renderCSV(render render.Render, r doorbot.Repositories, name string, rows [][]string) {
+	var buffer bytes.Buffer
+
+	w := csv.NewWriter(&buffer)
+	w.WriteAll(rows)
+
+	if err := w.Error(); err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"report":     name,
+			"step":       "csv-write",
+		}).Error("Api::Reports->renderCSV error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	render.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", name))
+	render.Data(http.StatusOK, buffer.Bytes())
+}
@@ -0,0 +1,117 @@
+// +build tests
+
+package reports
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseFilterDefaults(t *testing.T) {
+	now := time.Date(2015, 7, 23, 12, 0, 0, 0, time.UTC)
+
+	filter, errors := parseFilter(&http.Request{URL: &url.URL{RawQuery: "interval=week"}}, now)
+
+	assert.Empty(t, errors)
+	assert.Equal(t, doorbot.ReportIntervalWeek, filter.Interval)
+	assert.Equal(t, doorbot.ReportGroupDoor, filter.GroupBy)
+	assert.Equal(t, now, filter.Until)
+	assert.Equal(t, now.AddDate(0, 0, -84), filter.Since)
+}
+
+func TestParseFilterInvalid(t *testing.T) {
+	req := &http.Request{URL: &url.URL{RawQuery: "interval=year&group_by=visitor&person_id=a&since=today"}}
+
+	_, errors := parseFilter(req, time.Now())
+
+	assert.Equal(t, []string{
+		"The interval must be one of day, week or month",
+		"The group_by must be one of door, device or person",
+		"The person_id must be an unsigned integer",
+		"The since parameter must be a RFC 3339 date",
+	}, errors)
+}
+
+func TestVisits(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	reportRepo := new(tests.MockReportRepository)
+
+	req := &http.Request{URL: &url.URL{RawQuery: "group_by=person&since=2015-07-01T00:00:00Z&until=2015-07-03T00:00:00Z"}}
+
+	since := time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2015, 7, 3, 0, 0, 0, 0, time.UTC)
+
+	filter := &doorbot.ReportFilter{
+		Interval: doorbot.ReportIntervalDay,
+		GroupBy:  doorbot.ReportGroupPerson,
+		Since:    since,
+		Until:    until,
+	}
+
+	reports := []*doorbot.VisitReport{
+		&doorbot.VisitReport{Period: since, GroupID: 5, Visits: 3, Visitors: 2, PeakHour: 10, AverageDuration: 1800},
+	}
+
+	repositories.On("ReportRepository").Return(reportRepo)
+	repositories.On("DB").Return(db)
+
+	reportRepo.On("Visits", db, filter).Return(reports, nil)
+
+	render.On("JSON", http.StatusOK, VisitsViewModel{
+		ReportViewModel: ReportViewModel{Interval: "day", GroupBy: "person", Since: since, Until: until},
+		Visits:          reports,
+	}).Return()
+
+	Visits(render, repositories, req)
+
+	render.Mock.AssertExpectations(t)
+	reportRepo.Mock.AssertExpectations(t)
+}
+
+func TestResponseTimesCSV(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	reportRepo := new(tests.MockReportRepository)
+
+	req := &http.Request{
+		URL:    &url.URL{RawQuery: "interval=month&since=2015-06-01T00:00:00Z&until=2015-08-01T00:00:00Z"},
+		Header: http.Header{"Accept": []string{"text/csv"}},
+	}
+
+	june := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	filter := &doorbot.ReportFilter{
+		Interval: doorbot.ReportIntervalMonth,
+		GroupBy:  doorbot.ReportGroupDoor,
+		Since:    june,
+		Until:    time.Date(2015, 8, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	reports := []*doorbot.ResponseTimeReport{
+		&doorbot.ResponseTimeReport{Period: june, GroupID: 2, Notifications: 4, Acknowledged: 3, Unanswered: 1, MedianResponseTime: 42, AverageResponseTime: 60.5},
+	}
+
+	repositories.On("ReportRepository").Return(reportRepo)
+	repositories.On("DB").Return(db)
+
+	reportRepo.On("ResponseTimes", db, filter).Return(reports, nil)
+
+	render.On("Header").Return()
+	render.On("Data", http.StatusOK, []byte(
+		"period,door_id,notifications,acknowledged,unanswered,median_response_time,average_response_time\n"+
+			"2015-06-01T00:00:00Z,2,4,3,1,42.0,60.5\n",
+	)).Return()
+
+	ResponseTimes(render, repositories, req)
+
+	render.Mock.AssertExpectations(t)
+	reportRepo.Mock.AssertExpectations(t)
+}
@@ -3,11 +3,19 @@ package api
 
 import (
 	"github.com/masom/doorbot/doorbot/api/accounts"
+	"github.com/masom/doorbot/doorbot/api/acknowledgements"
 	"github.com/masom/doorbot/doorbot/api/auth"
 	"github.com/masom/doorbot/doorbot/api/devices"
 	"github.com/masom/doorbot/doorbot/api/doors"
+	"github.com/masom/doorbot/doorbot/api/events"
+	"github.com/masom/doorbot/doorbot/api/invitations"
 	"github.com/masom/doorbot/doorbot/api/notifications"
 	"github.com/masom/doorbot/doorbot/api/people"
+	"github.com/masom/doorbot/doorbot/api/reports"
+	"github.com/masom/doorbot/doorbot/api/stream"
+	"github.com/masom/doorbot/doorbot/api/teams"
+	"github.com/masom/doorbot/doorbot/api/visits"
+	"github.com/masom/doorbot/doorbot/api/webhooks"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/binding"
 	"github.com/martini-contrib/render"
@@ -37,6 +45,13 @@ func BindRoutes(r martini.Router) {
 		})
 	}, GatekeeperRouteHandler(), AccountScopeHandler(), RepositoryScopeHandler())
 
+	// Acknowledgements ( signed links and chat callbacks )
+	r.Group("/api/acknowledgements", func(r martini.Router) {
+		r.Post("/slack", acknowledgements.Slack)
+		r.Get("/:id", acknowledgements.Confirm)
+		r.Post("/:id", acknowledgements.Link)
+	}, AccountScopeHandler(), RepositoryScopeHandler())
+
 	//ADMIN
 	r.Group("/api", func(r martini.Router) {
 		r.Get("/accounts", accounts.Index)
@@ -60,6 +75,8 @@ func BindRoutes(r martini.Router) {
 			r.Delete("/:id", devices.Delete)
 		})
 
+		r.Get("/events", events.Index)
+
 		r.Get("/doors", doors.Index)
 		r.Post("/doors", binding.Bind(doors.DoorViewModel{}), doors.Post)
 		r.Group("/doors", func(r martini.Router) {
@@ -68,12 +85,44 @@ func BindRoutes(r martini.Router) {
 			r.Delete("/:id", doors.Delete)
 		})
 
+		r.Post("/notifications/test", NotificatorHandler(), binding.Bind(notifications.TestViewModel{}), notifications.Test)
+
+		r.Get("/notifications/templates", notifications.GetTemplates)
+		r.Post("/notifications/templates/preview", binding.Bind(notifications.TemplateViewModel{}), notifications.PreviewTemplate)
+		r.Post("/notifications/templates", binding.Bind(notifications.TemplateViewModel{}), notifications.PostTemplate)
+		r.Group("/notifications/templates", func(r martini.Router) {
+			r.Get("/:id", notifications.GetTemplate)
+			r.Put("/:id", binding.Bind(notifications.TemplateViewModel{}), notifications.PutTemplate)
+			r.Delete("/:id", notifications.DeleteTemplate)
+		})
+
+		r.Group("/reports", func(r martini.Router) {
+			r.Get("/visits", reports.Visits)
+			r.Get("/response-times", reports.ResponseTimes)
+		})
+
 		r.Post("/people", binding.Bind(people.PersonViewModel{}), people.Post)
 		r.Group("/people", func(r martini.Router) {
 			r.Post("/sync", BridgeHandler(), people.Sync)
 			r.Delete("/:id", people.Delete)
 		})
 
+		r.Post("/teams", binding.Bind(teams.TeamViewModel{}), teams.Post)
+		r.Group("/teams", func(r martini.Router) {
+			r.Put("/:id", binding.Bind(teams.TeamViewModel{}), teams.Put)
+			r.Delete("/:id", teams.Delete)
+		})
+
+		r.Get("/webhooks", webhooks.Index)
+		r.Post("/webhooks", binding.Bind(webhooks.WebhookViewModel{}), webhooks.Post)
+		r.Group("/webhooks", func(r martini.Router) {
+			r.Get("/:id", webhooks.Get)
+			r.Put("/:id", binding.Bind(webhooks.WebhookViewModel{}), webhooks.Put)
+			r.Delete("/:id", webhooks.Delete)
+			r.Get("/:id/deliveries", webhooks.Deliveries)
+			r.Post("/:id/deliveries/:delivery_id/redeliver", webhooks.Redeliver)
+		})
+
 	}, AccountScopeHandler(), RepositoryScopeHandler(), SecuredRouteHandler(), ManagerRestrictedRouteHandler())
 
 	//Account
@@ -82,12 +131,51 @@ func BindRoutes(r martini.Router) {
 			r.Get("/:id", accounts.Get)
 		})
 
+		r.Get("/invitations", invitations.Index)
+		r.Post("/invitations", NotificatorHandler(), binding.Bind(invitations.RequestViewModel{}), invitations.Post)
+		r.Group("/invitations", func(r martini.Router) {
+			r.Get("/code/:code", invitations.Lookup)
+			r.Post("/code/:code/check_in", NotificatorHandler(), invitations.CheckIn)
+			r.Get("/:id", invitations.Get)
+			r.Delete("/:id", invitations.Delete)
+		})
+
+		r.Get("/notifications", notifications.Index)
 		r.Post("/notifications", NotificatorHandler(), binding.Bind(notifications.ViewModel{}), notifications.Notify)
+		r.Group("/notifications", func(r martini.Router) {
+			r.Get("/:id", notifications.Get)
+			r.Post("/:id/acknowledge", binding.Bind(notifications.AcknowledgementViewModel{}), notifications.Acknowledge)
+		})
 
 		r.Get("/people", people.Index)
 		r.Group("/people", func(r martini.Router) {
 			r.Get("/:id", people.Get)
 			r.Put("/:id", binding.Bind(people.PersonViewModel{}), people.Put)
+			r.Get("/:id/escalation_policy", people.GetEscalationPolicy)
+			r.Put("/:id/escalation_policy", binding.Bind(people.EscalationPolicyViewModel{}), people.PutEscalationPolicy)
+			r.Get("/:id/delegates", people.GetDelegates)
+			r.Put("/:id/delegates", binding.Bind(people.DelegatesViewModel{}), people.PutDelegates)
+			r.Get("/:id/push_devices", people.GetPushDevices)
+			r.Post("/:id/push_devices", binding.Bind(people.PushDeviceViewModel{}), people.PostPushDevice)
+			r.Delete("/:id/push_devices/:device_id", people.DeletePushDevice)
+			r.Get("/:id/working_hours", people.GetWorkingHours)
+			r.Put("/:id/working_hours", binding.Bind(people.WorkingHoursViewModel{}), people.PutWorkingHours)
+			r.Get("/:id/out_of_office", people.GetOutOfOffice)
+			r.Post("/:id/out_of_office", binding.Bind(people.OutOfOfficeViewModel{}), people.PostOutOfOffice)
+			r.Put("/:id/out_of_office/:period_id", binding.Bind(people.OutOfOfficeViewModel{}), people.PutOutOfOffice)
+			r.Delete("/:id/out_of_office/:period_id", people.DeleteOutOfOffice)
+		})
+
+		r.Get("/stream", stream.Index)
+
+		r.Get("/teams", teams.Index)
+		r.Get("/teams/:id", teams.Get)
+
+		r.Get("/visits", visits.Index)
+		r.Post("/visits", NotificatorHandler(), binding.Bind(visits.CheckInViewModel{}), visits.Post)
+		r.Group("/visits", func(r martini.Router) {
+			r.Get("/:id", visits.Get)
+			r.Post("/:id/checkout", visits.Checkout)
 		})
 
 	}, AccountScopeHandler(), RepositoryScopeHandler(), SecuredRouteHandler())
@@ -2,11 +2,36 @@ package api
 
 import (
 	"github.com/martini-contrib/render"
+	"github.com/masom/doorbot/doorbot/services/notifications"
 	"net/http"
 )
 
+const (
+	// StatusOK every notification provider is healthy
+	StatusOK = "ok"
+	// StatusDegraded at least one notification provider circuit is open, knock-knocks fail over to the next provider
+	StatusDegraded = "degraded"
+)
+
+// StatusViewModel reports the server health
+type StatusViewModel struct {
+	Status    string                          `json:"status"`
+	Providers []*notifications.ProviderHealth `json:"providers"`
+}
+
+// Status reports the health of the notification providers as seen by this server.
+// The server keeps answering 200 while degraded since knock-knocks are still delivered through the remaining providers.
 func Status(render render.Render) {
+	vm := StatusViewModel{
+		Status:    StatusOK,
+		Providers: notifications.Breakers.Health(),
+	}
+
+	for _, provider := range vm.Providers {
+		if provider.State != notifications.CircuitClosed {
+			vm.Status = StatusDegraded
+		}
+	}
 
-	//TODO setup a way to determine if the status should be different than 200
-	render.Status(http.StatusOK)
+	render.JSON(http.StatusOK, vm)
 }
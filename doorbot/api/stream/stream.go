@@ -0,0 +1,228 @@
+// Package stream pushes the changes made on an account to the lobby devices using server-sent events.
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/api/people"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/availability"
+	"github.com/masom/doorbot/doorbot/services/realtime"
+	log "github.com/Sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// Heartbeat is the delay between two keep-alive comments sent on an idle stream.
+var Heartbeat = 30 * time.Second
+
+// PublicDevice represents the device data pushed to the other devices
+type PublicDevice struct {
+	ID        uint  `json:"id"`
+	DoorID    *uint `json:"door_id"`
+	IsEnabled bool  `json:"is_enabled"`
+}
+
+// Deleted represents an entity that no longer exists
+type Deleted struct {
+	ID uint `json:"id"`
+}
+
+// Index streams the account changes until the client disconnects.
+// Events are named after the entity and the action ( ex: person.updated ) and carry the entity as JSON data.
+func Index(w http.ResponseWriter, req *http.Request, r doorbot.Repositories, hub *realtime.Hub, a *doorbot.Account, session *auth.Authorization) {
+	if session.Type != auth.AuthorizationDevice {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var closed <-chan bool
+	if cn, ok := w.(http.CloseNotifier); ok {
+		closed = cn.CloseNotify()
+	}
+
+	changes, unsubscribe := hub.Subscribe(a.ID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	log.WithFields(log.Fields{
+		"account_id": a.ID,
+		"device_id":  session.Device.ID,
+	}).Info("Api::Stream->Index device connected")
+
+	heartbeat := time.NewTicker(Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			log.WithFields(log.Fields{
+				"account_id": a.ID,
+				"device_id":  session.Device.ID,
+			}).Info("Api::Stream->Index device disconnected")
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+
+			seen(r, session.Device)
+
+		case change := <-changes:
+			name, data, err := Message(r, change)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error":      err,
+					"account_id": a.ID,
+					"table":      change.Table,
+					"id":         change.ID,
+				}).Error("Api::Stream->Index database error")
+				continue
+			}
+
+			if len(name) == 0 {
+				continue
+			}
+
+			payload, err := json.Marshal(data)
+			if err != nil {
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+			flusher.Flush()
+
+			// The device only authenticates when it connects, the stream ends once it is disabled or deleted.
+			if revoked(name, data, session.Device.ID) {
+				log.WithFields(log.Fields{
+					"account_id": a.ID,
+					"device_id":  session.Device.ID,
+				}).Info("Api::Stream->Index device revoked")
+				return
+			}
+		}
+	}
+}
+
+// revoked tells if a pushed event disables or deletes the given device.
+func revoked(name string, data interface{}, deviceID uint) bool {
+	switch d := data.(type) {
+	case PublicDevice:
+		return d.ID == deviceID && !d.IsEnabled
+	case Deleted:
+		return name == "device.deleted" && d.ID == deviceID
+	}
+
+	return false
+}
+
+// seen keeps the last seen time of a connected device up to date.
+func seen(r doorbot.Repositories, device *doorbot.Device) {
+	if device.LastSeenAt != nil && time.Since(*device.LastSeenAt) < doorbot.DeviceSeenInterval {
+		return
+	}
+
+	_, err := r.DeviceRepository().Seen(r.DB(), device)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"device_id":  device.ID,
+			"step":       "device-seen",
+		}).Error("Api::Stream->seen database error")
+	}
+}
+
+// Message loads the entity matching a change and returns the event name and data pushed to the devices.
+// An empty name means nothing should be pushed.
+func Message(r doorbot.Repositories, change *realtime.Change) (string, interface{}, error) {
+	if change.Action == realtime.ActionResync {
+		return "resync", struct{}{}, nil
+	}
+
+	var entity string
+
+	switch change.Table {
+	case "people":
+		entity = "person"
+	case "doors":
+		entity = "door"
+	case "devices":
+		entity = "device"
+	case "notifications":
+		entity = "notification"
+	default:
+		return "", nil, nil
+	}
+
+	if change.Action == realtime.ActionDelete {
+		return entity + ".deleted", Deleted{ID: change.ID}, nil
+	}
+
+	action := "updated"
+	if change.Action == realtime.ActionInsert {
+		action = "created"
+	}
+
+	switch entity {
+	case "person":
+		person, err := r.PersonRepository().Find(r.DB(), change.ID)
+		if err != nil || person == nil {
+			return "", nil, err
+		}
+
+		// Hidden people are removed from the lobby devices, they cannot be knocked.
+		if !person.IsVisible {
+			return "person.deleted", Deleted{ID: person.ID}, nil
+		}
+
+		status, err := availability.Person(r, person)
+		if err != nil {
+			return "", nil, err
+		}
+
+		public := people.NewPublicPerson(person)
+		public.SetAvailability(status)
+
+		return "person." + action, public, nil
+
+	case "door":
+		door, err := r.DoorRepository().Find(r.DB(), change.ID)
+		if err != nil || door == nil {
+			return "", nil, err
+		}
+
+		return "door." + action, door, nil
+
+	case "device":
+		device, err := r.DeviceRepository().Find(r.DB(), change.ID)
+		if err != nil || device == nil {
+			return "", nil, err
+		}
+
+		return "device." + action, PublicDevice{ID: device.ID, DoorID: device.DoorID, IsEnabled: device.IsEnabled}, nil
+	}
+
+	// Notifications are only published when they get acknowledged.
+	notification, err := r.NotificationRepository().Find(r.DB(), change.ID)
+	if err != nil || notification == nil {
+		return "", nil, err
+	}
+
+	return "notification.acknowledged", notification, nil
+}
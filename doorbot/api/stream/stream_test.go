@@ -0,0 +1,178 @@
+// +build tests
+
+package stream
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/api/people"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/realtime"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMessagePerson(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repo := new(tests.MockPersonRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PersonRepository").Return(repo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DB").Return(db)
+
+	person := &doorbot.Person{
+		ID:          5,
+		Name:        "Romanian Landlords",
+		IsVisible:   true,
+		IsAvailable: true,
+	}
+
+	periods := []*doorbot.OutOfOffice{
+		&doorbot.OutOfOffice{
+			PersonID: 5,
+			StartsAt: time.Now().Add(-time.Hour),
+			EndsAt:   time.Now().Add(24 * time.Hour),
+		},
+	}
+
+	repo.On("Find", db, uint(5)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(5)).Return(periods, nil)
+
+	name, data, err := Message(repositories, &realtime.Change{AccountID: 1, Table: "people", Action: realtime.ActionUpdate, ID: 5})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "person.updated", name)
+
+	// The computed availability is pushed, not the stored flag.
+	public := data.(*people.PublicPerson)
+	assert.Equal(t, uint(5), public.ID)
+	assert.False(t, public.IsAvailable)
+	assert.NotNil(t, public.BackAt)
+
+	repo.Mock.AssertExpectations(t)
+}
+
+func TestMessageHiddenPerson(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repo := new(tests.MockPersonRepository)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("PersonRepository").Return(repo)
+	repositories.On("DB").Return(db)
+
+	person := &doorbot.Person{
+		ID:          5,
+		Name:        "Romanian Landlords",
+		IsVisible:   false,
+		IsAvailable: true,
+	}
+
+	repo.On("Find", db, uint(5)).Return(person, nil)
+
+	name, data, err := Message(repositories, &realtime.Change{AccountID: 1, Table: "people", Action: realtime.ActionUpdate, ID: 5})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "person.deleted", name)
+	assert.Equal(t, Deleted{ID: 5}, data)
+
+	repo.Mock.AssertExpectations(t)
+}
+
+func TestMessageDevice(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repo := new(tests.MockDeviceRepository)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("DeviceRepository").Return(repo)
+	repositories.On("DB").Return(db)
+
+	device := &doorbot.Device{
+		ID:        8,
+		IsEnabled: false,
+		Token:     "secret",
+	}
+
+	repo.On("Find", db, uint(8)).Return(device, nil)
+
+	name, data, err := Message(repositories, &realtime.Change{AccountID: 1, Table: "devices", Action: realtime.ActionInsert, ID: 8})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "device.created", name)
+	assert.Equal(t, PublicDevice{ID: 8}, data)
+
+	repo.Mock.AssertExpectations(t)
+}
+
+func TestMessageDeleted(t *testing.T) {
+	repositories := new(tests.MockRepositories)
+
+	name, data, err := Message(repositories, &realtime.Change{AccountID: 1, Table: "doors", Action: realtime.ActionDelete, ID: 3})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "door.deleted", name)
+	assert.Equal(t, Deleted{ID: 3}, data)
+
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestMessageResync(t *testing.T) {
+	repositories := new(tests.MockRepositories)
+
+	name, _, err := Message(repositories, &realtime.Change{Action: realtime.ActionResync})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "resync", name)
+}
+
+func TestRevoked(t *testing.T) {
+	assert.True(t, revoked("device.updated", PublicDevice{ID: 8}, 8))
+	assert.False(t, revoked("device.updated", PublicDevice{ID: 8, IsEnabled: true}, 8))
+	assert.False(t, revoked("device.updated", PublicDevice{ID: 9}, 8))
+	assert.True(t, revoked("device.deleted", Deleted{ID: 8}, 8))
+	assert.False(t, revoked("door.deleted", Deleted{ID: 8}, 8))
+}
+
+func TestIndexDeviceDisabled(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repo := new(tests.MockDeviceRepository)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("DeviceRepository").Return(repo)
+	repositories.On("DB").Return(db)
+
+	device := &doorbot.Device{ID: 8, IsEnabled: true}
+	repo.On("Find", db, uint(8)).Return(&doorbot.Device{ID: 8, IsEnabled: false}, nil)
+
+	hub := realtime.NewHub("")
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: device}
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		Index(w, nil, repositories, hub, &doorbot.Account{ID: 1}, session)
+		close(done)
+	}()
+
+	// The change is sent until the stream subscribed and ended.
+	timeout := time.After(time.Second)
+	for stopped := false; !stopped; {
+		hub.Dispatch(`{"account_id": 1, "table": "devices", "action": "UPDATE", "id": 8}`)
+
+		select {
+		case <-done:
+			stopped = true
+		case <-timeout:
+			t.Fatal("the stream was not closed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	assert.Contains(t, w.Body.String(), "event: device.updated\ndata: {\"id\":8,\"door_id\":null,\"is_enabled\":false}\n\n")
+}
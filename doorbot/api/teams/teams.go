@@ -0,0 +1,476 @@
+// Package teams wraps team-related api logic
+package teams
+
+import (
+	"fmt"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/audit"
+	"github.com/masom/doorbot/doorbot/services/availability"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+)
+
+// TeamsViewModel represents a list of teams along with their members
+type TeamsViewModel struct {
+	Teams   []*doorbot.Team       `json:"teams"`
+	Members []*doorbot.TeamMember `json:"members"`
+}
+
+// TeamViewModel represents a team. Members lists the person ids of the team, in order.
+type TeamViewModel struct {
+	Team    *doorbot.Team `json:"team"`
+	Members []uint        `json:"members"`
+}
+
+// PublicTeam represents publicly visible team data.
+// A team is available when at least one of its members is.
+type PublicTeam struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsAvailable bool   `json:"is_available"`
+}
+
+// NewPublicTeams transforms the visible teams into a public version of the data, along with their current availability
+func NewPublicTeams(teams []*doorbot.Team, members []*doorbot.TeamMember, statuses map[uint]*availability.Status) []*PublicTeam {
+	available := map[uint]bool{}
+
+	for _, member := range members {
+		if status, ok := statuses[member.PersonID]; ok && status.IsAvailable {
+			available[member.TeamID] = true
+		}
+	}
+
+	publicTeams := []*PublicTeam{}
+
+	for _, t := range teams {
+		if !t.IsVisible {
+			continue
+		}
+
+		publicTeams = append(publicTeams, &PublicTeam{
+			ID:          t.ID,
+			Name:        t.Name,
+			Description: t.Description,
+			IsAvailable: available[t.ID],
+		})
+	}
+
+	return publicTeams
+}
+
+// Index returns the teams
+func Index(render render.Render, r doorbot.Repositories) {
+	teams, err := r.TeamRepository().All(r.DB())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "teams-find",
+		}).Error("Api::Teams->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	members, err := r.TeamMemberRepository().All(r.DB())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "members-find",
+		}).Error("Api::Teams->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if teams == nil {
+		teams = []*doorbot.Team{}
+	}
+
+	if members == nil {
+		members = []*doorbot.TeamMember{}
+	}
+
+	render.JSON(http.StatusOK, TeamsViewModel{Teams: teams, Members: members})
+}
+
+// Get returns a specific team
+func Get(render render.Render, r doorbot.Repositories, params martini.Params) {
+	team, ok := findTeam(render, r, params["id"], "Get")
+	if !ok {
+		return
+	}
+
+	members, err := r.TeamMemberRepository().FindByTeamID(r.DB(), team.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"team_id":    team.ID,
+			"step":       "members-find",
+		}).Error("Api::Teams->Get database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.JSON(http.StatusOK, newTeamViewModel(team, members))
+}
+
+// Post creates a team
+func Post(render render.Render, r doorbot.Repositories, vm TeamViewModel, session *auth.Authorization) {
+	if vm.Team == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The team is required."}))
+		return
+	}
+
+	team := doorbot.NewTeam(r.AccountScope(), vm.Team.Name)
+	team.Description = vm.Team.Description
+	team.IsVisible = vm.Team.IsVisible
+
+	if len(vm.Team.Strategy) > 0 {
+		team.Strategy = vm.Team.Strategy
+	}
+
+	if !validateTeam(render, r, team, vm.Members, "Post") {
+		return
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "transaction-create",
+		}).Error("Api::Teams->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	err = r.TeamRepository().Create(tx, team)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "team-create",
+		}).Error("Api::Teams->Post database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	members, ok := replaceMembers(render, r, tx, team, vm.Members, "Post")
+	if !ok {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "transaction-commit",
+		}).Error("Api::Teams->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": r.AccountScope(),
+		"team_id":    team.ID,
+	}).Info("Api::Teams->Post team created")
+
+	audit.Team(r, session, doorbot.EventTeamAdded, team)
+
+	render.JSON(http.StatusCreated, newTeamViewModel(team, members))
+}
+
+// Put updates a team. The members are replaced when provided.
+// The members of a team synced from a bridge are managed by the bridge and cannot be changed.
+func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm TeamViewModel, session *auth.Authorization) {
+	if vm.Team == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The team is required."}))
+		return
+	}
+
+	team, ok := findTeam(render, r, params["id"], "Put")
+	if !ok {
+		return
+	}
+
+	if team.BridgeID > 0 && vm.Members != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The members of a team synced from a bridge are managed by the bridge."}))
+		return
+	}
+
+	team.Name = vm.Team.Name
+	team.Description = vm.Team.Description
+	team.IsVisible = vm.Team.IsVisible
+
+	if len(vm.Team.Strategy) > 0 {
+		team.Strategy = vm.Team.Strategy
+	}
+
+	if !validateTeam(render, r, team, vm.Members, "Put") {
+		return
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"team_id":    team.ID,
+			"step":       "transaction-create",
+		}).Error("Api::Teams->Put database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	_, err = r.TeamRepository().Update(tx, team)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"team_id":    team.ID,
+			"step":       "team-update",
+		}).Error("Api::Teams->Put database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	var members []*doorbot.TeamMember
+
+	if vm.Members != nil {
+		members, ok = replaceMembers(render, r, tx, team, vm.Members, "Put")
+		if !ok {
+			return
+		}
+	} else {
+		members, err = r.TeamMemberRepository().FindByTeamID(tx, team.ID)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": r.AccountScope(),
+				"team_id":    team.ID,
+				"step":       "members-find",
+			}).Error("Api::Teams->Put database error")
+
+			tx.Rollback()
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"team_id":    team.ID,
+			"step":       "transaction-commit",
+		}).Error("Api::Teams->Put database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": r.AccountScope(),
+		"team_id":    team.ID,
+	}).Info("Api::Teams->Put team updated")
+
+	audit.Team(r, session, doorbot.EventTeamUpdated, team)
+
+	render.JSON(http.StatusOK, newTeamViewModel(team, members))
+}
+
+// Delete a team
+func Delete(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	team, ok := findTeam(render, r, params["id"], "Delete")
+	if !ok {
+		return
+	}
+
+	_, err := r.TeamRepository().Delete(r.DB(), team)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"team_id":    team.ID,
+			"step":       "team-delete",
+		}).Error("Api::Teams->Delete database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": r.AccountScope(),
+		"team_id":    team.ID,
+	}).Info("Api::Teams->Delete team deleted")
+
+	audit.Team(r, session, doorbot.EventTeamRemoved, team)
+
+	render.Status(http.StatusNoContent)
+}
+
+// findTeam loads the team identified by the given id.
+// Errors are rendered and false returned when it cannot.
+func findTeam(render render.Render, r doorbot.Repositories, value string, method string) (*doorbot.Team, bool) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return nil, false
+	}
+
+	team, err := r.TeamRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"team_id":    id,
+			"step":       "team-find",
+		}).Error("Api::Teams->" + method + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	if team == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified team does not exists"}))
+		return nil, false
+	}
+
+	return team, true
+}
+
+// validateTeam renders the problems found in a team and its members. It returns false when there are any.
+func validateTeam(render render.Render, r doorbot.Repositories, team *doorbot.Team, members []uint, method string) bool {
+	errors := validate(team, members)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return false
+	}
+
+	for i, id := range members {
+		person, err := r.PersonRepository().Find(r.DB(), id)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": r.AccountScope(),
+				"person_id":  id,
+				"step":       "member-find",
+			}).Error("Api::Teams->" + method + " database error")
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return false
+		}
+
+		if person == nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{fmt.Sprintf("Member %d does not exists.", i+1)}))
+			return false
+		}
+	}
+
+	return true
+}
+
+// validate returns the list of problems found in a team and its members.
+func validate(team *doorbot.Team, members []uint) []string {
+	var errors []string
+
+	if len(team.Name) == 0 {
+		errors = append(errors, "The name is required.")
+	}
+
+	switch team.Strategy {
+	case doorbot.TeamStrategyBroadcast, doorbot.TeamStrategyRoundRobin, doorbot.TeamStrategyFirstAvailable:
+	default:
+		errors = append(errors, "The strategy must be one of broadcast, round_robin or first_available.")
+	}
+
+	seen := map[uint]bool{}
+
+	for i, id := range members {
+		switch {
+		case id == 0:
+			errors = append(errors, fmt.Sprintf("Member %d: the person id is required.", i+1))
+		case seen[id]:
+			errors = append(errors, fmt.Sprintf("Member %d: the person is already a member.", i+1))
+		default:
+			seen[id] = true
+		}
+	}
+
+	return errors
+}
+
+// replaceMembers replaces the members of a team within the transaction.
+// The transaction is rolled back and false returned on errors.
+func replaceMembers(render render.Render, r doorbot.Repositories, tx doorbot.Transaction, team *doorbot.Team, ids []uint, method string) ([]*doorbot.TeamMember, bool) {
+	repo := r.TeamMemberRepository()
+
+	err := repo.DeleteByTeamID(tx, team.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"team_id":    team.ID,
+			"step":       "members-delete",
+		}).Error("Api::Teams->" + method + " database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	members := make([]*doorbot.TeamMember, len(ids))
+
+	for i, id := range ids {
+		members[i] = &doorbot.TeamMember{TeamID: team.ID, PersonID: id, Position: uint(i)}
+
+		err = repo.Create(tx, members[i])
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": r.AccountScope(),
+				"team_id":    team.ID,
+				"step":       "member-create",
+			}).Error("Api::Teams->" + method + " database error")
+
+			tx.Rollback()
+
+			render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+			return nil, false
+		}
+	}
+
+	return members, true
+}
+
+func newTeamViewModel(team *doorbot.Team, members []*doorbot.TeamMember) TeamViewModel {
+	ids := make([]uint, len(members))
+
+	for i, member := range members {
+		ids[i] = member.PersonID
+	}
+
+	return TeamViewModel{Team: team, Members: ids}
+}
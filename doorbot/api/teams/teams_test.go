@@ -0,0 +1,132 @@
+package teams
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"testing"
+)
+
+func TestPost(t *testing.T) {
+	render := new(tests.MockRender)
+	teamRepo := new(tests.MockTeamRepository)
+	memberRepo := new(tests.MockTeamMemberRepository)
+	personRepo := new(tests.MockPersonRepository)
+	eventRepo := new(tests.MockEventRepository)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("TeamRepository").Return(teamRepo)
+	repositories.On("TeamMemberRepository").Return(memberRepo)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	vm := TeamViewModel{
+		Team:    &doorbot.Team{Name: "IT", Strategy: doorbot.TeamStrategyRoundRobin, IsVisible: true},
+		Members: []uint{7, 6},
+	}
+
+	personRepo.On("Find", db, uint(7)).Return(&doorbot.Person{ID: 7}, nil)
+	personRepo.On("Find", db, uint(6)).Return(&doorbot.Person{ID: 6}, nil)
+	teamRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Team")).Return(nil)
+	memberRepo.On("DeleteByTeamID", tx, uint(0)).Return(nil)
+	memberRepo.On("Create", tx, mock.AnythingOfType("*doorbot.TeamMember")).Return(nil)
+	tx.On("Commit").Return(nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("teams.TeamViewModel")).Return()
+
+	Post(render, repositories, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	teamRepo.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+
+	created := render.Mock.Calls[0].Arguments.Get(1).(TeamViewModel)
+
+	assert.Equal(t, "IT", created.Team.Name)
+	assert.Equal(t, doorbot.TeamStrategyRoundRobin, created.Team.Strategy)
+	assert.Equal(t, []uint{7, 6}, created.Members)
+
+	member := memberRepo.Mock.Calls[2].Arguments.Get(1).(*doorbot.TeamMember)
+	assert.Equal(t, uint(6), member.PersonID)
+	assert.Equal(t, uint(1), member.Position)
+}
+
+func TestPostInvalid(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	repositories.On("AccountScope").Return(uint(1))
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	vm := TeamViewModel{
+		Team:    &doorbot.Team{Strategy: "everyone"},
+		Members: []uint{5, 5, 0},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{
+		"The name is required.",
+		"The strategy must be one of broadcast, round_robin or first_available.",
+		"Member 2: the person is already a member.",
+		"Member 3: the person id is required.",
+	})).Return()
+
+	Post(render, repositories, vm, session)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestPutBridgeMembers(t *testing.T) {
+	render := new(tests.MockRender)
+	teamRepo := new(tests.MockTeamRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("TeamRepository").Return(teamRepo)
+	repositories.On("DB").Return(db)
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	teamRepo.On("Find", db, uint(3)).Return(&doorbot.Team{ID: 3, Name: "Sales", BridgeID: 2}, nil)
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The members of a team synced from a bridge are managed by the bridge."})).Return()
+
+	vm := TeamViewModel{Team: &doorbot.Team{Name: "Sales"}, Members: []uint{}}
+
+	Put(render, repositories, martini.Params{"id": "3"}, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	teamRepo.Mock.AssertExpectations(t)
+}
+
+func TestDeleteNotFound(t *testing.T) {
+	render := new(tests.MockRender)
+	teamRepo := new(tests.MockTeamRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("TeamRepository").Return(teamRepo)
+	repositories.On("DB").Return(db)
+
+	session := &auth.Authorization{Type: auth.AuthorizationAdministrator}
+
+	teamRepo.On("Find", db, uint(3)).Return((*doorbot.Team)(nil), nil)
+
+	render.On("JSON", http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified team does not exists"})).Return()
+
+	Delete(render, repositories, martini.Params{"id": "3"}, session)
+
+	render.Mock.AssertExpectations(t)
+	teamRepo.Mock.AssertExpectations(t)
+}
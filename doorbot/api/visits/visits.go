@@ -0,0 +1,461 @@
+// Package visits handles the visitors check-in and check-out log.
+package visits
+
+import (
+	"fmt"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/audit"
+	"github.com/masom/doorbot/doorbot/services/notifications"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VisitsViewModel wraps a list of visits
+type VisitsViewModel struct {
+	Visits []*Visit `json:"visits"`
+}
+
+// VisitViewModel wraps a visit
+type VisitViewModel struct {
+	Visit *Visit `json:"visit"`
+}
+
+// CheckInViewModel wraps a check-in request
+type CheckInViewModel struct {
+	Visit *CheckIn `json:"visit" binding:"required"`
+}
+
+// CheckInResultViewModel wraps the created visit and the knock-knock sent to the host, if any.
+// NotificationError explains why the host could not be notified, NotificationMessage who is coming instead of them.
+type CheckInResultViewModel struct {
+	Visit               *Visit                `json:"visit"`
+	Notification        *doorbot.Notification `json:"notification,omitempty"`
+	NotificationError   string                `json:"notification_error,omitempty"`
+	NotificationMessage string                `json:"notification_message,omitempty"`
+}
+
+// CheckIn represents a visitor checking in at a door to see a person.
+// The host is notified when Notify is set.
+type CheckIn struct {
+	PersonID uint             `json:"person_id" binding:"required"`
+	DoorID   uint             `json:"door_id" binding:"required"`
+	Notify   bool             `json:"notify"`
+	Visitor  *doorbot.Visitor `json:"visitor" binding:"required"`
+}
+
+// Visit represents a visit along with its visitor
+type Visit struct {
+	*doorbot.Visit
+	Visitor *doorbot.Visitor `json:"visitor"`
+}
+
+// newVisits pairs visits with their visitors
+func newVisits(visits []*doorbot.Visit, visitors []*doorbot.Visitor) []*Visit {
+	index := map[uint]*doorbot.Visitor{}
+	for _, v := range visitors {
+		index[v.ID] = v
+	}
+
+	result := make([]*Visit, len(visits))
+	for i, v := range visits {
+		result[i] = &Visit{Visit: v, Visitor: index[v.VisitorID]}
+	}
+
+	return result
+}
+
+// canView determines if the session can see the visits made to the given person.
+// Members can only see their own visitors.
+func canView(session *auth.Authorization, personID uint) bool {
+	switch session.Type {
+	case auth.AuthorizationAdministrator, auth.AuthorizationDevice:
+		return true
+	case auth.AuthorizationPerson:
+		return session.Person.IsAccountManager() || session.Person.ID == personID
+	}
+
+	return false
+}
+
+// Index returns the most recent visits, optionally filtered by person_id, door_id and active.
+func Index(render render.Render, r doorbot.Repositories, req *http.Request, session *auth.Authorization) {
+	filter := &doorbot.VisitFilter{}
+
+	for name, value := range map[string]*uint{"person_id": &filter.PersonID, "door_id": &filter.DoorID} {
+		param := req.URL.Query().Get(name)
+		if len(param) == 0 {
+			continue
+		}
+
+		id, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The " + name + " must be an unsigned integer"}))
+			return
+		}
+
+		*value = uint(id)
+	}
+
+	if active := req.URL.Query().Get("active"); len(active) > 0 {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The active parameter must be a boolean"}))
+			return
+		}
+
+		filter.Active = value
+	}
+
+	if session.Type == auth.AuthorizationPerson && !session.Person.IsAccountManager() {
+		if filter.PersonID != 0 && filter.PersonID != session.Person.ID {
+			render.Status(http.StatusForbidden)
+			return
+		}
+
+		filter.PersonID = session.Person.ID
+	}
+
+	if !canView(session, filter.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	visits, err := r.VisitRepository().All(r.DB(), filter)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  filter.PersonID,
+			"door_id":    filter.DoorID,
+			"step":       "visit-all",
+		}).Error("Api::Visits->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	seen := map[uint]bool{}
+	ids := []uint{}
+
+	for _, v := range visits {
+		if !seen[v.VisitorID] {
+			seen[v.VisitorID] = true
+			ids = append(ids, v.VisitorID)
+		}
+	}
+
+	visitors, err := r.VisitorRepository().FindByIDs(r.DB(), ids)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "visitors-find",
+		}).Error("Api::Visits->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.JSON(http.StatusOK, VisitsViewModel{Visits: newVisits(visits, visitors)})
+}
+
+// Get returns a visit and its visitor
+func Get(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	visit, ok := find(render, r, params, "Get")
+	if !ok {
+		return
+	}
+
+	if !canView(session, visit.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	visitor, err := r.VisitorRepository().Find(r.DB(), visit.VisitorID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"visit_id":   visit.ID,
+			"visitor_id": visit.VisitorID,
+			"step":       "visitor-find",
+		}).Error("Api::Visits->Get database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.JSON(http.StatusOK, VisitViewModel{Visit: &Visit{Visit: visit, Visitor: visitor}})
+}
+
+// Post checks a visitor in. Returning visitors are matched by email.
+// When requested, the host is notified through the Notificator.
+func Post(render render.Render, account *doorbot.Account, r doorbot.Repositories, notificator notifications.Notificator, vm CheckInViewModel, session *auth.Authorization) {
+	checkIn := vm.Visit
+
+	if checkIn.Visitor == nil || len(strings.TrimSpace(checkIn.Visitor.Name)) == 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The visitor name is required."}))
+		return
+	}
+
+	person, err := r.PersonRepository().Find(r.DB(), checkIn.PersonID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  checkIn.PersonID,
+			"door_id":    checkIn.DoorID,
+			"step":       "person-find",
+		}).Error("Api::Visits->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if person == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified person does not exists."}))
+		return
+	}
+
+	door, err := r.DoorRepository().Find(r.DB(), checkIn.DoorID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"person_id":  checkIn.PersonID,
+			"door_id":    checkIn.DoorID,
+			"step":       "door-find",
+		}).Error("Api::Visits->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if door == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified door does not exists."}))
+		return
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "transaction-create",
+		}).Error("Api::Visits->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	visitor, err := SaveVisitor(r, tx, checkIn.Visitor)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "visitor-save",
+		}).Error("Api::Visits->Post database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	visit := doorbot.NewVisit(r.AccountScope(), visitor.ID, person.ID, door.ID)
+	if session.Type == auth.AuthorizationDevice {
+		visit.DeviceID = &session.Device.ID
+	}
+
+	err = r.VisitRepository().Create(tx, visit)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"visitor_id": visitor.ID,
+			"step":       "visit-create",
+		}).Error("Api::Visits->Post database error")
+
+		tx.Rollback()
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"step":       "transaction-commit",
+		}).Error("Api::Visits->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": r.AccountScope(),
+		"visit_id":   visit.ID,
+		"visitor_id": visitor.ID,
+		"person_id":  person.ID,
+		"door_id":    door.ID,
+	}).Info("Api::Visits->Post visitor checked in")
+
+	audit.Visit(r, session, doorbot.EventVisitCheckedIn, visit)
+
+	result := CheckInResultViewModel{Visit: &Visit{Visit: visit, Visitor: visitor}}
+
+	if checkIn.Notify {
+		Notify(&result, account, r, notificator, door, person, session)
+	}
+
+	render.JSON(http.StatusCreated, result)
+}
+
+// Notify knocks the host of a checked in visitor. When they are away the knock-knock goes to one of their delegates
+// or the reception instead. The account dedup window and the device rate limit apply as for any knock-knock.
+// The outcome is recorded on the result.
+func Notify(result *CheckInResultViewModel, account *doorbot.Account, r doorbot.Repositories, notificator notifications.Notificator, door *doorbot.Door, person *doorbot.Person, session *auth.Authorization) {
+	knocked, err := notifications.Knock(r, account, notificator, door, person, result.Visit.Visit, session)
+
+	switch err {
+	case nil:
+	case notifications.ErrUnavailable:
+		// The visit is kept, the visitor is told when the person is back.
+		result.NotificationError = knocked.Status.Message
+		return
+	case notifications.ErrRateLimited:
+		result.NotificationError = fmt.Sprintf("Too many knock-knocks from this device, please try again in %ds.", int(knocked.RetryAfter.Seconds()))
+		return
+	case notifications.ErrNoChannels:
+		result.NotificationError = "The specified person cannot be reached on any notification channel."
+		return
+	default:
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"visit_id":   result.Visit.ID,
+			"person_id":  person.ID,
+			"door_id":    door.ID,
+			"step":       "notification-queue",
+		}).Error("Api::Visits->Notify notificator error")
+
+		result.NotificationError = "The specified person could not be notified."
+		return
+	}
+
+	result.Notification = knocked.Notification
+
+	switch {
+	case knocked.Duplicate:
+		result.NotificationMessage = fmt.Sprintf("%s was already notified %s ago.", person.Name, knocked.Ago)
+	case knocked.Delegate != nil:
+		result.NotificationMessage = fmt.Sprintf("%s %s is coming instead.", knocked.Status.Message, knocked.Delegate.Name)
+	}
+}
+
+// Checkout checks a visitor out.
+func Checkout(render render.Render, r doorbot.Repositories, params martini.Params, session *auth.Authorization) {
+	visit, ok := find(render, r, params, "Checkout")
+	if !ok {
+		return
+	}
+
+	if !canView(session, visit.PersonID) {
+		render.Status(http.StatusForbidden)
+		return
+	}
+
+	if visit.CheckedOutAt != nil {
+		render.JSON(http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"The visitor already checked out."}))
+		return
+	}
+
+	now := time.Now()
+	visit.CheckedOutAt = &now
+
+	_, err := r.VisitRepository().Update(r.DB(), visit)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"visit_id":   visit.ID,
+			"step":       "visit-update",
+		}).Error("Api::Visits->Checkout database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": r.AccountScope(),
+		"visit_id":   visit.ID,
+	}).Info("Api::Visits->Checkout visitor checked out")
+
+	audit.Visit(r, session, doorbot.EventVisitCheckedOut, visit)
+
+	render.JSON(http.StatusOK, VisitViewModel{Visit: &Visit{Visit: visit}})
+}
+
+// find loads the visit identified by the id parameter. Errors are rendered and false returned when not found.
+func find(render render.Render, r doorbot.Repositories, params martini.Params, action string) (*doorbot.Visit, bool) {
+	id, err := strconv.ParseUint(params["id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return nil, false
+	}
+
+	visit, err := r.VisitRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"visit_id":   id,
+			"step":       "visit-find",
+		}).Error("Api::Visits->" + action + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	if visit == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified visit does not exists."}))
+		return nil, false
+	}
+
+	return visit, true
+}
+
+// SaveVisitor creates the visitor or updates the one already registered with the same email.
+func SaveVisitor(r doorbot.Repositories, tx doorbot.Executor, v *doorbot.Visitor) (*doorbot.Visitor, error) {
+	v.ID = 0
+
+	if len(v.Email) > 0 {
+		existing, err := r.VisitorRepository().FindByEmail(tx, v.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			existing.Name = v.Name
+			existing.Company = v.Company
+
+			if len(v.PhoneNumber) > 0 {
+				existing.PhoneNumber = v.PhoneNumber
+			}
+
+			_, err = r.VisitorRepository().Update(tx, existing)
+			return existing, err
+		}
+	}
+
+	err := r.VisitorRepository().Create(tx, v)
+	return v, err
+}
@@ -0,0 +1,591 @@
+// +build tests
+
+package visits
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/notifications"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIndexMemberSeesOwnVisits(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 5, AccountType: doorbot.AccountMember},
+	}
+
+	req := &http.Request{URL: &url.URL{RawQuery: "active=true"}}
+
+	visits := []*doorbot.Visit{
+		&doorbot.Visit{ID: 1, VisitorID: 3, PersonID: 5},
+		&doorbot.Visit{ID: 2, VisitorID: 3, PersonID: 5},
+	}
+
+	visitors := []*doorbot.Visitor{
+		&doorbot.Visitor{ID: 3, Name: "Jane Doe"},
+	}
+
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("DB").Return(db)
+
+	visitRepo.On("All", db, &doorbot.VisitFilter{PersonID: 5, Active: true}).Return(visits, nil)
+	visitorRepo.On("FindByIDs", db, []uint{3}).Return(visitors, nil)
+
+	render.On("JSON", http.StatusOK, VisitsViewModel{Visits: []*Visit{
+		&Visit{Visit: visits[0], Visitor: visitors[0]},
+		&Visit{Visit: visits[1], Visitor: visitors[0]},
+	}}).Return()
+
+	Index(render, repositories, req, session)
+
+	render.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	visitorRepo.Mock.AssertExpectations(t)
+}
+
+func TestIndexMemberForbidden(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	session := &auth.Authorization{
+		Type:   auth.AuthorizationPerson,
+		Person: &doorbot.Person{ID: 5, AccountType: doorbot.AccountMember},
+	}
+
+	req := &http.Request{URL: &url.URL{RawQuery: "person_id=6"}}
+
+	render.On("Status", http.StatusForbidden).Return()
+
+	Index(render, repositories, req, session)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestPostMissingVisitorName(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+	notificator := new(tests.MockNotificator)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	vm := CheckInViewModel{Visit: &CheckIn{PersonID: 5, DoorID: 2, Visitor: &doorbot.Visitor{Name: " "}}}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The visitor name is required."})).Return()
+
+	Post(render, &doorbot.Account{ID: 1}, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+}
+
+func TestPostReturningVisitor(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	person := &doorbot.Person{ID: 5, IsVisible: true, IsAvailable: true}
+	door := &doorbot.Door{ID: 2}
+	existing := &doorbot.Visitor{ID: 3, Name: "Jane", Email: "jane@example.com", PhoneNumber: "5555555555"}
+	queued := &doorbot.Notification{ID: 8}
+
+	vm := CheckInViewModel{Visit: &CheckIn{
+		PersonID: 5,
+		DoorID:   2,
+		Notify:   true,
+		Visitor:  &doorbot.Visitor{Name: "Jane Doe", Company: "ACME", Email: "jane@example.com"},
+	}}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitorRepo.On("FindByEmail", tx, "jane@example.com").Return(existing, nil)
+	visitorRepo.On("Update", tx, existing).Return(true, nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	tx.On("Commit").Return(nil)
+	notificator.On("KnockKnock", door, person, mock.AnythingOfType("*doorbot.Visit")).Return(queued, nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	Post(render, &doorbot.Account{ID: 1}, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+	visitorRepo.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+
+	result := render.Calls[0].Arguments.Get(1).(CheckInResultViewModel)
+
+	assert.Equal(t, "Jane Doe", existing.Name)
+	assert.Equal(t, "ACME", existing.Company)
+	assert.Equal(t, "5555555555", existing.PhoneNumber)
+	assert.Equal(t, existing, result.Visit.Visitor)
+	assert.Equal(t, uint(3), result.Visit.VisitorID)
+	assert.Equal(t, uint(9), *result.Visit.DeviceID)
+	assert.Equal(t, queued, result.Notification)
+}
+
+func TestPostNoChannels(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	person := &doorbot.Person{ID: 5, IsVisible: true, IsAvailable: true}
+	door := &doorbot.Door{ID: 2}
+	visitor := &doorbot.Visitor{Name: "Jane Doe"}
+
+	vm := CheckInViewModel{Visit: &CheckIn{PersonID: 5, DoorID: 2, Notify: true, Visitor: visitor}}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitorRepo.On("Create", tx, visitor).Return(nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	tx.On("Commit").Return(nil)
+
+	var empty *doorbot.Notification
+	notificator.On("KnockKnock", door, person, mock.AnythingOfType("*doorbot.Visit")).Return(empty, notifications.ErrNoChannels)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	Post(render, &doorbot.Account{ID: 1}, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+	visitorRepo.Mock.AssertExpectations(t)
+
+	result := render.Calls[0].Arguments.Get(1).(CheckInResultViewModel)
+
+	assert.Nil(t, result.Notification)
+	assert.Equal(t, "The specified person cannot be reached on any notification channel.", result.NotificationError)
+}
+
+func TestPostDuplicate(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	notificationRepo := new(tests.MockNotificationRepository)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+
+	account := &doorbot.Account{ID: 1, NotificationsDedupWindow: 60}
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	person := &doorbot.Person{ID: 5, Name: "John Rambo", IsVisible: true, IsAvailable: true}
+	door := &doorbot.Door{ID: 2}
+	visitor := &doorbot.Visitor{Name: "Jane Doe"}
+	sent := &doorbot.Notification{ID: 3, DoorID: 2, PersonID: 5, CreatedAt: time.Now().Add(-20 * time.Second)}
+
+	vm := CheckInViewModel{Visit: &CheckIn{PersonID: 5, DoorID: 2, Notify: true, Visitor: visitor}}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitorRepo.On("Create", tx, visitor).Return(nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	notificationRepo.On("Lock", tx).Return(nil)
+	notificationRepo.On("All", tx, mock.AnythingOfType("*doorbot.NotificationFilter")).Return([]*doorbot.Notification{sent}, nil)
+	tx.On("Commit").Return(nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	Post(render, account, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertNotCalled(t, "KnockKnock", mock.Anything, mock.Anything, mock.Anything)
+
+	result := render.Calls[0].Arguments.Get(1).(CheckInResultViewModel)
+
+	assert.Equal(t, sent, result.Notification)
+	assert.Equal(t, "John Rambo was already notified 20s ago.", result.NotificationMessage)
+	assert.Empty(t, result.NotificationError)
+
+	filter := notificationRepo.Mock.Calls[1].Arguments.Get(1).(*doorbot.NotificationFilter)
+	assert.Equal(t, uint(2), filter.DoorID)
+	assert.Equal(t, uint(5), filter.PersonID)
+	assert.True(t, filter.IncludeRerouted)
+}
+
+func TestPostUnavailablePerson(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	person := &doorbot.Person{ID: 5, Name: "Jane", IsVisible: true, IsAvailable: false}
+	door := &doorbot.Door{ID: 2}
+	visitor := &doorbot.Visitor{Name: "Jane Doe"}
+
+	vm := CheckInViewModel{Visit: &CheckIn{PersonID: 5, DoorID: 2, Notify: true, Visitor: visitor}}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	delegateRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.Delegate{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitorRepo.On("Create", tx, visitor).Return(nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	tx.On("Commit").Return(nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	Post(render, &doorbot.Account{ID: 1}, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+
+	result := render.Calls[0].Arguments.Get(1).(CheckInResultViewModel)
+
+	assert.Nil(t, result.Notification)
+	assert.Equal(t, "Jane is currently not available.", result.NotificationError)
+}
+
+func TestPostAwayPerson(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	// Only works in three days.
+	person := &doorbot.Person{ID: 5, Name: "Jane", IsVisible: true, IsAvailable: true}
+	hours := []*doorbot.WorkingHours{&doorbot.WorkingHours{PersonID: 5, Day: uint(time.Now().UTC().Weekday()+3) % 7, StartsAt: "09:00", EndsAt: "17:00"}}
+
+	door := &doorbot.Door{ID: 2}
+	visitor := &doorbot.Visitor{Name: "Bob"}
+
+	vm := CheckInViewModel{Visit: &CheckIn{PersonID: 5, DoorID: 2, Notify: true, Visitor: visitor}}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(5)).Return(hours, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.OutOfOffice{}, nil)
+	delegateRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.Delegate{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitorRepo.On("Create", tx, visitor).Return(nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	tx.On("Commit").Return(nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	Post(render, &doorbot.Account{ID: 1}, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+
+	result := render.Calls[0].Arguments.Get(1).(CheckInResultViewModel)
+
+	assert.Nil(t, result.Notification)
+	assert.Equal(t, "Jane is not in the office, back on "+time.Now().UTC().AddDate(0, 0, 3).Format("Monday")+".", result.NotificationError)
+}
+
+func TestPostReception(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	receptionID := uint(8)
+	account := &doorbot.Account{ID: 1, ReceptionPersonID: &receptionID}
+
+	person := &doorbot.Person{ID: 5, Name: "Jane", IsVisible: true, IsAvailable: false}
+	reception := &doorbot.Person{ID: 8, Name: "Front Desk", IsVisible: true, IsAvailable: true}
+
+	door := &doorbot.Door{ID: 2}
+	visitor := &doorbot.Visitor{Name: "Bob"}
+
+	vm := CheckInViewModel{Visit: &CheckIn{PersonID: 5, DoorID: 2, Notify: true, Visitor: visitor}}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	personRepo.On("Find", db, uint(8)).Return(reception, nil)
+	delegateRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.Delegate{}, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(8)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(8)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitorRepo.On("Create", tx, visitor).Return(nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	tx.On("Commit").Return(nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	queued := &doorbot.Notification{ID: 7, PersonID: 8, ReroutedFromPersonID: &person.ID}
+	notificator.On("Reroute", door, person, reception, mock.AnythingOfType("*doorbot.Visit")).Return(queued, nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	Post(render, account, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+
+	result := render.Calls[0].Arguments.Get(1).(CheckInResultViewModel)
+
+	assert.Equal(t, queued, result.Notification)
+	assert.Empty(t, result.NotificationError)
+	assert.Equal(t, "Jane is currently not available. Front Desk is coming instead.", result.NotificationMessage)
+}
+
+func TestPostRerouteNoChannels(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	notificator := new(tests.MockNotificator)
+	personRepo := new(tests.MockPersonRepository)
+	doorRepo := new(tests.MockDoorRepository)
+	visitRepo := new(tests.MockVisitRepository)
+	visitorRepo := new(tests.MockVisitorRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+
+	receptionID := uint(8)
+	account := &doorbot.Account{ID: 1, ReceptionPersonID: &receptionID}
+
+	person := &doorbot.Person{ID: 5, Name: "Jane", IsVisible: true, IsAvailable: false}
+	reception := &doorbot.Person{ID: 8, Name: "Front Desk", IsVisible: true, IsAvailable: true}
+
+	door := &doorbot.Door{ID: 2}
+	visitor := &doorbot.Visitor{Name: "Bob"}
+
+	vm := CheckInViewModel{Visit: &CheckIn{PersonID: 5, DoorID: 2, Notify: true, Visitor: visitor}}
+
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DoorRepository").Return(doorRepo)
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("VisitorRepository").Return(visitorRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+	repositories.On("Transaction").Return(tx, nil)
+
+	personRepo.On("Find", db, uint(5)).Return(person, nil)
+	personRepo.On("Find", db, uint(8)).Return(reception, nil)
+	delegateRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.Delegate{}, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(8)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(8)).Return([]*doorbot.OutOfOffice{}, nil)
+	doorRepo.On("Find", db, uint(2)).Return(door, nil)
+	visitorRepo.On("Create", tx, visitor).Return(nil)
+	visitRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Visit")).Return(nil)
+	tx.On("Commit").Return(nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	notificator.On("Reroute", door, person, reception, mock.AnythingOfType("*doorbot.Visit")).Return((*doorbot.Notification)(nil), notifications.ErrNoChannels)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("visits.CheckInResultViewModel")).Return()
+
+	Post(render, account, repositories, notificator, vm, session)
+
+	render.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	notificator.Mock.AssertExpectations(t)
+
+	result := render.Calls[0].Arguments.Get(1).(CheckInResultViewModel)
+
+	assert.Nil(t, result.Notification)
+	assert.Equal(t, "The specified person cannot be reached on any notification channel.", result.NotificationError)
+	assert.Empty(t, result.NotificationMessage)
+}
+
+func TestCheckout(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+	visitRepo := new(tests.MockVisitRepository)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+	params := martini.Params{"id": "4"}
+
+	visit := &doorbot.Visit{ID: 4, PersonID: 5}
+
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("EventRepository").Return(eventRepo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(1))
+
+	visitRepo.On("Find", db, uint(4)).Return(visit, nil)
+	visitRepo.On("Update", db, visit).Return(true, nil)
+	eventRepo.On("Create", db, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+	render.On("JSON", http.StatusOK, VisitViewModel{Visit: &Visit{Visit: visit}}).Return()
+
+	Checkout(render, repositories, params, session)
+
+	render.Mock.AssertExpectations(t)
+	eventRepo.Mock.AssertExpectations(t)
+	visitRepo.Mock.AssertExpectations(t)
+	assert.NotNil(t, visit.CheckedOutAt)
+}
+
+func TestCheckoutAlreadyCheckedOut(t *testing.T) {
+	render := new(tests.MockRender)
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	visitRepo := new(tests.MockVisitRepository)
+
+	session := &auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 9}}
+	params := martini.Params{"id": "4"}
+
+	now := time.Now()
+	visit := &doorbot.Visit{ID: 4, PersonID: 5, CheckedOutAt: &now}
+
+	repositories.On("VisitRepository").Return(visitRepo)
+	repositories.On("DB").Return(db)
+
+	visitRepo.On("Find", db, uint(4)).Return(visit, nil)
+	render.On("JSON", http.StatusConflict, doorbot.NewConflictErrorResponse([]string{"The visitor already checked out."})).Return()
+
+	Checkout(render, repositories, params, session)
+
+	render.Mock.AssertExpectations(t)
+}
@@ -0,0 +1,327 @@
+package webhooks
+
+import (
+	"fmt"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/security"
+	"github.com/masom/doorbot/doorbot/services/notifications/webhook"
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// deliveriesLimit is the number of deliveries listed for a subscription
+const deliveriesLimit = 50
+
+// WebhooksViewModel represents a list of webhook subscriptions
+type WebhooksViewModel struct {
+	Webhooks []*doorbot.WebhookSubscription `json:"webhooks"`
+}
+
+// WebhookViewModel represents a webhook subscription
+type WebhookViewModel struct {
+	Webhook *doorbot.WebhookSubscription `json:"webhook"`
+}
+
+// DeliveriesViewModel represents a list of webhook deliveries
+type DeliveriesViewModel struct {
+	Deliveries []*doorbot.WebhookDelivery `json:"deliveries"`
+}
+
+// DeliveryViewModel represents a webhook delivery
+type DeliveryViewModel struct {
+	Delivery *doorbot.WebhookDelivery `json:"delivery"`
+}
+
+// Index returns the account webhook subscriptions
+func Index(render render.Render, r doorbot.Repositories) {
+	repo := r.WebhookSubscriptionRepository()
+
+	subscriptions, err := repo.All(r.DB())
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+		}).Error("Api::Webhooks->Index database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.JSON(http.StatusOK, WebhooksViewModel{Webhooks: subscriptions})
+}
+
+// Get returns a specific webhook subscription
+func Get(render render.Render, r doorbot.Repositories, params martini.Params) {
+	subscription, ok := find(render, r, params["id"], "Get")
+	if !ok {
+		return
+	}
+
+	render.JSON(http.StatusOK, WebhookViewModel{Webhook: subscription})
+}
+
+// Post creates a webhook subscription. A secret is generated when none is provided.
+func Post(render render.Render, r doorbot.Repositories, vm WebhookViewModel) {
+	if vm.Webhook == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The webhook is required."}))
+		return
+	}
+
+	errors := validate(vm.Webhook)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	now := time.Now()
+
+	subscription := &doorbot.WebhookSubscription{
+		AccountID: r.AccountScope(),
+		URL:       vm.Webhook.URL,
+		Secret:    vm.Webhook.Secret,
+		EventIDs:  vm.Webhook.EventIDs,
+		IsEnabled: vm.Webhook.IsEnabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if len(subscription.Secret) == 0 {
+		subscription.Secret = security.GenerateAPIToken()
+	}
+
+	err := r.WebhookSubscriptionRepository().Create(r.DB(), subscription)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+		}).Error("Api::Webhooks->Post database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":      r.AccountScope(),
+		"subscription_id": subscription.ID,
+	}).Info("Api::Webhooks->Post subscription created")
+
+	render.JSON(http.StatusCreated, WebhookViewModel{Webhook: subscription})
+}
+
+// Put updates a webhook subscription. The secret is only rotated when a new one is provided.
+func Put(render render.Render, r doorbot.Repositories, params martini.Params, vm WebhookViewModel) {
+	if vm.Webhook == nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The webhook is required."}))
+		return
+	}
+
+	errors := validate(vm.Webhook)
+	if len(errors) > 0 {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse(errors))
+		return
+	}
+
+	subscription, ok := find(render, r, params["id"], "Put")
+	if !ok {
+		return
+	}
+
+	subscription.URL = vm.Webhook.URL
+	subscription.EventIDs = vm.Webhook.EventIDs
+	subscription.IsEnabled = vm.Webhook.IsEnabled
+	subscription.UpdatedAt = time.Now()
+
+	if len(vm.Webhook.Secret) > 0 {
+		subscription.Secret = vm.Webhook.Secret
+	}
+
+	_, err := r.WebhookSubscriptionRepository().Update(r.DB(), subscription)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"subscription_id": subscription.ID,
+			"step":            "subscription-update",
+		}).Error("Api::Webhooks->Put database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":      r.AccountScope(),
+		"subscription_id": subscription.ID,
+	}).Info("Api::Webhooks->Put subscription updated")
+
+	render.JSON(http.StatusOK, WebhookViewModel{Webhook: subscription})
+}
+
+// Delete a webhook subscription
+func Delete(render render.Render, r doorbot.Repositories, params martini.Params) {
+	subscription, ok := find(render, r, params["id"], "Delete")
+	if !ok {
+		return
+	}
+
+	_, err := r.WebhookSubscriptionRepository().Delete(r.DB(), subscription)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"subscription_id": subscription.ID,
+			"step":            "subscription-delete",
+		}).Error("Api::Webhooks->Delete database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":      r.AccountScope(),
+		"subscription_id": subscription.ID,
+	}).Info("Api::Webhooks->Delete subscription deleted")
+
+	render.Status(http.StatusNoContent)
+}
+
+// Deliveries returns the most recent deliveries of a webhook subscription
+func Deliveries(render render.Render, r doorbot.Repositories, params martini.Params) {
+	subscription, ok := find(render, r, params["id"], "Deliveries")
+	if !ok {
+		return
+	}
+
+	deliveries, err := r.WebhookDeliveryRepository().FindBySubscriptionID(r.DB(), subscription.ID, deliveriesLimit)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"subscription_id": subscription.ID,
+			"step":            "deliveries-find",
+		}).Error("Api::Webhooks->Deliveries database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	render.JSON(http.StatusOK, DeliveriesViewModel{Deliveries: deliveries})
+}
+
+// Redeliver queues a new delivery of the event sent by a previous delivery.
+func Redeliver(render render.Render, r doorbot.Repositories, params martini.Params) {
+	subscription, ok := find(render, r, params["id"], "Redeliver")
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(params["delivery_id"], 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The delivery id must be an unsigned integer"}))
+		return
+	}
+
+	repo := r.WebhookDeliveryRepository()
+
+	previous, err := repo.Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"subscription_id": subscription.ID,
+			"delivery_id":     id,
+			"step":            "delivery-find",
+		}).Error("Api::Webhooks->Redeliver database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	if previous == nil || previous.SubscriptionID != subscription.ID {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified delivery does not exists"}))
+		return
+	}
+
+	delivery := doorbot.NewWebhookDelivery(r.AccountScope(), subscription.ID, previous.EventID)
+
+	err = repo.Create(r.DB(), delivery)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"subscription_id": subscription.ID,
+			"delivery_id":     id,
+			"step":            "delivery-create",
+		}).Error("Api::Webhooks->Redeliver database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":           r.AccountScope(),
+		"subscription_id":      subscription.ID,
+		"delivery_id":          delivery.ID,
+		"previous_delivery_id": previous.ID,
+	}).Info("Api::Webhooks->Redeliver delivery queued")
+
+	render.JSON(http.StatusCreated, DeliveryViewModel{Delivery: delivery})
+}
+
+// find loads the subscription identified by `value`, rendering the error response when it cannot.
+func find(render render.Render, r doorbot.Repositories, value string, method string) (*doorbot.WebhookSubscription, bool) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		render.JSON(http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{"The id must be an unsigned integer"}))
+		return nil, false
+	}
+
+	subscription, err := r.WebhookSubscriptionRepository().Find(r.DB(), uint(id))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      r.AccountScope(),
+			"subscription_id": id,
+			"step":            "subscription-find",
+		}).Error("Api::Webhooks->" + method + " database error")
+
+		render.JSON(http.StatusInternalServerError, doorbot.NewInternalServerErrorResponse([]string{}))
+		return nil, false
+	}
+
+	if subscription == nil {
+		render.JSON(http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified webhook does not exists"}))
+		return nil, false
+	}
+
+	return subscription, true
+}
+
+// validate returns the validation errors of a subscription
+func validate(s *doorbot.WebhookSubscription) []string {
+	var errors []string
+
+	if !webhook.PublicURL(s.URL) {
+		errors = append(errors, "The webhook URL must be an absolute http or https URL outside of the private network.")
+	}
+
+	if len(s.EventIDs) == 0 {
+		errors = append(errors, "At least one event must be subscribed to.")
+	}
+
+	for _, id := range s.EventIDs {
+		if !doorbot.IsEvent(id) {
+			errors = append(errors, fmt.Sprintf("%d is not a valid event.", id))
+		}
+	}
+
+	return errors
+}
@@ -0,0 +1,174 @@
+// +build tests
+
+package webhooks
+
+import (
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/go-martini/martini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	subscriptions := []*doorbot.WebhookSubscription{
+		&doorbot.WebhookSubscription{ID: 1, URL: "https://example.com/hook"},
+	}
+
+	render := new(tests.MockRender)
+	repo := new(tests.MockWebhookSubscriptionRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("WebhookSubscriptionRepository").Return(repo)
+	repositories.On("DB").Return(db)
+
+	repo.On("All", db).Return(subscriptions, nil)
+	render.On("JSON", http.StatusOK, WebhooksViewModel{Webhooks: subscriptions}).Return()
+
+	Index(render, repositories)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestPost(t *testing.T) {
+	render := new(tests.MockRender)
+	repo := new(tests.MockWebhookSubscriptionRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("WebhookSubscriptionRepository").Return(repo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(3))
+
+	vm := WebhookViewModel{
+		Webhook: &doorbot.WebhookSubscription{
+			URL:       "https://example.com/hook",
+			EventIDs:  doorbot.EventIDs{doorbot.EventDoorAdded},
+			IsEnabled: true,
+		},
+	}
+
+	repo.On("Create", db, mock.AnythingOfType("*doorbot.WebhookSubscription")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("WebhookViewModel")).Return()
+
+	Post(render, repositories, vm)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+
+	created := render.Mock.Calls[0].Arguments.Get(1).(WebhookViewModel).Webhook
+	assert.Equal(t, uint(3), created.AccountID)
+	assert.NotEmpty(t, created.Secret)
+	assert.Equal(t, doorbot.EventIDs{doorbot.EventDoorAdded}, created.EventIDs)
+}
+
+func TestPostInvalid(t *testing.T) {
+	render := new(tests.MockRender)
+	repositories := new(tests.MockRepositories)
+
+	vm := WebhookViewModel{
+		Webhook: &doorbot.WebhookSubscription{
+			URL:      "ftp://example.com",
+			EventIDs: doorbot.EventIDs{9999},
+		},
+	}
+
+	render.On("JSON", http.StatusBadRequest, doorbot.NewBadRequestErrorResponse([]string{
+		"The webhook URL must be an absolute http or https URL outside of the private network.",
+		"9999 is not a valid event.",
+	})).Return()
+
+	Post(render, repositories, vm)
+
+	render.Mock.AssertExpectations(t)
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestPutKeepsSecret(t *testing.T) {
+	render := new(tests.MockRender)
+	repo := new(tests.MockWebhookSubscriptionRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("WebhookSubscriptionRepository").Return(repo)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(3))
+
+	subscription := &doorbot.WebhookSubscription{ID: 4, URL: "https://example.com/old", Secret: "secret"}
+
+	vm := WebhookViewModel{
+		Webhook: &doorbot.WebhookSubscription{
+			URL:      "https://example.com/new",
+			EventIDs: doorbot.EventIDs{doorbot.EventDoorAdded},
+		},
+	}
+
+	repo.On("Find", db, uint(4)).Return(subscription, nil)
+	repo.On("Update", db, subscription).Return(true, nil)
+	render.On("JSON", http.StatusOK, WebhookViewModel{Webhook: subscription}).Return()
+
+	Put(render, repositories, martini.Params{"id": "4"}, vm)
+
+	render.Mock.AssertExpectations(t)
+	repo.Mock.AssertExpectations(t)
+
+	assert.Equal(t, "https://example.com/new", subscription.URL)
+	assert.Equal(t, "secret", subscription.Secret)
+}
+
+func TestRedeliver(t *testing.T) {
+	render := new(tests.MockRender)
+	subscriptions := new(tests.MockWebhookSubscriptionRepository)
+	deliveries := new(tests.MockWebhookDeliveryRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("WebhookSubscriptionRepository").Return(subscriptions)
+	repositories.On("WebhookDeliveryRepository").Return(deliveries)
+	repositories.On("DB").Return(db)
+	repositories.On("AccountScope").Return(uint(3))
+
+	subscription := &doorbot.WebhookSubscription{ID: 4}
+	previous := &doorbot.WebhookDelivery{ID: 8, SubscriptionID: 4, EventID: 33, Status: doorbot.WebhookDeliveryDead}
+
+	subscriptions.On("Find", db, uint(4)).Return(subscription, nil)
+	deliveries.On("Find", db, uint(8)).Return(previous, nil)
+	deliveries.On("Create", db, mock.AnythingOfType("*doorbot.WebhookDelivery")).Return(nil)
+	render.On("JSON", http.StatusCreated, mock.AnythingOfType("DeliveryViewModel")).Return()
+
+	Redeliver(render, repositories, martini.Params{"id": "4", "delivery_id": "8"})
+
+	render.Mock.AssertExpectations(t)
+	deliveries.Mock.AssertExpectations(t)
+
+	delivery := render.Mock.Calls[0].Arguments.Get(1).(DeliveryViewModel).Delivery
+	assert.Equal(t, uint(33), delivery.EventID)
+	assert.Equal(t, uint(4), delivery.SubscriptionID)
+	assert.Equal(t, doorbot.WebhookDeliveryPending, delivery.Status)
+}
+
+func TestRedeliverOtherSubscription(t *testing.T) {
+	render := new(tests.MockRender)
+	subscriptions := new(tests.MockWebhookSubscriptionRepository)
+	deliveries := new(tests.MockWebhookDeliveryRepository)
+	db := new(tests.MockExecutor)
+
+	repositories := new(tests.MockRepositories)
+	repositories.On("WebhookSubscriptionRepository").Return(subscriptions)
+	repositories.On("WebhookDeliveryRepository").Return(deliveries)
+	repositories.On("DB").Return(db)
+
+	subscriptions.On("Find", db, uint(4)).Return(&doorbot.WebhookSubscription{ID: 4}, nil)
+	deliveries.On("Find", db, uint(8)).Return(&doorbot.WebhookDelivery{ID: 8, SubscriptionID: 5}, nil)
+	render.On("JSON", http.StatusNotFound, doorbot.NewEntityNotFoundResponse([]string{"The specified delivery does not exists"})).Return()
+
+	Redeliver(render, repositories, martini.Params{"id": "4", "delivery_id": "8"})
+
+	render.Mock.AssertExpectations(t)
+	deliveries.Mock.AssertExpectations(t)
+}
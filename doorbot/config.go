@@ -4,6 +4,7 @@ import (
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"strings"
+	"time"
 )
 
 // DatabaseConfig holds database configuration values
@@ -17,16 +18,108 @@ type ServerConfig struct {
 	Port uint
 }
 
+// NotificatorConfig holds notification delivery queue configuration values
+type NotificatorConfig struct {
+	// Number of delivery workers started by the server. The queue is disabled when set to 0.
+	Workers int
+	// Number of delivery attempts before a notification is marked as dead.
+	MaxAttempts int
+	// Delay between two polls of the queue when it is empty.
+	PollInterval time.Duration
+	// Delay before the first retry. Following retries double the delay up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// Notifications locked for longer than this delay are considered abandoned by a crashed worker.
+	LockTimeout time.Duration
+	// Consecutive failures after which a provider is skipped in favor of the next one of the same kind.
+	BreakerThreshold uint
+	// Delay before a skipped provider is tried again.
+	BreakerCooldown time.Duration
+}
+
+// DigestConfig holds the lobby activity digest scheduler configuration values
+type DigestConfig struct {
+	// Delay between two checks for due digests. The scheduler is disabled when set to 0.
+	Interval time.Duration
+	// Hour of the day ( UTC ) after which the digests are sent.
+	Hour int
+	// Enabled devices not seen for longer than this delay are reported as offline.
+	OfflineAfter time.Duration
+}
+
+// WebhookConfig holds the webhook subscriptions delivery configuration values
+type WebhookConfig struct {
+	// Number of delivery workers started by the server. Deliveries are disabled when set to 0.
+	Workers int
+	// Number of delivery attempts before a delivery is marked as dead.
+	MaxAttempts int
+	// Delay between two polls of the deliveries when none are due.
+	PollInterval time.Duration
+	// Delay before the first retry. Following retries double the delay up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// Deliveries locked for longer than this delay are considered abandoned by a crashed worker.
+	LockTimeout time.Duration
+}
+
+// SMTPConfig holds the mail relay configuration values used by self-hosted deployments
+type SMTPConfig struct {
+	// The SMTP notifier is disabled when no host is set.
+	Host     string
+	Port     int
+	StartTLS bool
+	Username string
+	Password string
+	// Default sender address, accounts can set their own.
+	From string
+}
+
+// ProvidersConfig holds the platform notification provider credentials.
+// Accounts without their own credentials send through these.
+type ProvidersConfig struct {
+	HipChatToken string
+
+	MailgunAPIKey string
+	MailgunDomain string
+
+	NexmoAPIKey    string
+	NexmoAPISecret string
+	NexmoFrom      string
+
+	PostmarkToken string
+
+	SlackToken string
+
+	TwilioAccountSID  string
+	TwilioToken       string
+	TwilioPhoneNumber string
+
+	APNsToken string
+	APNsTopic string
+	FCMKey    string
+
+	// Default sender address of the Postmark and Mailgun emails, accounts can set their own.
+	EmailFrom string
+}
+
 // DoorbotConfig holds doorbot configuration values
 type DoorbotConfig struct {
 	Debug   bool
 	Domains map[string]interface{}
 
-	Server   ServerConfig
-	Database DatabaseConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Notificator NotificatorConfig
+	Digest      DigestConfig
+	Webhooks    WebhookConfig
+	SMTP        SMTPConfig
+	Providers   ProvidersConfig
 
 	// base domain name for user accounts ex: [name].doorbot.com
 	UserAccountsDomain string
+
+	// Secret used to sign the links sent in notifications
+	Secret string
 }
 
 // EnvConfig holds environment-provided configuration values
@@ -38,16 +131,40 @@ type EnvConfig struct {
 	NotificatorPostmarkToken string
 	NotificatorEmailFrom     string
 
+	NotificatorWorkers     int
+	NotificatorMaxAttempts int
+
 	NotificatorSmsEnabled        bool
 	NotificatorTwilioId          string
 	NotificatorTwilioToken       string
 	NotificatorTwilioPhoneNumber string
 
+	NotificatorHipchatToken string
+	NotificatorSlackToken   string
+
+	NotificatorMailgunApiKey string
+	NotificatorMailgunDomain string
+
+	NotificatorNexmoApiKey    string
+	NotificatorNexmoApiSecret string
+	NotificatorNexmoFrom      string
+
+	NotificatorApnsToken string
+	NotificatorApnsTopic string
+	NotificatorFcmKey    string
+
+	NotificatorSmtpHost     string
+	NotificatorSmtpPort     int
+	NotificatorSmtpStarttls bool
+	NotificatorSmtpUsername string
+	NotificatorSmtpPassword string
+
 	ServerPort    uint
 	DatabaseTrace bool
 	DatabaseUrl   string
 
 	UserAccountsDomain string
+	Secret             string
 }
 
 // HerokuConfig holds configuration data provided within the Heroku environment.
@@ -57,16 +174,114 @@ type HerokuConfig struct {
 
 	NotificatorEmailEnabled  bool
 	NotificatorPostmarkToken string
+	NotificatorEmailFrom     string
 
 	NotificatorSmsEnabled        bool
 	NotificatorTwilioId          string
 	NotificatorTwilioToken       string
 	NotificatorTwilioPhoneNumber string
 
+	NotificatorHipchatToken string
+	NotificatorSlackToken   string
+
+	NotificatorMailgunApiKey string
+	NotificatorMailgunDomain string
+
+	NotificatorNexmoApiKey    string
+	NotificatorNexmoApiSecret string
+	NotificatorNexmoFrom      string
+
+	NotificatorApnsToken string
+	NotificatorApnsTopic string
+	NotificatorFcmKey    string
+
+	NotificatorSmtpHost     string
+	NotificatorSmtpPort     int
+	NotificatorSmtpStarttls bool
+	NotificatorSmtpUsername string
+	NotificatorSmtpPassword string
+
+	NotificatorWorkers     int
+	NotificatorMaxAttempts int
+
 	Port    uint
 	Domains string
 
 	UserAccountsDomain string
+	Secret             string
+}
+
+// Unset marks the numeric environment values that were not provided, where 0 is a meaningful value.
+const Unset = -1
+
+// NewEnvConfig creates an EnvConfig where the values that can be 0 start unset
+func NewEnvConfig() *EnvConfig {
+	return &EnvConfig{
+		NotificatorWorkers: Unset,
+	}
+}
+
+// NewHerokuConfig creates a HerokuConfig where the values that can be 0 start unset
+func NewHerokuConfig() *HerokuConfig {
+	return &HerokuConfig{
+		NotificatorWorkers: Unset,
+	}
+}
+
+// NewNotificatorConfig creates a NotificatorConfig with default values
+func NewNotificatorConfig() NotificatorConfig {
+	return NotificatorConfig{
+		Workers:       2,
+		MaxAttempts:   5,
+		PollInterval:  time.Second,
+		RetryDelay:    5 * time.Second,
+		MaxRetryDelay: 5 * time.Minute,
+		LockTimeout:   2 * time.Minute,
+
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// NewDigestConfig creates a DigestConfig with default values
+func NewDigestConfig() DigestConfig {
+	return DigestConfig{
+		Interval:     5 * time.Minute,
+		Hour:         8,
+		OfflineAfter: time.Hour,
+	}
+}
+
+// NewWebhookConfig creates a WebhookConfig with default values
+func NewWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Workers:       1,
+		MaxAttempts:   8,
+		PollInterval:  time.Second,
+		RetryDelay:    10 * time.Second,
+		MaxRetryDelay: time.Hour,
+		LockTimeout:   2 * time.Minute,
+	}
+}
+
+// NewSMTPConfig creates a SMTPConfig with default values
+func NewSMTPConfig() SMTPConfig {
+	return SMTPConfig{
+		Port: 25,
+		From: "martin@doorbot.co",
+	}
+}
+
+// Override sets the worker and attempt counts provided by the environment, when set.
+// Setting 0 workers disables the queue, Unset keeps the default.
+func (c *NotificatorConfig) Override(workers int, attempts int) {
+	if workers >= 0 {
+		c.Workers = workers
+	}
+
+	if attempts > 0 {
+		c.MaxAttempts = attempts
+	}
 }
 
 // ParseDomains pasrses the provided domains and set them on the configuration instance.
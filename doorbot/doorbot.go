@@ -2,6 +2,7 @@ package doorbot
 
 import (
 	"database/sql"
+	"time"
 )
 
 const (
@@ -46,6 +47,21 @@ const (
 	// EventDeviceSignOut data point
 	EventDeviceSignOut = 36
 
+	// EventVisitCheckedIn data point
+	EventVisitCheckedIn = 40
+	// EventVisitCheckedOut data point
+	EventVisitCheckedOut = 41
+
+	// EventTeamAdded data point
+	EventTeamAdded = 50
+	// EventTeamRemoved data point
+	EventTeamRemoved = 51
+	// EventTeamUpdated data point
+	EventTeamUpdated = 52
+
+	// EventKnockKnock data point, recorded for every knock-knock requested by a device
+	EventKnockKnock = 60
+
 	// EventNotificationSent data point
 	EventNotificationSent = 100
 	// EventNotificationSMSSent data point
@@ -56,8 +72,168 @@ const (
 	EventNotificationAppSent = 103
 	// EventNotificationWebhookSent data point
 	EventNotificationWebhookSent = 104
+
+	// EventNotificationAcknowledged data point
+	EventNotificationAcknowledged = 110
+	// EventNotificationSMSAcknowledged data point
+	EventNotificationSMSAcknowledged = 111
+	// EventNotificationEmailAcknowledged data point
+	EventNotificationEmailAcknowledged = 112
+	// EventNotificationAppAcknowledged data point
+	EventNotificationAppAcknowledged = 113
+)
+
+// events lists the known event codes
+var events = map[uint]bool{
+	EventSignIn:                        true,
+	EventSignOut:                       true,
+	EventDoorAdded:                     true,
+	EventDoorRemoved:                   true,
+	EventDoorUpdated:                   true,
+	EventPersonAdded:                   true,
+	EventPersonRemoved:                 true,
+	EventPersonUpdated:                 true,
+	EventDeviceAdded:                   true,
+	EventDeviceRemoved:                 true,
+	EventDeviceUpdated:                 true,
+	EventDeviceAssigned:                true,
+	EventDeviceUnassigned:              true,
+	EventDeviceSignIn:                  true,
+	EventDeviceSignOut:                 true,
+	EventVisitCheckedIn:                true,
+	EventVisitCheckedOut:               true,
+	EventTeamAdded:                     true,
+	EventTeamRemoved:                   true,
+	EventTeamUpdated:                   true,
+	EventKnockKnock:                    true,
+	EventNotificationSent:              true,
+	EventNotificationSMSSent:           true,
+	EventNotificationEmailSent:         true,
+	EventNotificationAppSent:           true,
+	EventNotificationWebhookSent:       true,
+	EventNotificationAcknowledged:      true,
+	EventNotificationSMSAcknowledged:   true,
+	EventNotificationEmailAcknowledged: true,
+	EventNotificationAppAcknowledged:   true,
+}
+
+// IsEvent tells if an event code is one of the Event* constants
+func IsEvent(id uint) bool {
+	return events[id]
+}
+
+const (
+	// EventActorSystem the event was caused by doorbot itself ( ex: the notification queue )
+	EventActorSystem = "system"
+	// EventActorPerson the event was caused by a person
+	EventActorPerson = "person"
+	// EventActorDevice the event was caused by a device
+	EventActorDevice = "device"
+	// EventActorAdministrator the event was caused by a doorbot administrator
+	EventActorAdministrator = "administrator"
+)
+
+const (
+	// ReportIntervalDay buckets the report rows by day
+	ReportIntervalDay = "day"
+	// ReportIntervalWeek buckets the report rows by week, starting on monday
+	ReportIntervalWeek = "week"
+	// ReportIntervalMonth buckets the report rows by month
+	ReportIntervalMonth = "month"
+
+	// ReportGroupDoor reports one row per door and period
+	ReportGroupDoor = "door"
+	// ReportGroupDevice reports one row per device and period
+	ReportGroupDevice = "device"
+	// ReportGroupPerson reports one row per person and period
+	ReportGroupPerson = "person"
+)
+
+const (
+	// PushPlatformAPNs devices reached through the Apple Push Notification service
+	PushPlatformAPNs = "apns"
+	// PushPlatformFCM devices reached through Firebase Cloud Messaging
+	PushPlatformFCM = "fcm"
+)
+
+const (
+	// WebhookDeliveryPending the delivery is waiting for a worker
+	WebhookDeliveryPending = "pending"
+	// WebhookDeliveryProcessing the delivery is being sent by a worker
+	WebhookDeliveryProcessing = "processing"
+	// WebhookDeliveryDelivered the endpoint accepted the delivery
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryDead the delivery failed after all attempts
+	WebhookDeliveryDead = "dead"
+)
+
+const (
+	// NotificationPending the notification is waiting for a delivery worker
+	NotificationPending = "pending"
+	// NotificationProcessing the notification is being delivered by a worker
+	NotificationProcessing = "processing"
+	// NotificationDelivered the notification was delivered on at least one channel
+	NotificationDelivered = "delivered"
+	// NotificationEscalating the notification was delivered and further escalation steps are scheduled
+	NotificationEscalating = "escalating"
+	// NotificationDead the notification could not be delivered after all attempts
+	NotificationDead = "dead"
+
+	// NotificationAttemptDelivered the channel accepted the notification
+	NotificationAttemptDelivered = "delivered"
+	// NotificationAttemptFailed the channel returned an error
+	NotificationAttemptFailed = "failed"
+	// NotificationAttemptSkipped the channel provider circuit was open, the next provider was used
+	NotificationAttemptSkipped = "skipped"
+
+	// NotificationChannelChat chat notifiers ( HipChat, Slack )
+	NotificationChannelChat = "chat"
+	// NotificationChannelSMS sms notifiers ( Nexmo, Twilio )
+	NotificationChannelSMS = "sms"
+	// NotificationChannelEmail email notifiers ( Mailgun, Postmark )
+	NotificationChannelEmail = "email"
+	// NotificationChannelApp the doorbot dashboard and companion apps
+	NotificationChannelApp = "app"
+	// NotificationChannelWebhook signed HTTP callbacks to custom integrations
+	NotificationChannelWebhook = "webhook"
+
+	// AcknowledgementOnMyWay the person is coming to the door
+	AcknowledgementOnMyWay = "on_my_way"
+	// AcknowledgementPleaseWait the person will come to the door but asks the visitor to wait
+	AcknowledgementPleaseWait = "please_wait"
+	// AcknowledgementUnavailable the person cannot come to the door
+	AcknowledgementUnavailable = "unavailable"
+
+	// NotificationsModeFirst stops at the first channel accepting the notification
+	NotificationsModeFirst = "first"
+	// NotificationsModeAll notifies every enabled channel at once
+	NotificationsModeAll = "all"
+	// NotificationsModeEscalate follows the person escalation policy
+	NotificationsModeEscalate = "escalate"
+
+	// EscalationRecipientPerson the escalation step targets the notified person
+	EscalationRecipientPerson = "person"
+	// EscalationRecipientAccountContact the escalation step targets the account contact
+	EscalationRecipientAccountContact = "account_contact"
+
+	// TeamStrategyBroadcast knocks every available member of the team
+	TeamStrategyBroadcast = "broadcast"
+	// TeamStrategyRoundRobin knocks the next available member, taking turns
+	TeamStrategyRoundRobin = "round_robin"
+	// TeamStrategyFirstAvailable knocks the first available member, in the team order
+	TeamStrategyFirstAvailable = "first_available"
+
+	// DigestNever the person does not receive the lobby activity digest
+	DigestNever = "never"
+	// DigestDaily the digest is sent every day
+	DigestDaily = "daily"
+	// DigestWeekly the digest is sent every monday
+	DigestWeekly = "weekly"
 )
 
+// DeviceSeenInterval is the minimum delay between two updates of Device.LastSeenAt
+const DeviceSeenInterval = 5 * time.Minute
+
 // Repositories interface
 type Repositories interface {
 	// Return the database instance
@@ -71,10 +247,26 @@ type Repositories interface {
 	AdministratorRepository() AdministratorRepository
 	AdministratorAuthenticationRepository() AdministratorAuthenticationRepository
 	BridgeUserRepository() BridgeUserRepository
+	DelegateRepository() DelegateRepository
 	DeviceRepository() DeviceRepository
 	DoorRepository() DoorRepository
+	EscalationStepRepository() EscalationStepRepository
 	EventRepository() EventRepository
+	InvitationRepository() InvitationRepository
+	NotificationRepository() NotificationRepository
+	NotificationAttemptRepository() NotificationAttemptRepository
+	NotificationTemplateRepository() NotificationTemplateRepository
+	OutOfOfficeRepository() OutOfOfficeRepository
 	PersonRepository() PersonRepository
+	PushDeviceRepository() PushDeviceRepository
+	ReportRepository() ReportRepository
+	TeamRepository() TeamRepository
+	TeamMemberRepository() TeamMemberRepository
+	VisitRepository() VisitRepository
+	VisitorRepository() VisitorRepository
+	WebhookDeliveryRepository() WebhookDeliveryRepository
+	WebhookSubscriptionRepository() WebhookSubscriptionRepository
+	WorkingHoursRepository() WorkingHoursRepository
 
 	SetAccountScope(uint)
 
@@ -142,6 +334,7 @@ type DeviceRepository interface {
 	Find(Executor, uint) (*Device, error)
 	FindByDeviceID(Executor, string) (*Device, error)
 	FindByToken(Executor, string) (*Device, error)
+	Seen(Executor, *Device) (bool, error)
 	SetAccountScope(uint)
 	Update(t Executor, d *Device) (bool, error)
 	Enable(t Executor, d *Device, enabled bool) (bool, error)
@@ -157,17 +350,83 @@ type DoorRepository interface {
 	Update(Executor, *Door) (bool, error)
 }
 
+// DelegateRepository repository interface
+type DelegateRepository interface {
+	Create(Executor, *Delegate) error
+	DeleteByPersonID(Executor, uint) error
+	FindByPersonID(Executor, uint) ([]*Delegate, error)
+	SetAccountScope(uint)
+}
+
+// EscalationStepRepository repository interface
+type EscalationStepRepository interface {
+	Create(Executor, *EscalationStep) error
+	DeleteByPersonID(Executor, uint) error
+	FindByPersonID(Executor, uint) ([]*EscalationStep, error)
+	SetAccountScope(uint)
+}
+
 // EventRepository repository interface
 type EventRepository interface {
-	All(Executor, *Pagination) ([]*Event, error)
+	All(Executor, *EventFilter) ([]*Event, error)
 	Create(Executor, *Event) error
 	Find(Executor, uint) (*Event, error)
 	SetAccountScope(uint)
 }
 
+// InvitationRepository repository interface
+type InvitationRepository interface {
+	All(Executor, *InvitationFilter) ([]*Invitation, error)
+	Create(Executor, *Invitation) error
+	Delete(Executor, *Invitation) (bool, error)
+	Find(Executor, uint) (*Invitation, error)
+	FindByCode(Executor, string) (*Invitation, error)
+	SetAccountScope(uint)
+	Update(Executor, *Invitation) (bool, error)
+	// Use links the visit of the guest to an invitation. Returns false when the invitation was already used.
+	Use(Executor, *Invitation, uint) (bool, error)
+}
+
+// NotificationRepository repository interface
+type NotificationRepository interface {
+	All(Executor, *NotificationFilter) ([]*Notification, error)
+	Create(Executor, *Notification) error
+	Find(Executor, uint) (*Notification, error)
+	Update(Executor, *Notification) (bool, error)
+	// Claim locks up to `limit` notifications due for delivery. Notifications
+	// left processing for longer than `timeout` are considered abandoned and claimed again.
+	Claim(e Executor, limit uint, timeout time.Duration) ([]*Notification, error)
+	// Acknowledge saves the acknowledgement fields of a notification. Only the first acknowledgement is kept.
+	Acknowledge(Executor, *Notification) (bool, error)
+	// UpdateDelivery saves the delivery fields of a notification, leaving an acknowledgement made meanwhile untouched.
+	UpdateDelivery(Executor, *Notification) (bool, error)
+	// Lock serializes the knock-knocks of the account across API instances until the transaction ends.
+	Lock(Transaction) error
+	SetAccountScope(uint)
+}
+
+// NotificationAttemptRepository repository interface
+type NotificationAttemptRepository interface {
+	Create(Executor, *NotificationAttempt) error
+	FindByNotificationIDs(Executor, []uint) ([]*NotificationAttempt, error)
+	SetAccountScope(uint)
+}
+
+// NotificationTemplateRepository repository interface
+type NotificationTemplateRepository interface {
+	All(Executor) ([]*NotificationTemplate, error)
+	Create(Executor, *NotificationTemplate) error
+	Delete(Executor, *NotificationTemplate) (bool, error)
+	Find(Executor, uint) (*NotificationTemplate, error)
+	FindByChannel(Executor, string, uint) (*NotificationTemplate, error)
+	SetAccountScope(uint)
+	Update(Executor, *NotificationTemplate) (bool, error)
+}
+
 // PersonRepository repository interface
 type PersonRepository interface {
 	All(Executor) ([]*Person, error)
+	ClaimDigest(Executor, *Person, time.Time) (bool, error)
 	Create(Executor, *Person) error
 	Delete(Executor, *Person) (bool, error)
 	Find(Executor, uint) (*Person, error)
@@ -176,11 +435,174 @@ type PersonRepository interface {
 	Update(Executor, *Person) (bool, error)
 }
 
-// Pagination data structure
-type Pagination struct {
-	Page  uint
-	Limit uint
-	Order string
+// OutOfOfficeRepository repository interface
+type OutOfOfficeRepository interface {
+	Create(Executor, *OutOfOffice) error
+	Delete(Executor, *OutOfOffice) (bool, error)
+	Find(Executor, uint) (*OutOfOffice, error)
+	FindByPersonID(Executor, uint) ([]*OutOfOffice, error)
+	FindCurrent(Executor, time.Time) ([]*OutOfOffice, error)
+	SetAccountScope(uint)
+	Update(Executor, *OutOfOffice) (bool, error)
+}
+
+// PushDeviceRepository repository interface
+type PushDeviceRepository interface {
+	Create(Executor, *PushDevice) error
+	Delete(Executor, *PushDevice) (bool, error)
+	Find(Executor, uint) (*PushDevice, error)
+	FindByPersonID(Executor, uint) ([]*PushDevice, error)
+	FindByToken(Executor, string) (*PushDevice, error)
+	SetAccountScope(uint)
+	Update(Executor, *PushDevice) (bool, error)
+}
+
+// VisitRepository repository interface
+type VisitRepository interface {
+	All(Executor, *VisitFilter) ([]*Visit, error)
+	Create(Executor, *Visit) error
+	Find(Executor, uint) (*Visit, error)
+	SetAccountScope(uint)
+	Update(Executor, *Visit) (bool, error)
+}
+
+// ReportRepository repository interface. Reports are aggregated by the database.
+type ReportRepository interface {
+	ResponseTimes(Executor, *ReportFilter) ([]*ResponseTimeReport, error)
+	SetAccountScope(uint)
+	Visits(Executor, *ReportFilter) ([]*VisitReport, error)
+}
+
+// TeamRepository repository interface
+type TeamRepository interface {
+	All(Executor) ([]*Team, error)
+	Create(Executor, *Team) error
+	Delete(Executor, *Team) (bool, error)
+	Find(Executor, uint) (*Team, error)
+	// FindByBridgeID returns the teams synced from a bridge
+	FindByBridgeID(Executor, uint) ([]*Team, error)
+	SetAccountScope(uint)
+	Update(Executor, *Team) (bool, error)
+	// UpdateLastKnocked saves the member knocked last by a round-robin team, leaving the other fields untouched.
+	UpdateLastKnocked(Executor, *Team) (bool, error)
+}
+
+// TeamMemberRepository repository interface
+type TeamMemberRepository interface {
+	All(Executor) ([]*TeamMember, error)
+	Create(Executor, *TeamMember) error
+	DeleteByTeamID(Executor, uint) error
+	FindByTeamID(Executor, uint) ([]*TeamMember, error)
+	SetAccountScope(uint)
+}
+
+// VisitorRepository repository interface
+type VisitorRepository interface {
+	Create(Executor, *Visitor) error
+	Find(Executor, uint) (*Visitor, error)
+	FindByEmail(Executor, string) (*Visitor, error)
+	FindByIDs(Executor, []uint) ([]*Visitor, error)
+	SetAccountScope(uint)
+	Update(Executor, *Visitor) (bool, error)
+}
+
+// WebhookDeliveryRepository repository interface
+type WebhookDeliveryRepository interface {
+	// Claim locks up to `limit` deliveries due to be sent. Deliveries left
+	// processing for longer than `timeout` are considered abandoned and claimed again.
+	Claim(e Executor, limit uint, timeout time.Duration) ([]*WebhookDelivery, error)
+	Create(Executor, *WebhookDelivery) error
+	Find(Executor, uint) (*WebhookDelivery, error)
+	// FindBySubscriptionID returns the most recent deliveries of a subscription.
+	FindBySubscriptionID(e Executor, id uint, limit uint) ([]*WebhookDelivery, error)
+	SetAccountScope(uint)
+	Update(Executor, *WebhookDelivery) (bool, error)
+}
+
+// WebhookSubscriptionRepository repository interface
+type WebhookSubscriptionRepository interface {
+	All(Executor) ([]*WebhookSubscription, error)
+	Create(Executor, *WebhookSubscription) error
+	Delete(Executor, *WebhookSubscription) (bool, error)
+	Find(Executor, uint) (*WebhookSubscription, error)
+	SetAccountScope(uint)
+	Update(Executor, *WebhookSubscription) (bool, error)
+}
+
+// WorkingHoursRepository repository interface
+type WorkingHoursRepository interface {
+	All(Executor) ([]*WorkingHours, error)
+	Create(Executor, *WorkingHours) error
+	DeleteByPersonID(Executor, uint) error
+	FindByPersonID(Executor, uint) ([]*WorkingHours, error)
+	SetAccountScope(uint)
+}
+
+// EventFilter holds the optional criterias used to list events, most recent first.
+// Zero values are ignored. Before is a cursor: only the events with a lower id are returned.
+type EventFilter struct {
+	EventIDs []uint
+	DoorID   uint
+	DeviceID uint
+	PersonID uint
+	Since    *time.Time
+	Until    *time.Time
+	Before   uint
+	Limit    uint
+}
+
+// InvitationFilter holds the optional criterias used to list invitations.
+// Zero values are ignored. Upcoming only returns the invitations not used and not yet expired.
+type InvitationFilter struct {
+	PersonID uint
+	Upcoming bool
+	Limit    uint
+}
+
+// NotificationFilter holds the optional criterias used to list notifications.
+// Zero values are ignored.
+type NotificationFilter struct {
+	DoorID   uint
+	PersonID uint
+	// IncludeRerouted also matches the knock-knocks rerouted from the person to someone else
+	IncludeRerouted bool
+	Since           *time.Time
+	Limit           uint
+}
+
+// ReportFilter holds the criterias used to aggregate a report over [Since, Until).
+// Interval and GroupBy take the ReportInterval* and ReportGroup* values. Zero ids are ignored.
+type ReportFilter struct {
+	Interval string
+	GroupBy  string
+	Since    time.Time
+	Until    time.Time
+	DoorID   uint
+	DeviceID uint
+	PersonID uint
+}
+
+// VisitFilter holds the optional criterias used to list visits.
+// Zero values are ignored. Active only returns the visitors who did not check out yet.
+type VisitFilter struct {
+	PersonID uint
+	DoorID   uint
+	Active   bool
+	Limit    uint
+}
+
+// KnockKnock holds the details of a knock-knock handed to the notifiers.
+type KnockKnock struct {
+	Notification *Notification
+	// The visit and visitor are only set when the knock-knock was made for a visit.
+	Visit   *Visit
+	Visitor *Visitor
+	// Signed one-click acknowledgement links, keyed by reply ( see the Acknowledgement* constants ).
+	Links map[string]string
+	// Signed token identifying the notification, used as the callback id of interactive chat messages.
+	Token string
+	// Template of the channel kind the knock-knock is sent on, when the door or account defines one.
+	Template *NotificationTemplate
 }
 
 // Notificator interface
@@ -225,3 +647,4 @@ type Executor interface {
 	SelectNullStr(query string, args ...interface{}) (sql.NullString, error)
 	SelectOne(holder interface{}, query string, args ...interface{}) error
 }
+
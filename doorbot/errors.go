@@ -35,6 +35,11 @@ type InternalServerErrorReponse struct {
 	ErrorResponse
 }
 
+// TooManyRequestsErrorResponse HTTP 429
+type TooManyRequestsErrorResponse struct {
+	ErrorResponse
+}
+
 // ServiceUnavailableErrorResponse HTTP 503
 type ServiceUnavailableErrorResponse struct {
 	ErrorResponse
@@ -68,6 +73,13 @@ func NewInternalServerErrorResponse(errors []string) *InternalServerErrorReponse
 	return response
 }
 
+// NewTooManyRequestsErrorResponse creates a new TooManyRequestsErrorResponse
+func NewTooManyRequestsErrorResponse(errors []string) *TooManyRequestsErrorResponse {
+	response := &TooManyRequestsErrorResponse{}
+	response.Errors = errors
+	return response
+}
+
 // NewServiceUnavailableErrorResponse creates a new ServiceUnavailableErrorResponse
 func NewServiceUnavailableErrorResponse(errors []string) *ServiceUnavailableErrorResponse {
 	response := &ServiceUnavailableErrorResponse{}
@@ -1,6 +1,11 @@
 package doorbot
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,27 +31,62 @@ type Account struct {
 	ContactEmailConfirmed bool   `db:"contact_email_confirmed" json:"-"`
 	ContactPhoneNumber    string `db:"contact_phone_number" json:"contact_phone_number"`
 
+	// Person taking the knock-knocks of people who are unavailable and have no available delegate.
+	ReceptionPersonID *uint `db:"reception_person_id" json:"reception_person_id"`
+
 	NotificationsEnabled bool `db:"notifications_enabled" json:"notifications_enabled"`
 
+	// Seconds a delivered knock-knock waits for an acknowledgement before it is rerouted to a delegate, 0 disables it.
+	NotificationsFallbackDelay uint `db:"notifications_fallback_delay" json:"notifications_fallback_delay"`
+
+	// Seconds during which a knock-knock for the same person at the same door is not sent again, 0 disables it.
+	NotificationsDedupWindow uint `db:"notifications_dedup_window" json:"notifications_dedup_window"`
+	// Knock-knocks a device can send per minute, 0 disables the limit.
+	NotificationsDeviceRateLimit uint `db:"notifications_device_rate_limit" json:"notifications_device_rate_limit"`
+
 	NotificationsEmailMessageTemplate *string `db:"notifications_email_message_template" json:"notifications_email_message_template"`
 
+	// Sender address of the emails sent for the account, the server default is used when empty.
+	NotificationsEmailFrom string `db:"notifications_email_from" json:"notifications_email_from"`
+
 	NotificationsHipChatEnabled bool `db:"notifications_hipchat_enabled" json:"notifications_hipchat_enabled"`
 	NotificationsHipChatToken string `db:"notifications_hipchat_token" json:"notifications_hipchat_token"`
 
 	NotificationsMailgunEnabled         bool    `db:"notifications_mailgun_enabled" json:"notifications_mailgun_enabled"`
 
+	// Knock-knocks are posted to the Mattermost incoming webhook URL.
+	NotificationsMattermostEnabled bool   `db:"notifications_mattermost_enabled" json:"notifications_mattermost_enabled"`
+	NotificationsMattermostURL     string `db:"notifications_mattermost_url" json:"notifications_mattermost_url"`
+
 	NotificationsNexmoEnabled bool `db:"notifications_nexmo_enabled" json:"notifications_nexmo_enabled"`
 	NotificationsNexmoToken string `db:"notifications_nexmo_token" json:"notifications_nexmo_token"`
 
 	NotificationsPostmarkEnabled         bool    `db:"notifications_postmark_enabled" json:"notifications_postmark_enabled"`
 
+	// Knock-knocks are posted to the Rocket.Chat incoming webhook URL.
+	NotificationsRocketChatEnabled bool   `db:"notifications_rocketchat_enabled" json:"notifications_rocketchat_enabled"`
+	NotificationsRocketChatURL     string `db:"notifications_rocketchat_url" json:"notifications_rocketchat_url"`
+
 	NotificationsSlackEnabled bool `db:"notifications_slack_enabled" json:"notifications_slack_enabled"`
 	NotificationsSlackToken string `db:"notifications_slack_token" json:"notifications_slack_token"`
 
+	// Emails are sent through the server SMTP relay.
+	NotificationsSMTPEnabled bool `db:"notifications_smtp_enabled" json:"notifications_smtp_enabled"`
+
 	NotificationsSMSMessageTemplate *string `db:"notifications_sms_message_template" json:"notifications_sms_message_template"`
 
+	// Knock-knocks are posted to the Microsoft Teams incoming webhook URL.
+	NotificationsTeamsEnabled bool   `db:"notifications_teams_enabled" json:"notifications_teams_enabled"`
+	NotificationsTeamsURL     string `db:"notifications_teams_url" json:"notifications_teams_url"`
+
 	NotificationsTwilioEnabled           bool    `db:"notifications_twilio_enabled" json:"notifications_twilio_enabled"`
 	NotificationsTwilioSourcePhoneNumber *string `db:"notifications_twilio_source_phone_number" json:"notifications_twilio_source_phone_number"`
+	NotificationsTwilioToken             string  `db:"notifications_twilio_token" json:"notifications_twilio_token"`
+
+	// Knock-knocks are posted to the webhook URL, signed with the webhook secret.
+	NotificationsWebhookEnabled bool   `db:"notifications_webhook_enabled" json:"notifications_webhook_enabled"`
+	NotificationsWebhookURL     string `db:"notifications_webhook_url" json:"notifications_webhook_url"`
+	NotificationsWebhookSecret  string `db:"notifications_webhook_secret" json:"notifications_webhook_secret"`
 
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
@@ -91,6 +131,8 @@ type BridgeUser struct {
 	Email       string `db:"-"`
 	PhoneNumber string `db:"-"`
 	Title       string `db:"-"`
+	// Names of the groups the user belongs to on the bridge, when the bridge supports them
+	Groups []string `db:"-"`
 }
 
 // Door ... The door app client will report itself as one of these.
@@ -98,32 +140,134 @@ type Door struct {
 	AccountID uint   `db:"account_id" json:"account_id"`
 	ID        uint   `db:"id" json:"id"`
 	Name      string `db:"name" json:"name"`
+
+	// Mattermost and Rocket.Chat channel the knock-knocks of this door are posted to, instead of the webhook default.
+	NotificationsChatChannel string `db:"notifications_chat_channel" json:"notifications_chat_channel"`
+	// Microsoft Teams webhooks are bound to a channel, this URL replaces the account one for this door.
+	NotificationsTeamsURL string `db:"notifications_teams_url" json:"notifications_teams_url"`
+}
+
+// EscalationStep is a step of a person's knock-knock escalation policy.
+// Delay is the number of seconds after the knock-knock before the step is delivered.
+type EscalationStep struct {
+	ID        uint   `db:"id" json:"-"`
+	AccountID uint   `db:"account_id" json:"-"`
+	PersonID  uint   `db:"person_id" json:"-"`
+	Position  uint   `db:"position" json:"-"`
+	Channel   string `db:"channel" json:"channel"`
+	Delay     uint   `db:"delay" json:"delay"`
+	Recipient string `db:"recipient" json:"recipient"`
 }
 
 // Event holds event data.
+// The actor is who caused the event ( see the EventActor* constants ).
 type Event struct {
-	ID        uint      `db:"event_id" json:"event_id"`
+	ID        uint      `db:"id" json:"id"`
 	AccountID uint      `db:"account_id" json:"account_id"`
 	DoorID    uint      `db:"door_id" json:"door_id"`
 	DeviceID  uint      `db:"device_id" json:"device_id"`
 	EventID   uint      `db:"event_id" json:"event_id"`
 	PersonID  uint      `db:"person_id" json:"person_id"`
+	ActorType string    `db:"actor_type" json:"actor_type"`
+	ActorID   uint      `db:"actor_id" json:"actor_id"`
+	Metadata  Metadata  `db:"metadata" json:"metadata"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
+// Metadata holds free-form details about an event. It is stored as JSON.
+type Metadata map[string]interface{}
+
+// Value implements driver.Valuer
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (m *Metadata) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	}
+
+	return fmt.Errorf("doorbot: cannot scan %T into Metadata", src)
+}
+
+// Notification holds the delivery state of a knock-knock sent to a person.
+// Notifications are processed by the notification queue workers.
+type Notification struct {
+	ID            uint       `db:"id" json:"id"`
+	AccountID     uint       `db:"account_id" json:"account_id"`
+	DoorID        uint       `db:"door_id" json:"door_id"`
+	PersonID      uint       `db:"person_id" json:"person_id"`
+	Status        string     `db:"status" json:"status"`
+	Attempts      uint       `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"next_attempt_at"`
+	LockedAt      *time.Time `db:"locked_at" json:"-"`
+	LastError     string     `db:"last_error" json:"last_error"`
+	DeliveredAt   *time.Time `db:"delivered_at" json:"delivered_at"`
+	VisitID       *uint      `db:"visit_id" json:"visit_id"`
+
+	// Index of the escalation step being delivered. Attempts are counted per step.
+	Step           uint       `db:"step" json:"step"`
+	AcknowledgedAt *time.Time `db:"acknowledged_at" json:"acknowledged_at"`
+
+	// Acknowledgement sent back by the person. The ETA is in minutes.
+	AcknowledgedVia        string `db:"acknowledged_via" json:"acknowledged_via"`
+	AcknowledgementReply   string `db:"acknowledgement_reply" json:"acknowledgement_reply"`
+	AcknowledgementMessage string `db:"acknowledgement_message" json:"acknowledgement_message"`
+	AcknowledgementETA     uint   `db:"acknowledgement_eta" json:"acknowledgement_eta"`
+
+	// Set when the knock-knock was handed from the host to one of their delegates or the reception.
+	ReroutedFromPersonID *uint `db:"rerouted_from_person_id" json:"rerouted_from_person_id"`
+	// When an unacknowledged knock-knock is rerouted.
+	RerouteAt *time.Time `db:"reroute_at" json:"reroute_at"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// NotificationAttempt holds the result of a delivery attempt on a single channel.
+// EventID holds the EventNotification*Sent code matching the channel kind.
+type NotificationAttempt struct {
+	ID                uint      `db:"id" json:"id"`
+	AccountID         uint      `db:"account_id" json:"-"`
+	NotificationID    uint      `db:"notification_id" json:"notification_id"`
+	Attempt           uint      `db:"attempt" json:"attempt"`
+	Channel           string    `db:"channel" json:"channel"`
+	EventID           uint      `db:"event_id" json:"event_id"`
+	Status            string    `db:"status" json:"status"`
+	ProviderMessageID string    `db:"provider_message_id" json:"provider_message_id"`
+	Error             string    `db:"error" json:"error"`
+	CreatedAt         time.Time `db:"created_at" json:"created_at"`
+}
+
 // Device holds device data
 type Device struct {
-	AccountID   uint      `db:"account_id" json:"-"`
-	ID          uint      `db:"id" json:"id"`
-	Name        string    `db:"name" json:"name"`
-	DeviceID    *string   `db:"device_id" json:"device_id"`
-	DoorID      *uint     `db:"door_id" json:"door_id"`
-	Make        string    `db:"make" json:"make"`
-	Description string    `db:"description" json:"description"`
-	IsEnabled   bool      `db:"is_enabled" json:"is_enabled"`
-	Token       string    `db:"token" json:"token"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	AccountID   uint    `db:"account_id" json:"-"`
+	ID          uint    `db:"id" json:"id"`
+	Name        string  `db:"name" json:"name"`
+	DeviceID    *string `db:"device_id" json:"device_id"`
+	DoorID      *uint   `db:"door_id" json:"door_id"`
+	Make        string  `db:"make" json:"make"`
+	Description string  `db:"description" json:"description"`
+	IsEnabled   bool    `db:"is_enabled" json:"is_enabled"`
+	Token       string  `db:"token" json:"token"`
+
+	// Last time the device made an authenticated request. See DeviceSeenInterval.
+	LastSeenAt *time.Time `db:"last_seen_at" json:"last_seen_at"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
 // Person is someone who can be reached behind a Door
@@ -138,11 +282,29 @@ type Person struct {
 	IsVisible   bool   `db:"is_visible" form:"is_visible" json:"is_visible"`
 	IsAvailable bool   `db:"is_available" form:"is_available" json:"is_available"`
 
+	// IANA name of the timezone the working hours are expressed in ( ex: America/Montreal ), UTC when empty.
+	// Set along with the working hours.
+	Timezone string `db:"timezone" form:"timezone" json:"timezone"`
+
 	NotificationsEnabled      bool `db:"notifications_enabled" form:"notifications_enabled" json:"notifications_enabled"`
 	NotificationsAppEnabled   bool `db:"notifications_app_enabled" form:"notifications_app_enabled" json:"notifications_app_enabled"`
 	NotificationsEmailEnabled bool `db:"notifications_email_enabled" form:"notifications_email_enabled" json:"notifications_email_enabled"`
 	NotificationsChatEnabled   bool `db:"notifications_chat_enabled" form:"notifications_chat_enabled" json:"notifications_chat_enabled"`
 	NotificationsSMSEnabled   bool `db:"notifications_sms_enabled" form:"notifications_sms_enabled" json:"notifications_sms_enabled"`
+	NotificationsWebhookEnabled bool `db:"notifications_webhook_enabled" form:"notifications_webhook_enabled" json:"notifications_webhook_enabled"`
+
+	// Overrides the account webhook URL for the knock-knocks of this person.
+	NotificationsWebhookURL string `db:"notifications_webhook_url" form:"notifications_webhook_url" json:"notifications_webhook_url"`
+
+	// Username mentioned in the Mattermost and Rocket.Chat knock-knock messages.
+	NotificationsChatHandle string `db:"notifications_chat_handle" form:"notifications_chat_handle" json:"notifications_chat_handle"`
+
+	// How knock-knocks are delivered. See the NotificationsMode* constants.
+	NotificationsMode string `db:"notifications_mode" form:"notifications_mode" json:"notifications_mode"`
+
+	// How often account managers receive the lobby activity digest. See the Digest* constants.
+	DigestFrequency string     `db:"digest_frequency" form:"digest_frequency" json:"digest_frequency"`
+	DigestSentAt    *time.Time `db:"digest_sent_at" json:"-"`
 
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
@@ -158,6 +320,68 @@ func (p *Person) IsAccountOwner() bool {
 	return p.AccountType == AccountOwner
 }
 
+// WorkingHours is a weekly period during which a person can be knocked.
+// Times are HH:MM in the person timezone. A person without working hours can be knocked at any time.
+type WorkingHours struct {
+	ID        uint `db:"id" json:"-"`
+	AccountID uint `db:"account_id" json:"-"`
+	PersonID  uint `db:"person_id" json:"-"`
+	// Day of the week, 0 is Sunday
+	Day      uint   `db:"day" json:"day"`
+	StartsAt string `db:"starts_at" json:"starts_at"`
+	EndsAt   string `db:"ends_at" json:"ends_at"`
+}
+
+// OutOfOffice is a period during which a person is away.
+// EndsAt is the return date, the message is shown to the visitors along with it.
+type OutOfOffice struct {
+	ID        uint      `db:"id" json:"id"`
+	AccountID uint      `db:"account_id" json:"-"`
+	PersonID  uint      `db:"person_id" json:"person_id"`
+	StartsAt  time.Time `db:"starts_at" json:"starts_at"`
+	EndsAt    time.Time `db:"ends_at" json:"ends_at"`
+	Message   string    `db:"message" json:"message"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Delegate is a person taking the knock-knocks of another person while they are unavailable.
+// Delegates are tried by position.
+type Delegate struct {
+	ID         uint `db:"id" json:"-"`
+	AccountID  uint `db:"account_id" json:"-"`
+	PersonID   uint `db:"person_id" json:"-"`
+	DelegateID uint `db:"delegate_id" json:"delegate_id"`
+	Position   uint `db:"position" json:"-"`
+}
+
+// Team is a group of people visitors can ask for, ex: "IT" or "Sales".
+// The strategy is one of the TeamStrategy* constants. Teams synced from a bridge have their members managed by the bridge.
+type Team struct {
+	ID          uint   `db:"id" json:"id"`
+	AccountID   uint   `db:"account_id" json:"-"`
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+	Strategy    string `db:"strategy" json:"strategy"`
+	IsVisible   bool   `db:"is_visible" json:"is_visible"`
+	BridgeID    uint   `db:"bridge_id" json:"bridge_id"`
+
+	// Member knocked last by the round-robin strategy
+	LastKnockedID uint `db:"last_knocked_id" json:"-"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// TeamMember links a person to a team. Members are ordered by position.
+type TeamMember struct {
+	ID        uint `db:"id" json:"-"`
+	AccountID uint `db:"account_id" json:"-"`
+	TeamID    uint `db:"team_id" json:"team_id"`
+	PersonID  uint `db:"person_id" json:"person_id"`
+	Position  uint `db:"position" json:"-"`
+}
+
 // PersonArguments represents the different arguments required when creating a new Person
 type PersonArguments struct {
 	AccountID   uint
@@ -169,6 +393,200 @@ type PersonArguments struct {
 	IsVisible   bool
 }
 
+// Visitor is someone who came to see a Person
+type Visitor struct {
+	ID          uint      `db:"id" json:"id"`
+	AccountID   uint      `db:"account_id" json:"-"`
+	Name        string    `db:"name" json:"name"`
+	Company     string    `db:"company" json:"company"`
+	Email       string    `db:"email" json:"email"`
+	PhoneNumber string    `db:"phone_number" json:"phone_number"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// NotificationTemplate overrides the knock-knock message sent on a channel.
+// Templates without a door apply to the whole account, door templates take precedence over them.
+// See the services/notifications/templates package for the syntax and the available variables.
+type NotificationTemplate struct {
+	ID        uint   `db:"id" json:"id"`
+	AccountID uint   `db:"account_id" json:"-"`
+	DoorID    *uint  `db:"door_id" json:"door_id"`
+	Channel   string `db:"channel" json:"channel"`
+	// Subject of the emails, ignored by the other channels.
+	Subject string `db:"subject" json:"subject"`
+	Body    string `db:"body" json:"body"`
+	// HTML part of the emails. Generated from the body when empty.
+	HTMLBody  string    `db:"html_body" json:"html_body"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// PushDevice is a companion app installation registered by a person to receive push notifications.
+// Devices rejected by the push service are disabled.
+type PushDevice struct {
+	ID         uint      `db:"id" json:"id"`
+	AccountID  uint      `db:"account_id" json:"-"`
+	PersonID   uint      `db:"person_id" json:"person_id"`
+	Platform   string    `db:"platform" json:"platform"`
+	Token      string    `db:"token" json:"token"`
+	AppVersion string    `db:"app_version" json:"app_version"`
+	IsEnabled  bool      `db:"is_enabled" json:"is_enabled"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// WebhookSubscription posts the account events of the subscribed types to an integration endpoint.
+// Requests are signed with the secret, see the services/notifications/webhook package.
+type WebhookSubscription struct {
+	ID        uint      `db:"id" json:"id"`
+	AccountID uint      `db:"account_id" json:"-"`
+	URL       string    `db:"url" json:"url"`
+	Secret    string    `db:"secret" json:"secret"`
+	EventIDs  EventIDs  `db:"event_ids" json:"event_ids"`
+	IsEnabled bool      `db:"is_enabled" json:"is_enabled"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// EventIDs is a list of event codes stored as an integer array.
+type EventIDs []uint
+
+// Value implements driver.Valuer
+func (ids EventIDs) Value() (driver.Value, error) {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.FormatUint(uint64(id), 10)
+	}
+
+	return "{" + strings.Join(values, ",") + "}", nil
+}
+
+// Scan implements sql.Scanner
+func (ids *EventIDs) Scan(src interface{}) error {
+	var s string
+
+	switch v := src.(type) {
+	case nil:
+		*ids = nil
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("doorbot: cannot scan %T into EventIDs", src)
+	}
+
+	s = strings.Trim(s, "{}")
+	*ids = EventIDs{}
+
+	if len(s) == 0 {
+		return nil
+	}
+
+	for _, value := range strings.Split(s, ",") {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("doorbot: cannot scan %q into EventIDs", src)
+		}
+
+		*ids = append(*ids, uint(id))
+	}
+
+	return nil
+}
+
+// Contains tells if the list holds an event code
+func (ids EventIDs) Contains(id uint) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookDelivery is the delivery of an event to a webhook subscription.
+// Deliveries are queued by the database when an event matching a subscription is created.
+type WebhookDelivery struct {
+	ID             uint `db:"id" json:"id"`
+	AccountID      uint `db:"account_id" json:"-"`
+	SubscriptionID uint `db:"subscription_id" json:"subscription_id"`
+	// Id of the delivered Event, not its event code.
+	EventID       uint       `db:"event_id" json:"event_id"`
+	Status        string     `db:"status" json:"status"`
+	Attempts      uint       `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"next_attempt_at"`
+	LockedAt      *time.Time `db:"locked_at" json:"-"`
+	// HTTP status code of the last response, 0 when the endpoint could not be reached.
+	ResponseStatus int        `db:"response_status" json:"response_status"`
+	LastError      string     `db:"last_error" json:"last_error"`
+	DeliveredAt    *time.Time `db:"delivered_at" json:"delivered_at"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// VisitReport aggregates the visits made to a door, device or person over a period.
+// PeakHour is the hour of the day with the most check-ins. Durations are in seconds.
+type VisitReport struct {
+	Period          time.Time `db:"period" json:"period"`
+	GroupID         uint      `db:"group_id" json:"group_id"`
+	Visits          uint      `db:"visits" json:"visits"`
+	Visitors        uint      `db:"visitors" json:"visitors"`
+	PeakHour        uint      `db:"peak_hour" json:"peak_hour"`
+	AverageDuration float64   `db:"average_duration" json:"average_duration"`
+}
+
+// ResponseTimeReport aggregates how fast the knock-knocks of a door, device or person were acknowledged over a period.
+// Response times are in seconds and only account for acknowledged knock-knocks.
+type ResponseTimeReport struct {
+	Period              time.Time `db:"period" json:"period"`
+	GroupID             uint      `db:"group_id" json:"group_id"`
+	Notifications       uint      `db:"notifications" json:"notifications"`
+	Acknowledged        uint      `db:"acknowledged" json:"acknowledged"`
+	Unanswered          uint      `db:"unanswered" json:"unanswered"`
+	MedianResponseTime  float64   `db:"median_response_time" json:"median_response_time"`
+	AverageResponseTime float64   `db:"average_response_time" json:"average_response_time"`
+}
+
+// Visit logs a visitor checking in at a door to see a person. The visit is active until the visitor checks out.
+type Visit struct {
+	ID           uint       `db:"id" json:"id"`
+	AccountID    uint       `db:"account_id" json:"-"`
+	VisitorID    uint       `db:"visitor_id" json:"visitor_id"`
+	PersonID     uint       `db:"person_id" json:"person_id"`
+	DoorID       uint       `db:"door_id" json:"door_id"`
+	DeviceID     *uint      `db:"device_id" json:"device_id"`
+	CheckedInAt  time.Time  `db:"checked_in_at" json:"checked_in_at"`
+	CheckedOutAt *time.Time `db:"checked_out_at" json:"checked_out_at"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// Invitation is a visit registered ahead of time by a host. The guest checks in on the lobby device
+// using the invitation code while the invitation is open ( between StartsAt and EndsAt ).
+type Invitation struct {
+	ID        uint       `db:"id" json:"id"`
+	AccountID uint       `db:"account_id" json:"-"`
+	PersonID  uint       `db:"person_id" json:"person_id"`
+	VisitorID uint       `db:"visitor_id" json:"visitor_id"`
+	DoorID    *uint      `db:"door_id" json:"door_id"`
+	VisitID   *uint      `db:"visit_id" json:"visit_id"`
+	Code      string     `db:"code" json:"code"`
+	StartsAt  time.Time  `db:"starts_at" json:"starts_at"`
+	EndsAt    time.Time  `db:"ends_at" json:"ends_at"`
+	SentAt    *time.Time `db:"sent_at" json:"sent_at"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// IsOpen tells if the invitation can be used at the given time
+func (i *Invitation) IsOpen(t time.Time) bool {
+	return i.VisitID == nil && !t.Before(i.StartsAt) && !t.After(i.EndsAt)
+}
+
 // NewAuthentication creates a new Authentication
 func NewAuthentication(accountID uint, providerID uint, token string) *Authentication {
 	return &Authentication{
@@ -198,6 +616,35 @@ func NewPerson(args PersonArguments) *Person {
 	}
 }
 
+// NewTeam creates a new team knocking every available member
+func NewTeam(accountID uint, name string) *Team {
+	now := time.Now()
+
+	return &Team{
+		AccountID: accountID,
+		Name:      name,
+		Strategy:  TeamStrategyBroadcast,
+		IsVisible: true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// NewNotification creates a new Notification ready to be picked up by the delivery queue
+func NewNotification(accountID uint, doorID uint, personID uint) *Notification {
+	now := time.Now()
+
+	return &Notification{
+		AccountID:     accountID,
+		DoorID:        doorID,
+		PersonID:      personID,
+		Status:        NotificationPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
 // NewEvent creates a new event
 func NewEvent(accountID uint, deviceID uint, doorID uint, eventID uint, personID uint) *Event {
 	return &Event{
@@ -206,5 +653,37 @@ func NewEvent(accountID uint, deviceID uint, doorID uint, eventID uint, personID
 		DoorID:    doorID,
 		EventID:   eventID,
 		PersonID:  personID,
+		ActorType: EventActorSystem,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewVisit creates a new Visit checked in now
+func NewVisit(accountID uint, visitorID uint, personID uint, doorID uint) *Visit {
+	now := time.Now()
+
+	return &Visit{
+		AccountID:   accountID,
+		VisitorID:   visitorID,
+		PersonID:    personID,
+		DoorID:      doorID,
+		CheckedInAt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// NewWebhookDelivery creates a pending delivery of an event, due now.
+func NewWebhookDelivery(accountID uint, subscriptionID uint, eventID uint) *WebhookDelivery {
+	now := time.Now()
+
+	return &WebhookDelivery{
+		AccountID:      accountID,
+		SubscriptionID: subscriptionID,
+		EventID:        eventID,
+		Status:         WebhookDeliveryPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
 	}
 }
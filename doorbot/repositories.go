@@ -9,6 +9,8 @@ import (
 	// Ensure lib/pq is not skipped by Godeps
 	_ "github.com/lib/pq"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type repositories struct {
@@ -20,10 +22,26 @@ type repositories struct {
 	administratorAuthentication AdministratorAuthenticationRepository
 	authentication              AuthenticationRepository
 	bridgeUser                  BridgeUserRepository
+	delegate                    DelegateRepository
 	door                        DoorRepository
 	device                      DeviceRepository
+	escalationStep              EscalationStepRepository
 	event                       EventRepository
+	invitation                  InvitationRepository
+	notification                NotificationRepository
+	notificationAttempt         NotificationAttemptRepository
+	notificationTemplate        NotificationTemplateRepository
+	outOfOffice                 OutOfOfficeRepository
 	person                      PersonRepository
+	pushDevice                  PushDeviceRepository
+	report                      ReportRepository
+	team                        TeamRepository
+	teamMember                  TeamMemberRepository
+	visit                       VisitRepository
+	visitor                     VisitorRepository
+	webhookDelivery             WebhookDeliveryRepository
+	webhookSubscription         WebhookSubscriptionRepository
+	workingHours                WorkingHoursRepository
 }
 
 // DB returns the database instance
@@ -48,6 +66,10 @@ func (r *repositories) SetAccountScope(a uint) {
 		r.bridgeUser.SetAccountScope(a)
 	}
 
+	if r.delegate != nil {
+		r.delegate.SetAccountScope(a)
+	}
+
 	if r.device != nil {
 		r.device.SetAccountScope(a)
 	}
@@ -56,13 +78,73 @@ func (r *repositories) SetAccountScope(a uint) {
 		r.door.SetAccountScope(a)
 	}
 
+	if r.escalationStep != nil {
+		r.escalationStep.SetAccountScope(a)
+	}
+
 	if r.event != nil {
 		r.event.SetAccountScope(a)
 	}
 
+	if r.invitation != nil {
+		r.invitation.SetAccountScope(a)
+	}
+
+	if r.notification != nil {
+		r.notification.SetAccountScope(a)
+	}
+
+	if r.notificationAttempt != nil {
+		r.notificationAttempt.SetAccountScope(a)
+	}
+
+	if r.notificationTemplate != nil {
+		r.notificationTemplate.SetAccountScope(a)
+	}
+
+	if r.outOfOffice != nil {
+		r.outOfOffice.SetAccountScope(a)
+	}
+
 	if r.person != nil {
 		r.person.SetAccountScope(a)
 	}
+
+	if r.pushDevice != nil {
+		r.pushDevice.SetAccountScope(a)
+	}
+
+	if r.report != nil {
+		r.report.SetAccountScope(a)
+	}
+
+	if r.team != nil {
+		r.team.SetAccountScope(a)
+	}
+
+	if r.teamMember != nil {
+		r.teamMember.SetAccountScope(a)
+	}
+
+	if r.visit != nil {
+		r.visit.SetAccountScope(a)
+	}
+
+	if r.visitor != nil {
+		r.visitor.SetAccountScope(a)
+	}
+
+	if r.webhookDelivery != nil {
+		r.webhookDelivery.SetAccountScope(a)
+	}
+
+	if r.webhookSubscription != nil {
+		r.webhookSubscription.SetAccountScope(a)
+	}
+
+	if r.workingHours != nil {
+		r.workingHours.SetAccountScope(a)
+	}
 }
 
 // Transaction creates a new Transaction
@@ -92,24 +174,101 @@ type doorRepository struct {
 	AccountID uint
 }
 
+type delegateRepository struct {
+	AccountID uint
+}
+
 type deviceRepository struct {
 	AccountID uint
 }
 
+type escalationStepRepository struct {
+	AccountID uint
+}
+
 type eventRepository struct {
 	AccountID uint
 }
 
+type invitationRepository struct {
+	AccountID uint
+}
+
+type notificationRepository struct {
+	AccountID uint
+}
+
+type notificationAttemptRepository struct {
+	AccountID uint
+}
+
+type notificationTemplateRepository struct {
+	AccountID uint
+}
+
+type outOfOfficeRepository struct {
+	AccountID uint
+}
+
 type personRepository struct {
 	AccountID uint
 }
 
+type pushDeviceRepository struct {
+	AccountID uint
+}
+
+type reportRepository struct {
+	AccountID uint
+}
+
+// reportIntervals lists the accepted date_trunc precisions
+var reportIntervals = map[string]bool{
+	ReportIntervalDay:   true,
+	ReportIntervalWeek:  true,
+	ReportIntervalMonth: true,
+}
+
+type teamRepository struct {
+	AccountID uint
+}
+
+type teamMemberRepository struct {
+	AccountID uint
+}
+
+type visitRepository struct {
+	AccountID uint
+}
+
+type visitorRepository struct {
+	AccountID uint
+}
+
+type webhookDeliveryRepository struct {
+	AccountID uint
+}
+
+type webhookSubscriptionRepository struct {
+	AccountID uint
+}
+
+type workingHoursRepository struct {
+	AccountID uint
+}
+
 func newRepositories(d *gorp.DbMap) *repositories {
 	return &repositories{
 		db: d,
 	}
 }
 
+// NewRepositories creates a Repositories instance outside of a request scope ( ex: background workers ).
+// The returned instance is not safe for concurrent use.
+func NewRepositories(d *gorp.DbMap) Repositories {
+	return newRepositories(d)
+}
+
 // AccountRepository returns an AccountRepository instance
 func (r *repositories) AccountRepository() AccountRepository {
 	if r.account == nil {
@@ -178,6 +337,16 @@ func (r *repositories) PersonRepository() PersonRepository {
 	return r.person
 }
 
+// DelegateRepository returns a DelegateRepository instance
+func (r *repositories) DelegateRepository() DelegateRepository {
+	if r.delegate == nil {
+		r.delegate = &delegateRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.delegate
+}
+
 // DeviceRepository returns a DeviceRepository instance
 func (r *repositories) DeviceRepository() DeviceRepository {
 	if r.device == nil {
@@ -188,6 +357,16 @@ func (r *repositories) DeviceRepository() DeviceRepository {
 	return r.device
 }
 
+// EscalationStepRepository returns an EscalationStepRepository instance
+func (r *repositories) EscalationStepRepository() EscalationStepRepository {
+	if r.escalationStep == nil {
+		r.escalationStep = &escalationStepRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.escalationStep
+}
+
 // EventRepository retuns a EventRepository instance
 func (r *repositories) EventRepository() EventRepository {
 	if r.event == nil {
@@ -198,6 +377,149 @@ func (r *repositories) EventRepository() EventRepository {
 	return r.event
 }
 
+// InvitationRepository returns an InvitationRepository instance
+func (r *repositories) InvitationRepository() InvitationRepository {
+	if r.invitation == nil {
+		r.invitation = &invitationRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.invitation
+}
+
+// NotificationRepository returns a NotificationRepository instance
+func (r *repositories) NotificationRepository() NotificationRepository {
+	if r.notification == nil {
+		r.notification = &notificationRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.notification
+}
+
+// NotificationAttemptRepository returns a NotificationAttemptRepository instance
+func (r *repositories) NotificationAttemptRepository() NotificationAttemptRepository {
+	if r.notificationAttempt == nil {
+		r.notificationAttempt = &notificationAttemptRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.notificationAttempt
+}
+
+// NotificationTemplateRepository returns a NotificationTemplateRepository instance
+func (r *repositories) NotificationTemplateRepository() NotificationTemplateRepository {
+	if r.notificationTemplate == nil {
+		r.notificationTemplate = &notificationTemplateRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.notificationTemplate
+}
+
+// OutOfOfficeRepository returns an OutOfOfficeRepository instance
+func (r *repositories) OutOfOfficeRepository() OutOfOfficeRepository {
+	if r.outOfOffice == nil {
+		r.outOfOffice = &outOfOfficeRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.outOfOffice
+}
+
+// PushDeviceRepository returns a PushDeviceRepository instance
+func (r *repositories) PushDeviceRepository() PushDeviceRepository {
+	if r.pushDevice == nil {
+		r.pushDevice = &pushDeviceRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.pushDevice
+}
+
+// ReportRepository returns a ReportRepository instance
+func (r *repositories) ReportRepository() ReportRepository {
+	if r.report == nil {
+		r.report = &reportRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.report
+}
+
+// TeamRepository returns a TeamRepository instance
+func (r *repositories) TeamRepository() TeamRepository {
+	if r.team == nil {
+		r.team = &teamRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.team
+}
+
+// TeamMemberRepository returns a TeamMemberRepository instance
+func (r *repositories) TeamMemberRepository() TeamMemberRepository {
+	if r.teamMember == nil {
+		r.teamMember = &teamMemberRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.teamMember
+}
+
+// VisitRepository returns a VisitRepository instance
+func (r *repositories) VisitRepository() VisitRepository {
+	if r.visit == nil {
+		r.visit = &visitRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.visit
+}
+
+// VisitorRepository returns a VisitorRepository instance
+func (r *repositories) VisitorRepository() VisitorRepository {
+	if r.visitor == nil {
+		r.visitor = &visitorRepository{
+			AccountID: r.AccountID,
+		}
+	}
+	return r.visitor
+}
+
+// WebhookDeliveryRepository returns a WebhookDeliveryRepository instance
+func (r *repositories) WebhookDeliveryRepository() WebhookDeliveryRepository {
+	if r.webhookDelivery == nil {
+		r.webhookDelivery = &webhookDeliveryRepository{
+			AccountID: r.AccountID,
+		}
+	}
+
+	return r.webhookDelivery
+}
+
+// WebhookSubscriptionRepository returns a WebhookSubscriptionRepository instance
+func (r *repositories) WebhookSubscriptionRepository() WebhookSubscriptionRepository {
+	if r.webhookSubscription == nil {
+		r.webhookSubscription = &webhookSubscriptionRepository{
+			AccountID: r.AccountID,
+		}
+	}
+
+	return r.webhookSubscription
+}
+
+// WorkingHoursRepository returns a WorkingHoursRepository instance
+func (r *repositories) WorkingHoursRepository() WorkingHoursRepository {
+	if r.workingHours == nil {
+		r.workingHours = &workingHoursRepository{
+			AccountID: r.AccountID,
+		}
+	}
+
+	return r.workingHours
+}
+
 // All returns all accounts
 func (r *accountRepository) All(t Executor) ([]*Account, error) {
 	var accounts []*Account
@@ -686,6 +1008,30 @@ func (r *deviceRepository) Enable(t Executor, device *Device, enabled bool) (boo
 	return affected == 1, err
 }
 
+// Seen sets the device last seen time to now.
+func (r *deviceRepository) Seen(t Executor, device *Device) (bool, error) {
+	now := time.Now()
+
+	result, err := t.Exec(
+		"UPDATE devices SET last_seen_at = :now WHERE id = :id AND account_id = :account_id",
+		map[string]interface{}{
+			"now":        now,
+			"id":         device.ID,
+			"account_id": r.AccountID,
+		},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	device.LastSeenAt = &now
+
+	affected, err := result.RowsAffected()
+
+	return affected == 1, err
+}
+
 func (r *deviceRepository) SetAccountScope(accountID uint) {
 	r.AccountID = accountID
 }
@@ -740,19 +1086,62 @@ func (r *doorRepository) SetAccountScope(accountID uint) {
 	r.AccountID = accountID
 }
 
-func (r *eventRepository) All(t Executor, p *Pagination) ([]*Event, error) {
+// All returns the most recent events matching the filter
+func (r *eventRepository) All(t Executor, f *EventFilter) ([]*Event, error) {
 	var events []*Event
 
-	parameters := map[string]interface{}{}
+	query := "SELECT * FROM events WHERE account_id = :account_id"
+	parameters := map[string]interface{}{"account_id": r.AccountID}
 
-	query := "SELECT * FROM events"
+	if len(f.EventIDs) > 0 {
+		placeholders := make([]string, len(f.EventIDs))
 
-	if r.AccountID > 0 {
-		query += " WHERE account_id = :accountRepositoryunt_id"
-		parameters["account_id"] = r.AccountID
+		for i, id := range f.EventIDs {
+			key := fmt.Sprintf("event_id%d", i)
+			placeholders[i] = ":" + key
+			parameters[key] = id
+		}
+
+		query += " AND event_id IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	if f.DoorID > 0 {
+		query += " AND door_id = :door_id"
+		parameters["door_id"] = f.DoorID
 	}
 
-	query += fmt.Sprintf(" ORDER BY id %s LIMIT %d, %d", p.Order, p.Limit, (p.Page-1)*p.Limit)
+	if f.DeviceID > 0 {
+		query += " AND device_id = :device_id"
+		parameters["device_id"] = f.DeviceID
+	}
+
+	if f.PersonID > 0 {
+		query += " AND person_id = :person_id"
+		parameters["person_id"] = f.PersonID
+	}
+
+	if f.Since != nil {
+		query += " AND created_at >= :since"
+		parameters["since"] = *f.Since
+	}
+
+	if f.Until != nil {
+		query += " AND created_at < :until"
+		parameters["until"] = *f.Until
+	}
+
+	if f.Before > 0 {
+		query += " AND id < :before"
+		parameters["before"] = f.Before
+	}
+
+	limit := f.Limit
+	if limit == 0 || limit > 100 {
+		limit = 100
+	}
+
+	query += " ORDER BY id DESC LIMIT :limit"
+	parameters["limit"] = limit
 
 	_, err := t.Select(
 		&events,
@@ -768,21 +1157,11 @@ func (r *eventRepository) Find(t Executor, id uint) (*Event, error) {
 		events []*Event
 		event  *Event
 	)
-	parameters := map[string]interface{}{}
-
-	query := "SELECT * FROM events WHERE id = :id LIMIT 1"
-
-	if r.AccountID > 0 {
-		query += " AND account_id = :account_id"
-		parameters["account_id"] = r.AccountID
-	}
-
-	query += " LIMIT 1"
 
 	_, err := t.Select(
 		&events,
-		query,
-		parameters,
+		"SELECT * FROM events WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
 	)
 
 	if len(events) == 1 {
@@ -801,73 +1180,1365 @@ func (r *eventRepository) SetAccountScope(accountID uint) {
 	r.AccountID = accountID
 }
 
-func (r *personRepository) All(t Executor) ([]*Person, error) {
-	var people []*Person
+// FindByPersonID returns the delegates of a person, ordered by position
+func (r *delegateRepository) FindByPersonID(t Executor, id uint) ([]*Delegate, error) {
+	var delegates []*Delegate
 
 	_, err := t.Select(
-		&people,
-		"SELECT * FROM people WHERE account_id = :account_id ORDER BY name ASC",
-		map[string]interface{}{"account_id": r.AccountID},
+		&delegates,
+		"SELECT * FROM delegates WHERE account_id = :account_id AND person_id = :person_id ORDER BY position ASC",
+		map[string]interface{}{"account_id": r.AccountID, "person_id": id},
 	)
 
-	return people, err
+	return delegates, err
 }
 
-func (r *personRepository) Find(t Executor, id uint) (*Person, error) {
-	var (
-		people []*Person
-		person *Person
-	)
+// Create a new delegate, setting the repository AccountID.
+func (r *delegateRepository) Create(t Executor, delegate *Delegate) error {
+	delegate.AccountID = r.AccountID
+	return t.Insert(delegate)
+}
 
-	_, err := t.Select(
-		&people,
-		"SELECT * FROM people WHERE id = :id AND account_id = :account_id LIMIT 1",
-		map[string]interface{}{"id": id, "account_id": r.AccountID},
+// DeleteByPersonID removes the delegates of a person
+func (r *delegateRepository) DeleteByPersonID(t Executor, id uint) error {
+	_, err := t.Exec(
+		"DELETE FROM delegates WHERE account_id = :account_id AND person_id = :person_id",
+		map[string]interface{}{"account_id": r.AccountID, "person_id": id},
 	)
 
-	if len(people) == 1 {
-		person = people[0]
-	}
+	return err
+}
 
-	return person, err
+func (r *delegateRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
 }
 
-func (r *personRepository) FindByEmail(t Executor, email string) (*Person, error) {
-	var (
-		people []*Person
-		person *Person
-	)
+// FindByPersonID returns the escalation policy of a person, ordered by position
+func (r *escalationStepRepository) FindByPersonID(t Executor, id uint) ([]*EscalationStep, error) {
+	var steps []*EscalationStep
 
 	_, err := t.Select(
-		&people,
-		"SELECT * FROM people WHERE email = :email AND account_id = :account_id LIMIT 1",
-		map[string]interface{}{"email": email, "account_id": r.AccountID},
+		&steps,
+		"SELECT * FROM escalation_steps WHERE account_id = :account_id AND person_id = :person_id ORDER BY position ASC",
+		map[string]interface{}{"account_id": r.AccountID, "person_id": id},
 	)
 
-	if len(people) == 1 {
-		person = people[0]
-	}
-
-	return person, err
+	return steps, err
 }
 
-// Create a new person, setting the repository AccountID.
-func (r *personRepository) Create(t Executor, person *Person) error {
-	person.AccountID = r.AccountID
-	return t.Insert(person)
+// Create a new escalation step, setting the repository AccountID.
+func (r *escalationStepRepository) Create(t Executor, step *EscalationStep) error {
+	step.AccountID = r.AccountID
+	return t.Insert(step)
 }
 
-func (r *personRepository) Update(t Executor, person *Person) (bool, error) {
+// DeleteByPersonID removes the escalation policy of a person
+func (r *escalationStepRepository) DeleteByPersonID(t Executor, id uint) error {
+	_, err := t.Exec(
+		"DELETE FROM escalation_steps WHERE account_id = :account_id AND person_id = :person_id",
+		map[string]interface{}{"account_id": r.AccountID, "person_id": id},
+	)
+
+	return err
+}
+
+func (r *escalationStepRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// All returns the invitations matching the filter, soonest first
+func (r *invitationRepository) All(t Executor, f *InvitationFilter) ([]*Invitation, error) {
+	var invitations []*Invitation
+
+	query := "SELECT * FROM invitations WHERE account_id = :account_id"
+	parameters := map[string]interface{}{"account_id": r.AccountID}
+
+	if f.PersonID > 0 {
+		query += " AND person_id = :person_id"
+		parameters["person_id"] = f.PersonID
+	}
+
+	if f.Upcoming {
+		query += " AND visit_id IS NULL AND ends_at >= :now"
+		parameters["now"] = time.Now()
+	}
+
+	limit := f.Limit
+	if limit == 0 || limit > 100 {
+		limit = 100
+	}
+
+	query += " ORDER BY starts_at ASC LIMIT :limit"
+	parameters["limit"] = limit
+
+	_, err := t.Select(
+		&invitations,
+		query,
+		parameters,
+	)
+
+	return invitations, err
+}
+
+func (r *invitationRepository) Find(t Executor, id uint) (*Invitation, error) {
+	var (
+		invitations []*Invitation
+		invitation  *Invitation
+	)
+
+	_, err := t.Select(
+		&invitations,
+		"SELECT * FROM invitations WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(invitations) == 1 {
+		invitation = invitations[0]
+	}
+
+	return invitation, err
+}
+
+// FindByCode returns the invitation using the code. Codes are compared case-insensitively.
+func (r *invitationRepository) FindByCode(t Executor, code string) (*Invitation, error) {
+	var (
+		invitations []*Invitation
+		invitation  *Invitation
+	)
+
+	_, err := t.Select(
+		&invitations,
+		"SELECT * FROM invitations WHERE code = upper(:code) AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"code": code, "account_id": r.AccountID},
+	)
+
+	if len(invitations) == 1 {
+		invitation = invitations[0]
+	}
+
+	return invitation, err
+}
+
+// Create a new invitation, setting the repository AccountID.
+func (r *invitationRepository) Create(t Executor, invitation *Invitation) error {
+	now := time.Now()
+
+	invitation.AccountID = r.AccountID
+	invitation.CreatedAt = now
+	invitation.UpdatedAt = now
+
+	return t.Insert(invitation)
+}
+
+func (r *invitationRepository) Update(t Executor, invitation *Invitation) (bool, error) {
+	invitation.UpdatedAt = time.Now()
+
+	count, err := t.Update(invitation)
+	return count > 0, err
+}
+
+// Use links the visit of the guest to an invitation unless another check-in already did.
+func (r *invitationRepository) Use(t Executor, invitation *Invitation, visitID uint) (bool, error) {
+	now := time.Now()
+
+	result, err := t.Exec(
+		"UPDATE invitations SET visit_id = :visit_id, updated_at = :now WHERE id = :id AND account_id = :account_id AND visit_id IS NULL",
+		map[string]interface{}{
+			"visit_id":   visitID,
+			"now":        now,
+			"id":         invitation.ID,
+			"account_id": r.AccountID,
+		},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected > 0 {
+		invitation.VisitID = &visitID
+		invitation.UpdatedAt = now
+	}
+
+	return affected > 0, nil
+}
+
+func (r *invitationRepository) Delete(t Executor, invitation *Invitation) (bool, error) {
+	count, err := t.Delete(invitation)
+	return count > 0, err
+}
+
+func (r *invitationRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// All returns the most recent notifications matching the filter
+func (r *notificationRepository) All(t Executor, f *NotificationFilter) ([]*Notification, error) {
+	var notifications []*Notification
+
+	query := "SELECT * FROM notifications WHERE account_id = :account_id"
+	parameters := map[string]interface{}{"account_id": r.AccountID}
+
+	if f.DoorID > 0 {
+		query += " AND door_id = :door_id"
+		parameters["door_id"] = f.DoorID
+	}
+
+	if f.PersonID > 0 && f.IncludeRerouted {
+		query += " AND (person_id = :person_id OR rerouted_from_person_id = :person_id)"
+		parameters["person_id"] = f.PersonID
+	} else if f.PersonID > 0 {
+		query += " AND person_id = :person_id"
+		parameters["person_id"] = f.PersonID
+	}
+
+	if f.Since != nil {
+		query += " AND created_at >= :since"
+		parameters["since"] = *f.Since
+	}
+
+	limit := f.Limit
+	if limit == 0 || limit > 100 {
+		limit = 100
+	}
+
+	query += " ORDER BY id DESC LIMIT :limit"
+	parameters["limit"] = limit
+
+	_, err := t.Select(
+		&notifications,
+		query,
+		parameters,
+	)
+
+	return notifications, err
+}
+
+// notificationLockNamespace keys the knock-knock advisory locks, the account id being the second key.
+const notificationLockNamespace = 1001
+
+// Lock takes a transaction-level advisory lock on the account knock-knocks. It is released on commit or rollback.
+func (r *notificationRepository) Lock(t Transaction) error {
+	_, err := t.Exec(
+		"SELECT pg_advisory_xact_lock(:namespace, :account_id)",
+		map[string]interface{}{"namespace": notificationLockNamespace, "account_id": r.AccountID},
+	)
+
+	return err
+}
+
+func (r *notificationRepository) Find(t Executor, id uint) (*Notification, error) {
+	var (
+		notifications []*Notification
+		notification  *Notification
+	)
+
+	_, err := t.Select(
+		&notifications,
+		"SELECT * FROM notifications WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(notifications) == 1 {
+		notification = notifications[0]
+	}
+
+	return notification, err
+}
+
+// Claim marks due notifications as processing and returns them.
+// Delivered knock-knocks left unacknowledged past their reroute time are claimed to be rerouted.
+// When the repository is not scoped to an account, notifications from every account are claimed.
+func (r *notificationRepository) Claim(t Executor, limit uint, timeout time.Duration) ([]*Notification, error) {
+	var notifications []*Notification
+
+	parameters := map[string]interface{}{
+		"pending":    NotificationPending,
+		"processing": NotificationProcessing,
+		"escalating": NotificationEscalating,
+		"delivered":  NotificationDelivered,
+		"now":        time.Now(),
+		"stale":      time.Now().Add(-timeout),
+		"limit":      limit,
+	}
+
+	query := "SELECT id FROM notifications WHERE (((status = :pending OR status = :escalating) AND next_attempt_at <= :now) OR (status = :delivered AND reroute_at <= :now AND acknowledged_at IS NULL) OR (status = :processing AND locked_at < :stale))"
+
+	if r.AccountID > 0 {
+		query += " AND account_id = :account_id"
+		parameters["account_id"] = r.AccountID
+	}
+
+	// Rows locked by another worker are skipped rather than waited for, concurrent claims return different notifications.
+	query += " ORDER BY next_attempt_at ASC LIMIT :limit FOR UPDATE SKIP LOCKED"
+
+	_, err := t.Select(
+		&notifications,
+		"UPDATE notifications SET status = :processing, locked_at = :now, updated_at = :now WHERE id IN ("+query+") RETURNING *",
+		parameters,
+	)
+
+	return notifications, err
+}
+
+// Create a new notification, setting the repository AccountID.
+func (r *notificationRepository) Create(t Executor, notification *Notification) error {
+	notification.AccountID = r.AccountID
+	return t.Insert(notification)
+}
+
+func (r *notificationRepository) Update(t Executor, notification *Notification) (bool, error) {
+	notification.UpdatedAt = time.Now()
+
+	count, err := t.Update(notification)
+	return count > 0, err
+}
+
+// Acknowledge saves the acknowledgement of a notification unless it was already acknowledged.
+// Escalating notifications are marked as delivered so no further steps are sent.
+func (r *notificationRepository) Acknowledge(t Executor, notification *Notification) (bool, error) {
+	now := time.Now()
+
+	result, err := t.Exec(
+		"UPDATE notifications SET acknowledged_at = :now, acknowledged_via = :via, acknowledgement_reply = :reply, acknowledgement_message = :message, acknowledgement_eta = :eta, status = CASE WHEN status = :escalating THEN :delivered ELSE status END, updated_at = :now WHERE id = :id AND account_id = :account_id AND acknowledged_at IS NULL",
+		map[string]interface{}{
+			"now":        now,
+			"via":        notification.AcknowledgedVia,
+			"reply":      notification.AcknowledgementReply,
+			"message":    notification.AcknowledgementMessage,
+			"eta":        notification.AcknowledgementETA,
+			"escalating": NotificationEscalating,
+			"delivered":  NotificationDelivered,
+			"id":         notification.ID,
+			"account_id": r.AccountID,
+		},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected > 0 {
+		notification.AcknowledgedAt = &now
+		notification.UpdatedAt = now
+	}
+
+	return affected > 0, nil
+}
+
+// UpdateDelivery saves the delivery fields set by the queue without overwriting the acknowledgement fields.
+// A knock-knock acknowledged while it was being delivered is not escalated, retried or rerouted further:
+// it is saved as delivered and keeps its person.
+func (r *notificationRepository) UpdateDelivery(t Executor, notification *Notification) (bool, error) {
+	notification.UpdatedAt = time.Now()
+
+	result, err := t.Exec(
+		"UPDATE notifications SET status = CASE WHEN acknowledged_at IS NOT NULL AND :settle THEN :delivered ELSE :status END, attempts = :attempts, next_attempt_at = :next_attempt_at, locked_at = :locked_at, last_error = :last_error, delivered_at = :delivered_at, step = :step, person_id = CASE WHEN acknowledged_at IS NULL THEN :person_id ELSE person_id END, rerouted_from_person_id = CASE WHEN acknowledged_at IS NULL THEN :rerouted_from_person_id ELSE rerouted_from_person_id END, reroute_at = CASE WHEN acknowledged_at IS NULL THEN :reroute_at ELSE CAST(NULL AS TIMESTAMP WITH TIME ZONE) END, updated_at = :updated_at WHERE id = :id AND account_id = :account_id",
+		map[string]interface{}{
+			"status":                  notification.Status,
+			"settle":                  notification.Status == NotificationPending || notification.Status == NotificationEscalating,
+			"delivered":               NotificationDelivered,
+			"attempts":                notification.Attempts,
+			"next_attempt_at":         notification.NextAttemptAt,
+			"locked_at":               notification.LockedAt,
+			"last_error":              notification.LastError,
+			"delivered_at":            notification.DeliveredAt,
+			"step":                    notification.Step,
+			"person_id":               notification.PersonID,
+			"rerouted_from_person_id": notification.ReroutedFromPersonID,
+			"reroute_at":              notification.RerouteAt,
+			"updated_at":              notification.UpdatedAt,
+			"id":                      notification.ID,
+			"account_id":              notification.AccountID,
+		},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *notificationRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// Create a new notification attempt, setting the repository AccountID.
+func (r *notificationAttemptRepository) Create(t Executor, attempt *NotificationAttempt) error {
+	attempt.AccountID = r.AccountID
+	attempt.CreatedAt = time.Now()
+	return t.Insert(attempt)
+}
+
+// FindByNotificationIDs returns the attempts made for the given notifications, oldest first.
+func (r *notificationAttemptRepository) FindByNotificationIDs(t Executor, ids []uint) ([]*NotificationAttempt, error) {
+	var attempts []*NotificationAttempt
+
+	if len(ids) == 0 {
+		return attempts, nil
+	}
+
+	placeholders := make([]string, len(ids))
+	parameters := map[string]interface{}{"account_id": r.AccountID}
+
+	for i, id := range ids {
+		key := fmt.Sprintf("id%d", i)
+		placeholders[i] = ":" + key
+		parameters[key] = id
+	}
+
+	_, err := t.Select(
+		&attempts,
+		"SELECT * FROM notification_attempts WHERE account_id = :account_id AND notification_id IN ("+strings.Join(placeholders, ", ")+") ORDER BY id ASC",
+		parameters,
+	)
+
+	return attempts, err
+}
+
+func (r *notificationAttemptRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// All returns the account notification templates
+func (r *notificationTemplateRepository) All(t Executor) ([]*NotificationTemplate, error) {
+	var templates []*NotificationTemplate
+
+	_, err := t.Select(
+		&templates,
+		"SELECT * FROM notification_templates WHERE account_id = :account_id ORDER BY channel ASC, door_id ASC NULLS FIRST",
+		map[string]interface{}{"account_id": r.AccountID},
+	)
+
+	return templates, err
+}
+
+func (r *notificationTemplateRepository) Find(t Executor, id uint) (*NotificationTemplate, error) {
+	var (
+		templates []*NotificationTemplate
+		template  *NotificationTemplate
+	)
+
+	_, err := t.Select(
+		&templates,
+		"SELECT * FROM notification_templates WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(templates) == 1 {
+		template = templates[0]
+	}
+
+	return template, err
+}
+
+// FindByChannel returns the template of a channel used for a door.
+// The door template is preferred over the account one. A door id of 0 only matches the account template.
+func (r *notificationTemplateRepository) FindByChannel(t Executor, channel string, doorID uint) (*NotificationTemplate, error) {
+	var (
+		templates []*NotificationTemplate
+		template  *NotificationTemplate
+	)
+
+	_, err := t.Select(
+		&templates,
+		"SELECT * FROM notification_templates WHERE account_id = :account_id AND channel = :channel AND (door_id = :door_id OR door_id IS NULL) ORDER BY door_id IS NULL ASC LIMIT 1",
+		map[string]interface{}{"account_id": r.AccountID, "channel": channel, "door_id": doorID},
+	)
+
+	if len(templates) == 1 {
+		template = templates[0]
+	}
+
+	return template, err
+}
+
+// Create a new notification template, setting the repository AccountID.
+func (r *notificationTemplateRepository) Create(t Executor, template *NotificationTemplate) error {
+	now := time.Now()
+
+	template.AccountID = r.AccountID
+	template.CreatedAt = now
+	template.UpdatedAt = now
+
+	return t.Insert(template)
+}
+
+func (r *notificationTemplateRepository) Update(t Executor, template *NotificationTemplate) (bool, error) {
+	template.UpdatedAt = time.Now()
+
+	count, err := t.Update(template)
+	return count > 0, err
+}
+
+func (r *notificationTemplateRepository) Delete(t Executor, template *NotificationTemplate) (bool, error) {
+	count, err := t.Delete(template)
+	return count > 0, err
+}
+
+func (r *notificationTemplateRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+func (r *outOfOfficeRepository) Find(t Executor, id uint) (*OutOfOffice, error) {
+	var (
+		periods []*OutOfOffice
+		period  *OutOfOffice
+	)
+
+	_, err := t.Select(
+		&periods,
+		"SELECT * FROM out_of_office_periods WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(periods) == 1 {
+		period = periods[0]
+	}
+
+	return period, err
+}
+
+// FindByPersonID returns the out-of-office periods of a person that are not over, soonest first
+func (r *outOfOfficeRepository) FindByPersonID(t Executor, id uint) ([]*OutOfOffice, error) {
+	var periods []*OutOfOffice
+
+	_, err := t.Select(
+		&periods,
+		"SELECT * FROM out_of_office_periods WHERE account_id = :account_id AND person_id = :person_id AND ends_at > :now ORDER BY starts_at ASC",
+		map[string]interface{}{"account_id": r.AccountID, "person_id": id, "now": time.Now()},
+	)
+
+	return periods, err
+}
+
+// FindCurrent returns the out-of-office periods of the account including the given time
+func (r *outOfOfficeRepository) FindCurrent(t Executor, at time.Time) ([]*OutOfOffice, error) {
+	var periods []*OutOfOffice
+
+	_, err := t.Select(
+		&periods,
+		"SELECT * FROM out_of_office_periods WHERE account_id = :account_id AND starts_at <= :at AND ends_at > :at ORDER BY ends_at ASC",
+		map[string]interface{}{"account_id": r.AccountID, "at": at},
+	)
+
+	return periods, err
+}
+
+// Create a new out-of-office period, setting the repository AccountID.
+func (r *outOfOfficeRepository) Create(t Executor, period *OutOfOffice) error {
+	now := time.Now()
+
+	period.AccountID = r.AccountID
+	period.CreatedAt = now
+	period.UpdatedAt = now
+
+	return t.Insert(period)
+}
+
+func (r *outOfOfficeRepository) Update(t Executor, period *OutOfOffice) (bool, error) {
+	period.UpdatedAt = time.Now()
+
+	count, err := t.Update(period)
+	return count > 0, err
+}
+
+func (r *outOfOfficeRepository) Delete(t Executor, period *OutOfOffice) (bool, error) {
+	count, err := t.Delete(period)
+	return count > 0, err
+}
+
+func (r *outOfOfficeRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+func (r *personRepository) All(t Executor) ([]*Person, error) {
+	var people []*Person
+
+	_, err := t.Select(
+		&people,
+		"SELECT * FROM people WHERE account_id = :account_id ORDER BY name ASC",
+		map[string]interface{}{"account_id": r.AccountID},
+	)
+
+	return people, err
+}
+
+func (r *personRepository) Find(t Executor, id uint) (*Person, error) {
+	var (
+		people []*Person
+		person *Person
+	)
+
+	_, err := t.Select(
+		&people,
+		"SELECT * FROM people WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(people) == 1 {
+		person = people[0]
+	}
+
+	return person, err
+}
+
+func (r *personRepository) FindByEmail(t Executor, email string) (*Person, error) {
+	var (
+		people []*Person
+		person *Person
+	)
+
+	_, err := t.Select(
+		&people,
+		"SELECT * FROM people WHERE email = :email AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"email": email, "account_id": r.AccountID},
+	)
+
+	if len(people) == 1 {
+		person = people[0]
+	}
+
+	return person, err
+}
+
+// Create a new person, setting the repository AccountID.
+func (r *personRepository) Create(t Executor, person *Person) error {
+	person.AccountID = r.AccountID
+	return t.Insert(person)
+}
+
+func (r *personRepository) Update(t Executor, person *Person) (bool, error) {
 	count, err := t.Update(person)
 	return count > 0, err
 }
 
-func (r *personRepository) Delete(t Executor, person *Person) (bool, error) {
-	count, err := t.Delete(person)
+func (r *personRepository) Delete(t Executor, person *Person) (bool, error) {
+	count, err := t.Delete(person)
+	return count > 0, err
+}
+
+// ClaimDigest marks the digest of the person as sent, unless it was already sent after `due`.
+// Returns false when another worker already claimed the digest.
+func (r *personRepository) ClaimDigest(t Executor, person *Person, due time.Time) (bool, error) {
+	now := time.Now()
+
+	result, err := t.Exec(
+		"UPDATE people SET digest_sent_at = :now WHERE id = :id AND account_id = :account_id AND (digest_sent_at IS NULL OR digest_sent_at < :due)",
+		map[string]interface{}{
+			"now":        now,
+			"due":        due,
+			"id":         person.ID,
+			"account_id": r.AccountID,
+		},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if affected == 1 {
+		person.DigestSentAt = &now
+	}
+
+	return affected == 1, err
+}
+
+func (r *personRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// All returns the teams of the account, ordered by name
+func (r *teamRepository) All(t Executor) ([]*Team, error) {
+	var teams []*Team
+
+	_, err := t.Select(
+		&teams,
+		"SELECT * FROM teams WHERE account_id = :account_id ORDER BY name ASC",
+		map[string]interface{}{"account_id": r.AccountID},
+	)
+
+	return teams, err
+}
+
+// Find a team by its ID
+func (r *teamRepository) Find(t Executor, id uint) (*Team, error) {
+	var (
+		teams []*Team
+		team  *Team
+	)
+
+	_, err := t.Select(
+		&teams,
+		"SELECT * FROM teams WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(teams) == 1 {
+		team = teams[0]
+	}
+
+	return team, err
+}
+
+// FindByBridgeID returns the teams synced from a bridge
+func (r *teamRepository) FindByBridgeID(t Executor, id uint) ([]*Team, error) {
+	var teams []*Team
+
+	_, err := t.Select(
+		&teams,
+		"SELECT * FROM teams WHERE account_id = :account_id AND bridge_id = :bridge_id ORDER BY name ASC",
+		map[string]interface{}{"account_id": r.AccountID, "bridge_id": id},
+	)
+
+	return teams, err
+}
+
+// Create a new team, setting the repository AccountID.
+func (r *teamRepository) Create(t Executor, team *Team) error {
+	team.AccountID = r.AccountID
+	return t.Insert(team)
+}
+
+// Update a team
+func (r *teamRepository) Update(t Executor, team *Team) (bool, error) {
+	team.UpdatedAt = time.Now()
+	count, err := t.Update(team)
+	return count > 0, err
+}
+
+// UpdateLastKnocked saves the member knocked last without overwriting a concurrent edit of the team.
+func (r *teamRepository) UpdateLastKnocked(t Executor, team *Team) (bool, error) {
+	result, err := t.Exec(
+		"UPDATE teams SET last_knocked_id = :last_knocked_id WHERE id = :id AND account_id = :account_id",
+		map[string]interface{}{"last_knocked_id": team.LastKnockedID, "id": team.ID, "account_id": r.AccountID},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Delete a team
+func (r *teamRepository) Delete(t Executor, team *Team) (bool, error) {
+	count, err := t.Delete(team)
+	return count > 0, err
+}
+
+func (r *teamRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// All returns the team members of the account, ordered by team and position
+func (r *teamMemberRepository) All(t Executor) ([]*TeamMember, error) {
+	var members []*TeamMember
+
+	_, err := t.Select(
+		&members,
+		"SELECT * FROM team_members WHERE account_id = :account_id ORDER BY team_id ASC, position ASC",
+		map[string]interface{}{"account_id": r.AccountID},
+	)
+
+	return members, err
+}
+
+// FindByTeamID returns the members of a team, ordered by position
+func (r *teamMemberRepository) FindByTeamID(t Executor, id uint) ([]*TeamMember, error) {
+	var members []*TeamMember
+
+	_, err := t.Select(
+		&members,
+		"SELECT * FROM team_members WHERE account_id = :account_id AND team_id = :team_id ORDER BY position ASC",
+		map[string]interface{}{"account_id": r.AccountID, "team_id": id},
+	)
+
+	return members, err
+}
+
+// Create a new team member, setting the repository AccountID.
+func (r *teamMemberRepository) Create(t Executor, member *TeamMember) error {
+	member.AccountID = r.AccountID
+	return t.Insert(member)
+}
+
+// DeleteByTeamID removes the members of a team
+func (r *teamMemberRepository) DeleteByTeamID(t Executor, id uint) error {
+	_, err := t.Exec(
+		"DELETE FROM team_members WHERE account_id = :account_id AND team_id = :team_id",
+		map[string]interface{}{"account_id": r.AccountID, "team_id": id},
+	)
+
+	return err
+}
+
+func (r *teamMemberRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+func (r *pushDeviceRepository) Find(t Executor, id uint) (*PushDevice, error) {
+	var (
+		devices []*PushDevice
+		device  *PushDevice
+	)
+
+	_, err := t.Select(
+		&devices,
+		"SELECT * FROM push_devices WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(devices) == 1 {
+		device = devices[0]
+	}
+
+	return device, err
+}
+
+// FindByPersonID returns the push devices registered by a person
+func (r *pushDeviceRepository) FindByPersonID(t Executor, id uint) ([]*PushDevice, error) {
+	var devices []*PushDevice
+
+	_, err := t.Select(
+		&devices,
+		"SELECT * FROM push_devices WHERE account_id = :account_id AND person_id = :person_id ORDER BY id ASC",
+		map[string]interface{}{"account_id": r.AccountID, "person_id": id},
+	)
+
+	return devices, err
+}
+
+// FindByToken returns the push device registered with a push token
+func (r *pushDeviceRepository) FindByToken(t Executor, token string) (*PushDevice, error) {
+	var (
+		devices []*PushDevice
+		device  *PushDevice
+	)
+
+	_, err := t.Select(
+		&devices,
+		"SELECT * FROM push_devices WHERE token = :token AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"token": token, "account_id": r.AccountID},
+	)
+
+	if len(devices) == 1 {
+		device = devices[0]
+	}
+
+	return device, err
+}
+
+// Create a new push device, setting the repository AccountID.
+func (r *pushDeviceRepository) Create(t Executor, device *PushDevice) error {
+	now := time.Now()
+
+	device.AccountID = r.AccountID
+	device.CreatedAt = now
+	device.UpdatedAt = now
+
+	return t.Insert(device)
+}
+
+func (r *pushDeviceRepository) Update(t Executor, device *PushDevice) (bool, error) {
+	device.UpdatedAt = time.Now()
+
+	count, err := t.Update(device)
+	return count > 0, err
+}
+
+func (r *pushDeviceRepository) Delete(t Executor, device *PushDevice) (bool, error) {
+	count, err := t.Delete(device)
 	return count > 0, err
 }
 
-func (r *personRepository) SetAccountScope(accountID uint) {
+func (r *pushDeviceRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// reportParameters validates the filter and returns the query parameters shared by the reports.
+func (r *reportRepository) reportParameters(f *ReportFilter) (map[string]interface{}, error) {
+	if !reportIntervals[f.Interval] {
+		return nil, fmt.Errorf("invalid report interval %q", f.Interval)
+	}
+
+	return map[string]interface{}{
+		"account_id": r.AccountID,
+		"interval":   f.Interval,
+		"since":      f.Since,
+		"until":      f.Until,
+	}, nil
+}
+
+// Visits returns the number of visits, distinct visitors, peak hour and average visit duration per period and group.
+func (r *reportRepository) Visits(t Executor, f *ReportFilter) ([]*VisitReport, error) {
+	var reports []*VisitReport
+
+	groups := map[string]string{
+		ReportGroupDoor:   "door_id",
+		ReportGroupDevice: "COALESCE(device_id, 0)",
+		ReportGroupPerson: "person_id",
+	}
+
+	group, ok := groups[f.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid report group %q", f.GroupBy)
+	}
+
+	parameters, err := r.reportParameters(f)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT date_trunc(:interval, checked_in_at) AS period, " + group + " AS group_id," +
+		" COUNT(*) AS visits," +
+		" COUNT(DISTINCT visitor_id) AS visitors," +
+		" mode() WITHIN GROUP (ORDER BY EXTRACT(HOUR FROM checked_in_at))::integer AS peak_hour," +
+		" COALESCE(AVG(EXTRACT(EPOCH FROM checked_out_at - checked_in_at)), 0) AS average_duration" +
+		" FROM visits WHERE account_id = :account_id AND checked_in_at >= :since AND checked_in_at < :until"
+
+	if f.DoorID > 0 {
+		query += " AND door_id = :door_id"
+		parameters["door_id"] = f.DoorID
+	}
+
+	if f.DeviceID > 0 {
+		query += " AND device_id = :device_id"
+		parameters["device_id"] = f.DeviceID
+	}
+
+	if f.PersonID > 0 {
+		query += " AND person_id = :person_id"
+		parameters["person_id"] = f.PersonID
+	}
+
+	query += " GROUP BY 1, 2 ORDER BY 1, 2"
+
+	_, err = t.Select(
+		&reports,
+		query,
+		parameters,
+	)
+
+	return reports, err
+}
+
+// ResponseTimes returns how many knock-knocks were sent and acknowledged, along with the median and average
+// time to acknowledge them, per period and group. Devices are found through the visit of the knock-knock.
+func (r *reportRepository) ResponseTimes(t Executor, f *ReportFilter) ([]*ResponseTimeReport, error) {
+	var reports []*ResponseTimeReport
+
+	groups := map[string]string{
+		ReportGroupDoor:   "n.door_id",
+		ReportGroupDevice: "COALESCE(v.device_id, 0)",
+		ReportGroupPerson: "n.person_id",
+	}
+
+	group, ok := groups[f.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid report group %q", f.GroupBy)
+	}
+
+	parameters, err := r.reportParameters(f)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT date_trunc(:interval, n.created_at) AS period, " + group + " AS group_id," +
+		" COUNT(*) AS notifications," +
+		" COUNT(n.acknowledged_at) AS acknowledged," +
+		" COUNT(*) - COUNT(n.acknowledged_at) AS unanswered," +
+		" COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM n.acknowledged_at - n.created_at)), 0) AS median_response_time," +
+		" COALESCE(AVG(EXTRACT(EPOCH FROM n.acknowledged_at - n.created_at)), 0) AS average_response_time" +
+		" FROM notifications n LEFT JOIN visits v ON v.id = n.visit_id" +
+		" WHERE n.account_id = :account_id AND n.created_at >= :since AND n.created_at < :until"
+
+	if f.DoorID > 0 {
+		query += " AND n.door_id = :door_id"
+		parameters["door_id"] = f.DoorID
+	}
+
+	if f.DeviceID > 0 {
+		query += " AND v.device_id = :device_id"
+		parameters["device_id"] = f.DeviceID
+	}
+
+	if f.PersonID > 0 {
+		query += " AND n.person_id = :person_id"
+		parameters["person_id"] = f.PersonID
+	}
+
+	query += " GROUP BY 1, 2 ORDER BY 1, 2"
+
+	_, err = t.Select(
+		&reports,
+		query,
+		parameters,
+	)
+
+	return reports, err
+}
+
+func (r *reportRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// All returns the most recent visits matching the filter
+func (r *visitRepository) All(t Executor, f *VisitFilter) ([]*Visit, error) {
+	var visits []*Visit
+
+	query := "SELECT * FROM visits WHERE account_id = :account_id"
+	parameters := map[string]interface{}{"account_id": r.AccountID}
+
+	if f.PersonID > 0 {
+		query += " AND person_id = :person_id"
+		parameters["person_id"] = f.PersonID
+	}
+
+	if f.DoorID > 0 {
+		query += " AND door_id = :door_id"
+		parameters["door_id"] = f.DoorID
+	}
+
+	if f.Active {
+		query += " AND checked_out_at IS NULL"
+	}
+
+	limit := f.Limit
+	if limit == 0 || limit > 100 {
+		limit = 100
+	}
+
+	query += " ORDER BY checked_in_at DESC LIMIT :limit"
+	parameters["limit"] = limit
+
+	_, err := t.Select(
+		&visits,
+		query,
+		parameters,
+	)
+
+	return visits, err
+}
+
+func (r *visitRepository) Find(t Executor, id uint) (*Visit, error) {
+	var (
+		visits []*Visit
+		visit  *Visit
+	)
+
+	_, err := t.Select(
+		&visits,
+		"SELECT * FROM visits WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(visits) == 1 {
+		visit = visits[0]
+	}
+
+	return visit, err
+}
+
+// Create a new visit, setting the repository AccountID.
+func (r *visitRepository) Create(t Executor, visit *Visit) error {
+	visit.AccountID = r.AccountID
+	return t.Insert(visit)
+}
+
+func (r *visitRepository) Update(t Executor, visit *Visit) (bool, error) {
+	visit.UpdatedAt = time.Now()
+
+	count, err := t.Update(visit)
+	return count > 0, err
+}
+
+func (r *visitRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+func (r *visitorRepository) Find(t Executor, id uint) (*Visitor, error) {
+	var (
+		visitors []*Visitor
+		visitor  *Visitor
+	)
+
+	_, err := t.Select(
+		&visitors,
+		"SELECT * FROM visitors WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(visitors) == 1 {
+		visitor = visitors[0]
+	}
+
+	return visitor, err
+}
+
+// FindByEmail returns the visitor registered with the email. Emails are compared case-insensitively.
+func (r *visitorRepository) FindByEmail(t Executor, email string) (*Visitor, error) {
+	var (
+		visitors []*Visitor
+		visitor  *Visitor
+	)
+
+	_, err := t.Select(
+		&visitors,
+		"SELECT * FROM visitors WHERE lower(email) = lower(:email) AND account_id = :account_id ORDER BY id DESC LIMIT 1",
+		map[string]interface{}{"email": email, "account_id": r.AccountID},
+	)
+
+	if len(visitors) == 1 {
+		visitor = visitors[0]
+	}
+
+	return visitor, err
+}
+
+// FindByIDs returns the visitors matching the given ids.
+func (r *visitorRepository) FindByIDs(t Executor, ids []uint) ([]*Visitor, error) {
+	var visitors []*Visitor
+
+	if len(ids) == 0 {
+		return visitors, nil
+	}
+
+	placeholders := make([]string, len(ids))
+	parameters := map[string]interface{}{"account_id": r.AccountID}
+
+	for i, id := range ids {
+		key := fmt.Sprintf("id%d", i)
+		placeholders[i] = ":" + key
+		parameters[key] = id
+	}
+
+	_, err := t.Select(
+		&visitors,
+		"SELECT * FROM visitors WHERE account_id = :account_id AND id IN ("+strings.Join(placeholders, ", ")+")",
+		parameters,
+	)
+
+	return visitors, err
+}
+
+// Create a new visitor, setting the repository AccountID.
+func (r *visitorRepository) Create(t Executor, visitor *Visitor) error {
+	now := time.Now()
+
+	visitor.AccountID = r.AccountID
+	visitor.CreatedAt = now
+	visitor.UpdatedAt = now
+
+	return t.Insert(visitor)
+}
+
+func (r *visitorRepository) Update(t Executor, visitor *Visitor) (bool, error) {
+	visitor.UpdatedAt = time.Now()
+
+	count, err := t.Update(visitor)
+	return count > 0, err
+}
+
+func (r *visitorRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// Claim marks due deliveries as processing and returns them.
+// When the repository is not scoped to an account, deliveries from every account are claimed.
+func (r *webhookDeliveryRepository) Claim(t Executor, limit uint, timeout time.Duration) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+
+	parameters := map[string]interface{}{
+		"pending":    WebhookDeliveryPending,
+		"processing": WebhookDeliveryProcessing,
+		"now":        time.Now(),
+		"stale":      time.Now().Add(-timeout),
+		"limit":      limit,
+	}
+
+	query := "SELECT id FROM webhook_deliveries WHERE ((status = :pending AND next_attempt_at <= :now) OR (status = :processing AND locked_at < :stale))"
+
+	if r.AccountID > 0 {
+		query += " AND account_id = :account_id"
+		parameters["account_id"] = r.AccountID
+	}
+
+	query += " ORDER BY next_attempt_at ASC LIMIT :limit FOR UPDATE"
+
+	_, err := t.Select(
+		&deliveries,
+		"UPDATE webhook_deliveries SET status = :processing, locked_at = :now, updated_at = :now WHERE id IN ("+query+") RETURNING *",
+		parameters,
+	)
+
+	return deliveries, err
+}
+
+// Create a new delivery, setting the repository AccountID.
+func (r *webhookDeliveryRepository) Create(t Executor, delivery *WebhookDelivery) error {
+	delivery.AccountID = r.AccountID
+	return t.Insert(delivery)
+}
+
+func (r *webhookDeliveryRepository) Find(t Executor, id uint) (*WebhookDelivery, error) {
+	var (
+		deliveries []*WebhookDelivery
+		delivery   *WebhookDelivery
+	)
+
+	_, err := t.Select(
+		&deliveries,
+		"SELECT * FROM webhook_deliveries WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(deliveries) == 1 {
+		delivery = deliveries[0]
+	}
+
+	return delivery, err
+}
+
+// FindBySubscriptionID returns the most recent deliveries of a subscription
+func (r *webhookDeliveryRepository) FindBySubscriptionID(t Executor, id uint, limit uint) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+
+	_, err := t.Select(
+		&deliveries,
+		"SELECT * FROM webhook_deliveries WHERE account_id = :account_id AND subscription_id = :subscription_id ORDER BY id DESC LIMIT :limit",
+		map[string]interface{}{"account_id": r.AccountID, "subscription_id": id, "limit": limit},
+	)
+
+	return deliveries, err
+}
+
+func (r *webhookDeliveryRepository) Update(t Executor, delivery *WebhookDelivery) (bool, error) {
+	delivery.UpdatedAt = time.Now()
+
+	count, err := t.Update(delivery)
+	return count > 0, err
+}
+
+func (r *webhookDeliveryRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+func (r *webhookSubscriptionRepository) All(t Executor) ([]*WebhookSubscription, error) {
+	var subscriptions []*WebhookSubscription
+
+	_, err := t.Select(
+		&subscriptions,
+		"SELECT * FROM webhook_subscriptions WHERE account_id = :account_id ORDER BY id ASC",
+		map[string]interface{}{"account_id": r.AccountID},
+	)
+
+	return subscriptions, err
+}
+
+func (r *webhookSubscriptionRepository) Find(t Executor, id uint) (*WebhookSubscription, error) {
+	var (
+		subscriptions []*WebhookSubscription
+		subscription  *WebhookSubscription
+	)
+
+	_, err := t.Select(
+		&subscriptions,
+		"SELECT * FROM webhook_subscriptions WHERE id = :id AND account_id = :account_id LIMIT 1",
+		map[string]interface{}{"id": id, "account_id": r.AccountID},
+	)
+
+	if len(subscriptions) == 1 {
+		subscription = subscriptions[0]
+	}
+
+	return subscription, err
+}
+
+// Create a new subscription, setting the repository AccountID.
+func (r *webhookSubscriptionRepository) Create(t Executor, subscription *WebhookSubscription) error {
+	now := time.Now()
+
+	subscription.AccountID = r.AccountID
+	subscription.CreatedAt = now
+	subscription.UpdatedAt = now
+
+	return t.Insert(subscription)
+}
+
+func (r *webhookSubscriptionRepository) Update(t Executor, subscription *WebhookSubscription) (bool, error) {
+	subscription.UpdatedAt = time.Now()
+
+	count, err := t.Update(subscription)
+	return count > 0, err
+}
+
+func (r *webhookSubscriptionRepository) Delete(t Executor, subscription *WebhookSubscription) (bool, error) {
+	count, err := t.Delete(subscription)
+	return count > 0, err
+}
+
+func (r *webhookSubscriptionRepository) SetAccountScope(accountID uint) {
+	r.AccountID = accountID
+}
+
+// All returns the working hours of the account people
+func (r *workingHoursRepository) All(t Executor) ([]*WorkingHours, error) {
+	var hours []*WorkingHours
+
+	_, err := t.Select(
+		&hours,
+		"SELECT * FROM working_hours WHERE account_id = :account_id ORDER BY person_id ASC, day ASC, starts_at ASC",
+		map[string]interface{}{"account_id": r.AccountID},
+	)
+
+	return hours, err
+}
+
+// FindByPersonID returns the working hours of a person, by day
+func (r *workingHoursRepository) FindByPersonID(t Executor, id uint) ([]*WorkingHours, error) {
+	var hours []*WorkingHours
+
+	_, err := t.Select(
+		&hours,
+		"SELECT * FROM working_hours WHERE account_id = :account_id AND person_id = :person_id ORDER BY day ASC, starts_at ASC",
+		map[string]interface{}{"account_id": r.AccountID, "person_id": id},
+	)
+
+	return hours, err
+}
+
+// Create new working hours, setting the repository AccountID.
+func (r *workingHoursRepository) Create(t Executor, hours *WorkingHours) error {
+	hours.AccountID = r.AccountID
+	return t.Insert(hours)
+}
+
+// DeleteByPersonID removes the working hours of a person
+func (r *workingHoursRepository) DeleteByPersonID(t Executor, id uint) error {
+	_, err := t.Exec(
+		"DELETE FROM working_hours WHERE account_id = :account_id AND person_id = :person_id",
+		map[string]interface{}{"account_id": r.AccountID, "person_id": id},
+	)
+
+	return err
+}
+
+func (r *workingHoursRepository) SetAccountScope(accountID uint) {
 	r.AccountID = accountID
 }
 
@@ -885,10 +2556,25 @@ func initDatabase(c *DoorbotConfig) *gorp.DbMap {
 	dbmap.AddTableWithName(Account{}, "accounts").SetKeys(true, "ID")
 	dbmap.AddTableWithName(Authentication{}, "authentications").SetKeys(true, "ID")
 	dbmap.AddTableWithName(BridgeUser{}, "bridge_users").SetKeys(false)
+	dbmap.AddTableWithName(Delegate{}, "delegates").SetKeys(true, "ID")
 	dbmap.AddTableWithName(Door{}, "doors").SetKeys(true, "ID")
 	dbmap.AddTableWithName(Device{}, "devices").SetKeys(true, "ID")
+	dbmap.AddTableWithName(EscalationStep{}, "escalation_steps").SetKeys(true, "ID")
 	dbmap.AddTableWithName(Event{}, "events").SetKeys(true, "ID")
+	dbmap.AddTableWithName(Invitation{}, "invitations").SetKeys(true, "ID")
+	dbmap.AddTableWithName(Notification{}, "notifications").SetKeys(true, "ID")
+	dbmap.AddTableWithName(NotificationAttempt{}, "notification_attempts").SetKeys(true, "ID")
+	dbmap.AddTableWithName(NotificationTemplate{}, "notification_templates").SetKeys(true, "ID")
+	dbmap.AddTableWithName(OutOfOffice{}, "out_of_office_periods").SetKeys(true, "ID")
 	dbmap.AddTableWithName(Person{}, "people").SetKeys(true, "ID")
+	dbmap.AddTableWithName(PushDevice{}, "push_devices").SetKeys(true, "ID")
+	dbmap.AddTableWithName(Team{}, "teams").SetKeys(true, "ID")
+	dbmap.AddTableWithName(TeamMember{}, "team_members").SetKeys(true, "ID")
+	dbmap.AddTableWithName(Visit{}, "visits").SetKeys(true, "ID")
+	dbmap.AddTableWithName(Visitor{}, "visitors").SetKeys(true, "ID")
+	dbmap.AddTableWithName(WebhookDelivery{}, "webhook_deliveries").SetKeys(true, "ID")
+	dbmap.AddTableWithName(WebhookSubscription{}, "webhook_subscriptions").SetKeys(true, "ID")
+	dbmap.AddTableWithName(WorkingHours{}, "working_hours").SetKeys(true, "ID")
 
 	if c.Database.Trace {
 		logger := log.New()
@@ -899,8 +2585,11 @@ func initDatabase(c *DoorbotConfig) *gorp.DbMap {
 }
 
 // MapDatabase maps the database in Martini
-func MapDatabase(m *martini.Martini, config *DoorbotConfig) {
-	m.Map(initDatabase(config))
+func MapDatabase(m *martini.Martini, config *DoorbotConfig) *gorp.DbMap {
+	db := initDatabase(config)
+	m.Map(db)
+
+	return db
 }
 
 // UseRepositories maps the repositories within a martini context.
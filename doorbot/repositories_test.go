@@ -0,0 +1,89 @@
+// +build tests
+
+package doorbot
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/gorp.v1"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDatabase connects to the database named by DOORBOT_TEST_DATABASE_URL, skipping the test when it is not set.
+func testDatabase(t *testing.T) *gorp.DbMap {
+	url := os.Getenv("DOORBOT_TEST_DATABASE_URL")
+	if len(url) == 0 {
+		t.Skip("DOORBOT_TEST_DATABASE_URL is not set")
+	}
+
+	return initDatabase(&DoorbotConfig{Database: DatabaseConfig{URL: url}})
+}
+
+func TestNotificationClaimConcurrent(t *testing.T) {
+	db := testDatabase(t)
+	r := NewRepositories(db)
+
+	account := &Account{Name: "Claims", Host: fmt.Sprintf("claims-%d", time.Now().UnixNano()), IsEnabled: true}
+	if !assert.NoError(t, r.AccountRepository().Create(r.DB(), account)) {
+		return
+	}
+
+	r.SetAccountScope(account.ID)
+
+	door := &Door{Name: "Lobby"}
+	person := &Person{Name: "John Rambo", Email: "rambo@example.com"}
+
+	assert.NoError(t, r.DoorRepository().Create(r.DB(), door))
+	assert.NoError(t, r.PersonRepository().Create(r.DB(), person))
+
+	for i := 0; i < 2; i++ {
+		now := time.Now()
+		notification := &Notification{DoorID: door.ID, PersonID: person.ID, Status: NotificationPending, NextAttemptAt: now, CreatedAt: now, UpdatedAt: now}
+		assert.NoError(t, r.NotificationRepository().Create(r.DB(), notification))
+	}
+
+	defer func() {
+		r.DB().Exec("DELETE FROM notifications WHERE account_id = $1", account.ID)
+		r.DB().Exec("DELETE FROM people WHERE account_id = $1", account.ID)
+		r.DB().Exec("DELETE FROM doors WHERE account_id = $1", account.ID)
+		r.DB().Exec("DELETE FROM accounts WHERE id = $1", account.ID)
+	}()
+
+	// Two workers polling at the same time each claim a different notification instead of waiting on the same row.
+	claimed := make([][]*Notification, 2)
+	errs := make([]error, 2)
+
+	var start, done sync.WaitGroup
+	start.Add(1)
+
+	for i := range claimed {
+		done.Add(1)
+
+		go func(i int) {
+			defer done.Done()
+
+			worker := NewRepositories(db)
+			worker.SetAccountScope(account.ID)
+
+			start.Wait()
+			claimed[i], errs[i] = worker.NotificationRepository().Claim(worker.DB(), 1, time.Minute)
+		}(i)
+	}
+
+	start.Done()
+	done.Wait()
+
+	for i := range claimed {
+		assert.NoError(t, errs[i])
+		assert.Len(t, claimed[i], 1)
+	}
+
+	if len(claimed[0]) == 1 && len(claimed[1]) == 1 {
+		assert.NotEqual(t, claimed[0][0].ID, claimed[1][0].ID)
+		assert.Equal(t, NotificationProcessing, claimed[0][0].Status)
+		assert.Equal(t, NotificationProcessing, claimed[1][0].Status)
+	}
+}
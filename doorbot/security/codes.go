@@ -0,0 +1,26 @@
+package security
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// codeLetters excludes the characters easily mistaken for one another ( 0/O, 1/I/L ).
+var codeLetters = []rune("ABCDEFGHJKMNPQRSTUVWXYZ23456789")
+
+// GenerateCode generates a random upper case code of a given length, easy to read and type on a lobby device.
+func GenerateCode(l int) string {
+	max := big.NewInt(int64(len(codeLetters)))
+
+	b := make([]rune, l)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+
+		b[i] = codeLetters[n.Int64()]
+	}
+
+	return string(b)
+}
@@ -0,0 +1,18 @@
+// +build tests
+
+package security
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestGenerateCode(t *testing.T) {
+	code := GenerateCode(8)
+
+	assert.Len(t, code, 8)
+	assert.Equal(t, strings.ToUpper(code), code)
+	assert.False(t, strings.ContainsAny(code, "0O1IL"))
+	assert.NotEqual(t, GenerateCode(8), GenerateCode(8))
+}
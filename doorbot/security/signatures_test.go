@@ -0,0 +1,25 @@
+// +build tests
+
+package security
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	signature := Sign("secret", "message")
+
+	assert.Len(t, signature, 64)
+	assert.Equal(t, signature, Sign("secret", "message"))
+	assert.NotEqual(t, signature, Sign("other", "message"))
+}
+
+func TestVerifySignature(t *testing.T) {
+	signature := Sign("secret", "message")
+
+	assert.True(t, VerifySignature("secret", "message", signature))
+	assert.False(t, VerifySignature("secret", "tampered", signature))
+	assert.False(t, VerifySignature("other", "message", signature))
+	assert.False(t, VerifySignature("secret", "message", "not-hex"))
+}
@@ -0,0 +1,100 @@
+// Package audit records the events of the account audit log.
+package audit
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	log "github.com/Sirupsen/logrus"
+)
+
+// Record saves an event on behalf of the session. The actor is taken from the session, a nil session records a system event.
+// Errors are logged: failing to record an event never fails the request being audited.
+func Record(r doorbot.Repositories, session *auth.Authorization, event *doorbot.Event) {
+	event.ActorType, event.ActorID = actor(session)
+
+	err := r.EventRepository().Create(r.DB(), event)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": r.AccountScope(),
+			"event_id":   event.EventID,
+			"actor_type": event.ActorType,
+			"actor_id":   event.ActorID,
+			"step":       "event-create",
+		}).Error("Audit::Record database error")
+	}
+}
+
+// actor returns the type and id of whoever is behind the session.
+func actor(session *auth.Authorization) (string, uint) {
+	if session == nil {
+		return doorbot.EventActorSystem, 0
+	}
+
+	switch session.Type {
+	case auth.AuthorizationPerson:
+		if session.Person != nil {
+			return doorbot.EventActorPerson, session.Person.ID
+		}
+	case auth.AuthorizationDevice:
+		if session.Device != nil {
+			return doorbot.EventActorDevice, session.Device.ID
+		}
+	case auth.AuthorizationAdministrator:
+		if session.Administrator != nil {
+			return doorbot.EventActorAdministrator, session.Administrator.ID
+		}
+	}
+
+	return doorbot.EventActorSystem, 0
+}
+
+// Door records an event about a door
+func Door(r doorbot.Repositories, session *auth.Authorization, eventID uint, d *doorbot.Door) {
+	event := doorbot.NewEvent(r.AccountScope(), 0, d.ID, eventID, 0)
+	event.Metadata = doorbot.Metadata{"name": d.Name}
+
+	Record(r, session, event)
+}
+
+// Device records an event about a device
+func Device(r doorbot.Repositories, session *auth.Authorization, eventID uint, d *doorbot.Device) {
+	var doorID uint
+	if d.DoorID != nil {
+		doorID = *d.DoorID
+	}
+
+	event := doorbot.NewEvent(r.AccountScope(), d.ID, doorID, eventID, 0)
+	event.Metadata = doorbot.Metadata{"name": d.Name, "is_enabled": d.IsEnabled}
+
+	Record(r, session, event)
+}
+
+// Person records an event about a person
+func Person(r doorbot.Repositories, session *auth.Authorization, eventID uint, p *doorbot.Person) {
+	event := doorbot.NewEvent(r.AccountScope(), 0, 0, eventID, p.ID)
+	event.Metadata = doorbot.Metadata{"name": p.Name, "email": p.Email}
+
+	Record(r, session, event)
+}
+
+// Team records an event about a team
+func Team(r doorbot.Repositories, session *auth.Authorization, eventID uint, t *doorbot.Team) {
+	event := doorbot.NewEvent(r.AccountScope(), 0, 0, eventID, 0)
+	event.Metadata = doorbot.Metadata{"team_id": t.ID, "name": t.Name, "strategy": t.Strategy}
+
+	Record(r, session, event)
+}
+
+// Visit records an event about a visit
+func Visit(r doorbot.Repositories, session *auth.Authorization, eventID uint, v *doorbot.Visit) {
+	var deviceID uint
+	if v.DeviceID != nil {
+		deviceID = *v.DeviceID
+	}
+
+	event := doorbot.NewEvent(r.AccountScope(), deviceID, v.DoorID, eventID, v.PersonID)
+	event.Metadata = doorbot.Metadata{"visit_id": v.ID, "visitor_id": v.VisitorID}
+
+	Record(r, session, event)
+}
@@ -0,0 +1,26 @@
+package audit
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestActor(t *testing.T) {
+	kind, id := actor(nil)
+	assert.Equal(t, doorbot.EventActorSystem, kind)
+	assert.Equal(t, uint(0), id)
+
+	kind, id = actor(&auth.Authorization{Type: auth.AuthorizationPerson, Person: &doorbot.Person{ID: 4}})
+	assert.Equal(t, doorbot.EventActorPerson, kind)
+	assert.Equal(t, uint(4), id)
+
+	kind, id = actor(&auth.Authorization{Type: auth.AuthorizationDevice, Device: &doorbot.Device{ID: 7}})
+	assert.Equal(t, doorbot.EventActorDevice, kind)
+	assert.Equal(t, uint(7), id)
+
+	kind, id = actor(&auth.Authorization{Type: auth.AuthorizationAdministrator, Administrator: &doorbot.Administrator{ID: 2}})
+	assert.Equal(t, doorbot.EventActorAdministrator, kind)
+	assert.Equal(t, uint(2), id)
+}
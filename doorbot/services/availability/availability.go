@@ -0,0 +1,335 @@
+// Package availability computes whether a person can be knocked from their working hours and out-of-office periods.
+package availability
+
+import (
+	"fmt"
+	"github.com/masom/doorbot/doorbot"
+	"time"
+)
+
+const (
+	// ReasonUnavailable is used when the person turned their availability off
+	ReasonUnavailable = "unavailable"
+	// ReasonOutOfOffice is used during an out-of-office period
+	ReasonOutOfOffice = "out_of_office"
+	// ReasonOutsideWorkingHours is used outside of the person working hours
+	ReasonOutsideWorkingHours = "outside_working_hours"
+)
+
+// Status is the availability of a person at a given time
+type Status struct {
+	IsAvailable bool   `json:"is_available"`
+	Reason      string `json:"reason,omitempty"`
+	// When the person is expected back, nil when unknown
+	BackAt *time.Time `json:"back_at,omitempty"`
+	// Shown to the visitors, ex: "Jane is out of the office, back on Monday."
+	Message string `json:"message,omitempty"`
+}
+
+// Location returns the timezone of a person, UTC when unset or unknown.
+func Location(p *doorbot.Person) *time.Location {
+	if len(p.Timezone) == 0 {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// ParseClock parses a HH:MM time of day into minutes since midnight. 24:00 is accepted to end at midnight.
+func ParseClock(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+
+	t, err := time.Parse("15:04", value)
+	if err != nil || len(value) != 5 {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate returns the errors of a timezone and weekly working hours
+func Validate(timezone string, hours []*doorbot.WorkingHours) []string {
+	var errors []string
+
+	if len(timezone) > 0 {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			errors = append(errors, fmt.Sprintf("The timezone is unknown: %s.", timezone))
+		}
+	}
+
+	for i, h := range hours {
+		if h == nil {
+			errors = append(errors, fmt.Sprintf("Working hours %d are empty.", i+1))
+			continue
+		}
+
+		if h.Day > 6 {
+			errors = append(errors, fmt.Sprintf("Working hours %d: the day must be between 0 ( Sunday ) and 6 ( Saturday ).", i+1))
+		}
+
+		start, err := ParseClock(h.StartsAt)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Working hours %d: the start must be formatted as HH:MM.", i+1))
+			continue
+		}
+
+		end, err := ParseClock(h.EndsAt)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Working hours %d: the end must be formatted as HH:MM.", i+1))
+			continue
+		}
+
+		if end <= start {
+			errors = append(errors, fmt.Sprintf("Working hours %d: the end must be after the start.", i+1))
+		}
+	}
+
+	return errors
+}
+
+// Compute returns the availability of a person at the given time.
+// The availability flag of the person comes first, then the out-of-office periods and the working hours.
+func Compute(p *doorbot.Person, hours []*doorbot.WorkingHours, periods []*doorbot.OutOfOffice, now time.Time) *Status {
+	if !p.IsAvailable {
+		return &Status{
+			Reason:  ReasonUnavailable,
+			Message: fmt.Sprintf("%s is currently not available.", p.Name),
+		}
+	}
+
+	loc := Location(p)
+
+	for _, period := range periods {
+		if period.StartsAt.After(now) || !period.EndsAt.After(now) {
+			continue
+		}
+
+		back := next(hours, period.EndsAt, loc)
+
+		message := fmt.Sprintf("%s is out of the office, back %s.", p.Name, when(back, now.In(loc)))
+		if len(period.Message) > 0 {
+			message += " " + period.Message
+		}
+
+		return &Status{
+			Reason:  ReasonOutOfOffice,
+			BackAt:  &back,
+			Message: message,
+		}
+	}
+
+	if len(hours) > 0 && !within(hours, now.In(loc)) {
+		back := next(hours, now, loc)
+
+		return &Status{
+			Reason:  ReasonOutsideWorkingHours,
+			BackAt:  &back,
+			Message: fmt.Sprintf("%s is not in the office, back %s.", p.Name, when(back, now.In(loc))),
+		}
+	}
+
+	return &Status{IsAvailable: true}
+}
+
+// Person loads the schedule of a person and returns their current availability.
+// Hidden people are unavailable: they can neither be knocked nor take the knock-knocks of someone else.
+func Person(r doorbot.Repositories, p *doorbot.Person) (*Status, error) {
+	now := time.Now()
+
+	if !p.IsVisible {
+		return &Status{
+			Reason:  ReasonUnavailable,
+			Message: fmt.Sprintf("%s is currently not available.", p.Name),
+		}, nil
+	}
+
+	if !p.IsAvailable {
+		return Compute(p, nil, nil, now), nil
+	}
+
+	hours, err := r.WorkingHoursRepository().FindByPersonID(r.DB(), p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	periods, err := r.OutOfOfficeRepository().FindByPersonID(r.DB(), p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return Compute(p, hours, periods, now), nil
+}
+
+// People returns the current availability of the given people, by person id.
+func People(r doorbot.Repositories, people []*doorbot.Person) (map[uint]*Status, error) {
+	now := time.Now()
+
+	hours, err := r.WorkingHoursRepository().All(r.DB())
+	if err != nil {
+		return nil, err
+	}
+
+	periods, err := r.OutOfOfficeRepository().FindCurrent(r.DB(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	hoursByPerson := map[uint][]*doorbot.WorkingHours{}
+	for _, h := range hours {
+		hoursByPerson[h.PersonID] = append(hoursByPerson[h.PersonID], h)
+	}
+
+	periodsByPerson := map[uint][]*doorbot.OutOfOffice{}
+	for _, period := range periods {
+		periodsByPerson[period.PersonID] = append(periodsByPerson[period.PersonID], period)
+	}
+
+	statuses := make(map[uint]*Status, len(people))
+	for _, p := range people {
+		statuses[p.ID] = Compute(p, hoursByPerson[p.ID], periodsByPerson[p.ID], now)
+	}
+
+	return statuses, nil
+}
+
+// Fallback returns who takes the knock-knocks of an unavailable person: their first available delegate, then the account reception.
+// nil is returned when nobody can.
+func Fallback(r doorbot.Repositories, a *doorbot.Account, p *doorbot.Person) (*doorbot.Person, error) {
+	delegates, err := r.DelegateRepository().FindByPersonID(r.DB(), p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	for _, d := range delegates {
+		ids = append(ids, d.DelegateID)
+	}
+
+	if a.ReceptionPersonID != nil {
+		ids = append(ids, *a.ReceptionPersonID)
+	}
+
+	tried := map[uint]bool{p.ID: true}
+
+	for _, id := range ids {
+		if tried[id] {
+			continue
+		}
+
+		tried[id] = true
+
+		candidate, err := r.PersonRepository().Find(r.DB(), id)
+		if err != nil {
+			return nil, err
+		}
+
+		if candidate == nil {
+			continue
+		}
+
+		status, err := Person(r, candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.IsAvailable {
+			return candidate, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// within tells if the local time falls in the working hours. The end of the working hours is excluded.
+func within(hours []*doorbot.WorkingHours, local time.Time) bool {
+	minutes := local.Hour()*60 + local.Minute()
+
+	for _, h := range hours {
+		if time.Weekday(h.Day) != local.Weekday() {
+			continue
+		}
+
+		start, err := ParseClock(h.StartsAt)
+		if err != nil {
+			continue
+		}
+
+		end, err := ParseClock(h.EndsAt)
+		if err != nil {
+			continue
+		}
+
+		if minutes >= start && minutes < end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// next returns the first time from the given one that falls in the working hours.
+// The given time is returned when there are no working hours.
+func next(hours []*doorbot.WorkingHours, from time.Time, loc *time.Location) time.Time {
+	local := from.In(loc)
+
+	if len(hours) == 0 || within(hours, local) {
+		return local
+	}
+
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	for i := 0; i <= 7; i++ {
+		day := midnight.AddDate(0, 0, i)
+
+		var best time.Time
+		for _, h := range hours {
+			if time.Weekday(h.Day) != day.Weekday() {
+				continue
+			}
+
+			start, err := ParseClock(h.StartsAt)
+			if err != nil {
+				continue
+			}
+
+			at := time.Date(day.Year(), day.Month(), day.Day(), start/60, start%60, 0, 0, loc)
+			if at.After(local) && (best.IsZero() || at.Before(best)) {
+				best = at
+			}
+		}
+
+		if !best.IsZero() {
+			return best
+		}
+	}
+
+	return local
+}
+
+// when describes a return time relative to now, ex: "at 14:00", "tomorrow", "on Monday".
+// Both times must be in the person timezone.
+func when(back time.Time, now time.Time) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := time.Date(back.Year(), back.Month(), back.Day(), 0, 0, 0, 0, back.Location())
+
+	// Rounded to absorb the daylight saving time changes.
+	days := int(day.Sub(today).Hours()/24 + 0.5)
+
+	switch {
+	case days <= 0:
+		return "at " + back.Format("15:04")
+	case days == 1:
+		return "tomorrow"
+	case days < 7:
+		return "on " + back.Format("Monday")
+	default:
+		return "on " + back.Format("Monday, January 2")
+	}
+}
@@ -0,0 +1,178 @@
+package availability
+
+import (
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Monday to Friday, 9 to 17 with a lunch break.
+var weekdays = []*doorbot.WorkingHours{
+	&doorbot.WorkingHours{Day: 1, StartsAt: "09:00", EndsAt: "12:00"},
+	&doorbot.WorkingHours{Day: 1, StartsAt: "13:00", EndsAt: "17:00"},
+	&doorbot.WorkingHours{Day: 2, StartsAt: "09:00", EndsAt: "17:00"},
+	&doorbot.WorkingHours{Day: 3, StartsAt: "09:00", EndsAt: "17:00"},
+	&doorbot.WorkingHours{Day: 4, StartsAt: "09:00", EndsAt: "17:00"},
+	&doorbot.WorkingHours{Day: 5, StartsAt: "09:00", EndsAt: "17:00"},
+}
+
+func TestComputeWorkingHours(t *testing.T) {
+	montreal, _ := time.LoadLocation("America/Montreal")
+	person := &doorbot.Person{Name: "Jane", IsAvailable: true, Timezone: "America/Montreal"}
+
+	// Monday August 3rd 2015, 10:00 in Montreal
+	monday := time.Date(2015, 8, 3, 10, 0, 0, 0, montreal)
+
+	assert.Equal(t, &Status{IsAvailable: true}, Compute(person, weekdays, nil, monday))
+	assert.Equal(t, &Status{IsAvailable: true}, Compute(person, nil, nil, monday.Add(12*time.Hour)))
+
+	lunch := Compute(person, weekdays, nil, monday.Add(2*time.Hour+30*time.Minute))
+	assert.False(t, lunch.IsAvailable)
+	assert.Equal(t, ReasonOutsideWorkingHours, lunch.Reason)
+	assert.Equal(t, "Jane is not in the office, back at 13:00.", lunch.Message)
+
+	evening := Compute(person, weekdays, nil, monday.Add(8*time.Hour))
+	assert.Equal(t, "Jane is not in the office, back tomorrow.", evening.Message)
+	assert.Equal(t, time.Date(2015, 8, 4, 9, 0, 0, 0, montreal).Unix(), evening.BackAt.Unix())
+
+	// The working hours are in the person timezone: 10:00 in Paris is 4:00 in Montreal.
+	paris, _ := time.LoadLocation("Europe/Paris")
+	assert.False(t, Compute(person, weekdays, nil, time.Date(2015, 8, 3, 10, 0, 0, 0, paris)).IsAvailable)
+
+	weekend := Compute(person, weekdays, nil, time.Date(2015, 8, 7, 18, 0, 0, 0, montreal))
+	assert.Equal(t, "Jane is not in the office, back on Monday.", weekend.Message)
+}
+
+func TestComputeOutOfOffice(t *testing.T) {
+	person := &doorbot.Person{Name: "Jane", IsAvailable: true}
+
+	now := time.Date(2015, 8, 3, 10, 0, 0, 0, time.UTC)
+
+	periods := []*doorbot.OutOfOffice{
+		&doorbot.OutOfOffice{
+			StartsAt: now.Add(-24 * time.Hour),
+			EndsAt:   time.Date(2015, 8, 15, 0, 0, 0, 0, time.UTC),
+			Message:  "Please ask for John.",
+		},
+	}
+
+	status := Compute(person, weekdays, periods, now)
+	assert.False(t, status.IsAvailable)
+	assert.Equal(t, ReasonOutOfOffice, status.Reason)
+
+	// Back on Saturday the 15th, the next working hours are on Monday the 17th.
+	assert.Equal(t, time.Date(2015, 8, 17, 9, 0, 0, 0, time.UTC), *status.BackAt)
+	assert.Equal(t, "Jane is out of the office, back on Monday, August 17. Please ask for John.", status.Message)
+
+	// Periods that are over or not started yet are ignored.
+	assert.True(t, Compute(person, nil, periods, now.Add(-48*time.Hour)).IsAvailable)
+	assert.True(t, Compute(person, nil, periods, periods[0].EndsAt).IsAvailable)
+}
+
+func TestComputeUnavailable(t *testing.T) {
+	status := Compute(&doorbot.Person{Name: "Jane"}, nil, nil, time.Now())
+
+	assert.Equal(t, &Status{Reason: ReasonUnavailable, Message: "Jane is currently not available."}, status)
+}
+
+func TestFallback(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	personRepo := new(tests.MockPersonRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+
+	repositories.On("DB").Return(db)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+
+	receptionID := uint(4)
+	account := &doorbot.Account{ID: 1, ReceptionPersonID: &receptionID}
+
+	host := &doorbot.Person{ID: 1, Name: "Jane"}
+	reception := &doorbot.Person{ID: 4, Name: "Front Desk", IsVisible: true, IsAvailable: true}
+
+	// The first delegate is away, the second one no longer exists.
+	delegateRepo.On("FindByPersonID", db, uint(1)).Return([]*doorbot.Delegate{
+		&doorbot.Delegate{DelegateID: 2},
+		&doorbot.Delegate{DelegateID: 3},
+	}, nil)
+
+	var missing *doorbot.Person
+	personRepo.On("Find", db, uint(2)).Return(&doorbot.Person{ID: 2, Name: "John", IsVisible: true}, nil)
+	personRepo.On("Find", db, uint(3)).Return(missing, nil)
+	personRepo.On("Find", db, uint(4)).Return(reception, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(4)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(4)).Return([]*doorbot.OutOfOffice{}, nil)
+
+	fallback, err := Fallback(repositories, account, host)
+	assert.Nil(t, err)
+	assert.Equal(t, reception, fallback)
+
+	// Nobody takes the knock-knocks of the reception.
+	delegateRepo.On("FindByPersonID", db, uint(4)).Return([]*doorbot.Delegate{}, nil)
+
+	fallback, err = Fallback(repositories, account, reception)
+	assert.Nil(t, err)
+	assert.Nil(t, fallback)
+}
+
+func TestPersonHidden(t *testing.T) {
+	repositories := new(tests.MockRepositories)
+
+	status, err := Person(repositories, &doorbot.Person{Name: "Jane", IsAvailable: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &Status{Reason: ReasonUnavailable, Message: "Jane is currently not available."}, status)
+
+	// The schedule is not even loaded.
+	repositories.Mock.AssertNotCalled(t, "WorkingHoursRepository")
+}
+
+func TestFallbackHidden(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	personRepo := new(tests.MockPersonRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	repositories.On("DB").Return(db)
+	repositories.On("PersonRepository").Return(personRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+
+	receptionID := uint(4)
+	account := &doorbot.Account{ID: 1, ReceptionPersonID: &receptionID}
+
+	// Both the delegate and the reception are available but hidden.
+	delegateRepo.On("FindByPersonID", db, uint(1)).Return([]*doorbot.Delegate{&doorbot.Delegate{DelegateID: 2}}, nil)
+	personRepo.On("Find", db, uint(2)).Return(&doorbot.Person{ID: 2, Name: "John", IsAvailable: true}, nil)
+	personRepo.On("Find", db, uint(4)).Return(&doorbot.Person{ID: 4, Name: "Front Desk", IsAvailable: true}, nil)
+
+	fallback, err := Fallback(repositories, account, &doorbot.Person{ID: 1, Name: "Jane"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, fallback)
+	personRepo.Mock.AssertExpectations(t)
+}
+
+func TestValidate(t *testing.T) {
+	assert.Empty(t, Validate("America/Montreal", weekdays))
+	assert.Empty(t, Validate("", []*doorbot.WorkingHours{&doorbot.WorkingHours{Day: 6, StartsAt: "20:00", EndsAt: "24:00"}}))
+
+	errors := Validate("Mars/Olympus", []*doorbot.WorkingHours{
+		&doorbot.WorkingHours{Day: 7, StartsAt: "09:00", EndsAt: "17:00"},
+		&doorbot.WorkingHours{Day: 1, StartsAt: "9h", EndsAt: "17:00"},
+		&doorbot.WorkingHours{Day: 1, StartsAt: "17:00", EndsAt: "09:00"},
+	})
+
+	assert.Equal(t, []string{
+		"The timezone is unknown: Mars/Olympus.",
+		"Working hours 1: the day must be between 0 ( Sunday ) and 6 ( Saturday ).",
+		"Working hours 2: the start must be formatted as HH:MM.",
+		"Working hours 3: the end must be after the start.",
+	}, errors)
+}
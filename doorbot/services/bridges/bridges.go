@@ -7,6 +7,7 @@ import (
 	"github.com/masom/doorbot/doorbot/services/bridges/hipchat"
 	"github.com/masom/doorbot/doorbot/services/bridges/slack"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -74,6 +75,7 @@ func (b *bridges) GetUsers(bridgeID uint) ([]*doorbot.BridgeUser, error) {
 				UserID:   strconv.FormatUint(uint64(hu.ID), 10),
 				Name:     name,
 				Email:    hu.Email,
+				Groups:   hu.Groups,
 			}
 		}
 
@@ -140,6 +142,7 @@ func (b *bridges) GetUsers(bridgeID uint) ([]*doorbot.BridgeUser, error) {
 				UserID:   strconv.FormatUint(uint64(gu.ID), 10),
 				Name:     gu.DisplayName,
 				Email:    gu.Email,
+				Groups:   orgUnitGroups(gu.OrgUnitPath),
 			}
 		}
 
@@ -148,3 +151,16 @@ func (b *bridges) GetUsers(bridgeID uint) ([]*doorbot.BridgeUser, error) {
 
 	return users, nil
 }
+
+// orgUnitGroups maps a Google organizational unit path to a group named after its last unit, ex: "/Engineering/IT" is "IT".
+// Users in the root organizational unit have no group.
+func orgUnitGroups(path string) []string {
+	path = strings.Trim(path, "/")
+	if len(path) == 0 {
+		return nil
+	}
+
+	units := strings.Split(path, "/")
+
+	return []string{units[len(units)-1]}
+}
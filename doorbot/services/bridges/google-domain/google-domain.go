@@ -13,6 +13,8 @@ type (
 		ID          uint
 		Email       string
 		DisplayName string
+		// Organizational unit of the user, ex: "/Engineering/IT"
+		OrgUnitPath string
 	}
 
 	// Fetches google users
@@ -78,6 +80,7 @@ func (g *userDirectory) GetUsers() ([]*GoogleUser, error) {
 			ID:          uint(id),
 			Email:       u.PrimaryEmail,
 			DisplayName: u.Name.FullName,
+			OrgUnitPath: u.OrgUnitPath,
 		}
 	}
 
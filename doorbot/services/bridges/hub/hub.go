@@ -19,6 +19,8 @@ type HubUser struct {
 	ID          uint
 	DisplayName *string
 	Email       string
+	// Names of the hub groups the user belongs to
+	Groups []string
 }
 
 // Hub configuration data
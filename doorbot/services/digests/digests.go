@@ -0,0 +1,321 @@
+// Package digests sends the lobby activity digests to the account managers.
+package digests
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications"
+	"github.com/masom/doorbot/doorbot/services/rendering"
+	"time"
+)
+
+const subjectTemplate = "Doorbot - {{account}} {{frequency}} lobby activity"
+
+const bodyTemplate = "Hi {{name}},\n\nHere is the {{frequency}} lobby activity of {{account}} since {{since}}.\n\nVisitors per door:\n{{doors}}\nUnanswered knock-knocks: {{unanswered}}\n\nOffline devices:\n{{devices}}\n - Doorbot"
+
+// Digest summarizes the lobby activity of an account over a period.
+type Digest struct {
+	Account        *doorbot.Account
+	Frequency      string
+	Since          time.Time
+	Until          time.Time
+	Doors          []*DoorActivity
+	OfflineDevices []*doorbot.Device
+}
+
+// DoorActivity counts the visits and unanswered knock-knocks of a door.
+type DoorActivity struct {
+	Door       *doorbot.Door
+	Visits     uint
+	Unanswered uint
+}
+
+// Scheduler periodically sends the digests that are due.
+// A digest is claimed before being sent, which lets several API instances run a scheduler.
+type Scheduler struct {
+	Config       doorbot.DigestConfig
+	Repositories func() doorbot.Repositories
+	Notificator  func(a *doorbot.Account, r doorbot.Repositories) notifications.Notificator
+
+	stop chan struct{}
+}
+
+// NewScheduler creates a new Scheduler. The repositories function must return a new Repositories instance on every call.
+func NewScheduler(c doorbot.DigestConfig, r func() doorbot.Repositories) *Scheduler {
+	return &Scheduler{
+		Config:       c,
+		Repositories: r,
+		Notificator: func(a *doorbot.Account, r doorbot.Repositories) notifications.Notificator {
+			return notifications.New(notifications.Config{Account: a, Repositories: r})
+		},
+		stop: make(chan struct{}),
+	}
+}
+
+// Start the scheduler
+func (s *Scheduler) Start() {
+	log.WithFields(log.Fields{
+		"interval": s.Config.Interval,
+		"hour":     s.Config.Hour,
+	}).Info("Digests::Scheduler->Start starting")
+
+	go s.work()
+}
+
+// Stop the scheduler
+func (s *Scheduler) Stop() {
+	close(s.stop)
+}
+
+func (s *Scheduler) work() {
+	ticker := time.NewTicker(s.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.Run(now)
+		}
+	}
+}
+
+// Run sends the digests due at the given time for every enabled account.
+func (s *Scheduler) Run(now time.Time) {
+	r := s.Repositories()
+
+	accounts, err := r.AccountRepository().All(r.DB())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"step":  "account-all",
+		}).Error("Digests::Scheduler->Run database error")
+
+		return
+	}
+
+	for _, account := range accounts {
+		if account.IsEnabled {
+			s.account(account, now)
+		}
+	}
+}
+
+// account sends the digests due to the managers of an account. Digests are built once per frequency.
+func (s *Scheduler) account(a *doorbot.Account, now time.Time) {
+	r := s.Repositories()
+	r.SetAccountScope(a.ID)
+
+	people, err := r.PersonRepository().All(r.DB())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": a.ID,
+			"step":       "person-all",
+		}).Error("Digests::Scheduler->account database error")
+
+		return
+	}
+
+	digests := map[string]*Digest{}
+	var notificator notifications.Notificator
+
+	for _, p := range people {
+		if !p.IsAccountManager() {
+			continue
+		}
+
+		due, ok := Due(p.DigestFrequency, s.Config.Hour, now)
+		if !ok || (p.DigestSentAt != nil && !p.DigestSentAt.Before(due)) {
+			continue
+		}
+
+		claimed, err := r.PersonRepository().ClaimDigest(r.DB(), p, due)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": a.ID,
+				"person_id":  p.ID,
+				"step":       "person-claim-digest",
+			}).Error("Digests::Scheduler->account database error")
+
+			continue
+		}
+
+		if !claimed {
+			continue
+		}
+
+		digest, ok := digests[p.DigestFrequency]
+		if !ok {
+			digest, err = Build(r, a, p.DigestFrequency, due, s.Config.OfflineAfter)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error":      err,
+					"account_id": a.ID,
+					"frequency":  p.DigestFrequency,
+					"step":       "digest-build",
+				}).Error("Digests::Scheduler->account database error")
+
+				return
+			}
+
+			digests[p.DigestFrequency] = digest
+		}
+
+		if notificator == nil {
+			notificator = s.Notificator(a, r)
+		}
+
+		subject, body := Render(digest, p)
+
+		err = notificator.Digest(p, subject, body)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": a.ID,
+				"person_id":  p.ID,
+			}).Error("Digests::Scheduler->account unable to send the digest")
+
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"account_id": a.ID,
+			"person_id":  p.ID,
+			"frequency":  p.DigestFrequency,
+		}).Info("Digests::Scheduler->account digest sent")
+	}
+}
+
+// Due returns when the most recent digest of the given frequency was scheduled, at or before `now`.
+// Daily digests are scheduled every day at `hour` UTC, weekly digests on mondays. Returns false when the digest is disabled.
+func Due(frequency string, hour int, now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	due := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+
+	if now.Before(due) {
+		due = due.AddDate(0, 0, -1)
+	}
+
+	switch frequency {
+	case doorbot.DigestDaily:
+		return due, true
+	case doorbot.DigestWeekly:
+		days := (int(due.Weekday()) - int(time.Monday) + 7) % 7
+		return due.AddDate(0, 0, -days), true
+	}
+
+	return time.Time{}, false
+}
+
+// Build gathers the lobby activity of the period ending at `until`.
+// Devices are offline when they were last seen more than `offlineAfter` before the end of the period.
+func Build(r doorbot.Repositories, a *doorbot.Account, frequency string, until time.Time, offlineAfter time.Duration) (*Digest, error) {
+	digest := &Digest{
+		Account:   a,
+		Frequency: frequency,
+		Since:     until.AddDate(0, 0, -1),
+		Until:     until,
+	}
+
+	filter := &doorbot.ReportFilter{
+		Interval: doorbot.ReportIntervalDay,
+		GroupBy:  doorbot.ReportGroupDoor,
+		Since:    digest.Since,
+		Until:    until,
+	}
+
+	if frequency == doorbot.DigestWeekly {
+		digest.Since = until.AddDate(0, 0, -7)
+		filter.Interval = doorbot.ReportIntervalWeek
+		filter.Since = digest.Since
+	}
+
+	doors, err := r.DoorRepository().All(r.DB())
+	if err != nil {
+		return nil, err
+	}
+
+	visits, err := r.ReportRepository().Visits(r.DB(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := r.ReportRepository().ResponseTimes(r.DB(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	activities := map[uint]*DoorActivity{}
+	for _, d := range doors {
+		activity := &DoorActivity{Door: d}
+		activities[d.ID] = activity
+		digest.Doors = append(digest.Doors, activity)
+	}
+
+	for _, v := range visits {
+		if activity, ok := activities[v.GroupID]; ok {
+			activity.Visits += v.Visits
+		}
+	}
+
+	for _, rt := range responses {
+		if activity, ok := activities[rt.GroupID]; ok {
+			activity.Unanswered += rt.Unanswered
+		}
+	}
+
+	devices, err := r.DeviceRepository().All(r.DB())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range devices {
+		if d.IsEnabled && d.LastSeenAt != nil && until.Sub(*d.LastSeenAt) > offlineAfter {
+			digest.OfflineDevices = append(digest.OfflineDevices, d)
+		}
+	}
+
+	return digest, nil
+}
+
+// Render returns the subject and body of the digest email sent to a person.
+func Render(d *Digest, p *doorbot.Person) (string, string) {
+	dbb := rendering.DoorbotBar()
+
+	var unanswered uint
+	doors := ""
+
+	for _, activity := range d.Doors {
+		unanswered += activity.Unanswered
+		doors += fmt.Sprintf(" - %s: %d visits, %d unanswered knock-knocks\n", activity.Door.Name, activity.Visits, activity.Unanswered)
+	}
+
+	if len(doors) == 0 {
+		doors = " - No doors\n"
+	}
+
+	devices := ""
+	for _, device := range d.OfflineDevices {
+		devices += fmt.Sprintf(" - %s, last seen %s\n", device.Name, device.LastSeenAt.UTC().Format("Jan 2 at 15:04 MST"))
+	}
+
+	if len(devices) == 0 {
+		devices = " - None\n"
+	}
+
+	renderingData := map[string]string{
+		"name":       p.Name,
+		"account":    d.Account.Name,
+		"frequency":  d.Frequency,
+		"since":      d.Since.UTC().Format("Monday, January 2 at 15:04 MST"),
+		"doors":      doors,
+		"unanswered": fmt.Sprintf("%d", unanswered),
+		"devices":    devices,
+	}
+
+	return dbb.Render(subjectTemplate, renderingData), dbb.Render(bodyTemplate, renderingData)
+}
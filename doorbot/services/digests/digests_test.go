@@ -0,0 +1,76 @@
+package digests
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDue(t *testing.T) {
+	// Thursday
+	now := time.Date(2015, 7, 23, 10, 30, 0, 0, time.UTC)
+
+	due, ok := Due(doorbot.DigestDaily, 8, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2015, 7, 23, 8, 0, 0, 0, time.UTC), due)
+
+	due, ok = Due(doorbot.DigestDaily, 12, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2015, 7, 22, 12, 0, 0, 0, time.UTC), due)
+
+	due, ok = Due(doorbot.DigestWeekly, 8, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2015, 7, 20, 8, 0, 0, 0, time.UTC), due)
+
+	// Monday, before the digest hour
+	due, ok = Due(doorbot.DigestWeekly, 8, time.Date(2015, 7, 20, 7, 0, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2015, 7, 13, 8, 0, 0, 0, time.UTC), due)
+
+	_, ok = Due(doorbot.DigestNever, 8, now)
+	assert.False(t, ok)
+
+	_, ok = Due("", 8, now)
+	assert.False(t, ok)
+}
+
+func TestRender(t *testing.T) {
+	seen := time.Date(2015, 7, 22, 14, 5, 0, 0, time.UTC)
+
+	d := &Digest{
+		Account:   &doorbot.Account{Name: "ACME"},
+		Frequency: doorbot.DigestDaily,
+		Since:     time.Date(2015, 7, 22, 8, 0, 0, 0, time.UTC),
+		Until:     time.Date(2015, 7, 23, 8, 0, 0, 0, time.UTC),
+		Doors: []*DoorActivity{
+			&DoorActivity{Door: &doorbot.Door{Name: "Lobby"}, Visits: 12, Unanswered: 2},
+			&DoorActivity{Door: &doorbot.Door{Name: "Loading dock"}, Visits: 3, Unanswered: 1},
+		},
+		OfflineDevices: []*doorbot.Device{
+			&doorbot.Device{Name: "Front iPad", LastSeenAt: &seen},
+		},
+	}
+
+	subject, body := Render(d, &doorbot.Person{Name: "Cookie Monster"})
+
+	assert.Equal(t, "Doorbot - ACME daily lobby activity", subject)
+	assert.True(t, strings.HasPrefix(body, "Hi Cookie Monster,\n"))
+	assert.Contains(t, body, "since Wednesday, July 22 at 08:00 UTC")
+	assert.Contains(t, body, " - Lobby: 12 visits, 2 unanswered knock-knocks\n - Loading dock: 3 visits, 1 unanswered knock-knocks\n")
+	assert.Contains(t, body, "Unanswered knock-knocks: 3\n")
+	assert.Contains(t, body, " - Front iPad, last seen Jul 22 at 14:05 UTC\n")
+}
+
+func TestRenderEmpty(t *testing.T) {
+	d := &Digest{
+		Account:   &doorbot.Account{Name: "ACME"},
+		Frequency: doorbot.DigestWeekly,
+	}
+
+	_, body := Render(d, &doorbot.Person{Name: "Cookie Monster"})
+
+	assert.Contains(t, body, "Visitors per door:\n - No doors\n")
+	assert.Contains(t, body, "Offline devices:\n - None\n")
+}
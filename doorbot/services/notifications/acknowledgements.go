@@ -0,0 +1,167 @@
+package notifications
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/security"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AcknowledgementTTL is how long the acknowledgement links sent in a notification stay valid.
+const AcknowledgementTTL = 24 * time.Hour
+
+// Replies holds the canned acknowledgement messages shown to the visitor.
+var Replies = map[string]string{
+	doorbot.AcknowledgementOnMyWay:     "I'm on my way.",
+	doorbot.AcknowledgementPleaseWait:  "I'll be there shortly, please have a seat.",
+	doorbot.AcknowledgementUnavailable: "Sorry, I can't come to the door right now.",
+}
+
+// Links builds and verifies the signed links sent in notifications.
+// Links are disabled when no secret is configured.
+type Links struct {
+	Secret string
+	// Base domain of the user accounts ex: doorbot.com
+	Domain string
+}
+
+// NewLinks creates a Links instance from the server configuration
+func NewLinks(c *doorbot.DoorbotConfig) Links {
+	return Links{
+		Secret: c.Secret,
+		Domain: strings.TrimPrefix(c.UserAccountsDomain, "."),
+	}
+}
+
+func acknowledgementPayload(accountID uint, notificationID uint, channel string, expires int64) string {
+	return fmt.Sprintf("acknowledgement:%d:%d:%s:%d", accountID, notificationID, channel, expires)
+}
+
+// Acknowledgements returns the one-click acknowledgement links of a notification for a channel kind, keyed by reply.
+func (l Links) Acknowledgements(a *doorbot.Account, n *doorbot.Notification, channel string) map[string]string {
+	links := map[string]string{}
+
+	if len(l.Secret) == 0 || n == nil || n.ID == 0 {
+		return links
+	}
+
+	expires := time.Now().Add(AcknowledgementTTL).Unix()
+	signature := security.Sign(l.Secret, acknowledgementPayload(a.ID, n.ID, channel, expires))
+
+	for reply := range Replies {
+		values := url.Values{}
+		values.Set("channel", channel)
+		values.Set("expires", strconv.FormatInt(expires, 10))
+		values.Set("reply", reply)
+		values.Set("signature", signature)
+
+		links[reply] = fmt.Sprintf("https://%s.%s/api/acknowledgements/%d?%s", a.Host, l.Domain, n.ID, values.Encode())
+	}
+
+	return links
+}
+
+// Token returns a signed token identifying a notification, used by interactive chat messages.
+func (l Links) Token(a *doorbot.Account, n *doorbot.Notification, channel string) string {
+	if len(l.Secret) == 0 || n == nil || n.ID == 0 {
+		return ""
+	}
+
+	expires := time.Now().Add(AcknowledgementTTL).Unix()
+	signature := security.Sign(l.Secret, acknowledgementPayload(a.ID, n.ID, channel, expires))
+
+	return fmt.Sprintf("%d:%s:%d:%s", n.ID, channel, expires, signature)
+}
+
+// ParseToken verifies a token generated by Token and returns the notification id and channel it was issued for.
+func (l Links) ParseToken(a *doorbot.Account, token string) (uint, string, bool) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 4 {
+		return 0, "", false
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, "", false
+	}
+
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+
+	if !l.Verify(a, uint(id), parts[1], expires, parts[3]) {
+		return 0, "", false
+	}
+
+	return uint(id), parts[1], true
+}
+
+// Verify checks the signature and expiration of an acknowledgement link.
+func (l Links) Verify(a *doorbot.Account, notificationID uint, channel string, expires int64, signature string) bool {
+	if len(l.Secret) == 0 || time.Now().Unix() > expires {
+		return false
+	}
+
+	return security.VerifySignature(l.Secret, acknowledgementPayload(a.ID, notificationID, channel, expires), signature)
+}
+
+// Acknowledge records the answer of a person to a knock-knock and adds the matching event.
+// Returns false when the notification was already acknowledged.
+func Acknowledge(r doorbot.Repositories, n *doorbot.Notification, channel string, reply string, message string, eta uint) (bool, error) {
+	if len(message) == 0 {
+		message = Replies[reply]
+	}
+
+	n.AcknowledgedVia = channel
+	n.AcknowledgementReply = reply
+	n.AcknowledgementMessage = message
+	n.AcknowledgementETA = eta
+
+	acknowledged, err := r.NotificationRepository().Acknowledge(r.DB(), n)
+	if err != nil || !acknowledged {
+		return acknowledged, err
+	}
+
+	event := doorbot.NewEvent(n.AccountID, 0, n.DoorID, acknowledgementEvent(channel), n.PersonID)
+	event.ActorType = doorbot.EventActorPerson
+	event.ActorID = n.PersonID
+	event.Metadata = doorbot.Metadata{"notification_id": n.ID, "reply": reply}
+
+	err = r.EventRepository().Create(r.DB(), event)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      n.AccountID,
+			"notification_id": n.ID,
+			"step":            "event-create",
+		}).Error("Notificator::Acknowledge database error")
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":      n.AccountID,
+		"notification_id": n.ID,
+		"channel":         channel,
+		"reply":           reply,
+	}).Info("Notificator::Acknowledge notification acknowledged")
+
+	return true, nil
+}
+
+// acknowledgementEvent returns the event code matching the channel kind an acknowledgement came from.
+func acknowledgementEvent(channel string) uint {
+	switch channel {
+	case doorbot.NotificationChannelSMS:
+		return doorbot.EventNotificationSMSAcknowledged
+	case doorbot.NotificationChannelEmail:
+		return doorbot.EventNotificationEmailAcknowledged
+	case doorbot.NotificationChannelApp:
+		return doorbot.EventNotificationAppAcknowledged
+	}
+
+	return doorbot.EventNotificationAcknowledged
+}
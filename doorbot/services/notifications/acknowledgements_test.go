@@ -0,0 +1,74 @@
+package notifications
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/security"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcknowledgementLinks(t *testing.T) {
+	l := Links{Secret: "secret", Domain: "doorbot.com"}
+	a := &doorbot.Account{ID: 3, Host: "acme"}
+	n := &doorbot.Notification{ID: 12}
+
+	links := l.Acknowledgements(a, n, doorbot.NotificationChannelSMS)
+	assert.Len(t, links, len(Replies))
+
+	link := links[doorbot.AcknowledgementOnMyWay]
+	assert.True(t, strings.HasPrefix(link, "https://acme.doorbot.com/api/acknowledgements/12?"))
+
+	u, err := url.Parse(link)
+	assert.NoError(t, err)
+
+	q := u.Query()
+	expires, _ := strconv.ParseInt(q.Get("expires"), 10, 64)
+
+	assert.Equal(t, doorbot.AcknowledgementOnMyWay, q.Get("reply"))
+	assert.True(t, l.Verify(a, 12, doorbot.NotificationChannelSMS, expires, q.Get("signature")))
+	assert.False(t, l.Verify(a, 13, doorbot.NotificationChannelSMS, expires, q.Get("signature")))
+	assert.False(t, l.Verify(a, 12, doorbot.NotificationChannelEmail, expires, q.Get("signature")))
+	assert.False(t, l.Verify(&doorbot.Account{ID: 4}, 12, doorbot.NotificationChannelSMS, expires, q.Get("signature")))
+}
+
+func TestAcknowledgementLinksExpired(t *testing.T) {
+	l := Links{Secret: "secret", Domain: "doorbot.com"}
+	a := &doorbot.Account{ID: 3, Host: "acme"}
+
+	expires := time.Now().Add(-time.Minute).Unix()
+	signature := security.Sign("secret", acknowledgementPayload(3, 12, doorbot.NotificationChannelSMS, expires))
+
+	assert.False(t, l.Verify(a, 12, doorbot.NotificationChannelSMS, expires, signature))
+}
+
+func TestAcknowledgementLinksDisabled(t *testing.T) {
+	l := Links{Domain: "doorbot.com"}
+	a := &doorbot.Account{ID: 3, Host: "acme"}
+	n := &doorbot.Notification{ID: 12}
+
+	assert.Empty(t, l.Acknowledgements(a, n, doorbot.NotificationChannelSMS))
+	assert.Empty(t, l.Token(a, n, doorbot.NotificationChannelChat))
+	assert.False(t, l.Verify(a, 12, doorbot.NotificationChannelSMS, time.Now().Add(time.Hour).Unix(), ""))
+}
+
+func TestToken(t *testing.T) {
+	l := Links{Secret: "secret"}
+	a := &doorbot.Account{ID: 3}
+
+	token := l.Token(a, &doorbot.Notification{ID: 12}, doorbot.NotificationChannelChat)
+
+	id, channel, ok := l.ParseToken(a, token)
+	assert.True(t, ok)
+	assert.Equal(t, uint(12), id)
+	assert.Equal(t, doorbot.NotificationChannelChat, channel)
+
+	_, _, ok = l.ParseToken(&doorbot.Account{ID: 4}, token)
+	assert.False(t, ok)
+
+	_, _, ok = l.ParseToken(a, "12:chat:1:abc")
+	assert.False(t, ok)
+}
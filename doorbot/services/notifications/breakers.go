@@ -0,0 +1,305 @@
+package notifications
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/hipchat"
+	"github.com/masom/doorbot/doorbot/services/notifications/mailgun"
+	"github.com/masom/doorbot/doorbot/services/notifications/nexmo"
+	"github.com/masom/doorbot/doorbot/services/notifications/postmark"
+	"github.com/masom/doorbot/doorbot/services/notifications/slack"
+	"github.com/masom/doorbot/doorbot/services/notifications/smtp"
+	"github.com/masom/doorbot/doorbot/services/notifications/twilio"
+	"net"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// CircuitClosed the provider is healthy and used
+	CircuitClosed = "closed"
+	// CircuitOpen the provider failed too many times in a row and is skipped until the cooldown ends
+	CircuitOpen = "open"
+	// CircuitHalfOpen the cooldown ended, a single knock-knock is sent to check if the provider recovered
+	CircuitHalfOpen = "half_open"
+)
+
+// Breakers tracks the health of the notification providers of this server.
+var Breakers = NewBreakers(5, 30*time.Second)
+
+// ProviderHealth reports the health of a notification provider as seen by this server.
+// Rejected counts the messages refused by a working provider, ex: an unknown recipient. They do not open the circuit.
+// Latency is a moving average of the provider response times, in milliseconds.
+type ProviderHealth struct {
+	Name                string     `json:"name"`
+	Channel             string     `json:"channel"`
+	State               string     `json:"state"`
+	ConsecutiveFailures uint       `json:"consecutive_failures"`
+	Successes           uint       `json:"successes"`
+	Rejected            uint       `json:"rejected"`
+	Failures            uint       `json:"failures"`
+	Latency             int64      `json:"latency_ms"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// CircuitBreakers holds a circuit breaker per provider, and per account for the accounts using their own credentials.
+// A circuit opens after `threshold` consecutive failures and lets a knock-knock through again after `cooldown`.
+type CircuitBreakers struct {
+	mutex     sync.Mutex
+	threshold uint
+	cooldown  time.Duration
+	providers map[string]*breaker
+}
+
+type breaker struct {
+	health ProviderHealth
+	// Set when the breaker tracks the provider for a single account
+	accountID uint
+	// Set while the half-open circuit waits for the result of its trial knock-knock
+	probing bool
+	// When the trial knock-knock was let through. A trial never recorded, ex: after a panic, expires after the cooldown.
+	probedAt time.Time
+	latency time.Duration
+}
+
+// NewBreakers creates the circuit breakers of the providers
+func NewBreakers(threshold uint, cooldown time.Duration) *CircuitBreakers {
+	return &CircuitBreakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		providers: map[string]*breaker{},
+	}
+}
+
+// Configure replaces the failure threshold and the cooldown. Zero values are ignored.
+func (b *CircuitBreakers) Configure(threshold uint, cooldown time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if threshold > 0 {
+		b.threshold = threshold
+	}
+
+	if cooldown > 0 {
+		b.cooldown = cooldown
+	}
+}
+
+// Allow tells if a knock-knock can be sent through the notifier.
+// Notifiers that are not shared providers, ex: webhooks and push devices, are always allowed.
+func (b *CircuitBreakers) Allow(n Notifier, now time.Time) bool {
+	if !guarded(n) {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := b.get(n)
+
+	if b.blocked(c, now) {
+		return false
+	}
+
+	if c.health.State != CircuitClosed {
+		c.health.State = CircuitHalfOpen
+		c.probing = true
+		c.probedAt = now
+	}
+
+	return true
+}
+
+// AllOpen tells if every guarded notifier is blocked by an open circuit.
+// Trying a failing provider beats not trying at all, callers send the knock-knock anyway when it happens.
+func (b *CircuitBreakers) AllOpen(channels []Notifier, now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, n := range channels {
+		if !guarded(n) {
+			return false
+		}
+
+		if c, ok := b.providers[key(n)]; !ok || !b.blocked(c, now) {
+			return false
+		}
+	}
+
+	return len(channels) > 0
+}
+
+// blocked tells if a circuit refuses knock-knocks: open and cooling down, or half-open with a trial in flight
+// for less than the cooldown. The mutex must be held.
+func (b *CircuitBreakers) blocked(c *breaker, now time.Time) bool {
+	switch c.health.State {
+	case CircuitOpen:
+		return now.Before(c.health.OpenedAt.Add(b.cooldown))
+	case CircuitHalfOpen:
+		return c.probing && now.Before(c.probedAt.Add(b.cooldown))
+	}
+
+	return false
+}
+
+// Record the result of a knock-knock sent through the notifier and how long the provider took to answer.
+// Only provider failures count against the circuit: a message rejected for its recipient or the account credentials
+// shows the provider is up.
+func (b *CircuitBreakers) Record(n Notifier, err error, latency time.Duration, now time.Time) {
+	if !guarded(n) {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := b.get(n)
+	c.probing = false
+
+	// Exponential moving average, the first measure is taken as is.
+	if c.latency == 0 {
+		c.latency = latency
+	} else {
+		c.latency = (4*c.latency + latency) / 5
+	}
+
+	c.health.Latency = int64(c.latency / time.Millisecond)
+
+	if err == nil {
+		c.health.Successes++
+	} else if !failing(err) {
+		c.health.Rejected++
+	}
+
+	if err == nil || !failing(err) {
+		c.health.ConsecutiveFailures = 0
+		c.health.State = CircuitClosed
+		c.health.OpenedAt = nil
+		return
+	}
+
+	c.health.Failures++
+	c.health.ConsecutiveFailures++
+	c.health.LastFailureAt = &now
+
+	if c.health.State == CircuitHalfOpen || (c.health.State == CircuitClosed && c.health.ConsecutiveFailures >= b.threshold) {
+		c.health.State = CircuitOpen
+		c.health.OpenedAt = &now
+
+		log.WithFields(log.Fields{
+			"provider":             c.health.Name,
+			"account_id":           c.accountID,
+			"consecutive_failures": c.health.ConsecutiveFailures,
+			"cooldown":             b.cooldown,
+		}).Warn("Notificator::Breakers circuit opened")
+	}
+}
+
+// Health returns the health of every provider used with the platform credentials since the server started, sorted by name.
+// The breakers of the accounts using their own credentials are left out.
+func (b *CircuitBreakers) Health() []*ProviderHealth {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	health := make([]*ProviderHealth, 0, len(b.providers))
+
+	for _, c := range b.providers {
+		if c.accountID > 0 {
+			continue
+		}
+
+		h := c.health
+		health = append(health, &h)
+	}
+
+	sort.Sort(byName(health))
+
+	return health
+}
+
+// get returns the breaker of a notifier, creating it closed. The mutex must be held.
+func (b *CircuitBreakers) get(n Notifier) *breaker {
+	k := key(n)
+
+	c, ok := b.providers[k]
+	if !ok {
+		c = &breaker{health: ProviderHealth{Name: n.Name(), Channel: kindOf(n), State: CircuitClosed}}
+
+		if a := accountOf(n); ownCredentials(a, n) {
+			c.accountID = a.ID
+		}
+
+		b.providers[k] = c
+	}
+
+	return c
+}
+
+// guarded tells if a notifier goes through a provider shared by every account, one that can fail over to another provider.
+// Incoming webhooks, person webhooks and push devices deliver to their own destination and are not tracked.
+func guarded(n Notifier) bool {
+	switch n.(type) {
+	case *hipchat.HipChat, *slack.Slack, *nexmo.Nexmo, *twilio.Twilio, *mailgun.Mailgun, *postmark.Postmark, *smtp.SMTP:
+		return true
+	}
+
+	return false
+}
+
+// key identifies the breaker of a notifier: the provider, scoped to the account when it uses its own credentials.
+// A revoked account token must not take the provider down for every account.
+func key(n Notifier) string {
+	if a := accountOf(n); ownCredentials(a, n) {
+		return fmt.Sprintf("%s:%d", n.Name(), a.ID)
+	}
+
+	return n.Name()
+}
+
+// accountOf returns the account a guarded notifier sends for.
+func accountOf(n Notifier) *doorbot.Account {
+	switch p := n.(type) {
+	case *hipchat.HipChat:
+		return p.Account
+	case *slack.Slack:
+		return p.Account
+	case *nexmo.Nexmo:
+		return p.Account
+	case *twilio.Twilio:
+		return p.Account
+	}
+
+	return nil
+}
+
+// temporary is implemented by the provider errors able to tell an outage from a rejected message.
+type temporary interface {
+	Temporary() bool
+}
+
+// failing tells if an error means the provider itself is failing: a transport error, a timeout,
+// a server error or a rate limit. Errors specific to a message, a person or the account credentials are not.
+func failing(err error) bool {
+	switch e := err.(type) {
+	case *url.Error, net.Error:
+		return true
+	case *textproto.Error:
+		// The SMTP relay is shutting down or overloaded, other codes are about the message.
+		return e.Code == 421
+	case temporary:
+		return e.Temporary()
+	}
+
+	return false
+}
+
+type byName []*ProviderHealth
+
+func (h byName) Len() int           { return len(h) }
+func (h byName) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h byName) Less(i, j int) bool { return h[i].Name < h[j].Name }
@@ -0,0 +1,148 @@
+package notifications
+
+import (
+	"errors"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/mattermost"
+	"github.com/masom/doorbot/doorbot/services/notifications/nexmo"
+	"github.com/masom/doorbot/doorbot/services/notifications/slack"
+	"github.com/masom/doorbot/doorbot/services/notifications/twilio"
+	"github.com/stretchr/testify/assert"
+	"net/textproto"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBreakers(t *testing.T) {
+	b := NewBreakers(2, time.Minute)
+	primary := &twilio.Twilio{Account: &doorbot.Account{ID: 1}}
+	failure := &twilio.Error{Status: 503, Message: "Service Unavailable"}
+	now := time.Now()
+
+	assert.True(t, b.Allow(primary, now))
+	b.Record(primary, failure, 100*time.Millisecond, now)
+	assert.True(t, b.Allow(primary, now))
+	b.Record(primary, failure, 200*time.Millisecond, now)
+
+	// Open until the cooldown ends.
+	assert.False(t, b.Allow(primary, now.Add(30*time.Second)))
+
+	health := b.Health()
+	assert.Len(t, health, 1)
+	assert.Equal(t, "Twilio", health[0].Name)
+	assert.Equal(t, CircuitOpen, health[0].State)
+	assert.Equal(t, uint(2), health[0].ConsecutiveFailures)
+	assert.Equal(t, int64(120), health[0].Latency)
+
+	// A single trial goes through once the cooldown ended.
+	later := now.Add(2 * time.Minute)
+	assert.True(t, b.Allow(primary, later))
+	assert.False(t, b.Allow(primary, later))
+	assert.Equal(t, CircuitHalfOpen, b.Health()[0].State)
+
+	// A failed trial opens the circuit again.
+	b.Record(primary, failure, 100*time.Millisecond, later)
+	assert.Equal(t, CircuitOpen, b.Health()[0].State)
+	assert.False(t, b.Allow(primary, later.Add(30*time.Second)))
+
+	// A successful trial closes it.
+	latest := later.Add(2 * time.Minute)
+	assert.True(t, b.Allow(primary, latest))
+	b.Record(primary, nil, 100*time.Millisecond, latest)
+
+	health = b.Health()
+	assert.Equal(t, CircuitClosed, health[0].State)
+	assert.Equal(t, uint(0), health[0].ConsecutiveFailures)
+	assert.Equal(t, uint(3), health[0].Failures)
+	assert.Equal(t, uint(1), health[0].Successes)
+	assert.Nil(t, health[0].OpenedAt)
+}
+
+func TestBreakersLostTrial(t *testing.T) {
+	b := NewBreakers(1, time.Minute)
+	primary := &twilio.Twilio{Account: &doorbot.Account{ID: 1}}
+	now := time.Now()
+
+	b.Record(primary, &twilio.Error{Status: 503, Message: "Service Unavailable"}, time.Millisecond, now)
+
+	// The trial is never recorded, ex: the delivery panicked.
+	later := now.Add(2 * time.Minute)
+	assert.True(t, b.Allow(primary, later))
+	assert.False(t, b.Allow(primary, later.Add(30*time.Second)))
+	assert.False(t, b.AllOpen([]Notifier{primary}, later.Add(2*time.Minute)))
+
+	// Another trial goes through once the cooldown of the lost one ended.
+	assert.True(t, b.Allow(primary, later.Add(2*time.Minute)))
+	assert.Equal(t, CircuitHalfOpen, b.Health()[0].State)
+}
+
+func TestBreakersAllOpen(t *testing.T) {
+	b := NewBreakers(1, time.Minute)
+	now := time.Now()
+
+	primary := &twilio.Twilio{Account: &doorbot.Account{ID: 1}}
+	fallback := &nexmo.Nexmo{Account: &doorbot.Account{ID: 1}}
+	destination := &mattermost.Mattermost{}
+
+	b.Record(primary, &url.Error{Op: "Post", URL: "https://api.twilio.com", Err: errors.New("timeout")}, time.Millisecond, now)
+
+	assert.False(t, b.Allow(primary, now))
+	assert.True(t, b.Allow(fallback, now))
+	assert.False(t, b.AllOpen([]Notifier{fallback, primary}, now))
+	assert.True(t, b.AllOpen([]Notifier{primary}, now))
+
+	// Per-destination notifiers are never tracked.
+	b.Record(destination, errors.New("timeout"), time.Millisecond, now)
+	assert.True(t, b.Allow(destination, now))
+	assert.False(t, b.AllOpen([]Notifier{primary, destination}, now))
+	assert.Len(t, b.Health(), 2)
+}
+
+func TestBreakersRejected(t *testing.T) {
+	b := NewBreakers(1, time.Minute)
+	now := time.Now()
+
+	provider := &nexmo.Nexmo{Account: &doorbot.Account{ID: 1}}
+
+	// An invalid number is about the person, Nexmo is up.
+	b.Record(provider, &nexmo.Error{Status: "3", Text: "Invalid to number"}, time.Millisecond, now)
+	b.Record(provider, errors.New("slack: user not found"), time.Millisecond, now)
+
+	assert.True(t, b.Allow(provider, now))
+
+	health := b.Health()
+	assert.Equal(t, CircuitClosed, health[0].State)
+	assert.Equal(t, uint(2), health[0].Rejected)
+	assert.Equal(t, uint(0), health[0].Failures)
+}
+
+func TestBreakersAccountCredentials(t *testing.T) {
+	b := NewBreakers(1, time.Minute)
+	now := time.Now()
+
+	own := &slack.Slack{Account: &doorbot.Account{ID: 1, NotificationsSlackToken: "xoxb-1"}}
+	platform := &slack.Slack{Account: &doorbot.Account{ID: 2}}
+
+	b.Record(own, &slack.Error{Method: "chat.postMessage", StatusCode: 503}, time.Millisecond, now)
+
+	assert.False(t, b.Allow(own, now))
+	assert.True(t, b.Allow(platform, now))
+
+	// Only the platform breakers are reported.
+	health := b.Health()
+	assert.Len(t, health, 1)
+	assert.Equal(t, CircuitClosed, health[0].State)
+}
+
+func TestFailing(t *testing.T) {
+	assert.True(t, failing(&url.Error{Op: "Post", URL: "https://rest.nexmo.com", Err: errors.New("connection refused")}))
+	assert.True(t, failing(&textproto.Error{Code: 421, Msg: "Service not available"}))
+	assert.True(t, failing(&slack.Error{Method: "im.open", Code: "ratelimited"}))
+	assert.True(t, failing(&twilio.Error{Status: 429}))
+
+	assert.False(t, failing(&textproto.Error{Code: 550, Msg: "Mailbox unavailable"}))
+	assert.False(t, failing(&slack.Error{Method: "users.lookupByEmail", Code: "users_not_found"}))
+	assert.False(t, failing(&twilio.Error{Status: 400, Code: 21211}))
+	assert.False(t, failing(errors.New("nexmo: no messages in the response")))
+}
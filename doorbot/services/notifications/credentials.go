@@ -0,0 +1,113 @@
+package notifications
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/hipchat"
+	"github.com/masom/doorbot/doorbot/services/notifications/mailgun"
+	"github.com/masom/doorbot/doorbot/services/notifications/nexmo"
+	"github.com/masom/doorbot/doorbot/services/notifications/postmark"
+	"github.com/masom/doorbot/doorbot/services/notifications/push"
+	"github.com/masom/doorbot/doorbot/services/notifications/slack"
+	"github.com/masom/doorbot/doorbot/services/notifications/twilio"
+	"strings"
+)
+
+// NewConfig builds the notificator configuration of an account from the server configuration.
+// Providers use the platform credentials unless the account has its own:
+//
+//	HipChat   notifications_hipchat_token
+//	Nexmo     notifications_nexmo_token, as "api_key:api_secret"
+//	Slack     notifications_slack_token
+//	Twilio    notifications_twilio_token, as "account_sid:auth_token"
+//
+// The notifications_twilio_source_phone_number of an account is used with either credentials.
+func NewConfig(a *doorbot.Account, r doorbot.Repositories, c *doorbot.DoorbotConfig) Config {
+	config := Config{
+		Account:      a,
+		Repositories: r,
+	}
+
+	if c == nil {
+		return config
+	}
+
+	p := c.Providers
+
+	config.Links = NewLinks(c)
+	config.SMTP = c.SMTP
+	config.HipChat = hipchat.Config{Token: p.HipChatToken}
+	config.Mailgun = mailgun.Config{APIKey: p.MailgunAPIKey, Domain: p.MailgunDomain, From: p.EmailFrom}
+	config.Nexmo = nexmo.Config{APIKey: p.NexmoAPIKey, APISecret: p.NexmoAPISecret, From: p.NexmoFrom}
+	config.Postmark = postmark.Config{Token: p.PostmarkToken, From: p.EmailFrom}
+	config.Push = push.Config{APNsToken: p.APNsToken, APNsTopic: p.APNsTopic, FCMKey: p.FCMKey}
+	config.Slack = slack.Config{Token: p.SlackToken}
+	config.Twilio = twilio.Config{AccountSID: p.TwilioAccountSID, Token: p.TwilioToken, PhoneNumber: p.TwilioPhoneNumber}
+
+	if len(a.NotificationsHipChatToken) > 0 {
+		config.HipChat.Token = a.NotificationsHipChatToken
+	}
+
+	if key, secret, ok := nexmoToken(a); ok {
+		config.Nexmo.APIKey = key
+		config.Nexmo.APISecret = secret
+	}
+
+	if len(a.NotificationsSlackToken) > 0 {
+		config.Slack.Token = a.NotificationsSlackToken
+	}
+
+	if sid, token, ok := twilioToken(a); ok {
+		config.Twilio.AccountSID = sid
+		config.Twilio.Token = token
+	}
+
+	if a.NotificationsTwilioSourcePhoneNumber != nil && len(*a.NotificationsTwilioSourcePhoneNumber) > 0 {
+		config.Twilio.PhoneNumber = *a.NotificationsTwilioSourcePhoneNumber
+	}
+
+	return config
+}
+
+// nexmoToken splits the Nexmo credentials of an account, ok is false when the account has none.
+func nexmoToken(a *doorbot.Account) (string, string, bool) {
+	parts := strings.SplitN(a.NotificationsNexmoToken, ":", 2)
+	if len(parts) == 2 && len(parts[0]) > 0 && len(parts[1]) > 0 {
+		return parts[0], parts[1], true
+	}
+
+	return "", "", false
+}
+
+// twilioToken splits the Twilio credentials of an account, ok is false when the account has none.
+func twilioToken(a *doorbot.Account) (string, string, bool) {
+	parts := strings.SplitN(a.NotificationsTwilioToken, ":", 2)
+	if len(parts) == 2 && len(parts[0]) > 0 && len(parts[1]) > 0 {
+		return parts[0], parts[1], true
+	}
+
+	return "", "", false
+}
+
+// ownCredentials tells if the account reaches the provider of a notifier with its own credentials
+// rather than the platform ones.
+func ownCredentials(a *doorbot.Account, n Notifier) bool {
+	if a == nil {
+		return false
+	}
+
+	switch n.(type) {
+	case *hipchat.HipChat:
+		return len(a.NotificationsHipChatToken) > 0
+	case *nexmo.Nexmo:
+		_, _, ok := nexmoToken(a)
+		return ok
+	case *slack.Slack:
+		return len(a.NotificationsSlackToken) > 0
+	case *twilio.Twilio:
+		// A source number alone is a number of the platform Twilio account.
+		_, _, ok := twilioToken(a)
+		return ok
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package notifications
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/twilio"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewConfig(t *testing.T) {
+	c := &doorbot.DoorbotConfig{
+		Secret:             "secret",
+		UserAccountsDomain: ".doorbot.co",
+		SMTP:               doorbot.SMTPConfig{Host: "mail.example.com"},
+		Providers: doorbot.ProvidersConfig{
+			HipChatToken:      "hipchat-platform",
+			NexmoAPIKey:       "nexmo-key",
+			NexmoAPISecret:    "nexmo-secret",
+			PostmarkToken:     "postmark-platform",
+			SlackToken:        "xoxb-platform",
+			TwilioAccountSID:  "AC123",
+			TwilioToken:       "twilio-platform",
+			TwilioPhoneNumber: "+15550000000",
+			EmailFrom:         "martin@doorbot.co",
+		},
+	}
+
+	config := NewConfig(&doorbot.Account{}, nil, c)
+
+	assert.Equal(t, "hipchat-platform", config.HipChat.Token)
+	assert.Equal(t, "nexmo-key", config.Nexmo.APIKey)
+	assert.Equal(t, "nexmo-secret", config.Nexmo.APISecret)
+	assert.Equal(t, "postmark-platform", config.Postmark.Token)
+	assert.Equal(t, "martin@doorbot.co", config.Postmark.From)
+	assert.Equal(t, "xoxb-platform", config.Slack.Token)
+	assert.Equal(t, "AC123", config.Twilio.AccountSID)
+	assert.Equal(t, "twilio-platform", config.Twilio.Token)
+	assert.Equal(t, "+15550000000", config.Twilio.PhoneNumber)
+	assert.Equal(t, "mail.example.com", config.SMTP.Host)
+	assert.Equal(t, "doorbot.co", config.Links.Domain)
+
+	phone := "+15551111111"
+	account := &doorbot.Account{
+		NotificationsHipChatToken:            "hipchat-account",
+		NotificationsNexmoToken:              "account-key:account-secret",
+		NotificationsSlackToken:              "xoxb-account",
+		NotificationsTwilioSourcePhoneNumber: &phone,
+	}
+
+	config = NewConfig(account, nil, c)
+
+	assert.Equal(t, "hipchat-account", config.HipChat.Token)
+	assert.Equal(t, "account-key", config.Nexmo.APIKey)
+	assert.Equal(t, "account-secret", config.Nexmo.APISecret)
+	assert.Equal(t, "xoxb-account", config.Slack.Token)
+	assert.Equal(t, "AC123", config.Twilio.AccountSID)
+	assert.Equal(t, "twilio-platform", config.Twilio.Token)
+	assert.Equal(t, "+15551111111", config.Twilio.PhoneNumber)
+
+	account.NotificationsTwilioToken = "AC456:twilio-account"
+
+	config = NewConfig(account, nil, c)
+
+	assert.Equal(t, "AC456", config.Twilio.AccountSID)
+	assert.Equal(t, "twilio-account", config.Twilio.Token)
+	assert.Equal(t, "+15551111111", config.Twilio.PhoneNumber)
+}
+
+func TestNewConfigInvalidNexmoToken(t *testing.T) {
+	c := &doorbot.DoorbotConfig{Providers: doorbot.ProvidersConfig{NexmoAPIKey: "nexmo-key", NexmoAPISecret: "nexmo-secret"}}
+
+	config := NewConfig(&doorbot.Account{NotificationsNexmoToken: "account-key"}, nil, c)
+
+	assert.Equal(t, "nexmo-key", config.Nexmo.APIKey)
+	assert.Equal(t, "nexmo-secret", config.Nexmo.APISecret)
+}
+
+func TestOwnCredentialsTwilio(t *testing.T) {
+	phone := "+15551111111"
+
+	// A source number alone goes through the platform Twilio account.
+	account := &doorbot.Account{ID: 1, NotificationsTwilioSourcePhoneNumber: &phone}
+	assert.False(t, ownCredentials(account, &twilio.Twilio{Account: account}))
+
+	account.NotificationsTwilioToken = "AC456"
+	assert.False(t, ownCredentials(account, &twilio.Twilio{Account: account}))
+
+	account.NotificationsTwilioToken = "AC456:twilio-account"
+	assert.True(t, ownCredentials(account, &twilio.Twilio{Account: account}))
+}
@@ -1,9 +1,10 @@
 package hipchat
 
 import (
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/masom/doorbot/doorbot"
-	"github.com/masom/doorbot/doorbot/services/rendering"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
 	"github.com/tbruyelle/hipchat-go/hipchat"
 )
 
@@ -13,6 +14,20 @@ type Config struct {
 }
 
 
+// Error is returned when the HipChat API answers with an unexpected status.
+type Error struct {
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("hipchat: unexpected status code %d", e.StatusCode)
+}
+
+// Temporary tells if HipChat itself failed, rather than rejecting the message.
+func (e *Error) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == 429
+}
+
 // HipChat notifier
 type HipChat struct {
 	Token string
@@ -22,7 +37,8 @@ type HipChat struct {
 // New creates a HipChat instance
 func New(a *doorbot.Account, c Config) *HipChat {
 	return &HipChat{
-		Token: c.Token,
+		Account: a,
+		Token:   c.Token,
 	}
 }
 
@@ -32,7 +48,7 @@ func (h *HipChat) Name() string {
 }
 
 // KnockKnock sends a private message to a user.
-func (h *HipChat) KnockKnock(d *doorbot.Door, p *doorbot.Person) error {
+func (h *HipChat) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
 
 	log.WithFields(log.Fields{
 		"account_id": h.Account.ID,
@@ -43,22 +59,26 @@ func (h *HipChat) KnockKnock(d *doorbot.Door, p *doorbot.Person) error {
 
 	c := hipchat.NewClient(h.Token)
 
-	dbb := rendering.DoorbotBar()
+	message := templates.Render(templates.For(h.Account, k, doorbot.NotificationChannelChat), templates.Data(h.Account, d, p, k)).Text
 
-	renderingData := map[string]string{
-		"name": p.Name,
-		"door": d.Name,
+	if link, ok := k.Links[doorbot.AcknowledgementOnMyWay]; ok {
+		message += "\nLet them know you are on your way: " + link
 	}
 
 	messageRequest := &hipchat.MessageRequest{
-		Message: dbb.Render("Hi {{name}},\nThere is someone waiting at the {{door}}.\n\n - Doorbot", renderingData),
+		Message: message + "\n\n - Doorbot",
 		Notify: true,
 	}
 
+	// The client returns an error along with the response on unexpected statuses.
 	response, err := c.User.Message(p.Email, messageRequest)
-	if response.StatusCode != 200 {
-		return err
+	if response != nil && response.StatusCode != 200 && response.StatusCode != 204 {
+		return "", &Error{StatusCode: response.StatusCode}
+	}
+
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return "", nil
 }
@@ -0,0 +1,189 @@
+package notifications
+
+import (
+	"errors"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/auth"
+	"github.com/masom/doorbot/doorbot/services/availability"
+	"github.com/masom/doorbot/doorbot/services/throttle"
+	"time"
+)
+
+var (
+	// ErrUnavailable is returned by Knock when the person is away and nobody takes their knock-knocks.
+	ErrUnavailable = errors.New("the person is not available")
+	// ErrRateLimited is returned by Knock when the device is over its knock-knock rate limit.
+	ErrRateLimited = errors.New("too many knock-knocks from the device")
+)
+
+// Knocked is the outcome of a knock-knock
+type Knocked struct {
+	// Notification queued, or the one already sent when Duplicate is set.
+	Notification *doorbot.Notification
+	// Availability of the person knocked
+	Status *availability.Status
+	// Delegate or reception taking the knock-knock when the person is away
+	Delegate *doorbot.Person
+	// Duplicate is set when the knock-knock was already sent within the account dedup window.
+	Duplicate bool
+	// Ago describes how long ago the duplicate was sent, ex: "20s"
+	Ago string
+	// RetryAfter is how long the device has to wait when it is rate limited.
+	RetryAfter time.Duration
+}
+
+// Knock knocks a person from a door. The knock-knock goes to a delegate or the reception when the person is away.
+// Knock-knocks repeated within the account dedup window are not sent again and devices are rate limited.
+// The visit is optional. ErrUnavailable, ErrRateLimited and ErrNoChannels are returned along with the outcome.
+func Knock(r doorbot.Repositories, a *doorbot.Account, n Notificator, d *doorbot.Door, p *doorbot.Person, v *doorbot.Visit, session *auth.Authorization) (*Knocked, error) {
+	status, err := availability.Person(r, p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": a.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
+			"step":       "availability-find",
+		}).Error("Notificator::Knock database error")
+
+		return nil, err
+	}
+
+	result := &Knocked{Status: status}
+
+	if !status.IsAvailable {
+		result.Delegate, err = availability.Fallback(r, a, p)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": a.ID,
+				"person_id":  p.ID,
+				"door_id":    d.ID,
+				"step":       "fallback-find",
+			}).Error("Notificator::Knock database error")
+
+			return nil, err
+		}
+
+		if result.Delegate == nil {
+			log.WithFields(log.Fields{
+				"account_id": a.ID,
+				"person_id":  p.ID,
+				"door_id":    d.ID,
+				"reason":     status.Reason,
+			}).Info("Notificator::Knock person is away")
+
+			return result, ErrUnavailable
+		}
+	}
+
+	var deviceID uint
+	if session != nil && session.Type == auth.AuthorizationDevice && session.Device != nil {
+		deviceID = session.Device.ID
+	}
+
+	// The account lock is held from the checks until the knock-knock is queued and recorded.
+	limiter, err := throttle.Acquire(r, a, deviceID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": a.ID,
+			"device_id":  deviceID,
+			"step":       "limiter-acquire",
+		}).Error("Notificator::Knock database error")
+
+		return nil, err
+	}
+
+	defer release(a, limiter)
+
+	result.RetryAfter, err = limiter.RetryAfter()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": a.ID,
+			"device_id":  deviceID,
+			"step":       "rate-limit",
+		}).Error("Notificator::Knock database error")
+
+		return nil, err
+	}
+
+	if result.RetryAfter > 0 {
+		log.WithFields(log.Fields{
+			"account_id":  a.ID,
+			"device_id":   deviceID,
+			"retry_after": int(result.RetryAfter.Seconds()),
+		}).Warn("Notificator::Knock device rate limited")
+
+		return result, ErrRateLimited
+	}
+
+	duplicate, err := limiter.Duplicate(d.ID, p.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": a.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
+			"step":       "duplicate-find",
+		}).Error("Notificator::Knock database error")
+
+		return nil, err
+	}
+
+	if duplicate != nil {
+		log.WithFields(log.Fields{
+			"account_id":      a.ID,
+			"person_id":       p.ID,
+			"door_id":         d.ID,
+			"notification_id": duplicate.ID,
+		}).Info("Notificator::Knock duplicate knock-knock")
+
+		result.Notification = duplicate
+		result.Duplicate = true
+		result.Ago = limiter.Ago(duplicate)
+
+		return result, nil
+	}
+
+	if result.Delegate != nil {
+		result.Notification, err = n.Reroute(d, p, result.Delegate, v)
+	} else {
+		result.Notification, err = n.KnockKnock(d, p, v)
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	metadata := doorbot.Metadata{"notification_id": result.Notification.ID}
+	if v != nil {
+		metadata["visit_id"] = v.ID
+	}
+
+	err = limiter.Record(d.ID, p.ID, metadata)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": a.ID,
+			"door_id":    d.ID,
+			"step":       "knock-knock-record",
+		}).Error("Notificator::Knock database error")
+	}
+
+	return result, nil
+}
+
+// release ends the limiter transaction, letting the next knock-knock of the account through.
+func release(a *doorbot.Account, limiter *throttle.Limiter) {
+	err := limiter.Release()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": a.ID,
+			"step":       "limiter-release",
+		}).Error("Notificator::Knock database error")
+	}
+}
@@ -0,0 +1,101 @@
+package notifications
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestKnock(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	notificator := new(tests.MockNotificator)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DB").Return(db)
+
+	person := &doorbot.Person{ID: 5, Name: "Jane", IsVisible: true, IsAvailable: true}
+	door := &doorbot.Door{ID: 2}
+	visit := &doorbot.Visit{ID: 7}
+	queued := &doorbot.Notification{ID: 3, PersonID: 5}
+
+	workingHoursRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.OutOfOffice{}, nil)
+	notificator.On("KnockKnock", door, person, visit).Return(queued, nil)
+
+	knocked, err := Knock(repositories, &doorbot.Account{ID: 1}, notificator, door, person, visit, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, queued, knocked.Notification)
+	assert.Nil(t, knocked.Delegate)
+	assert.False(t, knocked.Duplicate)
+
+	notificator.Mock.AssertExpectations(t)
+}
+
+func TestKnockHiddenHost(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	notificator := new(tests.MockNotificator)
+	peopleRepo := new(tests.MockPersonRepository)
+	workingHoursRepo := new(tests.MockWorkingHoursRepository)
+	outOfOfficeRepo := new(tests.MockOutOfOfficeRepository)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	repositories.On("PersonRepository").Return(peopleRepo)
+	repositories.On("WorkingHoursRepository").Return(workingHoursRepo)
+	repositories.On("OutOfOfficeRepository").Return(outOfOfficeRepo)
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+
+	receptionID := uint(8)
+	account := &doorbot.Account{ID: 1, ReceptionPersonID: &receptionID}
+
+	// Hidden people are knocked through the reception like any other unavailable person.
+	person := &doorbot.Person{ID: 5, Name: "Jane", IsVisible: false, IsAvailable: true}
+	reception := &doorbot.Person{ID: 8, Name: "Front Desk", IsVisible: true, IsAvailable: true}
+	door := &doorbot.Door{ID: 2}
+	queued := &doorbot.Notification{ID: 3, PersonID: 8, ReroutedFromPersonID: &person.ID}
+
+	delegateRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.Delegate{}, nil)
+	peopleRepo.On("Find", db, uint(8)).Return(reception, nil)
+	workingHoursRepo.On("FindByPersonID", db, uint(8)).Return([]*doorbot.WorkingHours{}, nil)
+	outOfOfficeRepo.On("FindByPersonID", db, uint(8)).Return([]*doorbot.OutOfOffice{}, nil)
+	notificator.On("Reroute", door, person, reception, (*doorbot.Visit)(nil)).Return(queued, nil)
+
+	knocked, err := Knock(repositories, account, notificator, door, person, nil, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, queued, knocked.Notification)
+	assert.Equal(t, reception, knocked.Delegate)
+	assert.Equal(t, "Jane is currently not available.", knocked.Status.Message)
+
+	notificator.Mock.AssertExpectations(t)
+}
+
+func TestKnockUnavailable(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	notificator := new(tests.MockNotificator)
+	delegateRepo := new(tests.MockDelegateRepository)
+
+	repositories.On("DelegateRepository").Return(delegateRepo)
+	repositories.On("DB").Return(db)
+
+	person := &doorbot.Person{ID: 5, Name: "Jane", IsVisible: false, IsAvailable: true}
+
+	delegateRepo.On("FindByPersonID", db, uint(5)).Return([]*doorbot.Delegate{}, nil)
+
+	knocked, err := Knock(repositories, &doorbot.Account{ID: 1}, notificator, &doorbot.Door{ID: 2}, person, nil, nil)
+
+	assert.Equal(t, ErrUnavailable, err)
+	assert.Nil(t, knocked.Notification)
+	assert.Nil(t, knocked.Delegate)
+	assert.Equal(t, "Jane is currently not available.", knocked.Status.Message)
+
+	notificator.Mock.AssertExpectations(t)
+}
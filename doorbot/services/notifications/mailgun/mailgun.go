@@ -1,49 +1,187 @@
 package mailgun
 
-import(
+import (
+	"encoding/json"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// DefaultURL is the base URL of the Mailgun API
+const DefaultURL = "https://api.mailgun.net/v3"
 
+// Config holds Mailgun configuration values
 type Config struct {
+	APIKey string
+	// Sending domain registered with Mailgun
+	Domain string
+	From   string
+	// Base URL of the Mailgun API, defaults to DefaultURL
+	URL string
 }
 
+// Mailgun notifier
 type Mailgun struct {
 	Account *doorbot.Account
+	APIKey  string
+	Domain  string
+	From    string
+	URL     string
+	Client  *http.Client
 }
 
+// Error is returned when Mailgun rejects a message.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mailgun: %s (%d)", e.Message, e.StatusCode)
+}
+
+// Temporary tells if Mailgun itself failed, rather than rejecting the message.
+func (e *Error) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == 429
+}
+
+type response struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+// New creates a Mailgun instance. The account sender address is used when set.
 func New(a *doorbot.Account, c Config) *Mailgun {
-	return &Mailgun {
+	from := c.From
+	if len(a.NotificationsEmailFrom) > 0 {
+		from = a.NotificationsEmailFrom
+	}
+
+	base := c.URL
+	if len(base) == 0 {
+		base = DefaultURL
+	}
+
+	return &Mailgun{
 		Account: a,
+		APIKey:  c.APIKey,
+		Domain:  c.Domain,
+		From:    from,
+		URL:     strings.TrimSuffix(base, "/"),
+		Client:  &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
+// Name returns the notifier name
 func (m *Mailgun) Name() string {
 	return "Mailgun"
 }
 
-func (m *Mailgun) AccountCreated(p *doorbot.Person, password string ) error {
+// AccountCreated emails the temporary password of a new account owner.
+func (m *Mailgun) AccountCreated(p *doorbot.Person, password string) error {
 	log.WithFields(log.Fields{
 		"account_id":   m.Account.ID,
 		"person_id":    p.ID,
 		"person_email": p.Email,
 	}).Info("Notificator::Mailgun->AccountCreated")
 
-	/**
-	message := &postmark.Message{
-		From:    "martin@doorbot.co", //TODO make this a configuration value
-		To:      p.Email,
-		Subject: "Doorbot - Account Created",
-		TextBody: fmt.Sprintf(
-			"Welcome %s,\n\nYou can log in on the dashboard using this temporary password: %s\n\n\nAccount: %d\nTemporary Host: %s\n Email: %s\nPassword: %s\n\n- Doorbot",
-			p.Name, password, a.ID, a.Host, p.Email, password,
-		),
-	}
-	*/
+	body := fmt.Sprintf(
+		"Welcome %s,\n\nYou can log in on the dashboard using this temporary password: %s\n\n\nAccount: %d\nTemporary Host: %s\n Email: %s\nPassword: %s\n\n- Doorbot",
+		p.Name, password, m.Account.ID, m.Account.Host, p.Email, password,
+	)
+
+	_, err := m.Send(p.Email, "Doorbot - Account Created", body, "")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":        err,
+			"account_id":   m.Account.ID,
+			"person_id":    p.ID,
+			"person_email": p.Email,
+		}).Error("Notificator::Mailgun->AccountCreated error")
+
+		return err
+	}
+
 	return nil
 }
 
-func (m *Mailgun) KnockKnock(d *doorbot.Door, p *doorbot.Person) error {
-	return nil
+// KnockKnock emails the person a plain text and HTML message rendered from the email template.
+func (m *Mailgun) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id": m.Account.ID,
+		"person_id":  p.ID,
+		"door_id":    d.ID,
+	}).Info("Notificator::Mailgun->KnockKnock request")
+
+	message := templates.Render(templates.For(m.Account, k, doorbot.NotificationChannelEmail), templates.Data(m.Account, d, p, k))
+
+	text := message.Text + templates.TextReplies(k) + "\n - Doorbot"
+	html := message.HTML + templates.HTMLReplies(k) + "<p>- Doorbot</p>\n"
+
+	id, err := m.Send(p.Email, message.Subject, text, html)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": m.Account.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
+		}).Error("Notificator::Mailgun->KnockKnock error")
+
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Send posts a text message to the Mailgun messages endpoint of the sending domain and returns the message id.
+func (m *Mailgun) Send(to string, subject string, body string, html string) (string, error) {
+	values := url.Values{
+		"from":    {m.From},
+		"to":      {to},
+		"subject": {subject},
+		"text":    {body},
+	}
+
+	if len(html) > 0 {
+		values.Set("html", html)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/messages", m.URL, m.Domain), strings.NewReader(values.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.SetBasicAuth("api", m.APIKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var r response
+
+	// Mailgun replies with plain text on some errors, the body is used as the message then.
+	if json.Unmarshal(content, &r) != nil {
+		r.Message = strings.TrimSpace(string(content))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &Error{StatusCode: resp.StatusCode, Message: r.Message}
+	}
+
+	return r.ID, nil
 }
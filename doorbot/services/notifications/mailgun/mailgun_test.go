@@ -0,0 +1,58 @@
+package mailgun
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestKnockKnock(t *testing.T) {
+	var (
+		path string
+		form url.Values
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, password, _ := req.BasicAuth()
+		assert.Equal(t, "api", user)
+		assert.Equal(t, "key-123", password)
+
+		req.ParseForm()
+		path = req.URL.Path
+		form = req.PostForm
+		w.Write([]byte(`{"id": "<20150725.1@mg.doorbot.co>", "message": "Queued. Thank you."}`))
+	}))
+	defer server.Close()
+
+	template := "{{name}}, {{visitor}} is at the {{door}}.\n"
+	account := &doorbot.Account{ID: 3, NotificationsEmailMessageTemplate: &template}
+
+	m := New(account, Config{APIKey: "key-123", Domain: "mg.doorbot.co", From: "Doorbot <bot@doorbot.co>", URL: server.URL + "/"})
+
+	id, err := m.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{Name: "Jane", Email: "jane@example.com"}, &doorbot.KnockKnock{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "<20150725.1@mg.doorbot.co>", id)
+	assert.Equal(t, "/mg.doorbot.co/messages", path)
+	assert.Equal(t, "Doorbot <bot@doorbot.co>", form.Get("from"))
+	assert.Equal(t, "jane@example.com", form.Get("to"))
+	assert.Equal(t, "Doorbot - Someone is waiting at the Lobby.", form.Get("subject"))
+	assert.Equal(t, "Jane, Someone is at the Lobby.\n\n - Doorbot", form.Get("text"))
+}
+
+func TestAccountCreatedRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Forbidden"))
+	}))
+	defer server.Close()
+
+	m := New(&doorbot.Account{ID: 3}, Config{Domain: "mg.doorbot.co", URL: server.URL})
+
+	err := m.AccountCreated(&doorbot.Person{Email: "jane@example.com"}, "password")
+
+	assert.Equal(t, &Error{StatusCode: http.StatusUnauthorized, Message: "Forbidden"}, err)
+}
@@ -0,0 +1,97 @@
+// Package mattermost posts knock-knocks to a Mattermost incoming webhook.
+package mattermost
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Mattermost notifier
+type Mattermost struct {
+	Account *doorbot.Account
+	URL     string
+	Client  *http.Client
+}
+
+type payload struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username"`
+}
+
+// New creates a Mattermost instance posting to the account incoming webhook
+func New(a *doorbot.Account) *Mattermost {
+	return &Mattermost{
+		Account: a,
+		URL:     a.NotificationsMattermostURL,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the notifier name
+func (m *Mattermost) Name() string {
+	return "Mattermost"
+}
+
+// KnockKnock posts a message mentioning the person, in the door channel when one is set.
+func (m *Mattermost) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id": m.Account.ID,
+		"person_id":  p.ID,
+		"door_id":    d.ID,
+	}).Info("Notificator::Mattermost->KnockKnock request")
+
+	body, err := json.Marshal(payload{
+		Text:     Text(m.Account, d, p, k),
+		Channel:  d.NotificationsChatChannel,
+		Username: "Doorbot",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := m.Client.Post(m.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	// Drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("mattermost: unexpected status code %d", resp.StatusCode)
+	}
+
+	return "", nil
+}
+
+// Text renders the markdown message of a knock-knock from the chat template. The person is mentioned by their chat handle when set.
+// Rocket.Chat uses the same markdown flavour.
+func Text(a *doorbot.Account, d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) string {
+	data := templates.Data(a, d, p, k)
+
+	if len(p.NotificationsChatHandle) > 0 {
+		data["name"] = "@" + p.NotificationsChatHandle
+	}
+
+	text := templates.Render(templates.For(a, k, doorbot.NotificationChannelChat), data).Text
+
+	separator := "\n"
+	for _, r := range templates.Replies {
+		if link, ok := k.Links[r.Reply]; ok {
+			text += fmt.Sprintf("%s[%s](%s)", separator, r.ChatLabel, link)
+			separator = " | "
+		}
+	}
+
+	return text
+}
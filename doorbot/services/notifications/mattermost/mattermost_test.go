@@ -0,0 +1,52 @@
+package mattermost
+
+import (
+	"encoding/json"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKnockKnock(t *testing.T) {
+	var posted payload
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&posted)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	m := New(&doorbot.Account{ID: 3, NotificationsMattermostURL: server.URL})
+
+	k := &doorbot.KnockKnock{
+		Visitor: &doorbot.Visitor{Name: "Bob"},
+		Links: map[string]string{
+			doorbot.AcknowledgementOnMyWay:     "https://example.com/omw",
+			doorbot.AcknowledgementUnavailable: "https://example.com/no",
+		},
+	}
+
+	_, err := m.KnockKnock(&doorbot.Door{Name: "Lobby", NotificationsChatChannel: "front-desk"}, &doorbot.Person{Name: "Jane", NotificationsChatHandle: "jane"}, k)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "front-desk", posted.Channel)
+	assert.Equal(t, "Hi @jane, Bob is waiting at the Lobby.\n[I'm on my way](https://example.com/omw) | [I can't come to the door](https://example.com/no)", posted.Text)
+}
+
+func TestKnockKnockError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	m := New(&doorbot.Account{ID: 3, NotificationsMattermostURL: server.URL})
+
+	_, err := m.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{Name: "Jane"}, &doorbot.KnockKnock{})
+	assert.NotNil(t, err)
+}
+
+func TestText(t *testing.T) {
+	assert.Equal(t, "Hi Jane, Someone is waiting at the Lobby.", Text(&doorbot.Account{}, &doorbot.Door{Name: "Lobby"}, &doorbot.Person{Name: "Jane"}, &doorbot.KnockKnock{}))
+}
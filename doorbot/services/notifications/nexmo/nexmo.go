@@ -1,29 +1,163 @@
 package nexmo
 
-import(
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// DefaultURL is the Nexmo SMS API endpoint
+const DefaultURL = "https://rest.nexmo.com/sms/json"
+
+// Config holds Nexmo configuration values
 type Config struct {
-	Token string
+	APIKey    string
+	APISecret string
+	// Sender id or phone number the messages are sent from
+	From string
+	// SMS API endpoint, defaults to DefaultURL
+	URL string
 }
 
+// Nexmo notifier
 type Nexmo struct {
-	Account *doorbot.Account
-	Token string
+	Account   *doorbot.Account
+	APIKey    string
+	APISecret string
+	From      string
+	URL       string
+	Client    *http.Client
+}
+
+// Error is returned when Nexmo rejects a message. Status is the Nexmo status code.
+// StatusCode is set instead when the API answered with an unexpected HTTP status.
+type Error struct {
+	Status     string
+	Text       string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("nexmo: unexpected status code %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("nexmo: %s (status %s)", e.Text, e.Status)
+}
+
+// Temporary tells if Nexmo itself failed, rather than rejecting the message.
+// Nexmo status 1 is a throttled message and 5 an internal error.
+func (e *Error) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == 429 || e.Status == "1" || e.Status == "5"
 }
 
+type response struct {
+	Messages []struct {
+		Status    string `json:"status"`
+		MessageID string `json:"message-id"`
+		ErrorText string `json:"error-text"`
+	} `json:"messages"`
+}
+
+// New creates a Nexmo instance
 func New(a *doorbot.Account, c Config) *Nexmo {
+	endpoint := c.URL
+	if len(endpoint) == 0 {
+		endpoint = DefaultURL
+	}
+
 	return &Nexmo{
-		Account: a,
-		Token: c.Token,
+		Account:   a,
+		APIKey:    c.APIKey,
+		APISecret: c.APISecret,
+		From:      c.From,
+		URL:       endpoint,
+		Client:    &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
-func (s *Nexmo) Name() string {
+// Name returns the notifier name
+func (n *Nexmo) Name() string {
 	return "Nexmo"
 }
 
-func (s *Nexmo) KnockKnock(d *doorbot.Door, p *doorbot.Person) error {
-	return nil
+// KnockKnock sends a text message to the person using the account SMS template.
+// Returns the id of the first message part.
+func (n *Nexmo) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id":          n.Account.ID,
+		"person_id":           p.ID,
+		"person_phone_number": p.PhoneNumber,
+		"door_id":             d.ID,
+	}).Info("Notificator::Nexmo->KnockKnock request")
+
+	message := templates.Render(templates.For(n.Account, k, doorbot.NotificationChannelSMS), templates.Data(n.Account, d, p, k)).Text
+
+	if link, ok := k.Links[doorbot.AcknowledgementOnMyWay]; ok {
+		message += " On my way: " + link
+	}
+
+	values := url.Values{
+		"api_key":    {n.APIKey},
+		"api_secret": {n.APISecret},
+		"from":       {n.From},
+		"to":         {number(p.PhoneNumber)},
+		"text":       {message},
+	}
+
+	resp, err := n.Client.PostForm(n.URL, values)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &Error{StatusCode: resp.StatusCode}
+	}
+
+	var r response
+
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return "", err
+	}
+
+	if len(r.Messages) == 0 {
+		return "", fmt.Errorf("nexmo: no messages in the response")
+	}
+
+	// A message longer than a SMS is split in several parts, each with its own status.
+	for _, m := range r.Messages {
+		if m.Status != "0" {
+			log.WithFields(log.Fields{
+				"nexmo_status":  m.Status,
+				"nexmo_message": m.ErrorText,
+				"account_id":    n.Account.ID,
+				"person_id":     p.ID,
+				"door_id":       d.ID,
+			}).Error("Notificator::Nexmo->KnockKnock nexmo error")
+
+			return "", &Error{Status: m.Status, Text: m.ErrorText}
+		}
+	}
+
+	return r.Messages[0].MessageID, nil
+}
+
+// number formats a phone number the way Nexmo expects it, digits only in international format.
+func number(phoneNumber string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+
+		return -1
+	}, phoneNumber)
 }
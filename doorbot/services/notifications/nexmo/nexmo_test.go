@@ -0,0 +1,54 @@
+package nexmo
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestKnockKnock(t *testing.T) {
+	var form url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		form = req.PostForm
+		w.Write([]byte(`{"message-count": "1", "messages": [{"status": "0", "message-id": "0A0000001"}]}`))
+	}))
+	defer server.Close()
+
+	template := "{{visitor}} is at the {{door}}, {{name}}."
+	account := &doorbot.Account{ID: 3, NotificationsSMSMessageTemplate: &template}
+
+	n := New(account, Config{APIKey: "key", APISecret: "secret", From: "Doorbot", URL: server.URL})
+
+	k := &doorbot.KnockKnock{
+		Visitor: &doorbot.Visitor{Name: "Bob"},
+		Links:   map[string]string{doorbot.AcknowledgementOnMyWay: "https://acme.doorbot.com/ack"},
+	}
+
+	id, err := n.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{Name: "Jane", PhoneNumber: "+1 (514) 555-0100"}, k)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "0A0000001", id)
+	assert.Equal(t, "key", form.Get("api_key"))
+	assert.Equal(t, "secret", form.Get("api_secret"))
+	assert.Equal(t, "Doorbot", form.Get("from"))
+	assert.Equal(t, "15145550100", form.Get("to"))
+	assert.Equal(t, "Bob is at the Lobby, Jane. On my way: https://acme.doorbot.com/ack", form.Get("text"))
+}
+
+func TestKnockKnockRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"message-count": "1", "messages": [{"status": "4", "error-text": "Bad Credentials"}]}`))
+	}))
+	defer server.Close()
+
+	n := New(&doorbot.Account{ID: 3}, Config{URL: server.URL})
+
+	_, err := n.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{PhoneNumber: "15145550100"}, &doorbot.KnockKnock{})
+
+	assert.Equal(t, &Error{Status: "4", Text: "Bad Credentials"}, err)
+}
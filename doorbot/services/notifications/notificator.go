@@ -1,36 +1,66 @@
 package notifications
 
 import (
+	"errors"
 	log "github.com/Sirupsen/logrus"
 	"github.com/masom/doorbot/doorbot"
 	"github.com/masom/doorbot/doorbot/services/notifications/hipchat"
 	"github.com/masom/doorbot/doorbot/services/notifications/mailgun"
+	"github.com/masom/doorbot/doorbot/services/notifications/mattermost"
 	"github.com/masom/doorbot/doorbot/services/notifications/nexmo"
 	"github.com/masom/doorbot/doorbot/services/notifications/postmark"
+	"github.com/masom/doorbot/doorbot/services/notifications/push"
+	"github.com/masom/doorbot/doorbot/services/notifications/rocketchat"
 	"github.com/masom/doorbot/doorbot/services/notifications/slack"
+	"github.com/masom/doorbot/doorbot/services/notifications/smtp"
+	"github.com/masom/doorbot/doorbot/services/notifications/teams"
 	"github.com/masom/doorbot/doorbot/services/notifications/twilio"
+	"github.com/masom/doorbot/doorbot/services/notifications/webhook"
+	"github.com/masom/doorbot/doorbot/services/rendering"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoChannels is returned when neither the account nor the person have a notification channel enabled.
+	ErrNoChannels = errors.New("no notification channels available")
 )
 
 type (
 	// Notificator configuration
 	Config struct {
-		Account *doorbot.Account
-		HipChat hipchat.Config
-		Mailgun mailgun.Config
-		Nexmo nexmo.Config
-		Postmark postmark.Config
-		Slack slack.Config
-		Twilio twilio.Config
+		Account      *doorbot.Account
+		Repositories doorbot.Repositories
+		Links        Links
+		HipChat      hipchat.Config
+		Mailgun      mailgun.Config
+		Nexmo        nexmo.Config
+		Postmark     postmark.Config
+		Push         push.Config
+		Slack        slack.Config
+		SMTP         doorbot.SMTPConfig
+		Twilio       twilio.Config
+		Webhook      webhook.Config
 	}
 
 	Notificator interface {
 		AccountCreated(a *doorbot.Account, p *doorbot.Person, password string)
-		KnockKnock(d *doorbot.Door, p *doorbot.Person) bool
+		KnockKnock(d *doorbot.Door, p *doorbot.Person, v *doorbot.Visit) (*doorbot.Notification, error)
+		Reroute(d *doorbot.Door, host *doorbot.Person, p *doorbot.Person, v *doorbot.Visit) (*doorbot.Notification, error)
+		Invite(i *doorbot.Invitation, v *doorbot.Visitor, p *doorbot.Person) error
+		Digest(p *doorbot.Person, subject string, body string) error
+		Test(p *doorbot.Person, kind string, provider string) ([]*doorbot.NotificationAttempt, error)
+	}
+
+	// Mailer is implemented by the email notifiers to send messages other than knock-knocks.
+	Mailer interface {
+		Send(to string, subject string, body string, html string) (string, error)
 	}
 
-	// Notifier interface
+	// Notifier interface. KnockKnock returns the message id assigned by the provider, when available.
 	Notifier interface {
-		KnockKnock(d *doorbot.Door, p *doorbot.Person) error
+		KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error)
 		Name() string
 	}
 
@@ -47,64 +77,450 @@ func New(c Config) Notificator {
 	}
 }
 
-// AccountCreated sends an email to Doorbot
+// AccountCreated emails the temporary password to the account owner, through the SMTP relay when one is configured.
 func (n *notificator) AccountCreated(a *doorbot.Account, p *doorbot.Person, password string) {
+	if len(n.Config.SMTP.Host) > 0 {
+		go smtp.New(a, n.Config.SMTP).AccountCreated(p, password)
+		return
+	}
+
 	en := mailgun.New(a, n.Config.Mailgun)
 	go en.AccountCreated(p, password)
 }
 
-// KnockKnock sends a notification to a user that someone is looking for them at a certain door
-func (n *notificator) KnockKnock(d *doorbot.Door, p *doorbot.Person) bool {
-	channels := n.channels(p)
+// KnockKnock queues a notification telling a user that someone is looking for them at a certain door.
+// The visit is optional. The notification is delivered by the queue workers.
+func (n *notificator) KnockKnock(d *doorbot.Door, p *doorbot.Person, v *doorbot.Visit) (*doorbot.Notification, error) {
+	return n.queue(d, p, v, nil)
+}
 
-	if len(channels) == 0 {
+// Reroute queues the knock-knock of an unavailable host for the person taking it instead, a delegate or the reception.
+func (n *notificator) Reroute(d *doorbot.Door, host *doorbot.Person, p *doorbot.Person, v *doorbot.Visit) (*doorbot.Notification, error) {
+	return n.queue(d, p, v, host)
+}
+
+// queue creates the notification of a knock-knock. The host is set when it was rerouted.
+func (n *notificator) queue(d *doorbot.Door, p *doorbot.Person, v *doorbot.Visit, host *doorbot.Person) (*doorbot.Notification, error) {
+	reachable, err := n.reachable(d, p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": n.Config.Account.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
+			"step":       "escalation-policy",
+		}).Error("Notificator::KnockKnock database error")
+
+		return nil, err
+	}
+
+	if !reachable {
 		log.WithFields(log.Fields{
 			"account_id": n.Config.Account.ID,
-			"person_id": p.ID,
-			"door_id": d.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
 		}).Info("Notificator::KnockKnock no channels")
-		return false
+
+		return nil, ErrNoChannels
+	}
+
+	r := n.Config.Repositories
+	notification := doorbot.NewNotification(n.Config.Account.ID, d.ID, p.ID)
+	if v != nil {
+		notification.VisitID = &v.ID
 	}
 
-	go func(channels []Notifier){
+	if host != nil {
+		notification.ReroutedFromPersonID = &host.ID
+	}
+
+	err = r.NotificationRepository().Create(r.DB(), notification)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": n.Config.Account.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
+			"step":       "notification-create",
+		}).Error("Notificator::KnockKnock database error")
+
+		return nil, err
+	}
 
+	log.WithFields(log.Fields{
+		"account_id":      n.Config.Account.ID,
+		"person_id":       p.ID,
+		"door_id":         d.ID,
+		"notification_id": notification.ID,
+	}).Info("Notificator::KnockKnock queued")
+
+	return notification, nil
+}
+
+// Invite emails an invitation to the guest using the account email notifiers.
+func (n *notificator) Invite(i *doorbot.Invitation, v *doorbot.Visitor, p *doorbot.Person) error {
+	log.WithFields(log.Fields{
+		"account_id":    n.Config.Account.ID,
+		"invitation_id": i.ID,
+		"visitor_id":    v.ID,
+		"person_id":     p.ID,
+	}).Info("Notificator::Invite request")
+
+	dbb := rendering.DoorbotBar()
+
+	renderingData := map[string]string{
+		"name":    v.Name,
+		"host":    p.Name,
+		"account": n.Config.Account.Name,
+		"code":    i.Code,
+		"starts":  i.StartsAt.Format("Monday, January 2 at 15:04 MST"),
+		"ends":    i.EndsAt.Format("Monday, January 2 at 15:04 MST"),
+	}
+
+	subject := dbb.Render("Doorbot - {{host}} invited you to {{account}}", renderingData)
+	body := dbb.Render(
+		"Hi {{name}},\n{{host}} is expecting you at {{account}} from {{starts}} until {{ends}}.\n\nWhen you arrive, enter this code on the lobby device: {{code}}\n{{host}} will be told you are here.\n\n - Doorbot",
+		renderingData,
+	)
+
+	return n.mail(v.Email, subject, body)
+}
+
+// Digest emails a rendered lobby activity digest to a person using the account email notifiers.
+func (n *notificator) Digest(p *doorbot.Person, subject string, body string) error {
+	log.WithFields(log.Fields{
+		"account_id": n.Config.Account.ID,
+		"person_id":  p.ID,
+	}).Info("Notificator::Digest request")
+
+	return n.mail(p.Email, subject, body)
+}
+
+// mail sends a plain text email through the account email notifiers, in order, until one accepts it.
+func (n *notificator) mail(to string, subject string, body string) error {
+	var err error = ErrNoChannels
+
+	for _, notifier := range n.notifiers(doorbot.NotificationChannelEmail, nil, &doorbot.Person{Email: to}) {
+		mailer, ok := notifier.(Mailer)
+		if !ok {
+			continue
+		}
+
+		_, err = mailer.Send(to, subject, body, "")
+		if err == nil {
+			return nil
+		}
+
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": n.Config.Account.ID,
+			"notifier":   notifier.Name(),
+		}).Error("Notificator::mail error")
+	}
+
+	return err
+}
 
-		for _, channel := range channels {
-			err := channel.KnockKnock(d, p)
+// Test sends a sample knock-knock to a person through every notifier of a channel kind, or only the named provider.
+// Every notifier is tried so each provider configuration is checked, the attempts report the provider responses.
+func (n *notificator) Test(p *doorbot.Person, kind string, provider string) ([]*doorbot.NotificationAttempt, error) {
+	d := &doorbot.Door{AccountID: n.Config.Account.ID, Name: "test door"}
 
-			if err == nil {
-				log.WithFields(log.Fields{
-					"account_id": n.Config.Account.ID,
-					"person_id": p.ID,
-					"door_id": d.ID,
-					"channel":  channel.Name(),
-					"error": err,
-				}).Error("Notificator::KnockKnock error")
+	var channels []Notifier
 
-				continue
+	for _, channel := range n.notifiers(kind, d, p) {
+		if len(provider) == 0 || strings.EqualFold(provider, channel.Name()) {
+			channels = append(channels, channel)
+		}
+	}
+
+	if len(channels) == 0 {
+		return nil, ErrNoChannels
+	}
+
+	k := &doorbot.KnockKnock{
+		Visitor: &doorbot.Visitor{Name: "A test visitor", Company: "Doorbot"},
+	}
+
+	var attempts []*doorbot.NotificationAttempt
+
+	for _, channel := range channels {
+		a, _ := n.try(k, []Notifier{channel}, d, p)
+		attempts = append(attempts, a...)
+	}
+
+	log.WithFields(log.Fields{
+		"account_id": n.Config.Account.ID,
+		"person_id":  p.ID,
+		"channel":    kind,
+		"provider":   provider,
+		"notifiers":  len(channels),
+	}).Info("Notificator::Test sent")
+
+	return attempts, nil
+}
+
+// reachable tells if at least one channel can reach the person, following the escalation policy when enabled.
+func (n *notificator) reachable(d *doorbot.Door, p *doorbot.Person) (bool, error) {
+	if p.NotificationsMode == doorbot.NotificationsModeEscalate {
+		steps, err := n.policy(p)
+		if err != nil {
+			return false, err
+		}
+
+		for _, step := range steps {
+			if len(n.notifiers(step.Channel, d, n.recipient(step, p))) > 0 {
+				return true, nil
 			}
+		}
+
+		if len(steps) > 0 {
+			return false, nil
+		}
+	}
+
+	return len(n.channels(d, p)) > 0, nil
+}
+
+// policy returns the escalation policy of a person.
+func (n *notificator) policy(p *doorbot.Person) ([]*doorbot.EscalationStep, error) {
+	r := n.Config.Repositories
+	return r.EscalationStepRepository().FindByPersonID(r.DB(), p.ID)
+}
+
+// recipient returns the person targeted by an escalation step.
+func (n *notificator) recipient(step *doorbot.EscalationStep, p *doorbot.Person) *doorbot.Person {
+	if step.Recipient != doorbot.EscalationRecipientAccountContact {
+		return p
+	}
+
+	return &doorbot.Person{
+		AccountID:   n.Config.Account.ID,
+		Name:        n.Config.Account.ContactName,
+		Email:       n.Config.Account.ContactEmail,
+		PhoneNumber: n.Config.Account.ContactPhoneNumber,
+	}
+}
+
+// deliver sends the notification according to the person notifications mode.
+// Escalation policies are handled by the queue, one step at a time.
+func (n *notificator) deliver(k *doorbot.KnockKnock, d *doorbot.Door, p *doorbot.Person) ([]*doorbot.NotificationAttempt, bool) {
+	if p.NotificationsMode == doorbot.NotificationsModeAll {
+		return n.broadcast(k, d, p)
+	}
+
+	return n.try(k, n.channels(d, p), d, p)
+}
+
+// broadcast delivers the notification on every channel kind enabled by the person at once.
+func (n *notificator) broadcast(k *doorbot.KnockKnock, d *doorbot.Door, p *doorbot.Person) ([]*doorbot.NotificationAttempt, bool) {
+	kinds := n.kinds(p)
+
+	results := make([][]*doorbot.NotificationAttempt, len(kinds))
+	delivered := make([]bool, len(kinds))
+
+	var wg sync.WaitGroup
+
+	for i, kind := range kinds {
+		wg.Add(1)
+
+		go func(i int, kind string) {
+			defer wg.Done()
+			results[i], delivered[i] = n.try(k, n.notifiers(kind, d, p), d, p)
+		}(i, kind)
+	}
+
+	wg.Wait()
+
+	var attempts []*doorbot.NotificationAttempt
+	reached := false
+
+	for i := range kinds {
+		attempts = append(attempts, results[i]...)
+		reached = reached || delivered[i]
+	}
+
+	return attempts, reached
+}
+
+// try the given channels in order until one of them accepts the notification.
+// Every channel tried is reported as an attempt. Each channel receives its own acknowledgement links.
+// Providers with an open circuit are skipped in favor of the next provider of the same kind, unless all of them are open.
+func (n *notificator) try(k *doorbot.KnockKnock, channels []Notifier, d *doorbot.Door, p *doorbot.Person) ([]*doorbot.NotificationAttempt, bool) {
+	var attempts []*doorbot.NotificationAttempt
 
-			// TODO check if the user want to be contacted on multiple channels
+	resolved := map[string]*doorbot.NotificationTemplate{}
+	bypass := Breakers.AllOpen(channels, time.Now())
+
+	for _, channel := range channels {
+		attempt := &doorbot.NotificationAttempt{
+			Channel: channel.Name(),
+			EventID: eventFor(channel),
+			Status:  doorbot.NotificationAttemptDelivered,
+		}
+
+		attempts = append(attempts, attempt)
+
+		if !bypass && !Breakers.Allow(channel, time.Now()) {
+			log.WithFields(log.Fields{
+				"account_id": n.Config.Account.ID,
+				"person_id":  p.ID,
+				"door_id":    d.ID,
+				"channel":    channel.Name(),
+			}).Warn("Notificator::KnockKnock circuit open")
+
+			attempt.Status = doorbot.NotificationAttemptSkipped
+			attempt.Error = "circuit open"
+			continue
+		}
+
+		message := *k
+		message.Links = n.Config.Links.Acknowledgements(n.Config.Account, k.Notification, kindOf(channel))
+
+		if t, ok := resolved[kindOf(channel)]; ok {
+			message.Template = t
+		} else {
+			message.Template = n.template(kindOf(channel), d)
+			resolved[kindOf(channel)] = message.Template
+		}
+
+		if _, ok := channel.(*slack.Slack); ok {
+			message.Token = n.Config.Links.Token(n.Config.Account, k.Notification, kindOf(channel))
+		}
+
+		started := time.Now()
+		messageID, err := channel.KnockKnock(d, p, &message)
+		Breakers.Record(channel, err, time.Since(started), time.Now())
+
+		attempt.ProviderMessageID = messageID
+
+		if err != nil {
 			log.WithFields(log.Fields{
 				"account_id": n.Config.Account.ID,
-				"person_id": p.ID,
-				"door_id": d.ID,
-				"channel":  channel.Name(),
-			}).Info("Notificator::KnockKnock delivered")
+				"person_id":  p.ID,
+				"door_id":    d.ID,
+				"channel":    channel.Name(),
+				"error":      err,
+			}).Error("Notificator::KnockKnock error")
 
-			return
+			attempt.Status = doorbot.NotificationAttemptFailed
+			attempt.Error = err.Error()
+			continue
 		}
-	}(channels)
 
-	return true
+		log.WithFields(log.Fields{
+			"account_id": n.Config.Account.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
+			"channel":    channel.Name(),
+		}).Info("Notificator::KnockKnock delivered")
+
+		return attempts, true
+	}
+
+	return attempts, false
+}
+
+// template returns the door or account template of a channel kind, nil when neither define one.
+// Notifiers fall back to the legacy account templates and the defaults, see the templates package.
+func (n *notificator) template(kind string, d *doorbot.Door) *doorbot.NotificationTemplate {
+	r := n.Config.Repositories
+	if r == nil {
+		return nil
+	}
+
+	t, err := r.NotificationTemplateRepository().FindByChannel(r.DB(), kind, d.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": n.Config.Account.ID,
+			"door_id":    d.ID,
+			"channel":    kind,
+			"step":       "template-find",
+		}).Error("Notificator::KnockKnock database error")
+
+		return nil
+	}
+
+	return t
+}
+
+// eventFor returns the event code matching the kind of channel used by a notifier.
+func eventFor(n Notifier) uint {
+	switch kindOf(n) {
+	case doorbot.NotificationChannelSMS:
+		return doorbot.EventNotificationSMSSent
+	case doorbot.NotificationChannelEmail:
+		return doorbot.EventNotificationEmailSent
+	case doorbot.NotificationChannelApp:
+		return doorbot.EventNotificationAppSent
+	case doorbot.NotificationChannelWebhook:
+		return doorbot.EventNotificationWebhookSent
+	}
+
+	return doorbot.EventNotificationSent
+}
+
+// kindOf returns the channel kind of a notifier.
+func kindOf(n Notifier) string {
+	switch n.(type) {
+	case *nexmo.Nexmo, *twilio.Twilio:
+		return doorbot.NotificationChannelSMS
+	case *mailgun.Mailgun, *postmark.Postmark, *smtp.SMTP:
+		return doorbot.NotificationChannelEmail
+	case *push.Push:
+		return doorbot.NotificationChannelApp
+	case *webhook.Webhook:
+		return doorbot.NotificationChannelWebhook
+	}
+
+	return doorbot.NotificationChannelChat
+}
+
+// kinds returns the channel kinds the person has enabled, in delivery order.
+func (n *notificator) kinds(p *doorbot.Person) []string {
+	var kinds []string
+
+	if p.NotificationsAppEnabled {
+		kinds = append(kinds, doorbot.NotificationChannelApp)
+	}
+
+	if p.NotificationsChatEnabled {
+		kinds = append(kinds, doorbot.NotificationChannelChat)
+	}
+
+	if p.NotificationsSMSEnabled {
+		kinds = append(kinds, doorbot.NotificationChannelSMS)
+	}
+
+	if p.NotificationsEmailEnabled {
+		kinds = append(kinds, doorbot.NotificationChannelEmail)
+	}
+
+	if p.NotificationsWebhookEnabled {
+		kinds = append(kinds, doorbot.NotificationChannelWebhook)
+	}
+
+	return kinds
 }
 
 // channels builds a list of channels the account + user have enabled.
-func (n *notificator) channels(p *doorbot.Person) []Notifier {
+func (n *notificator) channels(d *doorbot.Door, p *doorbot.Person) []Notifier {
+	var notifiers []Notifier
+
+	for _, kind := range n.kinds(p) {
+		notifiers = append(notifiers, n.notifiers(kind, d, p)...)
+	}
 
+	return notifiers
+}
+
+// notifiers builds the list of notifiers of a given kind the account has enabled and that can reach the person.
+func (n *notificator) notifiers(kind string, d *doorbot.Door, p *doorbot.Person) []Notifier {
 	var notifiers []Notifier
 
-	if p.NotificationsChatEnabled {
+	switch kind {
+	case doorbot.NotificationChannelChat:
 		// HipChat
 		if n.Config.Account.NotificationsHipChatEnabled {
 			notifiers = append(notifiers, hipchat.New(n.Config.Account, n.Config.HipChat))
@@ -112,27 +528,43 @@ func (n *notificator) channels(p *doorbot.Person) []Notifier {
 
 		// Slack
 		if n.Config.Account.NotificationsSlackEnabled {
-			notifiers = append(notifiers, slack.New(n.Config.Account, n.Config.Slack))
+			notifiers = append(notifiers, slack.New(n.Config.Account, n.Config.Repositories, n.Config.Slack))
+		}
+
+		// Incoming webhooks
+		if n.Config.Account.NotificationsMattermostEnabled && len(n.Config.Account.NotificationsMattermostURL) > 0 {
+			notifiers = append(notifiers, mattermost.New(n.Config.Account))
+		}
+
+		if n.Config.Account.NotificationsRocketChatEnabled && len(n.Config.Account.NotificationsRocketChatURL) > 0 {
+			notifiers = append(notifiers, rocketchat.New(n.Config.Account))
+		}
+
+		// Doors can route their knock-knocks to their own Teams channel.
+		if n.Config.Account.NotificationsTeamsEnabled && (len(n.Config.Account.NotificationsTeamsURL) > 0 || (d != nil && len(d.NotificationsTeamsURL) > 0)) {
+			notifiers = append(notifiers, teams.New(n.Config.Account))
+		}
+
+	case doorbot.NotificationChannelSMS:
+		if len(p.PhoneNumber) <= 6 {
+			return notifiers
 		}
-	}
 
-	if p.NotificationsSMSEnabled {
 		// Nexmo
 		if n.Config.Account.NotificationsNexmoEnabled {
-			if  len(p.PhoneNumber) > 6 {
-				notifiers = append(notifiers, nexmo.New(n.Config.Account, n.Config.Nexmo))
-			}
+			notifiers = append(notifiers, nexmo.New(n.Config.Account, n.Config.Nexmo))
 		}
 
 		// Twilio
 		if n.Config.Account.NotificationsTwilioEnabled {
-			if len(p.PhoneNumber) > 6 {
-				notifiers = append(notifiers, twilio.New(n.Config.Account, n.Config.Twilio))
-			}
+			notifiers = append(notifiers, twilio.New(n.Config.Account, n.Config.Twilio))
+		}
+
+	case doorbot.NotificationChannelEmail:
+		if len(p.Email) == 0 {
+			return notifiers
 		}
-	}
 
-	if p.NotificationsEmailEnabled {
 		// Mailgun
 		if n.Config.Account.NotificationsMailgunEnabled {
 			notifiers = append(notifiers, mailgun.New(n.Config.Account, n.Config.Mailgun))
@@ -142,6 +574,46 @@ func (n *notificator) channels(p *doorbot.Person) []Notifier {
 		if n.Config.Account.NotificationsPostmarkEnabled {
 			notifiers = append(notifiers, postmark.New(n.Config.Account, n.Config.Postmark))
 		}
+
+		// SMTP relay, only available when configured on the server
+		if n.Config.Account.NotificationsSMTPEnabled && len(n.Config.SMTP.Host) > 0 {
+			notifiers = append(notifiers, smtp.New(n.Config.Account, n.Config.SMTP))
+		}
+
+	case doorbot.NotificationChannelApp:
+		// The account contact is not a person and has no registered devices.
+		if p.ID == 0 {
+			return notifiers
+		}
+
+		r := n.Config.Repositories
+
+		devices, err := r.PushDeviceRepository().FindByPersonID(r.DB(), p.ID)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": n.Config.Account.ID,
+				"person_id":  p.ID,
+				"step":       "push-devices-find",
+			}).Error("Notificator::notifiers database error")
+
+			return notifiers
+		}
+
+		pn := push.New(n.Config.Account, r, devices, n.Config.Push)
+		if len(pn.Devices) > 0 {
+			notifiers = append(notifiers, pn)
+		}
+
+	case doorbot.NotificationChannelWebhook:
+		if !n.Config.Account.NotificationsWebhookEnabled {
+			return notifiers
+		}
+
+		w := webhook.New(n.Config.Account, p, n.Config.Webhook)
+		if len(w.URL) > 0 {
+			notifiers = append(notifiers, w)
+		}
 	}
 
 	return notifiers
@@ -0,0 +1,224 @@
+package notifications
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/mailgun"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestKinds(t *testing.T) {
+	n := &notificator{Config: Config{Account: &doorbot.Account{}}}
+
+	p := &doorbot.Person{
+		NotificationsEmailEnabled: true,
+		NotificationsChatEnabled:  true,
+	}
+
+	assert.Equal(t, []string{doorbot.NotificationChannelChat, doorbot.NotificationChannelEmail}, n.kinds(p))
+}
+
+func TestNotifiers(t *testing.T) {
+	n := &notificator{
+		Config: Config{
+			Account: &doorbot.Account{
+				NotificationsSlackEnabled:    true,
+				NotificationsTwilioEnabled:   true,
+				NotificationsPostmarkEnabled: true,
+			},
+		},
+	}
+
+	p := &doorbot.Person{
+		Email:       "romanian.landlords@example.com",
+		PhoneNumber: "555",
+	}
+
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelChat, nil, p), 1)
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelSMS, nil, p), 0)
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelEmail, nil, p), 1)
+
+	p.PhoneNumber = "+15555555555"
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelSMS, nil, p), 1)
+}
+
+func TestWebhookNotifiers(t *testing.T) {
+	n := &notificator{Config: Config{Account: &doorbot.Account{NotificationsWebhookEnabled: true}}}
+
+	p := &doorbot.Person{}
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelWebhook, nil, p), 0)
+
+	p.NotificationsWebhookURL = "https://hooks.example.com/jane"
+	notifiers := n.notifiers(doorbot.NotificationChannelWebhook, nil, p)
+	assert.Len(t, notifiers, 1)
+	assert.Equal(t, doorbot.NotificationChannelWebhook, kindOf(notifiers[0]))
+	assert.Equal(t, uint(doorbot.EventNotificationWebhookSent), eventFor(notifiers[0]))
+}
+
+func TestIncomingWebhookNotifiers(t *testing.T) {
+	n := &notificator{
+		Config: Config{
+			Account: &doorbot.Account{
+				NotificationsMattermostEnabled: true,
+				NotificationsMattermostURL:     "https://chat.example.com/hooks/1",
+				NotificationsRocketChatEnabled: true,
+				NotificationsTeamsEnabled:      true,
+				NotificationsTeamsURL:          "https://example.webhook.office.com/1",
+			},
+		},
+	}
+
+	notifiers := n.notifiers(doorbot.NotificationChannelChat, nil, &doorbot.Person{})
+	assert.Len(t, notifiers, 2)
+	assert.Equal(t, "Mattermost", notifiers[0].Name())
+	assert.Equal(t, "Teams", notifiers[1].Name())
+	assert.Equal(t, doorbot.NotificationChannelChat, kindOf(notifiers[1]))
+}
+
+func TestTeamsDoorURL(t *testing.T) {
+	n := &notificator{Config: Config{Account: &doorbot.Account{NotificationsTeamsEnabled: true}}}
+
+	p := &doorbot.Person{}
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelChat, nil, p), 0)
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelChat, &doorbot.Door{}, p), 0)
+
+	notifiers := n.notifiers(doorbot.NotificationChannelChat, &doorbot.Door{NotificationsTeamsURL: "https://example.webhook.office.com/lobby"}, p)
+	assert.Len(t, notifiers, 1)
+	assert.Equal(t, "Teams", notifiers[0].Name())
+
+	n.Config.Account.NotificationsTeamsEnabled = false
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelChat, &doorbot.Door{NotificationsTeamsURL: "https://example.webhook.office.com/lobby"}, p), 0)
+}
+
+func TestAppKinds(t *testing.T) {
+	n := &notificator{Config: Config{Account: &doorbot.Account{}}}
+
+	p := &doorbot.Person{
+		NotificationsAppEnabled: true,
+		NotificationsSMSEnabled: true,
+	}
+
+	assert.Equal(t, []string{doorbot.NotificationChannelApp, doorbot.NotificationChannelSMS}, n.kinds(p))
+
+	// The account contact has no push devices.
+	assert.Len(t, n.notifiers(doorbot.NotificationChannelApp, nil, &doorbot.Person{}), 0)
+}
+
+func TestRecipient(t *testing.T) {
+	n := &notificator{
+		Config: Config{
+			Account: &doorbot.Account{
+				ID:           3,
+				ContactName:  "Front Desk",
+				ContactEmail: "desk@example.com",
+			},
+		},
+	}
+
+	p := &doorbot.Person{ID: 44}
+
+	assert.Equal(t, p, n.recipient(&doorbot.EscalationStep{Recipient: doorbot.EscalationRecipientPerson}, p))
+
+	contact := n.recipient(&doorbot.EscalationStep{Recipient: doorbot.EscalationRecipientAccountContact}, p)
+	assert.Equal(t, "desk@example.com", contact.Email)
+	assert.Equal(t, uint(3), contact.AccountID)
+}
+
+func TestTest(t *testing.T) {
+	var posted []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		posted = append(posted, string(body))
+	}))
+	defer server.Close()
+
+	n := &notificator{
+		Config: Config{
+			Account: &doorbot.Account{
+				NotificationsMattermostEnabled: true,
+				NotificationsMattermostURL:     server.URL,
+				NotificationsTeamsEnabled:      true,
+				NotificationsTeamsURL:          server.URL,
+			},
+		},
+	}
+
+	person := &doorbot.Person{Name: "Jane"}
+
+	attempts, err := n.Test(person, doorbot.NotificationChannelChat, "mattermost")
+	assert.Nil(t, err)
+	assert.Len(t, attempts, 1)
+	assert.Equal(t, "Mattermost", attempts[0].Channel)
+	assert.Equal(t, doorbot.NotificationAttemptDelivered, attempts[0].Status)
+	assert.Len(t, posted, 1)
+	assert.Contains(t, posted[0], "A test visitor is waiting at the test door.")
+
+	attempts, err = n.Test(person, doorbot.NotificationChannelChat, "")
+	assert.Nil(t, err)
+	assert.Len(t, attempts, 2)
+
+	_, err = n.Test(person, doorbot.NotificationChannelChat, "Slack")
+	assert.Equal(t, ErrNoChannels, err)
+}
+
+func TestInvite(t *testing.T) {
+	var posted url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted = r.PostForm
+		w.Write([]byte(`{"id": "<1@example.com>", "message": "Queued. Thank you."}`))
+	}))
+	defer server.Close()
+
+	n := &notificator{
+		Config: Config{
+			Account: &doorbot.Account{Name: "Lobby Inc"},
+			Mailgun: mailgun.Config{URL: server.URL, Domain: "example.com"},
+		},
+	}
+
+	invitation := &doorbot.Invitation{Code: "482913", StartsAt: time.Now(), EndsAt: time.Now().Add(time.Hour)}
+	visitor := &doorbot.Visitor{Name: "Bob", Email: "bob@example.com"}
+	host := &doorbot.Person{Name: "Jane"}
+
+	assert.Equal(t, ErrNoChannels, n.Invite(invitation, visitor, host))
+
+	n.Config.Account.NotificationsMailgunEnabled = true
+
+	assert.Nil(t, n.Invite(invitation, visitor, host))
+	assert.Equal(t, "bob@example.com", posted.Get("to"))
+	assert.Equal(t, "Doorbot - Jane invited you to Lobby Inc", posted.Get("subject"))
+	assert.Contains(t, posted.Get("text"), "482913")
+}
+
+func TestDigest(t *testing.T) {
+	var posted url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posted = r.PostForm
+		w.Write([]byte(`{"id": "<2@example.com>", "message": "Queued. Thank you."}`))
+	}))
+	defer server.Close()
+
+	n := &notificator{
+		Config: Config{
+			Account: &doorbot.Account{NotificationsMailgunEnabled: true},
+			Mailgun: mailgun.Config{URL: server.URL, Domain: "example.com"},
+		},
+	}
+
+	assert.Equal(t, ErrNoChannels, n.Digest(&doorbot.Person{}, "Doorbot - Weekly digest", "3 visits"))
+
+	assert.Nil(t, n.Digest(&doorbot.Person{Email: "jane@example.com"}, "Doorbot - Weekly digest", "3 visits"))
+	assert.Equal(t, "jane@example.com", posted.Get("to"))
+	assert.Equal(t, "Doorbot - Weekly digest", posted.Get("subject"))
+	assert.Equal(t, "3 visits", posted.Get("text"))
+}
@@ -3,24 +3,58 @@ package postmark
 import(
 	log "github.com/Sirupsen/logrus"
 	"github.com/masom/doorbot/doorbot"
-	"github.com/masom/doorbot/doorbot/services/rendering"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
 	"github.com/gcmurphy/postmark"
 	"fmt"
+	"net/url"
 )
 
+// DefaultFrom is the sender address used when neither the configuration nor the account set one
+const DefaultFrom = "martin@doorbot.co"
+
+// unavailable is the message of the Postmark client when the API answers with a server error
+const unavailable = "Postmark seems to be down!"
+
+// Error wraps the API errors of the Postmark client, which only tells an outage by its message.
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "postmark: " + e.Message
+}
+
+// Temporary tells if Postmark itself failed, rather than rejecting the message.
+func (e *Error) Temporary() bool {
+	return e.Message == unavailable
+}
+
 type Config struct {
 	Token string
+	// Default sender address, accounts can set their own.
+	From string
 }
 
 type Postmark struct {
 	Account *doorbot.Account
 	Token string
+	From string
 }
 
 func New(a *doorbot.Account, c Config) *Postmark {
+	from := c.From
+	if len(a.NotificationsEmailFrom) > 0 {
+		from = a.NotificationsEmailFrom
+	}
+
+	if len(from) == 0 {
+		from = DefaultFrom
+	}
+
 	return &Postmark{
 		Account: a,
 		Token: c.Token,
+		From: from,
 	}
 }
 
@@ -37,7 +71,7 @@ func (p *Postmark) AccountCreated(person *doorbot.Person, password string) error
 	}).Info("Notificator::Mailgun->AccountCreated")
 
 	message := &postmark.Message{
-		From:    "martin@doorbot.co", //TODO make this a configuration value
+		From:    p.From,
 		To:      person.Email,
 		Subject: "Doorbot - Account Created",
 		TextBody: fmt.Sprintf(
@@ -63,41 +97,56 @@ func (p *Postmark) AccountCreated(person *doorbot.Person, password string) error
 }
 
 // KnockKnock sends a private message to a user.
-func (p *Postmark) KnockKnock(d *doorbot.Door, person *doorbot.Person) error {
+func (p *Postmark) KnockKnock(d *doorbot.Door, person *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
 	log.WithFields(log.Fields{
 		"account_id": p.Account.ID,
 		"person_id":  person.ID,
 		"door_id":    d.ID,
 	}).Info("Notificator::Postmark->KnockKnock request")
 
-	dbb := rendering.DoorbotBar()
+	message := templates.Render(templates.For(p.Account, k, doorbot.NotificationChannelEmail), templates.Data(p.Account, d, person, k))
 
-	renderingData := map[string]string{
-		"name": person.Name,
-		"door": d.Name,
+	id, err := p.Send(
+		person.Email,
+		message.Subject,
+		message.Text+templates.TextReplies(k)+"\n - Doorbot",
+		message.HTML+templates.HTMLReplies(k)+"<p>- Doorbot</p>\n",
+	)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": p.Account.ID,
+			"person_id":  person.ID,
+			"door_id":    d.ID,
+		}).Error("Notificator::Postmark->KnockKnock error")
+
+		return "", err
 	}
 
-	//TODO HTML message template.
+	return id, nil
+}
+
+// Send emails a message and returns the Postmark message id. The HTML body is optional.
+func (p *Postmark) Send(to string, subject string, body string, html string) (string, error) {
 	message := &postmark.Message{
-		From:     "martin@canvaspop.com",
-		To:       person.Email,
-		Subject:  dbb.Render("Doorbot - There is someone waiting at the {{door}}.", renderingData),
-		TextBody: dbb.Render("Hi {{name}},\nThere is someone waiting at the {{door}}.\n\n - Doorbot", renderingData),
+		From:     p.From,
+		To:       to,
+		Subject:  subject,
+		TextBody: body,
+		HtmlBody: html,
 	}
 
 	pm := postmark.NewPostmark(p.Token)
 
-	_, err := pm.Send(message)
-
+	response, err := pm.Send(message)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error":      err,
-			"account_id": p.Account.ID,
-			"person_id":  person.ID,
-			"door_id":    d.ID,
-		}).Error("Notificator::Postmark->KnockKnock error")
-		return err
+		// Transport errors are kept as is.
+		if _, ok := err.(*url.Error); ok {
+			return "", err
+		}
+
+		return "", &Error{Message: err.Error()}
 	}
 
-	return nil
+	return response.MessageID, nil
 }
@@ -0,0 +1,341 @@
+// Package push notifies the companion apps of a person through the APNs and FCM push services.
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultAPNsURL is the base URL of the Apple Push Notification service
+	DefaultAPNsURL = "https://api.push.apple.com"
+	// DefaultFCMURL is the Firebase Cloud Messaging send endpoint
+	DefaultFCMURL = "https://fcm.googleapis.com/fcm/send"
+
+	// CategoryKnockKnock is the category the apps register the knock-knock reply actions with
+	CategoryKnockKnock = "KNOCK_KNOCK"
+)
+
+var (
+	// ErrNoDevices is returned when the person has no enabled push device.
+	ErrNoDevices = errors.New("push: no enabled devices")
+)
+
+// Config holds the push services configuration values
+type Config struct {
+	// Provider authentication token sent to APNs
+	APNsToken string
+	// Bundle id of the iOS app
+	APNsTopic string
+	// Base URL of APNs, defaults to DefaultAPNsURL
+	APNsURL string
+	// FCM server key
+	FCMKey string
+	// FCM send endpoint, defaults to DefaultFCMURL
+	FCMURL string
+}
+
+// Push notifier
+type Push struct {
+	Account      *doorbot.Account
+	Repositories doorbot.Repositories
+	Devices      []*doorbot.PushDevice
+	APNsToken    string
+	APNsTopic    string
+	APNsURL      string
+	FCMKey       string
+	FCMURL       string
+	Client       *http.Client
+}
+
+// Error is returned when a push service rejects a notification.
+type Error struct {
+	Platform   string
+	StatusCode int
+	Reason     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("push: %s rejected the notification: %s (%d)", e.Platform, e.Reason, e.StatusCode)
+}
+
+// Unregistered tells if the push service will never accept the device token again.
+func (e *Error) Unregistered() bool {
+	switch e.Reason {
+	case "Unregistered", "BadDeviceToken", "NotRegistered", "InvalidRegistration":
+		return true
+	}
+
+	return e.StatusCode == http.StatusGone
+}
+
+type apnsAlert struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type apnsPayload struct {
+	APS struct {
+		Alert    apnsAlert `json:"alert"`
+		Sound    string    `json:"sound"`
+		Category string    `json:"category"`
+	} `json:"aps"`
+	Data *data `json:"doorbot"`
+}
+
+type fcmPayload struct {
+	To           string `json:"to"`
+	Priority     string `json:"priority"`
+	Notification struct {
+		Title       string `json:"title"`
+		Body        string `json:"body"`
+		ClickAction string `json:"click_action"`
+	} `json:"notification"`
+	Data *data `json:"data"`
+}
+
+type fcmResponse struct {
+	Results []struct {
+		MessageID string `json:"message_id"`
+		Error     string `json:"error"`
+	} `json:"results"`
+}
+
+// data is the custom payload the apps use to answer the visitor.
+type data struct {
+	NotificationID uint              `json:"notification_id,omitempty"`
+	DoorID         uint              `json:"door_id"`
+	VisitID        uint              `json:"visit_id,omitempty"`
+	Links          map[string]string `json:"links,omitempty"`
+}
+
+// New creates a Push instance reaching the enabled devices among `devices`.
+func New(a *doorbot.Account, r doorbot.Repositories, devices []*doorbot.PushDevice, c Config) *Push {
+	apns := c.APNsURL
+	if len(apns) == 0 {
+		apns = DefaultAPNsURL
+	}
+
+	fcm := c.FCMURL
+	if len(fcm) == 0 {
+		fcm = DefaultFCMURL
+	}
+
+	var enabled []*doorbot.PushDevice
+	for _, device := range devices {
+		if device.IsEnabled {
+			enabled = append(enabled, device)
+		}
+	}
+
+	return &Push{
+		Account:      a,
+		Repositories: r,
+		Devices:      enabled,
+		APNsToken:    c.APNsToken,
+		APNsTopic:    c.APNsTopic,
+		APNsURL:      strings.TrimSuffix(apns, "/"),
+		FCMKey:       c.FCMKey,
+		FCMURL:       fcm,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the notifier name
+func (n *Push) Name() string {
+	return "Push"
+}
+
+// KnockKnock notifies every enabled device of the person.
+// The notification is delivered when at least one device accepted it, the id of the first message is returned.
+func (n *Push) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id": n.Account.ID,
+		"person_id":  p.ID,
+		"door_id":    d.ID,
+		"devices":    len(n.Devices),
+	}).Info("Notificator::Push->KnockKnock request")
+
+	if len(n.Devices) == 0 {
+		return "", ErrNoDevices
+	}
+
+	message := templates.Render(templates.For(n.Account, k, doorbot.NotificationChannelApp), templates.Data(n.Account, d, p, k))
+
+	custom := &data{DoorID: d.ID, Links: k.Links}
+	if k.Notification != nil {
+		custom.NotificationID = k.Notification.ID
+	}
+
+	if k.Visit != nil {
+		custom.VisitID = k.Visit.ID
+	}
+
+	var (
+		id  string
+		err error
+	)
+
+	delivered := false
+
+	for _, device := range n.Devices {
+		var messageID string
+
+		switch device.Platform {
+		case doorbot.PushPlatformAPNs:
+			messageID, err = n.apns(device, message.Subject, message.Text, custom)
+		case doorbot.PushPlatformFCM:
+			messageID, err = n.fcm(device, message.Subject, message.Text, custom)
+		default:
+			err = fmt.Errorf("push: unknown platform %q", device.Platform)
+		}
+
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":          err,
+				"account_id":     n.Account.ID,
+				"person_id":      p.ID,
+				"door_id":        d.ID,
+				"push_device_id": device.ID,
+				"platform":       device.Platform,
+			}).Warn("Notificator::Push->KnockKnock device error")
+
+			if e, ok := err.(*Error); ok && e.Unregistered() {
+				n.disable(device)
+			}
+
+			continue
+		}
+
+		if !delivered {
+			id = messageID
+			delivered = true
+		}
+	}
+
+	if !delivered {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// apns sends an alert to an iOS device and returns the apns-id assigned to the notification.
+func (n *Push) apns(device *doorbot.PushDevice, title string, body string, custom *data) (string, error) {
+	payload := apnsPayload{Data: custom}
+	payload.APS.Alert = apnsAlert{Title: title, Body: body}
+	payload.APS.Sound = "default"
+	payload.APS.Category = CategoryKnockKnock
+
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", n.APNsURL+"/3/device/"+device.Token, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+n.APNsToken)
+	req.Header.Set("apns-topic", n.APNsTopic)
+	req.Header.Set("apns-priority", "10")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var r struct {
+			Reason string `json:"reason"`
+		}
+
+		response, _ := ioutil.ReadAll(resp.Body)
+		json.Unmarshal(response, &r)
+
+		return "", &Error{Platform: doorbot.PushPlatformAPNs, StatusCode: resp.StatusCode, Reason: r.Reason}
+	}
+
+	return resp.Header.Get("apns-id"), nil
+}
+
+// fcm sends a notification to an Android device and returns the FCM message id.
+func (n *Push) fcm(device *doorbot.PushDevice, title string, body string, custom *data) (string, error) {
+	payload := fcmPayload{To: device.Token, Priority: "high", Data: custom}
+	payload.Notification.Title = title
+	payload.Notification.Body = body
+	payload.Notification.ClickAction = CategoryKnockKnock
+
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", n.FCMURL, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "key="+n.FCMKey)
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &Error{Platform: doorbot.PushPlatformFCM, StatusCode: resp.StatusCode, Reason: http.StatusText(resp.StatusCode)}
+	}
+
+	var r fcmResponse
+
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return "", err
+	}
+
+	if len(r.Results) == 0 {
+		return "", fmt.Errorf("push: no results in the fcm response")
+	}
+
+	if len(r.Results[0].Error) > 0 {
+		return "", &Error{Platform: doorbot.PushPlatformFCM, StatusCode: resp.StatusCode, Reason: r.Results[0].Error}
+	}
+
+	return r.Results[0].MessageID, nil
+}
+
+// disable a device the push service no longer accepts so it is skipped from now on.
+func (n *Push) disable(device *doorbot.PushDevice) {
+	device.IsEnabled = false
+
+	if n.Repositories == nil {
+		return
+	}
+
+	_, err := n.Repositories.PushDeviceRepository().Update(n.Repositories.DB(), device)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":          err,
+			"account_id":     n.Account.ID,
+			"push_device_id": device.ID,
+			"step":           "push-device-update",
+		}).Error("Notificator::Push->disable database error")
+	}
+}
@@ -0,0 +1,93 @@
+package push
+
+import (
+	"encoding/json"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKnockKnock(t *testing.T) {
+	var (
+		apns apnsPayload
+		fcm  fcmPayload
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/3/device/ios-token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "bearer apns-token", req.Header.Get("Authorization"))
+		assert.Equal(t, "com.example.doorbot", req.Header.Get("apns-topic"))
+		json.NewDecoder(req.Body).Decode(&apns)
+
+		w.Header().Set("apns-id", "apns-42")
+	})
+	mux.HandleFunc("/fcm/send", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "key=fcm-key", req.Header.Get("Authorization"))
+		json.NewDecoder(req.Body).Decode(&fcm)
+
+		w.Write([]byte(`{"success": 1, "failure": 0, "results": [{"message_id": "fcm-7"}]}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	devices := []*doorbot.PushDevice{
+		&doorbot.PushDevice{ID: 1, Platform: doorbot.PushPlatformAPNs, Token: "ios-token", IsEnabled: true},
+		&doorbot.PushDevice{ID: 2, Platform: doorbot.PushPlatformFCM, Token: "android-token", IsEnabled: true},
+		&doorbot.PushDevice{ID: 3, Platform: doorbot.PushPlatformFCM, Token: "old-token"},
+	}
+
+	n := New(&doorbot.Account{ID: 3}, nil, devices, Config{
+		APNsToken: "apns-token",
+		APNsTopic: "com.example.doorbot",
+		APNsURL:   server.URL,
+		FCMKey:    "fcm-key",
+		FCMURL:    server.URL + "/fcm/send",
+	})
+
+	assert.Len(t, n.Devices, 2)
+
+	k := &doorbot.KnockKnock{
+		Notification: &doorbot.Notification{ID: 12},
+		Visitor:      &doorbot.Visitor{Name: "Bob"},
+	}
+
+	id, err := n.KnockKnock(&doorbot.Door{ID: 2, Name: "Lobby"}, &doorbot.Person{ID: 5}, k)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "apns-42", id)
+
+	assert.Equal(t, "Bob is waiting at the Lobby.", apns.APS.Alert.Body)
+	assert.Equal(t, CategoryKnockKnock, apns.APS.Category)
+	assert.Equal(t, uint(12), apns.Data.NotificationID)
+
+	assert.Equal(t, "android-token", fcm.To)
+	assert.Equal(t, "Bob is waiting at the Lobby.", fcm.Notification.Body)
+	assert.Equal(t, uint(2), fcm.Data.DoorID)
+}
+
+func TestKnockKnockUnregistered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusGone)
+		w.Write([]byte(`{"reason": "Unregistered"}`))
+	}))
+	defer server.Close()
+
+	device := &doorbot.PushDevice{ID: 1, Platform: doorbot.PushPlatformAPNs, Token: "ios-token", IsEnabled: true}
+
+	n := New(&doorbot.Account{ID: 3}, nil, []*doorbot.PushDevice{device}, Config{APNsURL: server.URL})
+
+	_, err := n.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{ID: 5}, &doorbot.KnockKnock{})
+
+	assert.Equal(t, &Error{Platform: doorbot.PushPlatformAPNs, StatusCode: http.StatusGone, Reason: "Unregistered"}, err)
+	assert.False(t, device.IsEnabled)
+}
+
+func TestKnockKnockNoDevices(t *testing.T) {
+	n := New(&doorbot.Account{ID: 3}, nil, nil, Config{})
+
+	_, err := n.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{ID: 5}, &doorbot.KnockKnock{})
+	assert.Equal(t, ErrNoDevices, err)
+}
@@ -0,0 +1,457 @@
+package notifications
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/availability"
+	"time"
+)
+
+// Queue delivers the notifications queued by the Notificator using a pool of workers.
+// Workers poll the notifications table, which lets several API instances share the load.
+type Queue struct {
+	Config       doorbot.NotificatorConfig
+	Repositories func() doorbot.Repositories
+	// Server configuration, provides the provider credentials, the mail relay and the links secret
+	Server *doorbot.DoorbotConfig
+
+	stop chan struct{}
+}
+
+// NewQueue creates a new Queue. The repositories function must return a new Repositories instance on every call.
+func NewQueue(c doorbot.NotificatorConfig, r func() doorbot.Repositories) *Queue {
+	return &Queue{
+		Config:       c,
+		Repositories: r,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start the queue workers
+func (q *Queue) Start() {
+	log.WithFields(log.Fields{
+		"workers": q.Config.Workers,
+	}).Info("Notificator::Queue->Start starting workers")
+
+	for i := 0; i < q.Config.Workers; i++ {
+		go q.work(i)
+	}
+}
+
+// Stop the queue workers
+func (q *Queue) Stop() {
+	close(q.stop)
+}
+
+// Backoff computes the delay before the next delivery attempt.
+// The delay doubles on every attempt, starting at `base` and capped to `max`.
+func Backoff(attempts uint, base time.Duration, max time.Duration) time.Duration {
+	delay := base
+
+	for i := uint(1); i < attempts; i++ {
+		delay *= 2
+
+		if delay >= max {
+			return max
+		}
+	}
+
+	if delay > max {
+		return max
+	}
+
+	return delay
+}
+
+func (q *Queue) work(worker int) {
+	ticker := time.NewTicker(q.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			// Keep working while there are notifications to deliver.
+			for q.poll(worker) {
+			}
+		}
+	}
+}
+
+// poll claims a single notification and delivers it. Returns false when nothing was claimed.
+func (q *Queue) poll(worker int) bool {
+	r := q.Repositories()
+
+	notifications, err := r.NotificationRepository().Claim(r.DB(), 1, q.Config.LockTimeout)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err,
+			"worker": worker,
+			"step":   "notification-claim",
+		}).Error("Notificator::Queue->poll database error")
+
+		return false
+	}
+
+	if len(notifications) == 0 {
+		return false
+	}
+
+	for _, notification := range notifications {
+		q.process(notification)
+	}
+
+	return true
+}
+
+// process delivers a claimed notification and schedules a retry when every channel failed.
+// People using an escalation policy are notified one step at a time.
+// Knock-knocks left unacknowledged past the account fallback delay are rerouted.
+func (q *Queue) process(notification *doorbot.Notification) {
+	r := q.Repositories()
+	r.SetAccountScope(notification.AccountID)
+
+	defer func() {
+		if e := recover(); e != nil {
+			log.WithFields(log.Fields{
+				"error":           e,
+				"account_id":      notification.AccountID,
+				"notification_id": notification.ID,
+			}).Error("Notificator::Queue->process panic")
+
+			q.retry(r, notification, fmt.Sprintf("%v", e))
+		}
+	}()
+
+	account, err := r.AccountRepository().Find(r.DB(), notification.AccountID)
+	if err != nil {
+		q.retry(r, notification, err.Error())
+		return
+	}
+
+	door, err := r.DoorRepository().Find(r.DB(), notification.DoorID)
+	if err != nil {
+		q.retry(r, notification, err.Error())
+		return
+	}
+
+	person, err := r.PersonRepository().Find(r.DB(), notification.PersonID)
+	if err != nil {
+		q.retry(r, notification, err.Error())
+		return
+	}
+
+	if account == nil || door == nil || person == nil {
+		q.bury(r, notification, "The account, door or person no longer exists.")
+		return
+	}
+
+	if notification.RerouteAt != nil {
+		q.reroute(r, account, notification, person)
+		return
+	}
+
+	k, err := q.knockKnock(r, notification)
+	if err != nil {
+		q.retry(r, notification, err.Error())
+		return
+	}
+
+	n := &notificator{
+		Config: NewConfig(account, r, q.Server),
+	}
+
+	if person.NotificationsMode == doorbot.NotificationsModeEscalate {
+		steps, err := n.policy(person)
+		if err != nil {
+			q.retry(r, notification, err.Error())
+			return
+		}
+
+		if len(steps) > 0 {
+			q.escalate(r, n, k, steps, door, person)
+			return
+		}
+	}
+
+	attempts, delivered := n.deliver(k, door, person)
+
+	notification.Attempts++
+	q.record(r, notification, attempts)
+
+	if len(attempts) == 0 {
+		q.bury(r, notification, ErrNoChannels.Error())
+		return
+	}
+
+	if !delivered {
+		q.schedule(r, notification, attempts[len(attempts)-1].Error)
+		return
+	}
+
+	now := time.Now()
+	notification.Status = doorbot.NotificationDelivered
+	notification.DeliveredAt = &now
+	notification.LockedAt = nil
+	notification.LastError = ""
+
+	q.await(account, notification)
+	q.save(r, notification)
+}
+
+// escalate delivers the current step of the escalation policy and schedules the next one.
+// A step whose channels keep failing is skipped once all of its attempts are used.
+func (q *Queue) escalate(r doorbot.Repositories, n *notificator, k *doorbot.KnockKnock, steps []*doorbot.EscalationStep, d *doorbot.Door, p *doorbot.Person) {
+	notification := k.Notification
+
+	if notification.AcknowledgedAt != nil || int(notification.Step) >= len(steps) {
+		q.finish(r, n.Config.Account, notification)
+		return
+	}
+
+	step := steps[notification.Step]
+	recipient := n.recipient(step, p)
+
+	attempts, delivered := n.try(k, n.notifiers(step.Channel, d, recipient), d, recipient)
+
+	notification.Attempts++
+	q.record(r, notification, attempts)
+
+	if delivered {
+		now := time.Now()
+		if notification.DeliveredAt == nil {
+			notification.DeliveredAt = &now
+		}
+		notification.LastError = ""
+	} else {
+		reason := ErrNoChannels.Error()
+		if len(attempts) > 0 {
+			reason = attempts[len(attempts)-1].Error
+		}
+
+		notification.LastError = reason
+
+		if len(attempts) > 0 && notification.Attempts < uint(q.Config.MaxAttempts) {
+			q.schedule(r, notification, reason)
+			return
+		}
+	}
+
+	notification.Step++
+	notification.Attempts = 0
+
+	if int(notification.Step) >= len(steps) {
+		q.finish(r, n.Config.Account, notification)
+		return
+	}
+
+	next := notification.CreatedAt.Add(time.Duration(steps[notification.Step].Delay) * time.Second)
+	if next.Before(time.Now()) {
+		next = time.Now()
+	}
+
+	notification.Status = doorbot.NotificationEscalating
+	notification.NextAttemptAt = next
+	notification.LockedAt = nil
+
+	log.WithFields(log.Fields{
+		"account_id":      notification.AccountID,
+		"notification_id": notification.ID,
+		"escalation_step": notification.Step,
+		"next_attempt_at": notification.NextAttemptAt,
+	}).Info("Notificator::Queue->escalate next step scheduled")
+
+	q.save(r, notification)
+}
+
+// knockKnock gathers the details handed to the notifiers, including the visit when known.
+func (q *Queue) knockKnock(r doorbot.Repositories, notification *doorbot.Notification) (*doorbot.KnockKnock, error) {
+	k := &doorbot.KnockKnock{Notification: notification}
+
+	if notification.VisitID == nil {
+		return k, nil
+	}
+
+	visit, err := r.VisitRepository().Find(r.DB(), *notification.VisitID)
+	if err != nil || visit == nil {
+		return k, err
+	}
+
+	visitor, err := r.VisitorRepository().Find(r.DB(), visit.VisitorID)
+
+	k.Visit = visit
+	k.Visitor = visitor
+
+	return k, err
+}
+
+// finish closes an escalated notification. It is dead when no step could be delivered.
+func (q *Queue) finish(r doorbot.Repositories, a *doorbot.Account, notification *doorbot.Notification) {
+	if notification.DeliveredAt == nil {
+		q.bury(r, notification, notification.LastError)
+		return
+	}
+
+	notification.Status = doorbot.NotificationDelivered
+	notification.LockedAt = nil
+
+	q.await(a, notification)
+	q.save(r, notification)
+}
+
+// await schedules the reroute of a delivered knock-knock once the account fallback delay is over.
+// It stays delivered, the queue claims it back at its reroute time unless it was acknowledged meanwhile.
+// Knock-knocks already acknowledged or rerouted are not rerouted again.
+func (q *Queue) await(a *doorbot.Account, notification *doorbot.Notification) {
+	if a.NotificationsFallbackDelay == 0 || notification.AcknowledgedAt != nil || notification.ReroutedFromPersonID != nil {
+		return
+	}
+
+	at := time.Now().Add(time.Duration(a.NotificationsFallbackDelay) * time.Second)
+
+	notification.RerouteAt = &at
+	notification.Attempts = 0
+}
+
+// reroute hands an unacknowledged knock-knock to the first available delegate of the person, or the reception.
+// It is then delivered from the start to who takes it. The knock-knock stays delivered when nobody can.
+func (q *Queue) reroute(r doorbot.Repositories, a *doorbot.Account, notification *doorbot.Notification, p *doorbot.Person) {
+	var delegate *doorbot.Person
+
+	if notification.AcknowledgedAt == nil {
+		var err error
+
+		delegate, err = availability.Fallback(r, a, p)
+		if err != nil {
+			q.retry(r, notification, err.Error())
+			return
+		}
+	}
+
+	notification.RerouteAt = nil
+	notification.LockedAt = nil
+
+	if delegate == nil {
+		notification.Status = doorbot.NotificationDelivered
+
+		q.save(r, notification)
+		return
+	}
+
+	notification.ReroutedFromPersonID = &p.ID
+	notification.PersonID = delegate.ID
+	notification.Status = doorbot.NotificationPending
+	notification.NextAttemptAt = time.Now()
+	notification.Attempts = 0
+	notification.Step = 0
+	notification.DeliveredAt = nil
+	notification.LastError = ""
+
+	log.WithFields(log.Fields{
+		"account_id":      notification.AccountID,
+		"notification_id": notification.ID,
+		"person_id":       p.ID,
+		"delegate_id":     delegate.ID,
+	}).Info("Notificator::Queue->reroute knock-knock rerouted")
+
+	q.save(r, notification)
+}
+
+// record saves the attempts made during a delivery. Delivered attempts are also added to the events log.
+func (q *Queue) record(r doorbot.Repositories, notification *doorbot.Notification, attempts []*doorbot.NotificationAttempt) {
+	for _, attempt := range attempts {
+		attempt.NotificationID = notification.ID
+		attempt.Attempt = notification.Attempts
+
+		err := r.NotificationAttemptRepository().Create(r.DB(), attempt)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":           err,
+				"account_id":      notification.AccountID,
+				"notification_id": notification.ID,
+				"channel":         attempt.Channel,
+				"step":            "notification-attempt-create",
+			}).Error("Notificator::Queue->record database error")
+		}
+
+		if attempt.Status != doorbot.NotificationAttemptDelivered {
+			continue
+		}
+
+		event := doorbot.NewEvent(notification.AccountID, 0, notification.DoorID, attempt.EventID, notification.PersonID)
+		event.Metadata = doorbot.Metadata{"notification_id": notification.ID, "channel": attempt.Channel}
+
+		err = r.EventRepository().Create(r.DB(), event)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":           err,
+				"account_id":      notification.AccountID,
+				"notification_id": notification.ID,
+				"channel":         attempt.Channel,
+				"step":            "event-create",
+			}).Error("Notificator::Queue->record database error")
+		}
+	}
+}
+
+// retry counts a failed attempt that happened before any channel was tried.
+func (q *Queue) retry(r doorbot.Repositories, notification *doorbot.Notification, reason string) {
+	notification.Attempts++
+	q.schedule(r, notification, reason)
+}
+
+// schedule the next attempt or bury the notification once all attempts are used.
+func (q *Queue) schedule(r doorbot.Repositories, notification *doorbot.Notification, reason string) {
+	if notification.Attempts >= uint(q.Config.MaxAttempts) {
+		q.bury(r, notification, reason)
+		return
+	}
+
+	notification.Status = doorbot.NotificationPending
+	notification.NextAttemptAt = time.Now().Add(Backoff(notification.Attempts, q.Config.RetryDelay, q.Config.MaxRetryDelay))
+	notification.LockedAt = nil
+	notification.LastError = reason
+
+	log.WithFields(log.Fields{
+		"account_id":      notification.AccountID,
+		"notification_id": notification.ID,
+		"attempts":        notification.Attempts,
+		"next_attempt_at": notification.NextAttemptAt,
+		"reason":          reason,
+	}).Warn("Notificator::Queue->schedule retry scheduled")
+
+	q.save(r, notification)
+}
+
+// bury moves the notification to the dead-letter state.
+func (q *Queue) bury(r doorbot.Repositories, notification *doorbot.Notification, reason string) {
+	notification.Status = doorbot.NotificationDead
+	notification.LockedAt = nil
+	notification.LastError = reason
+
+	log.WithFields(log.Fields{
+		"account_id":      notification.AccountID,
+		"notification_id": notification.ID,
+		"attempts":        notification.Attempts,
+		"reason":          reason,
+	}).Error("Notificator::Queue->bury notification is dead")
+
+	q.save(r, notification)
+}
+
+// save the delivery state of a notification. An acknowledgement received during the delivery is kept.
+func (q *Queue) save(r doorbot.Repositories, notification *doorbot.Notification) {
+	_, err := r.NotificationRepository().UpdateDelivery(r.DB(), notification)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"account_id":      notification.AccountID,
+			"notification_id": notification.ID,
+			"status":          notification.Status,
+			"step":            "notification-update",
+		}).Error("Notificator::Queue->save database error")
+	}
+}
@@ -0,0 +1,70 @@
+package notifications
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	base := 5 * time.Second
+	max := time.Minute
+
+	assert.Equal(t, 5*time.Second, Backoff(1, base, max))
+	assert.Equal(t, 10*time.Second, Backoff(2, base, max))
+	assert.Equal(t, 20*time.Second, Backoff(3, base, max))
+	assert.Equal(t, 40*time.Second, Backoff(4, base, max))
+	assert.Equal(t, time.Minute, Backoff(5, base, max))
+	assert.Equal(t, time.Minute, Backoff(50, base, max))
+}
+
+func TestAwait(t *testing.T) {
+	q := &Queue{}
+	account := &doorbot.Account{NotificationsFallbackDelay: 120}
+
+	notification := &doorbot.Notification{Status: doorbot.NotificationDelivered, Attempts: 2}
+	q.await(account, notification)
+
+	assert.Equal(t, doorbot.NotificationDelivered, notification.Status)
+	assert.Equal(t, uint(0), notification.Attempts)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), *notification.RerouteAt, time.Second)
+
+	// Rerouted and acknowledged knock-knocks stay delivered, as do all of them when the account has no delay.
+	host := uint(4)
+	now := time.Now()
+
+	for _, c := range []struct {
+		account      *doorbot.Account
+		notification *doorbot.Notification
+	}{
+		{account, &doorbot.Notification{Status: doorbot.NotificationDelivered, ReroutedFromPersonID: &host}},
+		{account, &doorbot.Notification{Status: doorbot.NotificationDelivered, AcknowledgedAt: &now}},
+		{&doorbot.Account{}, &doorbot.Notification{Status: doorbot.NotificationDelivered}},
+	} {
+		q.await(c.account, c.notification)
+
+		assert.Equal(t, doorbot.NotificationDelivered, c.notification.Status)
+		assert.Nil(t, c.notification.RerouteAt)
+	}
+}
+
+func TestSaveKeepsAcknowledgement(t *testing.T) {
+	db := new(tests.MockExecutor)
+	repositories := new(tests.MockRepositories)
+	notificationRepo := new(tests.MockNotificationRepository)
+
+	repositories.On("DB").Return(db)
+	repositories.On("NotificationRepository").Return(notificationRepo)
+
+	// Only the delivery fields are saved, an acknowledgement made during the delivery is not overwritten.
+	notification := &doorbot.Notification{ID: 3, AccountID: 1, Status: doorbot.NotificationEscalating}
+	notificationRepo.On("UpdateDelivery", db, notification).Return(true, nil)
+
+	q := &Queue{}
+	q.save(repositories, notification)
+
+	notificationRepo.Mock.AssertExpectations(t)
+	notificationRepo.Mock.AssertNotCalled(t, "Update", db, notification)
+}
@@ -0,0 +1,97 @@
+// Package rocketchat posts knock-knocks to a Rocket.Chat incoming webhook.
+package rocketchat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/mattermost"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RocketChat notifier
+type RocketChat struct {
+	Account *doorbot.Account
+	URL     string
+	Client  *http.Client
+}
+
+type payload struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+	Alias   string `json:"alias"`
+}
+
+type response struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// New creates a RocketChat instance posting to the account incoming webhook
+func New(a *doorbot.Account) *RocketChat {
+	return &RocketChat{
+		Account: a,
+		URL:     a.NotificationsRocketChatURL,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the notifier name
+func (r *RocketChat) Name() string {
+	return "RocketChat"
+}
+
+// KnockKnock posts a message mentioning the person, in the door channel when one is set.
+func (r *RocketChat) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id": r.Account.ID,
+		"person_id":  p.ID,
+		"door_id":    d.ID,
+	}).Info("Notificator::RocketChat->KnockKnock request")
+
+	body, err := json.Marshal(payload{
+		Text:    mattermost.Text(r.Account, d, p, k),
+		Channel: channel(d.NotificationsChatChannel),
+		Alias:   "Doorbot",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := r.Client.Post(r.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("rocketchat: unexpected status code %d", resp.StatusCode)
+	}
+
+	var res response
+
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return "", err
+	}
+
+	if !res.Success {
+		return "", fmt.Errorf("rocketchat: %s", res.Error)
+	}
+
+	return "", nil
+}
+
+// channel prefixes a channel name with # as Rocket.Chat expects it, unless it targets a user.
+func channel(name string) string {
+	if len(name) == 0 || strings.HasPrefix(name, "#") || strings.HasPrefix(name, "@") {
+		return name
+	}
+
+	return "#" + name
+}
@@ -0,0 +1,47 @@
+package rocketchat
+
+import (
+	"encoding/json"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKnockKnock(t *testing.T) {
+	var posted payload
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&posted)
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	r := New(&doorbot.Account{ID: 3, NotificationsRocketChatURL: server.URL})
+
+	_, err := r.KnockKnock(&doorbot.Door{Name: "Lobby", NotificationsChatChannel: "front-desk"}, &doorbot.Person{Name: "Jane", NotificationsChatHandle: "jane"}, &doorbot.KnockKnock{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "#front-desk", posted.Channel)
+	assert.Equal(t, "Hi @jane, Someone is waiting at the Lobby.", posted.Text)
+}
+
+func TestKnockKnockError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"success": false, "error": "invalid-channel"}`))
+	}))
+	defer server.Close()
+
+	r := New(&doorbot.Account{ID: 3, NotificationsRocketChatURL: server.URL})
+
+	_, err := r.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{Name: "Jane"}, &doorbot.KnockKnock{})
+	assert.EqualError(t, err, "rocketchat: invalid-channel")
+}
+
+func TestChannel(t *testing.T) {
+	assert.Equal(t, "", channel(""))
+	assert.Equal(t, "#lobby", channel("lobby"))
+	assert.Equal(t, "#lobby", channel("#lobby"))
+	assert.Equal(t, "@jane", channel("@jane"))
+}
@@ -1,29 +1,280 @@
 package slack
 
-import(
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
 	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/bridges"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// DefaultURL is the base URL of the Slack Web API
+const DefaultURL = "https://slack.com/api"
+
+// ErrUserNotFound is returned when the person cannot be matched to a Slack user.
+var ErrUserNotFound = errors.New("slack: user not found")
+
+// Config holds Slack configuration values
 type Config struct {
 	Token string
+	// Base URL of the Slack Web API, defaults to DefaultURL
+	URL string
 }
 
+// Slack notifier
 type Slack struct {
-	Account *doorbot.Account
-	Token string
+	Account      *doorbot.Account
+	Repositories doorbot.Repositories
+	Token        string
+	URL          string
+	Client       *http.Client
+}
+
+// Error is returned when the Slack API rejects a call.
+// StatusCode is set instead of the code when the API answered with an unexpected HTTP status.
+type Error struct {
+	Method     string
+	Code       string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("slack: %s unexpected status code %d", e.Method, e.StatusCode)
+	}
+
+	return fmt.Sprintf("slack: %s failed: %s", e.Method, e.Code)
+}
+
+// Temporary tells if Slack itself failed, rather than rejecting the call.
+func (e *Error) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == 429 || e.Code == "ratelimited" || e.Code == "internal_error"
+}
+
+type response struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+type attachment struct {
+	Fallback   string   `json:"fallback"`
+	Color      string   `json:"color,omitempty"`
+	Fields     []field  `json:"fields,omitempty"`
+	CallbackID string   `json:"callback_id,omitempty"`
+	Actions    []action `json:"actions,omitempty"`
 }
 
-func New(a *doorbot.Account, c Config) *Slack {
+type field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type action struct {
+	Name  string `json:"name"`
+	Text  string `json:"text"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Style string `json:"style,omitempty"`
+}
+
+// New creates a Slack instance.
+// The repositories are used to find the Slack user linked to a person, they may be nil.
+func New(a *doorbot.Account, r doorbot.Repositories, c Config) *Slack {
+	base := c.URL
+	if len(base) == 0 {
+		base = DefaultURL
+	}
+
 	return &Slack{
-		Account: a,
-		Token: c.Token,
+		Account:      a,
+		Repositories: r,
+		Token:        c.Token,
+		URL:          strings.TrimSuffix(base, "/"),
+		Client:       &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
+// Name returns the notifier name
 func (s *Slack) Name() string {
 	return "Slack"
 }
 
-func (s *Slack) KnockKnock(d *doorbot.Door, p *doorbot.Person) error {
-	return nil
+// KnockKnock sends a direct message to the person with the door and visitor details.
+// Returns the timestamp of the posted message, which Slack uses as the message id.
+func (s *Slack) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id": s.Account.ID,
+		"person_id":  p.ID,
+		"door_id":    d.ID,
+	}).Info("Notificator::Slack->KnockKnock request")
+
+	userID, err := s.user(p)
+	if err != nil {
+		return "", err
+	}
+
+	var im struct {
+		Channel struct {
+			ID string `json:"id"`
+		} `json:"channel"`
+	}
+
+	err = s.call("im.open", url.Values{"user": {userID}}, &im)
+	if err != nil {
+		return "", err
+	}
+
+	text, attachments := message(s.Account, d, p, k)
+
+	encoded, err := json.Marshal(attachments)
+	if err != nil {
+		return "", err
+	}
+
+	var posted struct {
+		TS string `json:"ts"`
+	}
+
+	values := url.Values{
+		"channel":     {im.Channel.ID},
+		"text":        {text},
+		"attachments": {string(encoded)},
+		"as_user":     {"true"},
+	}
+
+	err = s.call("chat.postMessage", values, &posted)
+	if err != nil {
+		return "", err
+	}
+
+	return posted.TS, nil
+}
+
+// user returns the Slack user id of a person, using the Slack bridge link first and the email address otherwise.
+func (s *Slack) user(p *doorbot.Person) (string, error) {
+	if s.Repositories != nil && p.ID != 0 {
+		r := s.Repositories
+
+		bu, err := r.BridgeUserRepository().FindByPersonIDAndBridgeID(r.DB(), p.ID, bridges.BridgeSlack)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"account_id": s.Account.ID,
+				"person_id":  p.ID,
+				"step":       "bridge-user-find",
+			}).Error("Notificator::Slack->user database error")
+
+			return "", err
+		}
+
+		if bu != nil && len(bu.UserID) > 0 {
+			return bu.UserID, nil
+		}
+	}
+
+	if len(p.Email) == 0 {
+		return "", ErrUserNotFound
+	}
+
+	var lookup struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+
+	err := s.call("users.lookupByEmail", url.Values{"email": {p.Email}}, &lookup)
+	if e, ok := err.(*Error); ok && e.Code == "users_not_found" {
+		return "", ErrUserNotFound
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return lookup.User.ID, nil
+}
+
+// call posts a Web API method and decodes the reply into `out`. A reply that is not ok is returned as an *Error.
+func (s *Slack) call(method string, values url.Values, out interface{}) error {
+	values.Set("token", s.Token)
+
+	resp, err := s.Client.PostForm(s.URL+"/"+method, values)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &Error{Method: method, StatusCode: resp.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var r response
+
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return err
+	}
+
+	if !r.OK {
+		log.WithFields(log.Fields{
+			"account_id": s.Account.ID,
+			"method":     method,
+			"error":      r.Error,
+		}).Error("Notificator::Slack->call api error")
+
+		return &Error{Method: method, Code: r.Error}
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+// message renders the text and attachment of a knock-knock.
+// Reply buttons are added when the knock-knock has a token, acknowledgement links otherwise.
+func message(account *doorbot.Account, d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, []attachment) {
+	data := templates.Data(account, d, p, k)
+	text := templates.Render(templates.For(account, k, doorbot.NotificationChannelChat), data).Text
+
+	a := attachment{
+		Fallback: text,
+		Color:    "#36a64f",
+		Fields: []field{
+			{Title: "Door", Value: d.Name, Short: true},
+			{Title: "Visitor", Value: data["visitor"], Short: true},
+		},
+	}
+
+	if k.Visitor != nil && len(k.Visitor.Company) > 0 {
+		a.Fields = append(a.Fields, field{Title: "Company", Value: k.Visitor.Company, Short: true})
+	}
+
+	if k.Visit != nil {
+		a.Fields = append(a.Fields, field{Title: "Arrived", Value: k.Visit.CheckedInAt.UTC().Format("15:04 MST"), Short: true})
+	}
+
+	if len(k.Token) > 0 {
+		a.CallbackID = k.Token
+		a.Actions = []action{
+			{Name: "reply", Text: "On my way", Type: "button", Value: doorbot.AcknowledgementOnMyWay, Style: "primary"},
+			{Name: "reply", Text: "Please wait", Type: "button", Value: doorbot.AcknowledgementPleaseWait},
+			{Name: "reply", Text: "Unavailable", Type: "button", Value: doorbot.AcknowledgementUnavailable, Style: "danger"},
+		}
+	} else if link, ok := k.Links[doorbot.AcknowledgementOnMyWay]; ok {
+		text += fmt.Sprintf("\n<%s|Let them know you are on your way>", link)
+	}
+
+	return text, []attachment{a}
 }
@@ -0,0 +1,97 @@
+package slack
+
+import (
+	"encoding/json"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestKnockKnock(t *testing.T) {
+	var posted url.Values
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users.lookupByEmail", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "xoxb-account", req.FormValue("token"))
+		assert.Equal(t, "jane@example.com", req.FormValue("email"))
+		w.Write([]byte(`{"ok": true, "user": {"id": "U42"}}`))
+	})
+	mux.HandleFunc("/im.open", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "U42", req.FormValue("user"))
+		w.Write([]byte(`{"ok": true, "channel": {"id": "D7"}}`))
+	})
+	mux.HandleFunc("/chat.postMessage", func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		posted = req.PostForm
+		w.Write([]byte(`{"ok": true, "ts": "1437652800.000002"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	account := &doorbot.Account{ID: 3}
+	s := New(account, nil, Config{Token: "xoxb-account", URL: server.URL + "/"})
+
+	k := &doorbot.KnockKnock{
+		Visitor: &doorbot.Visitor{Name: "Bob", Company: "ACME"},
+		Token:   "12:chat:1437652800:signature",
+	}
+
+	ts, err := s.KnockKnock(&doorbot.Door{ID: 2, Name: "Lobby"}, &doorbot.Person{ID: 5, Name: "Jane", Email: "jane@example.com"}, k)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1437652800.000002", ts)
+	assert.Equal(t, "D7", posted.Get("channel"))
+	assert.Equal(t, "Hi Jane, Bob is waiting at the Lobby.", posted.Get("text"))
+
+	var attachments []attachment
+	assert.Nil(t, json.Unmarshal([]byte(posted.Get("attachments")), &attachments))
+	assert.Len(t, attachments, 1)
+	assert.Equal(t, "12:chat:1437652800:signature", attachments[0].CallbackID)
+	assert.Len(t, attachments[0].Actions, 3)
+	assert.Equal(t, []field{
+		{Title: "Door", Value: "Lobby", Short: true},
+		{Title: "Visitor", Value: "Bob", Short: true},
+		{Title: "Company", Value: "ACME", Short: true},
+	}, attachments[0].Fields)
+}
+
+func TestKnockKnockAPIError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users.lookupByEmail", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"ok": true, "user": {"id": "U42"}}`))
+	})
+	mux.HandleFunc("/im.open", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"ok": false, "error": "invalid_auth"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s := New(&doorbot.Account{ID: 3}, nil, Config{Token: "xoxb-config", URL: server.URL})
+
+	_, err := s.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{Email: "jane@example.com"}, &doorbot.KnockKnock{})
+
+	assert.Equal(t, &Error{Method: "im.open", Code: "invalid_auth"}, err)
+}
+
+func TestKnockKnockUserNotFound(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users.lookupByEmail", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"ok": false, "error": "users_not_found"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s := New(&doorbot.Account{ID: 3}, nil, Config{URL: server.URL})
+
+	_, err := s.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{Email: "jane@example.com"}, &doorbot.KnockKnock{})
+	assert.Equal(t, ErrUserNotFound, err)
+
+	_, err = s.KnockKnock(&doorbot.Door{Name: "Lobby"}, &doorbot.Person{}, &doorbot.KnockKnock{})
+	assert.Equal(t, ErrUserNotFound, err)
+}
@@ -0,0 +1,276 @@
+// Package smtp emails notifications through a mail relay, for deployments without Postmark or Mailgun.
+package smtp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	"net"
+	"net/mail"
+	netsmtp "net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP notifier
+type SMTP struct {
+	Account  *doorbot.Account
+	Host     string
+	Port     int
+	StartTLS bool
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+// New creates a SMTP instance. The account sender address is used when set, the server one otherwise.
+func New(a *doorbot.Account, c doorbot.SMTPConfig) *SMTP {
+	from := c.From
+	if len(a.NotificationsEmailFrom) > 0 {
+		from = a.NotificationsEmailFrom
+	}
+
+	port := c.Port
+	if port == 0 {
+		port = 25
+	}
+
+	return &SMTP{
+		Account:  a,
+		Host:     c.Host,
+		Port:     port,
+		StartTLS: c.StartTLS,
+		Username: c.Username,
+		Password: c.Password,
+		From:     from,
+		Timeout:  10 * time.Second,
+	}
+}
+
+// Name returns the notifier name
+func (s *SMTP) Name() string {
+	return "SMTP"
+}
+
+// AccountCreated emails the temporary password of a new account owner.
+func (s *SMTP) AccountCreated(p *doorbot.Person, password string) error {
+	log.WithFields(log.Fields{
+		"account_id":   s.Account.ID,
+		"person_id":    p.ID,
+		"person_email": p.Email,
+	}).Info("Notificator::SMTP->AccountCreated")
+
+	body := fmt.Sprintf(
+		"Welcome %s,\n\nYou can log in on the dashboard using this temporary password: %s\n\n\nAccount: %d\nTemporary Host: %s\n Email: %s\nPassword: %s\n\n- Doorbot",
+		p.Name, password, s.Account.ID, s.Account.Host, p.Email, password,
+	)
+
+	_, err := s.Send(p.Email, "Doorbot - Account Created", body, "")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":        err,
+			"account_id":   s.Account.ID,
+			"person_id":    p.ID,
+			"person_email": p.Email,
+		}).Error("Notificator::SMTP->AccountCreated error")
+
+		return err
+	}
+
+	return nil
+}
+
+// KnockKnock emails the person a plain text and HTML message rendered from the email template.
+func (s *SMTP) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id": s.Account.ID,
+		"person_id":  p.ID,
+		"door_id":    d.ID,
+	}).Info("Notificator::SMTP->KnockKnock request")
+
+	message := templates.Render(templates.For(s.Account, k, doorbot.NotificationChannelEmail), templates.Data(s.Account, d, p, k))
+
+	text := message.Text + templates.TextReplies(k) + "\n - Doorbot"
+	html := message.HTML + templates.HTMLReplies(k) + "<p>- Doorbot</p>\n"
+
+	id, err := s.Send(p.Email, message.Subject, text, html)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": s.Account.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
+		}).Error("Notificator::SMTP->KnockKnock error")
+
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Send emails a message through the relay and returns its Message-ID.
+// The message is sent as plain text, or as a multipart/alternative message when an HTML body is given.
+func (s *SMTP) Send(to string, subject string, body string, html string) (string, error) {
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return "", err
+	}
+
+	// Parsing refuses line breaks, the address cannot inject headers or SMTP commands.
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return "", err
+	}
+
+	id := messageID(from.Address)
+	message := s.message(from, recipient, subject, body, html, id, time.Now())
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+
+	conn, err := net.DialTimeout("tcp", addr, s.Timeout)
+	if err != nil {
+		return "", err
+	}
+
+	conn.SetDeadline(time.Now().Add(s.Timeout))
+
+	c, err := netsmtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+
+	defer c.Close()
+
+	if s.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return "", fmt.Errorf("smtp: %s does not support STARTTLS", addr)
+		}
+
+		err = c.StartTLS(&tls.Config{ServerName: s.Host})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(s.Username) > 0 {
+		err = c.Auth(netsmtp.PlainAuth("", s.Username, s.Password, s.Host))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = c.Mail(from.Address)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.Rcpt(recipient.Address)
+	if err != nil {
+		return "", err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = w.Write(message)
+	if err != nil {
+		return "", err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return id, c.Quit()
+}
+
+// message builds the RFC 5322 message sent to the relay.
+func (s *SMTP) message(from *mail.Address, to *mail.Address, subject string, body string, html string, id string, now time.Time) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", to.String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", header(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", id)
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(html) == 0 {
+		part(&b, "text/plain", body)
+		return b.Bytes()
+	}
+
+	boundary := boundary()
+
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=\"%s\"\r\n", boundary)
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	part(&b, "text/plain", body)
+	fmt.Fprintf(&b, "\r\n--%s\r\n", boundary)
+	part(&b, "text/html", html)
+	fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+
+	return b.Bytes()
+}
+
+// part writes the headers and the content of a utf-8 text part.
+func part(b *bytes.Buffer, contentType string, content string) {
+	fmt.Fprintf(b, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(strings.Replace(content, "\r\n", "\n", -1), "\n", "\r\n", -1))
+}
+
+// boundary generates a multipart boundary that cannot appear in the parts.
+func boundary() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return "doorbot-" + hex.EncodeToString(b)
+}
+
+// header encodes a header value containing non ASCII characters ( ex: a visitor name ).
+// Control characters are replaced by spaces so a value cannot break the line and add headers.
+func header(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 {
+			return ' '
+		}
+
+		return r
+	}, value)
+
+	for _, r := range value {
+		if r > 127 {
+			return "=?utf-8?B?" + base64.StdEncoding.EncodeToString([]byte(value)) + "?="
+		}
+	}
+
+	return value
+}
+
+// messageID generates a unique Message-ID in the domain of the sender.
+func messageID(from string) string {
+	domain := "doorbot"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain)
+}
@@ -0,0 +1,154 @@
+package smtp
+
+import (
+	"bufio"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// relay accepts a single message and sends the envelope sender, recipient and data on the returned channel.
+func relay(t *testing.T) (*net.TCPAddr, chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	received := make(chan []string, 1)
+
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var envelope []string
+		data := false
+
+		reply("220 localhost ESMTP")
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimRight(line, "\r\n")
+
+			if data {
+				if line == "." {
+					data = false
+					reply("250 OK")
+					continue
+				}
+
+				envelope[len(envelope)-1] += line + "\n"
+				continue
+			}
+
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(line, "MAIL FROM:"), strings.HasPrefix(line, "RCPT TO:"):
+				envelope = append(envelope, line)
+				reply("250 OK")
+			case line == "DATA":
+				envelope = append(envelope, "")
+				data = true
+				reply("354 Go ahead")
+			case line == "QUIT":
+				reply("221 Bye")
+				received <- envelope
+				return
+			default:
+				reply("502 Not implemented")
+			}
+		}
+	}()
+
+	return l.Addr().(*net.TCPAddr), received
+}
+
+func TestKnockKnock(t *testing.T) {
+	addr, received := relay(t)
+
+	account := &doorbot.Account{ID: 3, NotificationsEmailFrom: "Lobby <lobby@example.com>"}
+	s := New(account, doorbot.SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: "doorbot@example.org"})
+
+	assert.Equal(t, "Lobby <lobby@example.com>", s.From)
+
+	k := &doorbot.KnockKnock{
+		Visitor: &doorbot.Visitor{Name: "Bob"},
+		Links:   map[string]string{doorbot.AcknowledgementOnMyWay: "https://example.com/ack"},
+	}
+
+	id, err := s.KnockKnock(&doorbot.Door{ID: 2, Name: "Lobby"}, &doorbot.Person{ID: 5, Name: "Jane", Email: "jane@example.com"}, k)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(id, "@example.com>"))
+
+	var envelope []string
+	select {
+	case envelope = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	assert.Len(t, envelope, 3)
+	assert.Equal(t, "MAIL FROM:<lobby@example.com>", strings.SplitN(envelope[0], " BODY", 2)[0])
+	assert.Equal(t, "RCPT TO:<jane@example.com>", envelope[1])
+
+	message, err := mail.ReadMessage(strings.NewReader(envelope[2]))
+	assert.Nil(t, err)
+	assert.Equal(t, "Doorbot - Bob is waiting at the Lobby.", message.Header.Get("Subject"))
+	assert.Equal(t, id, message.Header.Get("Message-ID"))
+	assert.Contains(t, envelope[2], "Bob is waiting at the Lobby.")
+	assert.Contains(t, envelope[2], "I'm on my way: https://example.com/ack")
+	assert.True(t, strings.HasPrefix(message.Header.Get("Content-Type"), "multipart/alternative; boundary="))
+	assert.Contains(t, envelope[2], "Content-Type: text/html; charset=utf-8")
+	assert.Contains(t, envelope[2], "<p><strong>Bob</strong> is waiting at the Lobby.</p>")
+	assert.Contains(t, envelope[2], "<li><a href=\"https://example.com/ack\">I'm on my way</a></li>")
+}
+
+func TestNewDefaults(t *testing.T) {
+	s := New(&doorbot.Account{}, doorbot.SMTPConfig{Host: "mail.example.org", From: "doorbot@example.org"})
+
+	assert.Equal(t, 25, s.Port)
+	assert.Equal(t, "doorbot@example.org", s.From)
+}
+
+func TestHeader(t *testing.T) {
+	assert.Equal(t, "Bob is here", header("Bob is here"))
+	assert.Equal(t, "=?utf-8?B?w4lsb2RpZQ==?=", header("Élodie"))
+	assert.Equal(t, "x  Bcc: victim@example.com", header("x\r\nBcc: victim@example.com"))
+}
+
+func TestMessageHeaders(t *testing.T) {
+	s := New(&doorbot.Account{}, doorbot.SMTPConfig{Host: "mail.example.org", From: "doorbot@example.org"})
+
+	from := &mail.Address{Address: "doorbot@example.org"}
+	to := &mail.Address{Address: "jane@example.com"}
+
+	raw := s.message(from, to, "Doorbot - x\r\nBcc: victim@example.com is waiting", "Hello", "", "<1@example.org>", time.Now())
+
+	message, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	assert.Nil(t, err)
+	assert.Equal(t, "<jane@example.com>", message.Header.Get("To"))
+	assert.Empty(t, message.Header.Get("Bcc"))
+	assert.Equal(t, "Doorbot - x  Bcc: victim@example.com is waiting", message.Header.Get("Subject"))
+}
+
+func TestSendInvalidRecipient(t *testing.T) {
+	s := New(&doorbot.Account{}, doorbot.SMTPConfig{Host: "127.0.0.1", From: "doorbot@example.org"})
+
+	_, err := s.Send("jane@example.com\r\nRCPT TO:<victim@example.com>", "Subject", "Hello", "")
+	assert.NotNil(t, err)
+}
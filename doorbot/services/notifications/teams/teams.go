@@ -0,0 +1,159 @@
+// Package teams posts knock-knocks to a Microsoft Teams incoming webhook as adaptive cards.
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Teams notifier
+type Teams struct {
+	Account *doorbot.Account
+	URL     string
+	Client  *http.Client
+}
+
+// Message is the document posted to the incoming webhook
+type Message struct {
+	Type        string       `json:"type"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment wraps the adaptive card
+type Attachment struct {
+	ContentType string `json:"contentType"`
+	Content     Card   `json:"content"`
+}
+
+// Card is an adaptive card
+type Card struct {
+	Schema  string        `json:"$schema"`
+	Type    string        `json:"type"`
+	Version string        `json:"version"`
+	Body    []TextBlock   `json:"body"`
+	Actions []Action      `json:"actions,omitempty"`
+	MSTeams *CardSettings `json:"msteams,omitempty"`
+}
+
+// TextBlock is an adaptive card text element
+type TextBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	Wrap bool   `json:"wrap"`
+}
+
+// Action opens an acknowledgement link
+type Action struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// CardSettings holds the Teams specific card settings
+type CardSettings struct {
+	Entities []Mention `json:"entities"`
+}
+
+// Mention links the <at> tag of the text to a Teams user
+type Mention struct {
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	Mentioned struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"mentioned"`
+}
+
+// New creates a Teams instance posting to the account incoming webhook
+func New(a *doorbot.Account) *Teams {
+	return &Teams{
+		Account: a,
+		URL:     a.NotificationsTeamsURL,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the notifier name
+func (t *Teams) Name() string {
+	return "Teams"
+}
+
+// KnockKnock posts a card mentioning the person. Doors can route their knock-knocks to their own channel webhook.
+func (t *Teams) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id": t.Account.ID,
+		"person_id":  p.ID,
+		"door_id":    d.ID,
+	}).Info("Notificator::Teams->KnockKnock request")
+
+	endpoint := t.URL
+	if len(d.NotificationsTeamsURL) > 0 {
+		endpoint = d.NotificationsTeamsURL
+	}
+
+	body, err := json.Marshal(NewMessage(t.Account, d, p, k))
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := t.Client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	// Drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("teams: unexpected status code %d", resp.StatusCode)
+	}
+
+	return "", nil
+}
+
+// NewMessage builds the card of a knock-knock. The person is mentioned using their email, which Teams uses as the user principal name.
+func NewMessage(a *doorbot.Account, d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) *Message {
+	data := templates.Data(a, d, p, k)
+
+	card := Card{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.2",
+	}
+
+	if len(p.Email) > 0 {
+		data["name"] = "<at>" + p.Name + "</at>"
+
+		mention := Mention{Type: "mention", Text: data["name"]}
+		mention.Mentioned.ID = p.Email
+		mention.Mentioned.Name = p.Name
+
+		card.MSTeams = &CardSettings{Entities: []Mention{mention}}
+	}
+
+	card.Body = []TextBlock{{
+		Type: "TextBlock",
+		Text: templates.Render(templates.For(a, k, doorbot.NotificationChannelChat), data).Text,
+		Wrap: true,
+	}}
+
+	for _, r := range templates.Replies {
+		if link, ok := k.Links[r.Reply]; ok {
+			card.Actions = append(card.Actions, Action{Type: "Action.OpenUrl", Title: r.ChatLabel, URL: link})
+		}
+	}
+
+	return &Message{
+		Type:        "message",
+		Attachments: []Attachment{{ContentType: "application/vnd.microsoft.card.adaptive", Content: card}},
+	}
+}
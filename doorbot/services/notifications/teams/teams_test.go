@@ -0,0 +1,50 @@
+package teams
+
+import (
+	"encoding/json"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKnockKnock(t *testing.T) {
+	var (
+		posted Message
+		path   string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		json.NewDecoder(req.Body).Decode(&posted)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tn := New(&doorbot.Account{ID: 3, NotificationsTeamsURL: server.URL + "/account"})
+
+	k := &doorbot.KnockKnock{
+		Visitor: &doorbot.Visitor{Name: "Bob"},
+		Links:   map[string]string{doorbot.AcknowledgementOnMyWay: "https://example.com/omw"},
+	}
+
+	_, err := tn.KnockKnock(&doorbot.Door{Name: "Lobby", NotificationsTeamsURL: server.URL + "/lobby"}, &doorbot.Person{Name: "Jane", Email: "jane@example.com"}, k)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/lobby", path)
+	assert.Len(t, posted.Attachments, 1)
+
+	card := posted.Attachments[0].Content
+	assert.Equal(t, "Hi <at>Jane</at>, Bob is waiting at the Lobby.", card.Body[0].Text)
+	assert.Equal(t, []Action{{Type: "Action.OpenUrl", Title: "I'm on my way", URL: "https://example.com/omw"}}, card.Actions)
+	assert.Equal(t, "jane@example.com", card.MSTeams.Entities[0].Mentioned.ID)
+}
+
+func TestNewMessageWithoutEmail(t *testing.T) {
+	m := NewMessage(&doorbot.Account{}, &doorbot.Door{Name: "Lobby"}, &doorbot.Person{Name: "Jane"}, &doorbot.KnockKnock{})
+
+	card := m.Attachments[0].Content
+	assert.Equal(t, "Hi Jane, Someone is waiting at the Lobby.", card.Body[0].Text)
+	assert.Nil(t, card.MSTeams)
+}
@@ -0,0 +1,353 @@
+// Package templates renders the knock-knock messages of the notification channels.
+//
+// Messages use the rendering.Template syntax:
+//
+//	Hi {{name}}, {{visitor}}{{#visitor_company}} from {{visitor_company}}{{/visitor_company}} is at the {{door}}.
+//
+// A door template takes precedence over the account template of the same channel, which itself takes precedence
+// over the legacy account message templates and the defaults.
+package templates
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/rendering"
+	"html"
+	"strings"
+	"time"
+)
+
+const (
+	// SMSLimit is the number of characters fitting in a single text message
+	SMSLimit = 160
+	// SMSColumnLimit is the length of the legacy account SMS message template column
+	SMSColumnLimit = 140
+)
+
+// Variable describes a value available to the templates
+type Variable struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Variables lists the values available to the templates
+var Variables = []Variable{
+	{"name", "Name of the host ( same as host )"},
+	{"host", "Name of the host"},
+	{"host_title", "Title of the host"},
+	{"host_email", "Email address of the host"},
+	{"visitor", "Name of the visitor, \"Someone\" when unknown"},
+	{"visitor_company", "Company of the visitor"},
+	{"visitor_email", "Email address of the visitor"},
+	{"door", "Name of the door"},
+	{"account", "Name of the account"},
+	{"time", "Time of the knock-knock ( ex: 15:04 UTC )"},
+	{"date", "Date of the knock-knock ( ex: Monday, January 2 )"},
+}
+
+// Channels lists the channel kinds accepting templates
+var Channels = []string{
+	doorbot.NotificationChannelApp,
+	doorbot.NotificationChannelChat,
+	doorbot.NotificationChannelSMS,
+	doorbot.NotificationChannelEmail,
+}
+
+// Defaults holds the template used by each channel when neither the door nor the account define one.
+var Defaults = map[string]*doorbot.NotificationTemplate{
+	doorbot.NotificationChannelApp: {
+		Channel: doorbot.NotificationChannelApp,
+		Subject: "Doorbot",
+		Body:    "{{visitor}} is waiting at the {{door}}.",
+	},
+	doorbot.NotificationChannelChat: {
+		Channel: doorbot.NotificationChannelChat,
+		Body:    "Hi {{name}}, {{visitor}} is waiting at the {{door}}.",
+	},
+	doorbot.NotificationChannelSMS: {
+		Channel: doorbot.NotificationChannelSMS,
+		Body:    "Hi {{name}}, {{visitor}} is waiting for you at the {{door}}.",
+	},
+	doorbot.NotificationChannelEmail: {
+		Channel:  doorbot.NotificationChannelEmail,
+		Subject:  "Doorbot - {{visitor}} is waiting at the {{door}}.",
+		Body:     "Hi {{name}},\n{{visitor}}{{#visitor_company}} from {{visitor_company}}{{/visitor_company}} is waiting at the {{door}}.\n",
+		HTMLBody: "<p>Hi {{name}},</p>\n<p><strong>{{visitor}}</strong>{{#visitor_company}} from {{visitor_company}}{{/visitor_company}} is waiting at the {{door}}.</p>\n",
+	},
+}
+
+// Message is a rendered template
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Data returns the template values of a knock-knock.
+// The time of the visit is used when the knock-knock was made for one.
+func Data(a *doorbot.Account, d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) map[string]string {
+	at := time.Now()
+	if k != nil && k.Visit != nil {
+		at = k.Visit.CheckedInAt
+	}
+
+	data := map[string]string{
+		"name":       p.Name,
+		"host":       p.Name,
+		"host_title": p.Title,
+		"host_email": p.Email,
+		"visitor":    "Someone",
+		"door":       d.Name,
+		"account":    a.Name,
+		"time":       at.UTC().Format("15:04 MST"),
+		"date":       at.UTC().Format("Monday, January 2"),
+	}
+
+	if k != nil && k.Visitor != nil {
+		data["visitor"] = k.Visitor.Name
+		data["visitor_company"] = k.Visitor.Company
+		data["visitor_email"] = k.Visitor.Email
+	}
+
+	return data
+}
+
+// Sample returns values used to preview and validate templates.
+func Sample(a *doorbot.Account) map[string]string {
+	return Data(
+		a,
+		&doorbot.Door{Name: "Main Entrance"},
+		&doorbot.Person{Name: "Jane Appleseed", Title: "Office Manager", Email: "jane@example.com"},
+		&doorbot.KnockKnock{
+			Visit:   &doorbot.Visit{CheckedInAt: time.Date(2015, time.July, 30, 9, 41, 0, 0, time.UTC)},
+			Visitor: &doorbot.Visitor{Name: "John Doe", Company: "Acme Corporation", Email: "john@example.com"},
+		},
+	)
+}
+
+// For returns the template to render a knock-knock with on a channel.
+// The template resolved by the notificator is used when set, the legacy account templates or the defaults otherwise.
+func For(a *doorbot.Account, k *doorbot.KnockKnock, channel string) *doorbot.NotificationTemplate {
+	if k != nil && k.Template != nil && k.Template.Channel == channel {
+		return k.Template
+	}
+
+	var legacy *string
+
+	switch channel {
+	case doorbot.NotificationChannelSMS:
+		legacy = a.NotificationsSMSMessageTemplate
+	case doorbot.NotificationChannelEmail:
+		legacy = a.NotificationsEmailMessageTemplate
+	}
+
+	if legacy != nil && len(*legacy) > 0 {
+		return &doorbot.NotificationTemplate{Channel: channel, Body: *legacy}
+	}
+
+	return Defaults[channel]
+}
+
+// Render a template. Missing parts are taken from the channel default and the HTML part is generated from the
+// text when the template does not define one.
+// A template that does not parse is replaced by the channel default.
+func Render(t *doorbot.NotificationTemplate, data map[string]string) *Message {
+	message, err := render(t, data)
+	if err == nil {
+		return message
+	}
+
+	log.WithFields(log.Fields{
+		"error":       err,
+		"account_id":  t.AccountID,
+		"template_id": t.ID,
+		"channel":     t.Channel,
+	}).Warn("Notificator::Templates->Render invalid template, using the default")
+
+	message, _ = render(Defaults[t.Channel], data)
+	return message
+}
+
+func render(t *doorbot.NotificationTemplate, data map[string]string) (*Message, error) {
+	defaults := Defaults[t.Channel]
+	if defaults == nil {
+		return nil, fmt.Errorf("templates: unknown channel %q", t.Channel)
+	}
+
+	subject := t.Subject
+	if len(subject) == 0 {
+		subject = defaults.Subject
+	}
+
+	body, err := rendering.Parse(t.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := rendering.Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &Message{
+		Subject: s.Text(data),
+		Text:    body.Text(data),
+	}
+
+	switch {
+	case len(t.HTMLBody) > 0:
+		h, err := rendering.Parse(t.HTMLBody)
+		if err != nil {
+			return nil, err
+		}
+
+		message.HTML = h.HTML(data)
+
+	default:
+		message.HTML = Paragraphs(message.Text)
+	}
+
+	return message, nil
+}
+
+// Paragraphs converts plain text to HTML, one paragraph per block of lines.
+func Paragraphs(text string) string {
+	var paragraphs []string
+
+	for _, block := range strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n\n") {
+		block = strings.TrimSpace(block)
+		if len(block) == 0 {
+			continue
+		}
+
+		paragraphs = append(paragraphs, "<p>"+strings.Replace(html.EscapeString(block), "\n", "<br>\n", -1)+"</p>")
+	}
+
+	return strings.Join(paragraphs, "\n")
+}
+
+// Validate returns the validation errors of a template
+func Validate(t *doorbot.NotificationTemplate) []string {
+	var errors []string
+
+	if Defaults[t.Channel] == nil {
+		return []string{"The channel must be one of " + strings.Join(Channels, ", ") + "."}
+	}
+
+	if len(strings.TrimSpace(t.Body)) == 0 {
+		errors = append(errors, "The template body is required.")
+	}
+
+	parts := []struct {
+		name  string
+		value string
+	}{
+		{"subject", t.Subject},
+		{"body", t.Body},
+		{"HTML body", t.HTMLBody},
+	}
+
+	for _, part := range parts {
+		tpl, err := rendering.Parse(part.value)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("The template %s is invalid: %s.", part.name, err))
+			continue
+		}
+
+		for _, key := range tpl.Keys() {
+			if !known(key) {
+				errors = append(errors, fmt.Sprintf("The template %s uses an unknown variable: %s.", part.name, key))
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	if t.Channel == doorbot.NotificationChannelSMS {
+		length := len([]rune(Render(t, Sample(&doorbot.Account{Name: "Acme"})).Text))
+		if length > SMSLimit {
+			errors = append(errors, fmt.Sprintf("The SMS renders to %d characters with the sample values, the limit is %d.", length, SMSLimit))
+		}
+	}
+
+	return errors
+}
+
+// ValidateLegacy returns the validation errors of the account message templates.
+func ValidateLegacy(a *doorbot.Account) []string {
+	var errors []string
+
+	if a.NotificationsSMSMessageTemplate != nil && len(*a.NotificationsSMSMessageTemplate) > 0 {
+		if len([]rune(*a.NotificationsSMSMessageTemplate)) > SMSColumnLimit {
+			errors = append(errors, fmt.Sprintf("The SMS message template cannot exceed %d characters.", SMSColumnLimit))
+		} else {
+			errors = append(errors, Validate(&doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelSMS, Body: *a.NotificationsSMSMessageTemplate})...)
+		}
+	}
+
+	if a.NotificationsEmailMessageTemplate != nil && len(*a.NotificationsEmailMessageTemplate) > 0 {
+		errors = append(errors, Validate(&doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelEmail, Body: *a.NotificationsEmailMessageTemplate})...)
+	}
+
+	return errors
+}
+
+// Reply is an acknowledgement offered as a link in the knock-knock messages.
+type Reply struct {
+	Reply string
+	// Label of the email links, chat messages use the shorter ChatLabel.
+	Label     string
+	ChatLabel string
+}
+
+// Replies lists the acknowledgements in the order they are offered.
+var Replies = []Reply{
+	{doorbot.AcknowledgementOnMyWay, "I'm on my way", "I'm on my way"},
+	{doorbot.AcknowledgementPleaseWait, "Please wait, I'll be there shortly", "Please wait"},
+	{doorbot.AcknowledgementUnavailable, "I can't come to the door", "I can't come to the door"},
+}
+
+// TextReplies lists the acknowledgement links of an email as plain text, so the person can answer the visitor in one click.
+func TextReplies(k *doorbot.KnockKnock) string {
+	if len(k.Links) == 0 {
+		return ""
+	}
+
+	text := "\nLet the visitor know:\n"
+	for _, r := range Replies {
+		if link, ok := k.Links[r.Reply]; ok {
+			text += fmt.Sprintf(" - %s: %s\n", r.Label, link)
+		}
+	}
+
+	return text
+}
+
+// HTMLReplies lists the acknowledgement links of an email as HTML.
+func HTMLReplies(k *doorbot.KnockKnock) string {
+	if len(k.Links) == 0 {
+		return ""
+	}
+
+	text := "<p>Let the visitor know:</p>\n<ul>\n"
+	for _, r := range Replies {
+		if link, ok := k.Links[r.Reply]; ok {
+			text += fmt.Sprintf("<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(link), r.Label)
+		}
+	}
+
+	return text + "</ul>\n"
+}
+
+func known(key string) bool {
+	for _, v := range Variables {
+		if v.Name == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package templates
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRenderDefaults(t *testing.T) {
+	account := &doorbot.Account{Name: "Acme"}
+	door := &doorbot.Door{Name: "Lobby"}
+	person := &doorbot.Person{Name: "Jane"}
+
+	k := &doorbot.KnockKnock{Visitor: &doorbot.Visitor{Name: "Bob <Bobby>", Company: "Initech"}}
+
+	message := Render(For(account, k, doorbot.NotificationChannelEmail), Data(account, door, person, k))
+
+	assert.Equal(t, "Doorbot - Bob <Bobby> is waiting at the Lobby.", message.Subject)
+	assert.Equal(t, "Hi Jane,\nBob <Bobby> from Initech is waiting at the Lobby.\n", message.Text)
+	assert.Equal(t, "<p>Hi Jane,</p>\n<p><strong>Bob &lt;Bobby&gt;</strong> from Initech is waiting at the Lobby.</p>\n", message.HTML)
+
+	message = Render(For(account, &doorbot.KnockKnock{}, doorbot.NotificationChannelChat), Data(account, door, person, nil))
+	assert.Equal(t, "Hi Jane, Someone is waiting at the Lobby.", message.Text)
+}
+
+func TestFor(t *testing.T) {
+	legacy := "{{name}}, {{visitor}} is at the {{door}}."
+	account := &doorbot.Account{NotificationsSMSMessageTemplate: &legacy}
+
+	assert.Equal(t, legacy, For(account, &doorbot.KnockKnock{}, doorbot.NotificationChannelSMS).Body)
+	assert.Equal(t, Defaults[doorbot.NotificationChannelEmail], For(account, &doorbot.KnockKnock{}, doorbot.NotificationChannelEmail))
+
+	door := &doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelSMS, Body: "{{visitor}} is at the {{door}}."}
+	assert.Equal(t, door, For(account, &doorbot.KnockKnock{Template: door}, doorbot.NotificationChannelSMS))
+
+	// A template resolved for another channel is ignored.
+	assert.Equal(t, Defaults[doorbot.NotificationChannelChat], For(account, &doorbot.KnockKnock{Template: door}, doorbot.NotificationChannelChat))
+}
+
+func TestRenderGeneratedHTML(t *testing.T) {
+	template := &doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelEmail, Body: "Hi {{name}},\n\n{{visitor}} & co are here."}
+
+	message := Render(template, map[string]string{"name": "Jane", "visitor": "Bob"})
+
+	assert.Equal(t, "Doorbot - Bob is waiting at the .", message.Subject)
+	assert.Equal(t, "<p>Hi Jane,</p>\n<p>Bob &amp; co are here.</p>", message.HTML)
+}
+
+func TestRenderInvalidTemplate(t *testing.T) {
+	template := &doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelChat, Body: "{{#visitor}}{{visitor}}"}
+
+	message := Render(template, map[string]string{"name": "Jane", "visitor": "Bob", "door": "Lobby"})
+	assert.Equal(t, "Hi Jane, Bob is waiting at the Lobby.", message.Text)
+}
+
+func TestValidate(t *testing.T) {
+	assert.Empty(t, Validate(&doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelChat, Body: "{{visitor}}{{#visitor_company}} ({{visitor_company}}){{/visitor_company}} is here."}))
+
+	assert.Equal(
+		t,
+		[]string{"The channel must be one of app, chat, sms, email."},
+		Validate(&doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelWebhook, Body: "{{visitor}}"}),
+	)
+
+	assert.Equal(
+		t,
+		[]string{
+			"The template subject uses an unknown variable: guest.",
+			"The template body is invalid: {{#visitor}} is never closed.",
+		},
+		Validate(&doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelEmail, Subject: "{{guest}}", Body: "{{#visitor}}"}),
+	)
+
+	long := &doorbot.NotificationTemplate{Channel: doorbot.NotificationChannelSMS, Body: strings.Repeat("{{visitor_company}} ", 10)}
+	assert.Equal(t, []string{"The SMS renders to 170 characters with the sample values, the limit is 160."}, Validate(long))
+}
+
+func TestValidateLegacy(t *testing.T) {
+	long := strings.Repeat("a", SMSColumnLimit+1)
+	assert.Equal(t, []string{"The SMS message template cannot exceed 140 characters."}, ValidateLegacy(&doorbot.Account{NotificationsSMSMessageTemplate: &long}))
+
+	legacy := "Hi {{name}}"
+	assert.Empty(t, ValidateLegacy(&doorbot.Account{NotificationsSMSMessageTemplate: &legacy, NotificationsEmailMessageTemplate: &legacy}))
+}
+
+func TestReplies(t *testing.T) {
+	k := &doorbot.KnockKnock{Links: map[string]string{
+		doorbot.AcknowledgementOnMyWay:     "https://doorbot.co/r/a",
+		doorbot.AcknowledgementUnavailable: "https://doorbot.co/r/c?x=1&y=2",
+	}}
+
+	assert.Equal(t, "\nLet the visitor know:\n - I'm on my way: https://doorbot.co/r/a\n - I can't come to the door: https://doorbot.co/r/c?x=1&y=2\n", TextReplies(k))
+	assert.Contains(t, HTMLReplies(k), "<li><a href=\"https://doorbot.co/r/c?x=1&amp;y=2\">I can't come to the door</a></li>")
+
+	k.Links = nil
+	assert.Equal(t, "", TextReplies(k))
+	assert.Equal(t, "", HTMLReplies(k))
+}
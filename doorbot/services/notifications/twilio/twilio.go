@@ -1,17 +1,35 @@
 package twilio
 
 import (
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/masom/doorbot/doorbot"
-	"github.com/masom/doorbot/doorbot/services/rendering"
+	"github.com/masom/doorbot/doorbot/services/notifications/templates"
 	"github.com/sfreiberg/gotwilio"
 )
 
 type Config struct {
+	AccountSID string
 	Token string
 	PhoneNumber string
 }
 
+// Error is returned when Twilio rejects a message. Status is the HTTP status and Code the Twilio error code.
+type Error struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("twilio: %s (%d)", e.Message, e.Code)
+}
+
+// Temporary tells if Twilio itself failed, rather than rejecting the message.
+func (e *Error) Temporary() bool {
+	return e.Status >= 500 || e.Status == 429
+}
+
 type Twilio struct {
 	Account *doorbot.Account
 	AccountSID string
@@ -23,6 +41,7 @@ type Twilio struct {
 func New(a *doorbot.Account, c Config) *Twilio {
 	return &Twilio{
 		Account: a,
+		AccountSID: c.AccountSID,
 		Token: c.Token,
 		PhoneNumber: c.PhoneNumber,
 	}
@@ -32,7 +51,7 @@ func (t *Twilio) Name() string {
 	return "Twilio"
 }
 
-func (t *Twilio) KnockKnock(d *doorbot.Door, p *doorbot.Person) error {
+func (t *Twilio) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
 	log.WithFields(log.Fields{
 		"account_id": t.Account.ID,
 		"person_id":  p.ID,
@@ -43,23 +62,14 @@ func (t *Twilio) KnockKnock(d *doorbot.Door, p *doorbot.Person) error {
 	from := t.PhoneNumber
 	to := p.PhoneNumber
 
-	renderingData := map[string]string{
-		"name": p.Name,
-		"door": d.Name,
-	}
-
-	dbb := rendering.DoorbotBar()
+	message := templates.Render(templates.For(t.Account, k, doorbot.NotificationChannelSMS), templates.Data(t.Account, d, p, k)).Text
 
-	template := *t.Account.NotificationsSMSMessageTemplate
-	if len(template) == 0 {
-		template = "Hi {{name}}, there is someone waiting for your at the {{door}}."
+	if link, ok := k.Links[doorbot.AcknowledgementOnMyWay]; ok {
+		message += " On my way: " + link
 	}
 
-	message := dbb.Render(template, renderingData)
-
 	twilio := gotwilio.NewTwilioClient(t.AccountSID, t.Token)
-	// was response
-	_, exception, err := twilio.SendSMS(from, to, message, "", "")
+	response, exception, err := twilio.SendSMS(from, to, message, "", "")
 	if exception != nil {
 		log.WithFields(log.Fields{
 			"twilio_message": exception.Message,
@@ -71,8 +81,12 @@ func (t *Twilio) KnockKnock(d *doorbot.Door, p *doorbot.Person) error {
 			"sms_from":       from,
 		}).Error("Notificator::Twilio->KnockKnock twilio exception")
 
-		return err
+		return "", &Error{Status: exception.Status, Code: exception.Code, Message: exception.Message}
+	}
+
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return response.Sid, nil
 }
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a webhook host resolves to an address of the internal network.
+var ErrPrivateAddress = errors.New("webhook: the host resolves to a private address")
+
+// private lists the loopback, private, shared, link-local and unique local networks.
+var private = networks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+func networks(cidrs ...string) []*net.IPNet {
+	var n []*net.IPNet
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		n = append(n, network)
+	}
+
+	return n
+}
+
+// Private tells if an address belongs to the internal network: loopback, private, link-local or unspecified.
+func Private(ip net.IP) bool {
+	for _, network := range private {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PublicURL tells if a webhook URL is valid and does not target the internal network by its address or name.
+// Host names are only resolved when connecting, see NewClient.
+func PublicURL(s string) bool {
+	if !ValidURL(s) {
+		return false
+	}
+
+	u, _ := url.Parse(s)
+
+	host := u.Host
+	if h, _, err := net.SplitHostPort(u.Host); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(strings.Trim(host, "[]"))
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return !Private(ip)
+	}
+
+	return true
+}
+
+// NewClient returns an HTTP client refusing to connect to the internal network, whatever the URL host resolves to.
+// Used for the URLs set by people who must not reach the servers behind Doorbot.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	dial := func(network string, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ip := range ips {
+			if Private(ip) {
+				return nil, ErrPrivateAddress
+			}
+		}
+
+		// Dialing the checked address keeps the name from resolving somewhere else in between.
+		return dialer.Dial(network, net.JoinHostPort(ips[0].String(), port))
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{Dial: dial, TLSHandshakeTimeout: timeout},
+	}
+}
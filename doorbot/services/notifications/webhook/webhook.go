@@ -0,0 +1,258 @@
+// Package webhook posts knock-knocks to the HTTP endpoints of custom integrations.
+//
+// Every request carries the unix time it was sent at in the X-Doorbot-Timestamp header and
+// the hex encoded HMAC-SHA256 of "<timestamp>.<body>", keyed with the account webhook secret,
+// in the X-Doorbot-Signature header.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/security"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// TimestampHeader holds the unix time the request was signed at
+	TimestampHeader = "X-Doorbot-Timestamp"
+	// SignatureHeader holds the request signature
+	SignatureHeader = "X-Doorbot-Signature"
+
+	// EventKnockKnock is the payload event of a knock-knock
+	EventKnockKnock = "knock_knock"
+)
+
+// Config holds webhook delivery configuration values
+type Config struct {
+	// Number of requests made before giving up on a delivery, defaults to 3.
+	Attempts int
+	// Delay before the first retry, doubled on every retry. Defaults to 1 second.
+	RetryDelay time.Duration
+}
+
+// Webhook notifier
+type Webhook struct {
+	Account    *doorbot.Account
+	URL        string
+	Secret     string
+	Attempts   int
+	RetryDelay time.Duration
+	Client     *http.Client
+}
+
+// Error is returned when the endpoint answers with an unexpected status code.
+type Error struct {
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("webhook: unexpected status code %d", e.StatusCode)
+}
+
+// Temporary tells if the request may succeed when retried: server errors, timeouts and rate limiting ( 429 ).
+func (e *Error) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == 429
+}
+
+// Payload is the JSON document posted to the endpoint.
+type Payload struct {
+	Event          string            `json:"event"`
+	NotificationID uint              `json:"notification_id,omitempty"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Account        PayloadAccount    `json:"account"`
+	Door           PayloadDoor       `json:"door"`
+	Person         PayloadPerson     `json:"person"`
+	Visitor        *PayloadVisitor   `json:"visitor"`
+	VisitID        uint              `json:"visit_id,omitempty"`
+	Links          map[string]string `json:"links,omitempty"`
+}
+
+// PayloadAccount describes the account in a payload
+type PayloadAccount struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Host string `json:"host"`
+}
+
+// PayloadDoor describes the door in a payload
+type PayloadDoor struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// PayloadPerson describes the person knocked in a payload
+type PayloadPerson struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+// PayloadVisitor describes the visitor in a payload
+type PayloadVisitor struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	Company string `json:"company"`
+}
+
+// New creates a Webhook instance. The person URL is used when set, the account URL otherwise.
+func New(a *doorbot.Account, p *doorbot.Person, c Config) *Webhook {
+	endpoint := a.NotificationsWebhookURL
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	// Any member can set their own URL, it must not reach the internal network.
+	if len(p.NotificationsWebhookURL) > 0 {
+		endpoint = p.NotificationsWebhookURL
+		client = NewClient(10 * time.Second)
+	}
+
+	if c.Attempts <= 0 {
+		c.Attempts = 3
+	}
+
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = time.Second
+	}
+
+	return &Webhook{
+		Account:    a,
+		URL:        endpoint,
+		Secret:     a.NotificationsWebhookSecret,
+		Attempts:   c.Attempts,
+		RetryDelay: c.RetryDelay,
+		Client:     client,
+	}
+}
+
+// ValidURL tells if a webhook URL is an absolute http or https URL.
+func ValidURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
+
+// Name returns the notifier name
+func (w *Webhook) Name() string {
+	return "Webhook"
+}
+
+// KnockKnock posts the knock-knock to the endpoint. Network errors and server errors are retried.
+func (w *Webhook) KnockKnock(d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock) (string, error) {
+	log.WithFields(log.Fields{
+		"account_id": w.Account.ID,
+		"person_id":  p.ID,
+		"door_id":    d.ID,
+	}).Info("Notificator::Webhook->KnockKnock request")
+
+	body, err := json.Marshal(NewPayload(w.Account, d, p, k, time.Now()))
+	if err != nil {
+		return "", err
+	}
+
+	delay := w.RetryDelay
+
+	for attempt := 1; ; attempt++ {
+		err = w.post(body, time.Now())
+		if err == nil {
+			return "", nil
+		}
+
+		log.WithFields(log.Fields{
+			"error":      err,
+			"account_id": w.Account.ID,
+			"person_id":  p.ID,
+			"door_id":    d.ID,
+			"attempt":    attempt,
+		}).Warn("Notificator::Webhook->KnockKnock delivery failed")
+
+		if e, ok := err.(*Error); (ok && !e.Temporary()) || attempt >= w.Attempts {
+			return "", err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// NewPayload builds the payload of a knock-knock
+func NewPayload(a *doorbot.Account, d *doorbot.Door, p *doorbot.Person, k *doorbot.KnockKnock, now time.Time) *Payload {
+	payload := &Payload{
+		Event:     EventKnockKnock,
+		Timestamp: now.UTC(),
+		Account:   PayloadAccount{ID: a.ID, Name: a.Name, Host: a.Host},
+		Door:      PayloadDoor{ID: d.ID, Name: d.Name},
+		Person: PayloadPerson{
+			ID:          p.ID,
+			Name:        p.Name,
+			Title:       p.Title,
+			Email:       p.Email,
+			PhoneNumber: p.PhoneNumber,
+		},
+		Links: k.Links,
+	}
+
+	if k.Notification != nil {
+		payload.NotificationID = k.Notification.ID
+	}
+
+	if k.Visitor != nil {
+		payload.Visitor = &PayloadVisitor{ID: k.Visitor.ID, Name: k.Visitor.Name, Company: k.Visitor.Company}
+	}
+
+	if k.Visit != nil {
+		payload.VisitID = k.Visit.ID
+	}
+
+	return payload
+}
+
+// Signature returns the signature of a request body sent at a given unix time.
+func Signature(secret string, timestamp int64, body []byte) string {
+	return security.Sign(secret, strconv.FormatInt(timestamp, 10)+"."+string(body))
+}
+
+// Sign sets the content type, timestamp and signature headers of a request posting `body`.
+func Sign(req *http.Request, secret string, body []byte, now time.Time) {
+	timestamp := now.Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Doorbot-Webhook/1.0")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Signature(secret, timestamp, body))
+}
+
+func (w *Webhook) post(body []byte, now time.Time) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	Sign(req, w.Secret, body, now)
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	// Drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &Error{StatusCode: resp.StatusCode}
+	}
+
+	return nil
+}
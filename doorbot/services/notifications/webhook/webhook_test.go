@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"encoding/json"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/security"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestKnockKnock(t *testing.T) {
+	var (
+		requests int
+		payload  Payload
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+
+		body, _ := ioutil.ReadAll(req.Body)
+
+		timestamp, err := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+		assert.Nil(t, err)
+		assert.True(t, security.VerifySignature("secret", req.Header.Get(TimestampHeader)+"."+string(body), req.Header.Get(SignatureHeader)))
+		assert.Equal(t, Signature("secret", timestamp, body), req.Header.Get(SignatureHeader))
+
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		json.Unmarshal(body, &payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	account := &doorbot.Account{ID: 3, Name: "ACME", NotificationsWebhookURL: "http://example.com", NotificationsWebhookSecret: "secret"}
+	person := &doorbot.Person{ID: 5, Name: "Jane", NotificationsWebhookURL: server.URL}
+
+	w := New(account, person, Config{RetryDelay: time.Millisecond})
+
+	// The test server listens on the loopback, which person webhooks cannot reach.
+	w.Client = &http.Client{}
+
+	k := &doorbot.KnockKnock{
+		Notification: &doorbot.Notification{ID: 12},
+		Visitor:      &doorbot.Visitor{ID: 7, Name: "Bob", Company: "Initech"},
+	}
+
+	_, err := w.KnockKnock(&doorbot.Door{ID: 2, Name: "Lobby"}, person, k)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, EventKnockKnock, payload.Event)
+	assert.Equal(t, uint(12), payload.NotificationID)
+	assert.Equal(t, PayloadAccount{ID: 3, Name: "ACME"}, payload.Account)
+	assert.Equal(t, PayloadDoor{ID: 2, Name: "Lobby"}, payload.Door)
+	assert.Equal(t, uint(5), payload.Person.ID)
+	assert.Equal(t, &PayloadVisitor{ID: 7, Name: "Bob", Company: "Initech"}, payload.Visitor)
+}
+
+func TestKnockKnockClientError(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	account := &doorbot.Account{ID: 3, NotificationsWebhookURL: server.URL}
+
+	w := New(account, &doorbot.Person{}, Config{RetryDelay: time.Millisecond})
+
+	_, err := w.KnockKnock(&doorbot.Door{}, &doorbot.Person{}, &doorbot.KnockKnock{})
+
+	assert.Equal(t, &Error{StatusCode: http.StatusGone}, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestKnockKnockGivesUp(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	account := &doorbot.Account{ID: 3, NotificationsWebhookURL: server.URL}
+
+	w := New(account, &doorbot.Person{}, Config{Attempts: 2, RetryDelay: time.Millisecond})
+
+	_, err := w.KnockKnock(&doorbot.Door{}, &doorbot.Person{}, &doorbot.KnockKnock{})
+
+	assert.Equal(t, &Error{StatusCode: http.StatusServiceUnavailable}, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestKnockKnockPrivateAddress(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	account := &doorbot.Account{ID: 3, NotificationsWebhookURL: server.URL}
+	person := &doorbot.Person{ID: 5, NotificationsWebhookURL: server.URL}
+
+	w := New(account, person, Config{Attempts: 1, RetryDelay: time.Millisecond})
+
+	_, err := w.KnockKnock(&doorbot.Door{}, person, &doorbot.KnockKnock{})
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrPrivateAddress.Error())
+	assert.Equal(t, 0, requests)
+}
+
+func TestPublicURL(t *testing.T) {
+	assert.True(t, PublicURL("https://hooks.example.com/doorbot?team=1"))
+	assert.True(t, PublicURL("http://93.184.216.34:8080/knock"))
+	assert.False(t, PublicURL("http://10.0.0.4:8080/knock"))
+	assert.False(t, PublicURL("http://127.0.0.1/knock"))
+	assert.False(t, PublicURL("http://169.254.169.254/latest/meta-data"))
+	assert.False(t, PublicURL("http://[::1]:8080/knock"))
+	assert.False(t, PublicURL("http://localhost:8080/knock"))
+	assert.False(t, PublicURL("ftp://example.com"))
+}
+
+func TestValidURL(t *testing.T) {
+	assert.True(t, ValidURL("https://hooks.example.com/doorbot?team=1"))
+	assert.True(t, ValidURL("http://10.0.0.4:8080/knock"))
+	assert.False(t, ValidURL("hooks.example.com/doorbot"))
+	assert.False(t, ValidURL("ftp://example.com"))
+	assert.False(t, ValidURL(""))
+}
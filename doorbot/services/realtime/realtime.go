@@ -0,0 +1,168 @@
+// Package realtime fans out the database change notifications to the connected clients of each account.
+// Changes are published by database triggers using PostgreSQL NOTIFY, so every API instance receives them.
+package realtime
+
+import (
+	"encoding/json"
+	log "github.com/Sirupsen/logrus"
+	"github.com/lib/pq"
+	"sync"
+	"time"
+)
+
+// Channel is the PostgreSQL notification channel the database triggers publish on.
+const Channel = "doorbot_changes"
+
+const (
+	// ActionInsert a row was created
+	ActionInsert = "INSERT"
+	// ActionUpdate a row was updated
+	ActionUpdate = "UPDATE"
+	// ActionDelete a row was deleted
+	ActionDelete = "DELETE"
+	// ActionResync changes may have been missed while the database connection was lost
+	ActionResync = "RESYNC"
+)
+
+// Change is published by the database triggers when a row changes.
+type Change struct {
+	AccountID uint   `json:"account_id"`
+	Table     string `json:"table"`
+	Action    string `json:"action"`
+	ID        uint   `json:"id"`
+}
+
+// Hub listens to the database changes and dispatches them to the subscribers of each account.
+type Hub struct {
+	URL string
+
+	mutex       sync.Mutex
+	subscribers map[uint]map[chan *Change]struct{}
+	listener    *pq.Listener
+}
+
+// NewHub creates a Hub listening on the given database
+func NewHub(url string) *Hub {
+	return &Hub{
+		URL:         url,
+		subscribers: map[uint]map[chan *Change]struct{}{},
+	}
+}
+
+// Start listening for database changes
+func (h *Hub) Start() error {
+	h.listener = pq.NewListener(h.URL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"event": event,
+			}).Error("Realtime::Hub listener error")
+		}
+	})
+
+	err := h.listener.Listen(Channel)
+	if err != nil {
+		return err
+	}
+
+	go h.listen()
+
+	return nil
+}
+
+func (h *Hub) listen() {
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case n, ok := <-h.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// A nil notification is sent after the connection was re-established.
+			if n == nil {
+				h.broadcast(&Change{Action: ActionResync})
+				continue
+			}
+
+			h.Dispatch(n.Extra)
+
+		case <-ping.C:
+			go h.listener.Ping()
+		}
+	}
+}
+
+// Dispatch decodes a change payload and sends it to the subscribers of the account
+func (h *Hub) Dispatch(payload string) {
+	change := &Change{}
+
+	err := json.Unmarshal([]byte(payload), change)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":   err,
+			"payload": payload,
+		}).Error("Realtime::Hub->Dispatch invalid payload")
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for c := range h.subscribers[change.AccountID] {
+		h.send(c, change)
+	}
+}
+
+// broadcast sends a change to every subscriber
+func (h *Hub) broadcast(change *Change) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, subscribers := range h.subscribers {
+		for c := range subscribers {
+			h.send(c, change)
+		}
+	}
+}
+
+// send never blocks: changes are dropped for subscribers not keeping up.
+func (h *Hub) send(c chan *Change, change *Change) {
+	select {
+	case c <- change:
+	default:
+		log.WithFields(log.Fields{
+			"account_id": change.AccountID,
+			"table":      change.Table,
+			"id":         change.ID,
+		}).Warn("Realtime::Hub->send subscriber is too slow, change dropped")
+	}
+}
+
+// Subscribe returns a channel receiving the changes made on an account.
+// The returned function must be called to unsubscribe.
+func (h *Hub) Subscribe(accountID uint) (<-chan *Change, func()) {
+	c := make(chan *Change, 32)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.subscribers[accountID] == nil {
+		h.subscribers[accountID] = map[chan *Change]struct{}{}
+	}
+
+	h.subscribers[accountID][c] = struct{}{}
+
+	return c, func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		delete(h.subscribers[accountID], c)
+
+		if len(h.subscribers[accountID]) == 0 {
+			delete(h.subscribers, accountID)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package realtime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	h := NewHub("")
+
+	changes, unsubscribe := h.Subscribe(1)
+	others, unsubscribeOthers := h.Subscribe(2)
+	defer unsubscribeOthers()
+
+	h.Dispatch(`{"account_id":1,"table":"people","action":"UPDATE","id":5}`)
+
+	change := <-changes
+	assert.Equal(t, &Change{AccountID: 1, Table: "people", Action: ActionUpdate, ID: 5}, change)
+	assert.Len(t, others, 0)
+
+	unsubscribe()
+
+	h.Dispatch(`{"account_id":1,"table":"people","action":"UPDATE","id":5}`)
+	assert.Len(t, changes, 0)
+
+	_, ok := h.subscribers[1]
+	assert.False(t, ok)
+}
+
+func TestDispatchInvalidPayload(t *testing.T) {
+	h := NewHub("")
+
+	changes, unsubscribe := h.Subscribe(1)
+	defer unsubscribe()
+
+	h.Dispatch("not json")
+	assert.Len(t, changes, 0)
+}
+
+func TestBroadcast(t *testing.T) {
+	h := NewHub("")
+
+	a, unsubscribeA := h.Subscribe(1)
+	defer unsubscribeA()
+
+	b, unsubscribeB := h.Subscribe(2)
+	defer unsubscribeB()
+
+	h.broadcast(&Change{Action: ActionResync})
+
+	assert.Equal(t, ActionResync, (<-a).Action)
+	assert.Equal(t, ActionResync, (<-b).Action)
+}
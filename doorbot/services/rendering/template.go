@@ -0,0 +1,166 @@
+package rendering
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Template is a parsed notification template.
+// It extends the DoorbotBar syntax with sections:
+//
+//	{{key}}               inserts the value of key
+//	{{#key}}...{{/key}}   renders the content when key has a value
+//	{{^key}}...{{/key}}   renders the content when key is missing or empty
+//
+// Tags that are not a valid key ( ex: {{}} ) are rendered as is.
+type Template struct {
+	nodes []*node
+}
+
+type node struct {
+	text    string
+	key     string
+	section byte
+	nodes   []*node
+}
+
+// Parse the given input into a Template.
+// An error is returned when a section is not closed or closed in the wrong order.
+func Parse(input string) (*Template, error) {
+	root := &node{}
+	stack := []*node{root}
+
+	for len(input) > 0 {
+		current := stack[len(stack)-1]
+
+		start := strings.Index(input, "{{")
+		if start < 0 {
+			current.nodes = append(current.nodes, &node{text: input})
+			break
+		}
+
+		end := strings.Index(input[start+2:], "}}")
+		if end < 0 {
+			current.nodes = append(current.nodes, &node{text: input})
+			break
+		}
+
+		if start > 0 {
+			current.nodes = append(current.nodes, &node{text: input[:start]})
+		}
+
+		raw := input[start : start+end+4]
+		tag := strings.TrimSpace(raw[2 : len(raw)-2])
+		input = input[start+end+4:]
+
+		switch {
+		case len(tag) > 1 && (tag[0] == '#' || tag[0] == '^') && validKey(tag[1:]):
+			section := &node{key: tag[1:], section: tag[0]}
+			current.nodes = append(current.nodes, section)
+			stack = append(stack, section)
+
+		case len(tag) > 1 && tag[0] == '/' && validKey(tag[1:]):
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("{{%s}} closes a section that was never opened", tag)
+			}
+
+			if current.key != tag[1:] {
+				return nil, fmt.Errorf("{{%s}} found while {{%c%s}} is still open", tag, current.section, current.key)
+			}
+
+			stack = stack[:len(stack)-1]
+
+		case validKey(tag):
+			current.nodes = append(current.nodes, &node{key: tag})
+
+		default:
+			current.nodes = append(current.nodes, &node{text: raw})
+		}
+	}
+
+	if len(stack) > 1 {
+		current := stack[len(stack)-1]
+		return nil, fmt.Errorf("{{%c%s}} is never closed", current.section, current.key)
+	}
+
+	return &Template{nodes: root.nodes}, nil
+}
+
+// Text renders the template as plain text.
+func (t *Template) Text(data map[string]string) string {
+	var b bytes.Buffer
+	render(&b, t.nodes, data, false)
+	return b.String()
+}
+
+// HTML renders the template, escaping the inserted values. The template text itself is kept as is.
+func (t *Template) HTML(data map[string]string) string {
+	var b bytes.Buffer
+	render(&b, t.nodes, data, true)
+	return b.String()
+}
+
+// Keys returns the keys used by the template, in order of appearance.
+func (t *Template) Keys() []string {
+	var keys []string
+	seen := map[string]bool{}
+
+	var walk func(nodes []*node)
+	walk = func(nodes []*node) {
+		for _, n := range nodes {
+			if len(n.key) > 0 && !seen[n.key] {
+				seen[n.key] = true
+				keys = append(keys, n.key)
+			}
+
+			walk(n.nodes)
+		}
+	}
+
+	walk(t.nodes)
+
+	return keys
+}
+
+func render(b *bytes.Buffer, nodes []*node, data map[string]string, escape bool) {
+	for _, n := range nodes {
+		switch {
+		case n.section == '#':
+			if len(data[n.key]) > 0 {
+				render(b, n.nodes, data, escape)
+			}
+
+		case n.section == '^':
+			if len(data[n.key]) == 0 {
+				render(b, n.nodes, data, escape)
+			}
+
+		case len(n.key) > 0:
+			if escape {
+				b.WriteString(html.EscapeString(data[n.key]))
+			} else {
+				b.WriteString(data[n.key])
+			}
+
+		default:
+			b.WriteString(n.text)
+		}
+	}
+}
+
+// validKey tells if a tag is a key made of lowercase letters, digits and underscores.
+func validKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+
+	for _, r := range key {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+
+	return true
+}
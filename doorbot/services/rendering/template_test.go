@@ -0,0 +1,41 @@
+package rendering
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTemplateText(t *testing.T) {
+	tpl, err := Parse("Hello {{name}} {{ name }}{{name}}{{}}")
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello bob bobbob{{}}", tpl.Text(map[string]string{"name": "bob"}))
+	assert.Equal(t, "Hello  {{}}", tpl.Text(map[string]string{}))
+}
+
+func TestTemplateSections(t *testing.T) {
+	tpl, err := Parse("{{visitor}}{{#company}} from {{company}}{{/company}}{{^company}} (no company){{/company}} is here.")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "Bob from Acme is here.", tpl.Text(map[string]string{"visitor": "Bob", "company": "Acme"}))
+	assert.Equal(t, "Bob (no company) is here.", tpl.Text(map[string]string{"visitor": "Bob"}))
+	assert.Equal(t, []string{"visitor", "company"}, tpl.Keys())
+}
+
+func TestTemplateHTML(t *testing.T) {
+	tpl, err := Parse("<p>Hi {{name}}</p>")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "<p>Hi Bob &lt;script&gt; &amp; co</p>", tpl.HTML(map[string]string{"name": "Bob <script> & co"}))
+	assert.Equal(t, "<p>Hi Bob <script> & co</p>", tpl.Text(map[string]string{"name": "Bob <script> & co"}))
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse("{{#company}} from {{company}}")
+	assert.EqualError(t, err, "{{#company}} is never closed")
+
+	_, err = Parse("{{visitor}}{{/company}}")
+	assert.EqualError(t, err, "{{/company}} closes a section that was never opened")
+
+	_, err = Parse("{{#a}}{{^b}}{{/a}}{{/b}}")
+	assert.EqualError(t, err, "{{/a}} found while {{^b}} is still open")
+}
@@ -0,0 +1,92 @@
+// Package teams picks which members of a team are knocked, following the team strategy.
+package teams
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/availability"
+)
+
+// Members returns the people of a team, in the team order. Members that no longer exist are skipped.
+func Members(r doorbot.Repositories, t *doorbot.Team) ([]*doorbot.Person, error) {
+	members, err := r.TeamMemberRepository().FindByTeamID(r.DB(), t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var people []*doorbot.Person
+
+	for _, member := range members {
+		person, err := r.PersonRepository().Find(r.DB(), member.PersonID)
+		if err != nil {
+			return nil, err
+		}
+
+		if person == nil {
+			continue
+		}
+
+		people = append(people, person)
+	}
+
+	return people, nil
+}
+
+// Recipients returns the members of a team that can be knocked, an empty list when nobody is available.
+// Hidden members are left out. Unless the team broadcasts, the first of them that can be reached is knocked.
+func Recipients(r doorbot.Repositories, t *doorbot.Team) ([]*doorbot.Person, error) {
+	people, err := Members(r, t)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := availability.People(r, people)
+	if err != nil {
+		return nil, err
+	}
+
+	available := map[uint]bool{}
+	for _, person := range people {
+		available[person.ID] = person.IsVisible && statuses[person.ID].IsAvailable
+	}
+
+	return Select(t, people, available), nil
+}
+
+// Knocked remembers the member knocked by a round-robin team so the next knock-knock goes to someone else.
+func Knocked(r doorbot.Repositories, t *doorbot.Team, p *doorbot.Person) error {
+	if t.Strategy != doorbot.TeamStrategyRoundRobin {
+		return nil
+	}
+
+	t.LastKnockedID = p.ID
+
+	_, err := r.TeamRepository().UpdateLastKnocked(r.DB(), t)
+	return err
+}
+
+// Select orders the available members of a team, given in the team order, following the team strategy.
+// Round-robin starts after the member knocked last, wrapping around to the first.
+func Select(t *doorbot.Team, members []*doorbot.Person, available map[uint]bool) []*doorbot.Person {
+	recipients := []*doorbot.Person{}
+
+	start := 0
+
+	if t.Strategy == doorbot.TeamStrategyRoundRobin {
+		for i, person := range members {
+			if person.ID == t.LastKnockedID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	for i := range members {
+		person := members[(start+i)%len(members)]
+
+		if available[person.ID] {
+			recipients = append(recipients, person)
+		}
+	}
+
+	return recipients
+}
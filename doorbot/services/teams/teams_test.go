@@ -0,0 +1,41 @@
+package teams
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	members := []*doorbot.Person{
+		&doorbot.Person{ID: 4},
+		&doorbot.Person{ID: 2},
+		&doorbot.Person{ID: 7},
+		&doorbot.Person{ID: 5},
+	}
+
+	available := map[uint]bool{4: true, 2: false, 7: true, 5: true}
+
+	team := &doorbot.Team{Strategy: doorbot.TeamStrategyBroadcast}
+	assert.Equal(t, []*doorbot.Person{members[0], members[2], members[3]}, Select(team, members, available))
+
+	team.Strategy = doorbot.TeamStrategyFirstAvailable
+	assert.Equal(t, []*doorbot.Person{members[0], members[2], members[3]}, Select(team, members, available))
+
+	// Round-robin follows the team order, skipping unavailable members and wrapping around.
+	team.Strategy = doorbot.TeamStrategyRoundRobin
+	assert.Equal(t, []*doorbot.Person{members[0], members[2], members[3]}, Select(team, members, available))
+
+	team.LastKnockedID = 4
+	assert.Equal(t, []*doorbot.Person{members[2], members[3], members[0]}, Select(team, members, available))
+
+	team.LastKnockedID = 5
+	assert.Equal(t, []*doorbot.Person{members[0], members[2], members[3]}, Select(team, members, available))
+
+	// The member knocked last left the team.
+	team.LastKnockedID = 9
+	assert.Equal(t, []*doorbot.Person{members[0], members[2], members[3]}, Select(team, members, available))
+
+	assert.Equal(t, []*doorbot.Person{}, Select(team, members, map[uint]bool{}))
+	assert.Equal(t, []*doorbot.Person{}, Select(team, nil, available))
+}
@@ -0,0 +1,144 @@
+// Package throttle suppresses duplicate knock-knocks and limits how many knock-knocks a device can send.
+// The checks run under an advisory lock on the account so they hold across API instances.
+package throttle
+
+import (
+	"fmt"
+	"github.com/masom/doorbot/doorbot"
+	"time"
+)
+
+// RateWindow is the period over which the knock-knocks of a device are counted
+const RateWindow = time.Minute
+
+// Limiter enforces the knock-knock limits of an account while holding its lock.
+// A nil Limiter enforces nothing.
+type Limiter struct {
+	r        doorbot.Repositories
+	tx       doorbot.Transaction
+	account  *doorbot.Account
+	deviceID uint
+	now      time.Time
+}
+
+// Acquire locks the knock-knocks of the account until the limiter is released.
+// The lock serializes the knock-knocks of the whole account: acquire it once the recipient and the door are loaded,
+// and release it as soon as the knock-knock is queued and recorded.
+// The device is optional. nil is returned when the account has no limits to enforce.
+func Acquire(r doorbot.Repositories, a *doorbot.Account, deviceID uint) (*Limiter, error) {
+	if a.NotificationsDedupWindow == 0 && (a.NotificationsDeviceRateLimit == 0 || deviceID == 0) {
+		return nil, nil
+	}
+
+	tx, err := r.Transaction()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.NotificationRepository().Lock(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &Limiter{r: r, tx: tx, account: a, deviceID: deviceID, now: time.Now()}, nil
+}
+
+// Duplicate returns the knock-knock sent to one of the people from the door within the dedup window, nil when there is none.
+// Knock-knocks rerouted from the people to a delegate count as sent to them.
+func (l *Limiter) Duplicate(doorID uint, people ...uint) (*doorbot.Notification, error) {
+	if l == nil || l.account.NotificationsDedupWindow == 0 {
+		return nil, nil
+	}
+
+	since := l.now.Add(-time.Duration(l.account.NotificationsDedupWindow) * time.Second)
+
+	for _, id := range people {
+		found, err := l.r.NotificationRepository().All(l.tx, &doorbot.NotificationFilter{
+			DoorID:          doorID,
+			PersonID:        id,
+			IncludeRerouted: true,
+			Since:           &since,
+			Limit:           1,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(found) > 0 {
+			return found[0], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// RetryAfter returns how long the device has to wait before its next knock-knock, 0 when it is under its rate limit.
+func (l *Limiter) RetryAfter() (time.Duration, error) {
+	if l == nil || l.deviceID == 0 || l.account.NotificationsDeviceRateLimit == 0 {
+		return 0, nil
+	}
+
+	since := l.now.Add(-RateWindow)
+	limit := l.account.NotificationsDeviceRateLimit
+
+	events, err := l.r.EventRepository().All(l.tx, &doorbot.EventFilter{
+		EventIDs: []uint{doorbot.EventKnockKnock},
+		DeviceID: l.deviceID,
+		Since:    &since,
+		Limit:    limit,
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if uint(len(events)) < limit {
+		return 0, nil
+	}
+
+	// Events come newest first: the device can knock again once the oldest one counted leaves the window.
+	wait := events[len(events)-1].CreatedAt.Add(RateWindow).Sub(l.now)
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	return wait, nil
+}
+
+// Record counts a knock-knock of the device against its rate limit.
+func (l *Limiter) Record(doorID uint, personID uint, metadata doorbot.Metadata) error {
+	if l == nil || l.deviceID == 0 {
+		return nil
+	}
+
+	event := doorbot.NewEvent(l.account.ID, l.deviceID, doorID, doorbot.EventKnockKnock, personID)
+	event.ActorType = doorbot.EventActorDevice
+	event.ActorID = l.deviceID
+	event.Metadata = metadata
+
+	return l.r.EventRepository().Create(l.tx, event)
+}
+
+// Release commits the recorded knock-knocks and releases the account lock.
+func (l *Limiter) Release() error {
+	if l == nil {
+		return nil
+	}
+
+	return l.tx.Commit()
+}
+
+// Ago describes how long ago a knock-knock was sent, ex: "20s" or "2m".
+func (l *Limiter) Ago(n *doorbot.Notification) string {
+	return ago(l.now.Sub(n.CreatedAt))
+}
+
+func ago(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
@@ -0,0 +1,210 @@
+package throttle
+
+import (
+	"github.com/masom/doorbot/doorbot"
+	"bitbucket.org/msamson/doorbot-api/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestAcquireWithoutLimits(t *testing.T) {
+	repositories := new(tests.MockRepositories)
+
+	// The rate limit only applies to devices.
+	limiter, err := Acquire(repositories, &doorbot.Account{ID: 1, NotificationsDeviceRateLimit: 10}, 0)
+
+	assert.Nil(t, err)
+	assert.Nil(t, limiter)
+
+	duplicate, err := limiter.Duplicate(3, 4)
+	assert.Nil(t, duplicate)
+	assert.Nil(t, err)
+
+	wait, err := limiter.RetryAfter()
+	assert.Equal(t, time.Duration(0), wait)
+	assert.Nil(t, err)
+
+	assert.Nil(t, limiter.Record(3, 4, nil))
+	assert.Nil(t, limiter.Release())
+
+	repositories.Mock.AssertExpectations(t)
+}
+
+func TestAcquire(t *testing.T) {
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	notificationRepo := new(tests.MockNotificationRepository)
+
+	repositories.On("Transaction").Return(tx, nil)
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	notificationRepo.On("Lock", tx).Return(nil)
+	tx.On("Commit").Return(nil)
+
+	limiter, err := Acquire(repositories, &doorbot.Account{ID: 1, NotificationsDedupWindow: 60}, 0)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, limiter)
+	assert.Nil(t, limiter.Release())
+
+	notificationRepo.Mock.AssertExpectations(t)
+	tx.Mock.AssertExpectations(t)
+}
+
+func TestDuplicate(t *testing.T) {
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	notificationRepo := new(tests.MockNotificationRepository)
+
+	now := time.Now()
+	sent := &doorbot.Notification{ID: 3, DoorID: 2, PersonID: 6, CreatedAt: now.Add(-20 * time.Second)}
+
+	repositories.On("NotificationRepository").Return(notificationRepo)
+	notificationRepo.On("All", tx, mock.AnythingOfType("*doorbot.NotificationFilter")).Return([]*doorbot.Notification{}, nil).Once()
+	notificationRepo.On("All", tx, mock.AnythingOfType("*doorbot.NotificationFilter")).Return([]*doorbot.Notification{sent}, nil).Once()
+
+	limiter := &Limiter{r: repositories, tx: tx, account: &doorbot.Account{ID: 1, NotificationsDedupWindow: 60}, now: now}
+
+	duplicate, err := limiter.Duplicate(2, 5, 6, 7)
+
+	assert.Nil(t, err)
+	assert.Equal(t, sent, duplicate)
+	assert.Equal(t, "20s", limiter.Ago(duplicate))
+
+	// The search stops at the first person already notified.
+	notificationRepo.Mock.AssertNumberOfCalls(t, "All", 2)
+
+	filter := notificationRepo.Mock.Calls[1].Arguments.Get(1).(*doorbot.NotificationFilter)
+	assert.Equal(t, uint(2), filter.DoorID)
+	assert.Equal(t, uint(6), filter.PersonID)
+	assert.True(t, filter.IncludeRerouted)
+	assert.Equal(t, uint(1), filter.Limit)
+	assert.Equal(t, now.Add(-time.Minute), *filter.Since)
+}
+
+func TestDuplicateWithoutWindow(t *testing.T) {
+	repositories := new(tests.MockRepositories)
+
+	limiter := &Limiter{r: repositories, account: &doorbot.Account{ID: 1, NotificationsDeviceRateLimit: 5}, deviceID: 9, now: time.Now()}
+
+	duplicate, err := limiter.Duplicate(2, 5)
+
+	assert.Nil(t, err)
+	assert.Nil(t, duplicate)
+
+	repositories.Mock.AssertNotCalled(t, "NotificationRepository")
+}
+
+func TestRetryAfter(t *testing.T) {
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+
+	now := time.Now()
+
+	repositories.On("EventRepository").Return(eventRepo)
+
+	// Events come newest first, the oldest one leaves the window in 45 seconds.
+	eventRepo.On("All", tx, mock.AnythingOfType("*doorbot.EventFilter")).Return([]*doorbot.Event{
+		&doorbot.Event{DeviceID: 9, EventID: doorbot.EventKnockKnock, CreatedAt: now.Add(-5 * time.Second)},
+		&doorbot.Event{DeviceID: 9, EventID: doorbot.EventKnockKnock, CreatedAt: now.Add(-15 * time.Second)},
+	}, nil)
+
+	limiter := &Limiter{r: repositories, tx: tx, account: &doorbot.Account{ID: 1, NotificationsDeviceRateLimit: 2}, deviceID: 9, now: now}
+
+	wait, err := limiter.RetryAfter()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 45*time.Second, wait)
+
+	filter := eventRepo.Mock.Calls[0].Arguments.Get(1).(*doorbot.EventFilter)
+	assert.Equal(t, []uint{doorbot.EventKnockKnock}, filter.EventIDs)
+	assert.Equal(t, uint(9), filter.DeviceID)
+	assert.Equal(t, uint(2), filter.Limit)
+	assert.Equal(t, now.Add(-RateWindow), *filter.Since)
+}
+
+func TestRetryAfterUnderLimit(t *testing.T) {
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+
+	now := time.Now()
+
+	repositories.On("EventRepository").Return(eventRepo)
+	eventRepo.On("All", tx, mock.AnythingOfType("*doorbot.EventFilter")).Return([]*doorbot.Event{
+		&doorbot.Event{DeviceID: 9, EventID: doorbot.EventKnockKnock, CreatedAt: now.Add(-5 * time.Second)},
+	}, nil)
+
+	limiter := &Limiter{r: repositories, tx: tx, account: &doorbot.Account{ID: 1, NotificationsDeviceRateLimit: 2}, deviceID: 9, now: now}
+
+	wait, err := limiter.RetryAfter()
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestRetryAfterMinimum(t *testing.T) {
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+
+	now := time.Now()
+
+	repositories.On("EventRepository").Return(eventRepo)
+
+	// The oldest knock-knock is about to leave the window, the device still waits a second.
+	eventRepo.On("All", tx, mock.AnythingOfType("*doorbot.EventFilter")).Return([]*doorbot.Event{
+		&doorbot.Event{DeviceID: 9, EventID: doorbot.EventKnockKnock, CreatedAt: now.Add(-RateWindow + 200*time.Millisecond)},
+	}, nil)
+
+	limiter := &Limiter{r: repositories, tx: tx, account: &doorbot.Account{ID: 1, NotificationsDeviceRateLimit: 1}, deviceID: 9, now: now}
+
+	wait, err := limiter.RetryAfter()
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, wait)
+}
+
+func TestRecord(t *testing.T) {
+	tx := new(tests.MockTransaction)
+	repositories := new(tests.MockRepositories)
+	eventRepo := new(tests.MockEventRepository)
+
+	repositories.On("EventRepository").Return(eventRepo)
+	eventRepo.On("Create", tx, mock.AnythingOfType("*doorbot.Event")).Return(nil)
+
+	limiter := &Limiter{r: repositories, tx: tx, account: &doorbot.Account{ID: 1, NotificationsDeviceRateLimit: 2}, deviceID: 9, now: time.Now()}
+
+	err := limiter.Record(2, 5, doorbot.Metadata{"notification_id": uint(3)})
+
+	assert.Nil(t, err)
+	eventRepo.Mock.AssertExpectations(t)
+
+	event := eventRepo.Mock.Calls[0].Arguments.Get(1).(*doorbot.Event)
+	assert.Equal(t, uint(1), event.AccountID)
+	assert.Equal(t, uint(9), event.DeviceID)
+	assert.Equal(t, uint(2), event.DoorID)
+	assert.Equal(t, uint(5), event.PersonID)
+	assert.Equal(t, uint(doorbot.EventKnockKnock), event.EventID)
+	assert.Equal(t, doorbot.EventActorDevice, event.ActorType)
+	assert.Equal(t, uint(9), event.ActorID)
+	assert.Equal(t, uint(3), event.Metadata["notification_id"])
+}
+
+func TestRecordWithoutDevice(t *testing.T) {
+	repositories := new(tests.MockRepositories)
+
+	limiter := &Limiter{r: repositories, account: &doorbot.Account{ID: 1, NotificationsDedupWindow: 60}, now: time.Now()}
+
+	assert.Nil(t, limiter.Record(2, 5, nil))
+
+	repositories.Mock.AssertNotCalled(t, "EventRepository")
+}
+
+func TestAgo(t *testing.T) {
+	assert.Equal(t, "0s", ago(300*time.Millisecond))
+	assert.Equal(t, "20s", ago(20*time.Second))
+	assert.Equal(t, "2m", ago(150*time.Second))
+}
@@ -0,0 +1,246 @@
+// Package webhooks delivers the account events to the webhook subscriptions.
+// Deliveries are queued by the database when an event is created and sent by a pool of workers.
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications"
+	"github.com/masom/doorbot/doorbot/services/notifications/webhook"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DeliveryHeader holds the delivery id, identical across redeliveries of the same attempt
+	DeliveryHeader = "X-Doorbot-Delivery"
+	// EventHeader holds the event code
+	EventHeader = "X-Doorbot-Event"
+)
+
+// Payload is the JSON document posted to the subscription URL.
+type Payload struct {
+	DeliveryID     uint           `json:"delivery_id"`
+	SubscriptionID uint           `json:"subscription_id"`
+	Event          *doorbot.Event `json:"event"`
+}
+
+// Dispatcher sends the queued deliveries using a pool of workers.
+// Workers poll the deliveries table, which lets several API instances share the load.
+type Dispatcher struct {
+	Config       doorbot.WebhookConfig
+	Repositories func() doorbot.Repositories
+	Client       *http.Client
+
+	stop chan struct{}
+}
+
+// NewDispatcher creates a new Dispatcher. The repositories function must return a new Repositories instance on every call.
+func NewDispatcher(c doorbot.WebhookConfig, r func() doorbot.Repositories) *Dispatcher {
+	return &Dispatcher{
+		Config:       c,
+		Repositories: r,
+		Client:       webhook.NewClient(10 * time.Second),
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start the dispatcher workers
+func (d *Dispatcher) Start() {
+	log.WithFields(log.Fields{
+		"workers": d.Config.Workers,
+	}).Info("Webhooks::Dispatcher->Start starting workers")
+
+	for i := 0; i < d.Config.Workers; i++ {
+		go d.work(i)
+	}
+}
+
+// Stop the dispatcher workers
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+}
+
+func (d *Dispatcher) work(worker int) {
+	ticker := time.NewTicker(d.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			// Keep working while there are deliveries to send.
+			for d.poll(worker) {
+			}
+		}
+	}
+}
+
+// poll claims a single delivery and sends it. Returns false when nothing was claimed.
+func (d *Dispatcher) poll(worker int) bool {
+	r := d.Repositories()
+
+	deliveries, err := r.WebhookDeliveryRepository().Claim(r.DB(), 1, d.Config.LockTimeout)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err,
+			"worker": worker,
+			"step":   "webhook-delivery-claim",
+		}).Error("Webhooks::Dispatcher->poll database error")
+
+		return false
+	}
+
+	for _, delivery := range deliveries {
+		d.process(delivery)
+	}
+
+	return len(deliveries) > 0
+}
+
+// process sends a claimed delivery and schedules a retry when the endpoint did not accept it.
+func (d *Dispatcher) process(delivery *doorbot.WebhookDelivery) {
+	r := d.Repositories()
+	r.SetAccountScope(delivery.AccountID)
+
+	subscription, err := r.WebhookSubscriptionRepository().Find(r.DB(), delivery.SubscriptionID)
+	if err != nil {
+		d.schedule(r, delivery, err.Error())
+		return
+	}
+
+	event, err := r.EventRepository().Find(r.DB(), delivery.EventID)
+	if err != nil {
+		d.schedule(r, delivery, err.Error())
+		return
+	}
+
+	if subscription == nil || event == nil {
+		d.bury(r, delivery, "The subscription or event no longer exists.")
+		return
+	}
+
+	if !subscription.IsEnabled {
+		d.bury(r, delivery, "The subscription is disabled.")
+		return
+	}
+
+	status, err := Deliver(d.Client, subscription, delivery, event, time.Now())
+
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+
+	if err != nil {
+		d.schedule(r, delivery, err.Error())
+		return
+	}
+
+	now := time.Now()
+	delivery.Status = doorbot.WebhookDeliveryDelivered
+	delivery.DeliveredAt = &now
+	delivery.LockedAt = nil
+	delivery.LastError = ""
+
+	log.WithFields(log.Fields{
+		"account_id":      delivery.AccountID,
+		"subscription_id": delivery.SubscriptionID,
+		"delivery_id":     delivery.ID,
+	}).Info("Webhooks::Dispatcher->process delivered")
+
+	d.save(r, delivery)
+}
+
+// Deliver posts the signed event payload to the subscription URL.
+// Returns the response status code, 0 when the endpoint could not be reached. Any status outside of 2xx is an error.
+func Deliver(c *http.Client, s *doorbot.WebhookSubscription, delivery *doorbot.WebhookDelivery, e *doorbot.Event, now time.Time) (int, error) {
+	body, err := json.Marshal(Payload{DeliveryID: delivery.ID, SubscriptionID: s.ID, Event: e})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	webhook.Sign(req, s.Secret, body, now)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(EventHeader, strconv.FormatUint(uint64(e.EventID), 10))
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	// Drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhooks: unexpected status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// schedule the next attempt or bury the delivery once all attempts are used.
+func (d *Dispatcher) schedule(r doorbot.Repositories, delivery *doorbot.WebhookDelivery, reason string) {
+	if delivery.Attempts >= uint(d.Config.MaxAttempts) {
+		d.bury(r, delivery, reason)
+		return
+	}
+
+	delivery.Status = doorbot.WebhookDeliveryPending
+	delivery.NextAttemptAt = time.Now().Add(notifications.Backoff(delivery.Attempts, d.Config.RetryDelay, d.Config.MaxRetryDelay))
+	delivery.LockedAt = nil
+	delivery.LastError = reason
+
+	log.WithFields(log.Fields{
+		"account_id":      delivery.AccountID,
+		"subscription_id": delivery.SubscriptionID,
+		"delivery_id":     delivery.ID,
+		"attempts":        delivery.Attempts,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"reason":          reason,
+	}).Warn("Webhooks::Dispatcher->schedule retry scheduled")
+
+	d.save(r, delivery)
+}
+
+// bury moves the delivery to the dead-letter state. It can still be sent again using the redeliver endpoint.
+func (d *Dispatcher) bury(r doorbot.Repositories, delivery *doorbot.WebhookDelivery, reason string) {
+	delivery.Status = doorbot.WebhookDeliveryDead
+	delivery.LockedAt = nil
+	delivery.LastError = reason
+
+	log.WithFields(log.Fields{
+		"account_id":      delivery.AccountID,
+		"subscription_id": delivery.SubscriptionID,
+		"delivery_id":     delivery.ID,
+		"attempts":        delivery.Attempts,
+		"reason":          reason,
+	}).Error("Webhooks::Dispatcher->bury delivery is dead")
+
+	d.save(r, delivery)
+}
+
+func (d *Dispatcher) save(r doorbot.Repositories, delivery *doorbot.WebhookDelivery) {
+	_, err := r.WebhookDeliveryRepository().Update(r.DB(), delivery)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err,
+			"account_id":  delivery.AccountID,
+			"delivery_id": delivery.ID,
+			"status":      delivery.Status,
+			"step":        "webhook-delivery-update",
+		}).Error("Webhooks::Dispatcher->save database error")
+	}
+}
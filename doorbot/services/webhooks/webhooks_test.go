@@ -0,0 +1,58 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"github.com/masom/doorbot/doorbot"
+	"github.com/masom/doorbot/doorbot/services/notifications/webhook"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDeliver(t *testing.T) {
+	var payload Payload
+
+	now := time.Unix(1437652800, 0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		assert.Equal(t, "8", req.Header.Get(DeliveryHeader))
+		assert.Equal(t, strconv.Itoa(doorbot.EventDoorAdded), req.Header.Get(EventHeader))
+		assert.Equal(t, webhook.Signature("secret", now.Unix(), body), req.Header.Get(webhook.SignatureHeader))
+
+		json.Unmarshal(body, &payload)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	subscription := &doorbot.WebhookSubscription{ID: 4, URL: server.URL, Secret: "secret"}
+	event := &doorbot.Event{ID: 33, EventID: doorbot.EventDoorAdded, DoorID: 2}
+
+	status, err := Deliver(http.DefaultClient, subscription, &doorbot.WebhookDelivery{ID: 8}, event, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, uint(8), payload.DeliveryID)
+	assert.Equal(t, uint(4), payload.SubscriptionID)
+	assert.Equal(t, uint(33), payload.Event.ID)
+	assert.Equal(t, uint(2), payload.Event.DoorID)
+}
+
+func TestDeliverError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	subscription := &doorbot.WebhookSubscription{ID: 4, URL: server.URL, Secret: "secret"}
+
+	status, err := Deliver(http.DefaultClient, subscription, &doorbot.WebhookDelivery{ID: 8}, &doorbot.Event{ID: 33}, time.Now())
+
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusGone, status)
+}
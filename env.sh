@@ -10,3 +10,4 @@ export DOORBOT_NOTIFICATOR_ENABLED=true
 export DOORBOT_NOTIFICATOR_EMAIL_ENABLED=true
 export DOORBOT_NOTIFICATOR_POSTMARK_TOKEN=""
 export DOORBOT_USER_ACCOUNTS_DOMAIN=.doorbot.dev
+export DOORBOT_SECRET=dev-secret
@@ -17,11 +17,15 @@ func Configure() *doorbot.DoorbotConfig {
 	c := &doorbot.DoorbotConfig{}
 	c.Database = doorbot.DatabaseConfig{}
 	c.Server = doorbot.ServerConfig{}
+	c.Notificator = doorbot.NewNotificatorConfig()
+	c.Digest = doorbot.NewDigestConfig()
+	c.Webhooks = doorbot.NewWebhookConfig()
+	c.SMTP = doorbot.NewSMTPConfig()
 
 	if os.Getenv("HEROKU") != "" {
 		log.Info("Using HEROKU config")
 
-		hc := &doorbot.HerokuConfig{}
+		hc := doorbot.NewHerokuConfig()
 
 		err := envconfig.Process("", hc)
 		if err != nil {
@@ -34,13 +38,46 @@ func Configure() *doorbot.DoorbotConfig {
 		c.Server.Port = hc.Port
 
 		c.UserAccountsDomain = hc.UserAccountsDomain
+		c.Secret = hc.Secret
 
 		c.ParseDomains(hc.Domains)
+		c.Notificator.Override(hc.NotificatorWorkers, hc.NotificatorMaxAttempts)
+
+		c.SMTP.Host = hc.NotificatorSmtpHost
+		c.SMTP.StartTLS = hc.NotificatorSmtpStarttls
+		c.SMTP.Username = hc.NotificatorSmtpUsername
+		c.SMTP.Password = hc.NotificatorSmtpPassword
+
+		if hc.NotificatorSmtpPort > 0 {
+			c.SMTP.Port = hc.NotificatorSmtpPort
+		}
+
+		if len(hc.NotificatorEmailFrom) > 0 {
+			c.SMTP.From = hc.NotificatorEmailFrom
+		}
+
+		c.Providers = doorbot.ProvidersConfig{
+			HipChatToken:      hc.NotificatorHipchatToken,
+			MailgunAPIKey:     hc.NotificatorMailgunApiKey,
+			MailgunDomain:     hc.NotificatorMailgunDomain,
+			NexmoAPIKey:       hc.NotificatorNexmoApiKey,
+			NexmoAPISecret:    hc.NotificatorNexmoApiSecret,
+			NexmoFrom:         hc.NotificatorNexmoFrom,
+			PostmarkToken:     hc.NotificatorPostmarkToken,
+			SlackToken:        hc.NotificatorSlackToken,
+			TwilioAccountSID:  hc.NotificatorTwilioId,
+			TwilioToken:       hc.NotificatorTwilioToken,
+			TwilioPhoneNumber: hc.NotificatorTwilioPhoneNumber,
+			APNsToken:         hc.NotificatorApnsToken,
+			APNsTopic:         hc.NotificatorApnsTopic,
+			FCMKey:            hc.NotificatorFcmKey,
+			EmailFrom:         hc.NotificatorEmailFrom,
+		}
 
 	} else {
 		log.Info("Using ENV config.")
 
-		ec := &doorbot.EnvConfig{}
+		ec := doorbot.NewEnvConfig()
 
 		err := envconfig.Process("doorbot", ec)
 		if err != nil {
@@ -51,10 +88,47 @@ func Configure() *doorbot.DoorbotConfig {
 		c.ParseDomains(ec.Domains)
 
 		c.UserAccountsDomain = ec.UserAccountsDomain
+		c.Secret = ec.Secret
 
 		c.Database.URL = ec.DatabaseUrl
 		c.Database.Trace = ec.DatabaseTrace
 		c.Server.Port = ec.ServerPort
+		c.Notificator.Override(ec.NotificatorWorkers, ec.NotificatorMaxAttempts)
+
+		c.SMTP.Host = ec.NotificatorSmtpHost
+		c.SMTP.StartTLS = ec.NotificatorSmtpStarttls
+		c.SMTP.Username = ec.NotificatorSmtpUsername
+		c.SMTP.Password = ec.NotificatorSmtpPassword
+
+		if ec.NotificatorSmtpPort > 0 {
+			c.SMTP.Port = ec.NotificatorSmtpPort
+		}
+
+		if len(ec.NotificatorEmailFrom) > 0 {
+			c.SMTP.From = ec.NotificatorEmailFrom
+		}
+
+		c.Providers = doorbot.ProvidersConfig{
+			HipChatToken:      ec.NotificatorHipchatToken,
+			MailgunAPIKey:     ec.NotificatorMailgunApiKey,
+			MailgunDomain:     ec.NotificatorMailgunDomain,
+			NexmoAPIKey:       ec.NotificatorNexmoApiKey,
+			NexmoAPISecret:    ec.NotificatorNexmoApiSecret,
+			NexmoFrom:         ec.NotificatorNexmoFrom,
+			PostmarkToken:     ec.NotificatorPostmarkToken,
+			SlackToken:        ec.NotificatorSlackToken,
+			TwilioAccountSID:  ec.NotificatorTwilioId,
+			TwilioToken:       ec.NotificatorTwilioToken,
+			TwilioPhoneNumber: ec.NotificatorTwilioPhoneNumber,
+			APNsToken:         ec.NotificatorApnsToken,
+			APNsTopic:         ec.NotificatorApnsTopic,
+			FCMKey:            ec.NotificatorFcmKey,
+			EmailFrom:         ec.NotificatorEmailFrom,
+		}
+	}
+
+	if len(c.Secret) == 0 {
+		log.Warn("No secret configured, acknowledgement links are disabled.")
 	}
 
 	log.Info(fmt.Sprintf("Database URL: `%s`", c.Database.URL))
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/martini-contrib/render"
 	"github.com/masom/doorbot/doorbot"
+	"time"
 )
 
 type MockNotificator struct {
@@ -23,6 +24,29 @@ func (m *MockNotificator) Notify(a *doorbot.Account, d *doorbot.Door, p *doorbot
 	return args.Error(0)
 }
 
+func (m *MockNotificator) KnockKnock(d *doorbot.Door, p *doorbot.Person, v *doorbot.Visit) (*doorbot.Notification, error) {
+	args := m.Mock.Called(d, p, v)
+	return args.Get(0).(*doorbot.Notification), args.Error(1)
+}
+
+func (m *MockNotificator) Reroute(d *doorbot.Door, host *doorbot.Person, p *doorbot.Person, v *doorbot.Visit) (*doorbot.Notification, error) {
+	args := m.Mock.Called(d, host, p, v)
+	return args.Get(0).(*doorbot.Notification), args.Error(1)
+}
+
+func (m *MockNotificator) Invite(i *doorbot.Invitation, v *doorbot.Visitor, p *doorbot.Person) error {
+	return m.Mock.Called(i, v, p).Error(0)
+}
+
+func (m *MockNotificator) Digest(p *doorbot.Person, subject string, body string) error {
+	return m.Mock.Called(p, subject, body).Error(0)
+}
+
+func (m *MockNotificator) Test(p *doorbot.Person, kind string, provider string) ([]*doorbot.NotificationAttempt, error) {
+	args := m.Mock.Called(p, kind, provider)
+	return args.Get(0).([]*doorbot.NotificationAttempt), args.Error(1)
+}
+
 type MockBridges struct {
 	mock.Mock
 }
@@ -235,21 +259,101 @@ func (m *MockRepositories) SetAccountScope(id uint) {
 	m.Mock.Called(id)
 }
 
+func (m *MockRepositories) DelegateRepository() doorbot.DelegateRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.DelegateRepository)
+}
+
 func (m *MockRepositories) DoorRepository() doorbot.DoorRepository {
 	args := m.Mock.Called()
 	return args.Get(0).(doorbot.DoorRepository)
 }
 
+func (m *MockRepositories) EscalationStepRepository() doorbot.EscalationStepRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.EscalationStepRepository)
+}
+
 func (m *MockRepositories) EventRepository() doorbot.EventRepository {
 	args := m.Mock.Called()
 	return args.Get(0).(doorbot.EventRepository)
 }
 
+func (m *MockRepositories) InvitationRepository() doorbot.InvitationRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.InvitationRepository)
+}
+
+func (m *MockRepositories) NotificationRepository() doorbot.NotificationRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.NotificationRepository)
+}
+
+func (m *MockRepositories) NotificationAttemptRepository() doorbot.NotificationAttemptRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.NotificationAttemptRepository)
+}
+
 func (m *MockRepositories) PersonRepository() doorbot.PersonRepository {
 	args := m.Mock.Called()
 	return args.Get(0).(doorbot.PersonRepository)
 }
 
+func (m *MockRepositories) NotificationTemplateRepository() doorbot.NotificationTemplateRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.NotificationTemplateRepository)
+}
+
+func (m *MockRepositories) OutOfOfficeRepository() doorbot.OutOfOfficeRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.OutOfOfficeRepository)
+}
+
+func (m *MockRepositories) WorkingHoursRepository() doorbot.WorkingHoursRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.WorkingHoursRepository)
+}
+
+func (m *MockRepositories) PushDeviceRepository() doorbot.PushDeviceRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.PushDeviceRepository)
+}
+
+func (m *MockRepositories) ReportRepository() doorbot.ReportRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.ReportRepository)
+}
+
+func (m *MockRepositories) TeamRepository() doorbot.TeamRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.TeamRepository)
+}
+
+func (m *MockRepositories) TeamMemberRepository() doorbot.TeamMemberRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.TeamMemberRepository)
+}
+
+func (m *MockRepositories) VisitRepository() doorbot.VisitRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.VisitRepository)
+}
+
+func (m *MockRepositories) VisitorRepository() doorbot.VisitorRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.VisitorRepository)
+}
+
+func (m *MockRepositories) WebhookDeliveryRepository() doorbot.WebhookDeliveryRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.WebhookDeliveryRepository)
+}
+
+func (m *MockRepositories) WebhookSubscriptionRepository() doorbot.WebhookSubscriptionRepository {
+	args := m.Mock.Called()
+	return args.Get(0).(doorbot.WebhookSubscriptionRepository)
+}
+
 
 
 type MockAccountRepository struct {
@@ -482,6 +586,11 @@ func (m *MockDeviceRepository) Enable(e doorbot.Executor, d *doorbot.Device, ena
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockDeviceRepository) Seen(e doorbot.Executor, d *doorbot.Device) (bool, error) {
+	args := m.Mock.Called(e, d)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockDeviceRepository) SetAccountScope(accountID uint) {
 	m.Mock.Called(accountID)
 }
@@ -519,12 +628,58 @@ func (m *MockDoorRepository) SetAccountScope(accountID uint) {
 	m.Mock.Called(accountID)
 }
 
+type MockDelegateRepository struct {
+	mock.Mock
+}
+
+func (m *MockDelegateRepository) Create(e doorbot.Executor, d *doorbot.Delegate) error {
+	args := m.Mock.Called(e, d)
+	return args.Error(0)
+}
+
+func (m *MockDelegateRepository) DeleteByPersonID(e doorbot.Executor, id uint) error {
+	args := m.Mock.Called(e, id)
+	return args.Error(0)
+}
+
+func (m *MockDelegateRepository) FindByPersonID(e doorbot.Executor, id uint) ([]*doorbot.Delegate, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).([]*doorbot.Delegate), args.Error(1)
+}
+
+func (m *MockDelegateRepository) SetAccountScope(id uint) {
+	m.Mock.Called(id)
+}
+
+type MockEscalationStepRepository struct {
+	mock.Mock
+}
+
+func (m *MockEscalationStepRepository) Create(e doorbot.Executor, s *doorbot.EscalationStep) error {
+	args := m.Mock.Called(e, s)
+	return args.Error(0)
+}
+
+func (m *MockEscalationStepRepository) DeleteByPersonID(e doorbot.Executor, id uint) error {
+	args := m.Mock.Called(e, id)
+	return args.Error(0)
+}
+
+func (m *MockEscalationStepRepository) FindByPersonID(e doorbot.Executor, id uint) ([]*doorbot.EscalationStep, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).([]*doorbot.EscalationStep), args.Error(1)
+}
+
+func (m *MockEscalationStepRepository) SetAccountScope(id uint) {
+	m.Mock.Called(id)
+}
+
 type MockEventRepository struct {
 	mock.Mock
 }
 
-func (m *MockEventRepository) All(e doorbot.Executor, p *doorbot.Pagination) ([]*doorbot.Event, error) {
-	args := m.Mock.Called(e, p)
+func (m *MockEventRepository) All(e doorbot.Executor, f *doorbot.EventFilter) ([]*doorbot.Event, error) {
+	args := m.Mock.Called(e, f)
 	return args.Get(0).([]*doorbot.Event), args.Error(1)
 }
 
@@ -542,10 +697,120 @@ func (m *MockEventRepository) SetAccountScope(id uint) {
 	m.Mock.Called(id)
 }
 
+type MockInvitationRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvitationRepository) All(e doorbot.Executor, f *doorbot.InvitationFilter) ([]*doorbot.Invitation, error) {
+	args := m.Mock.Called(e, f)
+	return args.Get(0).([]*doorbot.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) Create(e doorbot.Executor, i *doorbot.Invitation) error {
+	return m.Mock.Called(e, i).Error(0)
+}
+
+func (m *MockInvitationRepository) Delete(e doorbot.Executor, i *doorbot.Invitation) (bool, error) {
+	args := m.Mock.Called(e, i)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockInvitationRepository) Find(e doorbot.Executor, id uint) (*doorbot.Invitation, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) FindByCode(e doorbot.Executor, code string) (*doorbot.Invitation, error) {
+	args := m.Mock.Called(e, code)
+	return args.Get(0).(*doorbot.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) Update(e doorbot.Executor, i *doorbot.Invitation) (bool, error) {
+	args := m.Mock.Called(e, i)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockInvitationRepository) Use(e doorbot.Executor, i *doorbot.Invitation, visitID uint) (bool, error) {
+	args := m.Mock.Called(e, i, visitID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockInvitationRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+type MockNotificationRepository struct {
+	mock.Mock
+}
+
+func (m *MockNotificationRepository) All(e doorbot.Executor, f *doorbot.NotificationFilter) ([]*doorbot.Notification, error) {
+	args := m.Mock.Called(e, f)
+	return args.Get(0).([]*doorbot.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) Create(e doorbot.Executor, n *doorbot.Notification) error {
+	return m.Mock.Called(e, n).Error(0)
+}
+
+func (m *MockNotificationRepository) Find(e doorbot.Executor, id uint) (*doorbot.Notification, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) Update(e doorbot.Executor, n *doorbot.Notification) (bool, error) {
+	args := m.Mock.Called(e, n)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) UpdateDelivery(e doorbot.Executor, n *doorbot.Notification) (bool, error) {
+	args := m.Mock.Called(e, n)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) Claim(e doorbot.Executor, limit uint, timeout time.Duration) ([]*doorbot.Notification, error) {
+	args := m.Mock.Called(e, limit, timeout)
+	return args.Get(0).([]*doorbot.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) Acknowledge(e doorbot.Executor, n *doorbot.Notification) (bool, error) {
+	args := m.Mock.Called(e, n)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) Lock(t doorbot.Transaction) error {
+	return m.Mock.Called(t).Error(0)
+}
+
+func (m *MockNotificationRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+type MockNotificationAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockNotificationAttemptRepository) Create(e doorbot.Executor, a *doorbot.NotificationAttempt) error {
+	return m.Mock.Called(e, a).Error(0)
+}
+
+func (m *MockNotificationAttemptRepository) FindByNotificationIDs(e doorbot.Executor, ids []uint) ([]*doorbot.NotificationAttempt, error) {
+	args := m.Mock.Called(e, ids)
+	return args.Get(0).([]*doorbot.NotificationAttempt), args.Error(1)
+}
+
+func (m *MockNotificationAttemptRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
 type MockPersonRepository struct {
 	mock.Mock
 }
 
+func (m *MockPersonRepository) ClaimDigest(e doorbot.Executor, p *doorbot.Person, due time.Time) (bool, error) {
+	args := m.Mock.Called(e, p, due)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockPersonRepository) All(e doorbot.Executor) ([]*doorbot.Person, error) {
 	args := m.Mock.Called(e)
 
@@ -581,6 +846,289 @@ func (m *MockPersonRepository) SetAccountScope(accountID uint) {
 	m.Mock.Called(accountID)
 }
 
+type MockReportRepository struct {
+	mock.Mock
+}
+
+func (m *MockReportRepository) ResponseTimes(e doorbot.Executor, f *doorbot.ReportFilter) ([]*doorbot.ResponseTimeReport, error) {
+	args := m.Mock.Called(e, f)
+	return args.Get(0).([]*doorbot.ResponseTimeReport), args.Error(1)
+}
+
+func (m *MockReportRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+func (m *MockReportRepository) Visits(e doorbot.Executor, f *doorbot.ReportFilter) ([]*doorbot.VisitReport, error) {
+	args := m.Mock.Called(e, f)
+	return args.Get(0).([]*doorbot.VisitReport), args.Error(1)
+}
+
+type MockTeamRepository struct {
+	mock.Mock
+}
+
+func (m *MockTeamRepository) All(e doorbot.Executor) ([]*doorbot.Team, error) {
+	args := m.Mock.Called(e)
+	return args.Get(0).([]*doorbot.Team), args.Error(1)
+}
+
+func (m *MockTeamRepository) Create(e doorbot.Executor, t *doorbot.Team) error {
+	return m.Mock.Called(e, t).Error(0)
+}
+
+func (m *MockTeamRepository) Delete(e doorbot.Executor, t *doorbot.Team) (bool, error) {
+	args := m.Mock.Called(e, t)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTeamRepository) Find(e doorbot.Executor, id uint) (*doorbot.Team, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.Team), args.Error(1)
+}
+
+func (m *MockTeamRepository) FindByBridgeID(e doorbot.Executor, id uint) ([]*doorbot.Team, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).([]*doorbot.Team), args.Error(1)
+}
+
+func (m *MockTeamRepository) SetAccountScope(id uint) {
+	m.Mock.Called(id)
+}
+
+func (m *MockTeamRepository) Update(e doorbot.Executor, t *doorbot.Team) (bool, error) {
+	args := m.Mock.Called(e, t)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTeamRepository) UpdateLastKnocked(e doorbot.Executor, t *doorbot.Team) (bool, error) {
+	args := m.Mock.Called(e, t)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockTeamMemberRepository struct {
+	mock.Mock
+}
+
+func (m *MockTeamMemberRepository) All(e doorbot.Executor) ([]*doorbot.TeamMember, error) {
+	args := m.Mock.Called(e)
+	return args.Get(0).([]*doorbot.TeamMember), args.Error(1)
+}
+
+func (m *MockTeamMemberRepository) Create(e doorbot.Executor, t *doorbot.TeamMember) error {
+	return m.Mock.Called(e, t).Error(0)
+}
+
+func (m *MockTeamMemberRepository) DeleteByTeamID(e doorbot.Executor, id uint) error {
+	return m.Mock.Called(e, id).Error(0)
+}
+
+func (m *MockTeamMemberRepository) FindByTeamID(e doorbot.Executor, id uint) ([]*doorbot.TeamMember, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).([]*doorbot.TeamMember), args.Error(1)
+}
+
+func (m *MockTeamMemberRepository) SetAccountScope(id uint) {
+	m.Mock.Called(id)
+}
+
+type MockVisitRepository struct {
+	mock.Mock
+}
+
+func (m *MockVisitRepository) All(e doorbot.Executor, f *doorbot.VisitFilter) ([]*doorbot.Visit, error) {
+	args := m.Mock.Called(e, f)
+	return args.Get(0).([]*doorbot.Visit), args.Error(1)
+}
+
+func (m *MockVisitRepository) Create(e doorbot.Executor, v *doorbot.Visit) error {
+	return m.Mock.Called(e, v).Error(0)
+}
+
+func (m *MockVisitRepository) Find(e doorbot.Executor, id uint) (*doorbot.Visit, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.Visit), args.Error(1)
+}
+
+func (m *MockVisitRepository) Update(e doorbot.Executor, v *doorbot.Visit) (bool, error) {
+	args := m.Mock.Called(e, v)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVisitRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+type MockVisitorRepository struct {
+	mock.Mock
+}
+
+func (m *MockVisitorRepository) Create(e doorbot.Executor, v *doorbot.Visitor) error {
+	return m.Mock.Called(e, v).Error(0)
+}
+
+func (m *MockVisitorRepository) Find(e doorbot.Executor, id uint) (*doorbot.Visitor, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.Visitor), args.Error(1)
+}
+
+func (m *MockVisitorRepository) FindByEmail(e doorbot.Executor, email string) (*doorbot.Visitor, error) {
+	args := m.Mock.Called(e, email)
+	return args.Get(0).(*doorbot.Visitor), args.Error(1)
+}
+
+func (m *MockVisitorRepository) FindByIDs(e doorbot.Executor, ids []uint) ([]*doorbot.Visitor, error) {
+	args := m.Mock.Called(e, ids)
+	return args.Get(0).([]*doorbot.Visitor), args.Error(1)
+}
+
+func (m *MockVisitorRepository) Update(e doorbot.Executor, v *doorbot.Visitor) (bool, error) {
+	args := m.Mock.Called(e, v)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVisitorRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+type MockPushDeviceRepository struct {
+	mock.Mock
+}
+
+func (m *MockPushDeviceRepository) Create(e doorbot.Executor, d *doorbot.PushDevice) error {
+	return m.Mock.Called(e, d).Error(0)
+}
+
+func (m *MockPushDeviceRepository) Delete(e doorbot.Executor, d *doorbot.PushDevice) (bool, error) {
+	args := m.Mock.Called(e, d)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPushDeviceRepository) Find(e doorbot.Executor, id uint) (*doorbot.PushDevice, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.PushDevice), args.Error(1)
+}
+
+func (m *MockPushDeviceRepository) FindByPersonID(e doorbot.Executor, id uint) ([]*doorbot.PushDevice, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).([]*doorbot.PushDevice), args.Error(1)
+}
+
+func (m *MockPushDeviceRepository) FindByToken(e doorbot.Executor, token string) (*doorbot.PushDevice, error) {
+	args := m.Mock.Called(e, token)
+	return args.Get(0).(*doorbot.PushDevice), args.Error(1)
+}
+
+func (m *MockPushDeviceRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+func (m *MockPushDeviceRepository) Update(e doorbot.Executor, d *doorbot.PushDevice) (bool, error) {
+	args := m.Mock.Called(e, d)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockNotificationTemplateRepository struct {
+	mock.Mock
+}
+
+func (m *MockNotificationTemplateRepository) All(e doorbot.Executor) ([]*doorbot.NotificationTemplate, error) {
+	args := m.Mock.Called(e)
+	return args.Get(0).([]*doorbot.NotificationTemplate), args.Error(1)
+}
+
+func (m *MockNotificationTemplateRepository) Create(e doorbot.Executor, t *doorbot.NotificationTemplate) error {
+	return m.Mock.Called(e, t).Error(0)
+}
+
+func (m *MockNotificationTemplateRepository) Delete(e doorbot.Executor, t *doorbot.NotificationTemplate) (bool, error) {
+	args := m.Mock.Called(e, t)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationTemplateRepository) Find(e doorbot.Executor, id uint) (*doorbot.NotificationTemplate, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.NotificationTemplate), args.Error(1)
+}
+
+func (m *MockNotificationTemplateRepository) FindByChannel(e doorbot.Executor, channel string, doorID uint) (*doorbot.NotificationTemplate, error) {
+	args := m.Mock.Called(e, channel, doorID)
+	return args.Get(0).(*doorbot.NotificationTemplate), args.Error(1)
+}
+
+func (m *MockNotificationTemplateRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+func (m *MockNotificationTemplateRepository) Update(e doorbot.Executor, t *doorbot.NotificationTemplate) (bool, error) {
+	args := m.Mock.Called(e, t)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockWebhookDeliveryRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookDeliveryRepository) Claim(e doorbot.Executor, limit uint, timeout time.Duration) ([]*doorbot.WebhookDelivery, error) {
+	args := m.Mock.Called(e, limit, timeout)
+	return args.Get(0).([]*doorbot.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookDeliveryRepository) Create(e doorbot.Executor, d *doorbot.WebhookDelivery) error {
+	return m.Mock.Called(e, d).Error(0)
+}
+
+func (m *MockWebhookDeliveryRepository) Find(e doorbot.Executor, id uint) (*doorbot.WebhookDelivery, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookDeliveryRepository) FindBySubscriptionID(e doorbot.Executor, id uint, limit uint) ([]*doorbot.WebhookDelivery, error) {
+	args := m.Mock.Called(e, id, limit)
+	return args.Get(0).([]*doorbot.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookDeliveryRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+func (m *MockWebhookDeliveryRepository) Update(e doorbot.Executor, d *doorbot.WebhookDelivery) (bool, error) {
+	args := m.Mock.Called(e, d)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockWebhookSubscriptionRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookSubscriptionRepository) All(e doorbot.Executor) ([]*doorbot.WebhookSubscription, error) {
+	args := m.Mock.Called(e)
+	return args.Get(0).([]*doorbot.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockWebhookSubscriptionRepository) Create(e doorbot.Executor, s *doorbot.WebhookSubscription) error {
+	return m.Mock.Called(e, s).Error(0)
+}
+
+func (m *MockWebhookSubscriptionRepository) Delete(e doorbot.Executor, s *doorbot.WebhookSubscription) (bool, error) {
+	args := m.Mock.Called(e, s)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWebhookSubscriptionRepository) Find(e doorbot.Executor, id uint) (*doorbot.WebhookSubscription, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockWebhookSubscriptionRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+func (m *MockWebhookSubscriptionRepository) Update(e doorbot.Executor, s *doorbot.WebhookSubscription) (bool, error) {
+	args := m.Mock.Called(e, s)
+	return args.Bool(0), args.Error(1)
+}
+
 type MockRender struct {
 	mock.Mock
 }
@@ -625,3 +1173,66 @@ func (m *MockRender) Header() http.Header {
 
 	return r.Header()
 }
+
+type MockOutOfOfficeRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutOfOfficeRepository) Create(e doorbot.Executor, o *doorbot.OutOfOffice) error {
+	return m.Mock.Called(e, o).Error(0)
+}
+
+func (m *MockOutOfOfficeRepository) Delete(e doorbot.Executor, o *doorbot.OutOfOffice) (bool, error) {
+	args := m.Mock.Called(e, o)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOutOfOfficeRepository) Find(e doorbot.Executor, id uint) (*doorbot.OutOfOffice, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).(*doorbot.OutOfOffice), args.Error(1)
+}
+
+func (m *MockOutOfOfficeRepository) FindByPersonID(e doorbot.Executor, id uint) ([]*doorbot.OutOfOffice, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).([]*doorbot.OutOfOffice), args.Error(1)
+}
+
+func (m *MockOutOfOfficeRepository) FindCurrent(e doorbot.Executor, at time.Time) ([]*doorbot.OutOfOffice, error) {
+	args := m.Mock.Called(e, at)
+	return args.Get(0).([]*doorbot.OutOfOffice), args.Error(1)
+}
+
+func (m *MockOutOfOfficeRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}
+
+func (m *MockOutOfOfficeRepository) Update(e doorbot.Executor, o *doorbot.OutOfOffice) (bool, error) {
+	args := m.Mock.Called(e, o)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockWorkingHoursRepository struct {
+	mock.Mock
+}
+
+func (m *MockWorkingHoursRepository) All(e doorbot.Executor) ([]*doorbot.WorkingHours, error) {
+	args := m.Mock.Called(e)
+	return args.Get(0).([]*doorbot.WorkingHours), args.Error(1)
+}
+
+func (m *MockWorkingHoursRepository) Create(e doorbot.Executor, h *doorbot.WorkingHours) error {
+	return m.Mock.Called(e, h).Error(0)
+}
+
+func (m *MockWorkingHoursRepository) DeleteByPersonID(e doorbot.Executor, id uint) error {
+	return m.Mock.Called(e, id).Error(0)
+}
+
+func (m *MockWorkingHoursRepository) FindByPersonID(e doorbot.Executor, id uint) ([]*doorbot.WorkingHours, error) {
+	args := m.Mock.Called(e, id)
+	return args.Get(0).([]*doorbot.WorkingHours), args.Error(1)
+}
+
+func (m *MockWorkingHoursRepository) SetAccountScope(accountID uint) {
+	m.Mock.Called(accountID)
+}